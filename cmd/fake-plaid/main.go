@@ -0,0 +1,45 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"log"
+	"net/http"
+	"os"
+
+	"github.com/Seymour-creates/budget-server/internal/plaidfake"
+)
+
+// fake-plaid serves an in-memory Plaid API for running the server offline. Point the server at it with
+// PLAID_BASE_URL.
+//
+//	fake-plaid [-addr :8081] [-fixtures fixtures.json]
+//
+// The fixtures file holds a JSON array of plaidfake.Fixture; without one a single "Fake Bank" (ins_fake) is
+// served.
+func main() {
+	addr := flag.String("addr", ":8081", "address to listen on")
+	fixturesPath := flag.String("fixtures", "", "JSON file of institutions, accounts and transactions to serve")
+	flag.Parse()
+
+	fixtures := []plaidfake.Fixture{plaidfake.DefaultFixture()}
+	if *fixturesPath != "" {
+		raw, err := os.ReadFile(*fixturesPath)
+		if err != nil {
+			log.Fatalf("error reading fixtures: %v", err)
+		}
+		fixtures = nil
+		if err := json.Unmarshal(raw, &fixtures); err != nil {
+			log.Fatalf("error parsing fixtures: %v", err)
+		}
+		if len(fixtures) == 0 {
+			log.Fatalf("%v has no fixtures", *fixturesPath)
+		}
+	}
+
+	server := plaidfake.New(fixtures...)
+	log.Printf("fake Plaid listening on %v with %d institution(s), e.g. %v", *addr, len(fixtures), fixtures[0].InstitutionID)
+	log.Printf(`link an item with: curl -X POST localhost%v/sandbox/public_token/create -d '{"institution_id":"%v","initial_products":["transactions"]}'`, *addr, fixtures[0].InstitutionID)
+	log.Printf("then POST public_token=<token> as a form to the server's /create_plaid_item")
+	log.Fatal(http.ListenAndServe(*addr, server))
+}
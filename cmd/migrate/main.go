@@ -0,0 +1,56 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"os"
+
+	"github.com/Seymour-creates/budget-server/internal/db"
+)
+
+// migrate applies or reverts schema migrations against the database in DSN.
+//
+//	migrate up          apply all pending migrations
+//	migrate down [-n N] revert the last N migrations (default 1)
+//	migrate version     print the current schema version
+func main() {
+	flag.Usage = func() {
+		fmt.Fprintf(flag.CommandLine.Output(), "usage: migrate up | down [-n steps] | version\n")
+	}
+	flag.Parse()
+	if flag.NArg() < 1 {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	conn, dialect, err := db.Open(os.Getenv("DSN"))
+	if err != nil {
+		log.Fatalf("error connecting to db: %v", err)
+	}
+	migrator, err := db.NewMigrator(conn, dialect)
+	if err != nil {
+		log.Fatalf("error loading migrations: %v", err)
+	}
+
+	switch flag.Arg(0) {
+	case "up":
+		err = migrator.Up()
+	case "down":
+		downFlags := flag.NewFlagSet("down", flag.ExitOnError)
+		steps := downFlags.Int("n", 1, "number of migrations to revert")
+		_ = downFlags.Parse(flag.Args()[1:])
+		err = migrator.Down(*steps)
+	case "version":
+		var version int
+		if version, err = migrator.Version(); err == nil {
+			fmt.Println(version)
+		}
+	default:
+		flag.Usage()
+		os.Exit(2)
+	}
+	if err != nil {
+		log.Fatal(err)
+	}
+}
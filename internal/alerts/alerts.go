@@ -0,0 +1,267 @@
+// Package alerts raises alerts when spending or balances cross configured thresholds and sends them through
+// pluggable notification channels. Each alert is raised once per period, however often thresholds are checked.
+package alerts
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/Seymour-creates/budget-server/internal/db"
+	"github.com/Seymour-creates/budget-server/internal/types"
+	"github.com/Seymour-creates/budget-server/internal/utils"
+)
+
+const (
+	// deliveryRetryInterval is how often the delivery worker looks for failed sends due to be retried, and how
+	// long it waits before the first retry. Each further retry waits twice as long as the one before.
+	deliveryRetryInterval = time.Minute
+	// maxDeliveryAttempts is how many times an alert is sent through a channel before it is given up on.
+	maxDeliveryAttempts = 8
+)
+
+// Evaluator checks the configured thresholds against the stored expenses, forecasts and balances.
+type Evaluator struct {
+	db       db.Repository
+	config   Config
+	channels []Channel
+
+	// mu serializes evaluations so one triggered by a sync and one by a manual import never race to raise the
+	// same alert.
+	mu sync.Mutex
+	// deliverMu serializes deliveries so the same alert is never sent twice at once. It is separate from mu so
+	// a slow channel never holds up an evaluation.
+	deliverMu sync.Mutex
+	// wake tells the delivery worker alerts were just recorded, so it sends them without waiting for a retry.
+	wake chan struct{}
+	now  func() time.Time
+}
+
+func NewEvaluator(repo db.Repository, config Config, channels ...Channel) *Evaluator {
+	return &Evaluator{db: repo, config: config, channels: channels, wake: make(chan struct{}, 1), now: time.Now}
+}
+
+// Evaluate checks every threshold for the month containing now and records the alerts not raised before, each
+// with a pending delivery through every channel, returning them. RunDeliveryWorker sends them, so a slow or
+// unreachable channel never holds up the import that raised an alert. Only database failures are returned as
+// errors.
+func (e *Evaluator) Evaluate(now time.Time) ([]types.Alert, *types.HTTPError) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	period, err := utils.PeriodContaining(now, types.IntervalMonth)
+	if err != nil {
+		return nil, utils.NewHTTPError(http.StatusInternalServerError, err.Error())
+	}
+	checks := []func(types.Period) ([]types.Alert, *types.HTTPError){e.budgetAlerts, e.largeTransactionAlerts, e.lowBalanceAlerts}
+	var candidates []types.Alert
+	for _, check := range checks {
+		alerts, httpErr := check(period)
+		if httpErr != nil {
+			return nil, httpErr
+		}
+		candidates = append(candidates, alerts...)
+	}
+
+	names := make([]string, len(e.channels))
+	for i, channel := range e.channels {
+		names[i] = channel.Name()
+	}
+	raised := []types.Alert{}
+	defer func() {
+		if len(raised) > 0 {
+			e.signal()
+		}
+	}()
+	for _, candidate := range candidates {
+		alert, httpErr := e.db.RecordAlert(candidate, names...)
+		if httpErr != nil {
+			return raised, httpErr
+		}
+		if alert == nil {
+			continue
+		}
+		log.Printf("alert %v: %v", alert.Key, alert.Message)
+		raised = append(raised, *alert)
+	}
+	return raised, nil
+}
+
+// signal wakes the delivery worker, unless it has already been woken and hasn't looked yet.
+func (e *Evaluator) signal() {
+	select {
+	case e.wake <- struct{}{}:
+	default:
+	}
+}
+
+// RunDeliveryWorker sends recorded alerts through their channels until ctx is cancelled: straight after an
+// evaluation raises some, and every deliveryRetryInterval for sends that failed or were interrupted by a
+// restart.
+func (e *Evaluator) RunDeliveryWorker(ctx context.Context) {
+	ticker := time.NewTicker(deliveryRetryInterval)
+	defer ticker.Stop()
+	for {
+		if err := e.Deliver(ctx); err != nil {
+			log.Printf("error delivering alerts: %v", err.Message)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-e.wake:
+		case <-ticker.C:
+		}
+	}
+}
+
+// Deliver sends every pending delivery that is due and records how each went. A failed send is retried after
+// a delay that doubles with each attempt, and given up on after maxDeliveryAttempts; it doesn't stop the other
+// sends. Deliveries through a channel that has since been turned off are left pending. Only database failures
+// are returned as errors.
+func (e *Evaluator) Deliver(ctx context.Context) *types.HTTPError {
+	e.deliverMu.Lock()
+	defer e.deliverMu.Unlock()
+
+	deliveries, httpErr := e.db.PendingDeliveries(e.now(), maxDeliveryAttempts)
+	if httpErr != nil {
+		return httpErr
+	}
+	channels := make(map[string]Channel, len(e.channels))
+	for _, channel := range e.channels {
+		channels[channel.Name()] = channel
+	}
+	for _, delivery := range deliveries {
+		channel, ok := channels[delivery.Channel]
+		if !ok {
+			continue
+		}
+		if err := channel.Send(ctx, delivery.Alert); err != nil {
+			attempts := delivery.Attempts + 1
+			if attempts >= maxDeliveryAttempts {
+				log.Printf("giving up sending alert %v through %v after %d attempts: %v", delivery.Key, delivery.Channel, attempts, err)
+			} else {
+				log.Printf("error sending alert %v through %v, will retry: %v", delivery.Key, delivery.Channel, err)
+			}
+			retryAt := e.now().Add(deliveryRetryInterval << (attempts - 1))
+			if httpErr := e.db.MarkDeliveryFailed(delivery.ID, delivery.Channel, err.Error(), retryAt); httpErr != nil {
+				return httpErr
+			}
+			continue
+		}
+		if httpErr := e.db.MarkDelivered(delivery.ID, delivery.Channel); httpErr != nil {
+			return httpErr
+		}
+	}
+	return nil
+}
+
+// budgetAlerts raises an alert for each category whose spending in period has reached a threshold percentage of
+// its forecast, for the highest threshold reached.
+func (e *Evaluator) budgetAlerts(period types.Period) ([]types.Alert, *types.HTTPError) {
+	if len(e.config.BudgetThresholds) == 0 {
+		return nil, nil
+	}
+	report, httpErr := e.db.GetVarianceReport(period)
+	if httpErr != nil {
+		return nil, httpErr
+	}
+	var alerts []types.Alert
+	for _, variance := range report.Categories {
+		if variance.PercentUsed == nil {
+			continue
+		}
+		var reached float64
+		for _, threshold := range e.config.BudgetThresholds {
+			if *variance.PercentUsed >= threshold {
+				reached = threshold
+			}
+		}
+		if reached == 0 {
+			continue
+		}
+		alerts = append(alerts, types.Alert{
+			Key:       fmt.Sprintf("%v:%v:%v:%v", types.AlertBudget, variance.Category, period.Start.Format("2006-01"), reached),
+			Kind:      types.AlertBudget,
+			Period:    period,
+			Category:  variance.Category,
+			Amount:    variance.Actual,
+			Threshold: variance.Planned * reached / 100,
+			Message: fmt.Sprintf("%v spending is at %.0f%% of its %v forecast for %v (%v spent)", variance.Category,
+				*variance.PercentUsed, dollars(variance.Planned), period.Start.Format("January 2006"), dollars(variance.Actual)),
+		})
+	}
+	return alerts, nil
+}
+
+// largeTransactionAlerts raises an alert for each expense in period of at least the large transaction amount.
+func (e *Evaluator) largeTransactionAlerts(period types.Period) ([]types.Alert, *types.HTTPError) {
+	if e.config.LargeTransaction == 0 {
+		return nil, nil
+	}
+	expenses, httpErr := e.db.FetchExpenses(period.Start, period.End)
+	if httpErr != nil {
+		return nil, httpErr
+	}
+	var alerts []types.Alert
+	for _, expense := range expenses {
+		if expense.Amount < e.config.LargeTransaction {
+			continue
+		}
+		name := expense.Merchant
+		if name == "" {
+			name = expense.Description
+		}
+		alerts = append(alerts, types.Alert{
+			Key:       fmt.Sprintf("%v:%v", types.AlertLargeTransaction, expense.ID),
+			Kind:      types.AlertLargeTransaction,
+			Period:    period,
+			Category:  expense.Category,
+			AccountID: expense.AccountID,
+			ExpenseID: expense.ID,
+			Amount:    expense.Amount,
+			Threshold: e.config.LargeTransaction,
+			Message:   fmt.Sprintf("Large transaction: %v at %v on %v", dollars(expense.Amount), name, expense.Date.Format("Jan 2")),
+		})
+	}
+	return alerts, nil
+}
+
+// lowBalanceAlerts raises an alert for each open depository account whose latest balance is below the low
+// balance amount, once per period.
+func (e *Evaluator) lowBalanceAlerts(period types.Period) ([]types.Alert, *types.HTTPError) {
+	if e.config.LowBalance == 0 {
+		return nil, nil
+	}
+	accounts, httpErr := e.db.ListAccountBalances()
+	if httpErr != nil {
+		return nil, httpErr
+	}
+	var alerts []types.Alert
+	for _, account := range accounts {
+		if account.Type != types.AccountTypeDepository || account.ClosedAt != nil || account.Balance == nil ||
+			account.Balance.Current >= e.config.LowBalance {
+			continue
+		}
+		alerts = append(alerts, types.Alert{
+			Key:       fmt.Sprintf("%v:%v:%v", types.AlertLowBalance, account.AccountID, period.Start.Format("2006-01")),
+			Kind:      types.AlertLowBalance,
+			Period:    period,
+			AccountID: account.AccountID,
+			Amount:    account.Balance.Current,
+			Threshold: e.config.LowBalance,
+			Message: fmt.Sprintf("%v balance is %v, below %v", account.Name, dollars(account.Balance.Current),
+				dollars(e.config.LowBalance)),
+		})
+	}
+	return alerts, nil
+}
+
+func dollars(amount float64) string {
+	if amount < 0 {
+		return fmt.Sprintf("-$%.2f", -amount)
+	}
+	return fmt.Sprintf("$%.2f", amount)
+}
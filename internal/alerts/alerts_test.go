@@ -0,0 +1,81 @@
+package alerts_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/Seymour-creates/budget-server/internal/alerts"
+	"github.com/Seymour-creates/budget-server/internal/testutil"
+	"github.com/Seymour-creates/budget-server/internal/types"
+)
+
+// flakyChannel fails as many sends as failures says, then records the alerts it is sent.
+type flakyChannel struct {
+	failures int
+	sent     []types.Alert
+}
+
+func (c *flakyChannel) Name() string { return "flaky" }
+
+func (c *flakyChannel) Send(_ context.Context, alert types.Alert) error {
+	if c.failures > 0 {
+		c.failures--
+		return errors.New("mail server unavailable")
+	}
+	c.sent = append(c.sent, alert)
+	return nil
+}
+
+func TestFailedDeliveryIsRetried(t *testing.T) {
+	man := testutil.NewDB(t)
+	now := time.Now()
+	if httpErr := man.InsertExpenses([]types.Expense{{Date: now, Description: "Couch", Category: "misc",
+		Amount: 900}}); httpErr != nil {
+		t.Fatal(httpErr.Message)
+	}
+	channel := &flakyChannel{failures: 2}
+	evaluator := alerts.NewEvaluator(man, alerts.Config{LargeTransaction: 500}, channel)
+	evaluator.SetClock(func() time.Time { return now })
+
+	raised, httpErr := evaluator.Evaluate(now)
+	if httpErr != nil {
+		t.Fatal(httpErr.Message)
+	}
+	if len(raised) != 1 || len(channel.sent) != 0 {
+		t.Fatalf("evaluating raised %d alerts and sent %d, want 1 raised and none sent yet", len(raised), len(channel.sent))
+	}
+
+	ctx := context.Background()
+	if httpErr := evaluator.Deliver(ctx); httpErr != nil {
+		t.Fatal(httpErr.Message)
+	}
+	pending, httpErr := man.PendingDeliveries(now.Add(24*time.Hour), alerts.MaxDeliveryAttempts)
+	if httpErr != nil {
+		t.Fatal(httpErr.Message)
+	}
+	if len(pending) != 1 || pending[0].Attempts != 1 || pending[0].LastError == "" {
+		t.Fatalf("pending deliveries after a failed send = %+v, want one with the failure recorded", pending)
+	}
+
+	// Retrying right away sends nothing; the second attempt comes a minute after the first and the third two
+	// minutes after that.
+	for _, wait := range []time.Duration{0, alerts.DeliveryRetryInterval, 3 * alerts.DeliveryRetryInterval} {
+		evaluator.SetClock(func() time.Time { return now.Add(wait) })
+		if httpErr := evaluator.Deliver(ctx); httpErr != nil {
+			t.Fatal(httpErr.Message)
+		}
+	}
+	if len(channel.sent) != 1 || channel.sent[0].ID != raised[0].ID {
+		t.Fatalf("sent %+v after retrying, want the raised alert once", channel.sent)
+	}
+	if pending, _ := man.PendingDeliveries(now.Add(24*time.Hour), alerts.MaxDeliveryAttempts); len(pending) != 0 {
+		t.Errorf("deliveries still pending after sending: %+v", pending)
+	}
+
+	// Raising the same alert again doesn't send it again.
+	if raised, _ := evaluator.Evaluate(now); len(raised) != 0 {
+		t.Errorf("evaluating again raised %+v", raised)
+	}
+}
@@ -0,0 +1,143 @@
+package alerts
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"mime"
+	"net"
+	"net/http"
+	"net/smtp"
+	"strings"
+	"time"
+
+	"github.com/Seymour-creates/budget-server/internal/db"
+	"github.com/Seymour-creates/budget-server/internal/types"
+)
+
+// sendTimeout bounds how long a webhook or mail server may take to accept an alert.
+const sendTimeout = 10 * time.Second
+
+// Channel delivers alerts somewhere a person will see them.
+type Channel interface {
+	// Name identifies the channel in logs.
+	Name() string
+	Send(ctx context.Context, alert types.Alert) error
+}
+
+// Inbox delivers alerts to the in-app inbox served at /inbox.
+type Inbox struct {
+	db db.Repository
+}
+
+func NewInbox(repo db.Repository) *Inbox {
+	return &Inbox{db: repo}
+}
+
+func (i *Inbox) Name() string { return "inbox" }
+
+func (i *Inbox) Send(_ context.Context, alert types.Alert) error {
+	if err := i.db.AddToInbox(alert.ID); err != nil {
+		return err
+	}
+	return nil
+}
+
+// Webhook POSTs each alert as JSON to a URL. With a secret, the body's hex HMAC-SHA256 under it is sent in the
+// X-Alert-Signature header so the receiver can check the alert came from this server.
+type Webhook struct {
+	url    string
+	secret string
+	client *http.Client
+}
+
+func NewWebhook(url, secret string) *Webhook {
+	return &Webhook{url: url, secret: secret, client: &http.Client{Timeout: sendTimeout}}
+}
+
+func (wh *Webhook) Name() string { return "webhook" }
+
+func (wh *Webhook) Send(ctx context.Context, alert types.Alert) error {
+	body, err := json.Marshal(alert)
+	if err != nil {
+		return fmt.Errorf("encoding alert: %w", err)
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, wh.url, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("building webhook request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if wh.secret != "" {
+		mac := hmac.New(sha256.New, []byte(wh.secret))
+		mac.Write(body)
+		req.Header.Set("X-Alert-Signature", hex.EncodeToString(mac.Sum(nil)))
+	}
+	resp, err := wh.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("posting webhook: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("webhook responded %v", resp.Status)
+	}
+	return nil
+}
+
+// Email sends each alert as a plain text email through an SMTP server, using STARTTLS when the server offers it.
+type Email struct {
+	addr string
+	auth smtp.Auth
+	from string
+	to   []string
+}
+
+// NewEmail returns an Email sending through the server at addr (host:port), signing in with PLAIN auth when
+// username is set.
+func NewEmail(addr, username, password, from string, to []string) *Email {
+	email := &Email{addr: addr, from: from, to: to}
+	if username != "" {
+		host, _, _ := net.SplitHostPort(addr)
+		email.auth = smtp.PlainAuth("", username, password, host)
+	}
+	return email
+}
+
+func (e *Email) Name() string { return "email" }
+
+func (e *Email) Send(ctx context.Context, alert types.Alert) error {
+	// smtp.SendMail can't be cancelled, so give it a deadline of its own on top of ctx's.
+	ctx, cancel := context.WithTimeout(ctx, sendTimeout)
+	defer cancel()
+	done := make(chan error, 1)
+	go func() {
+		done <- smtp.SendMail(e.addr, e.auth, e.from, e.to, e.message(alert))
+	}()
+	select {
+	case err := <-done:
+		if err != nil {
+			return fmt.Errorf("sending email: %w", err)
+		}
+		return nil
+	case <-ctx.Done():
+		return fmt.Errorf("sending email: %w", ctx.Err())
+	}
+}
+
+// message renders alert as an RFC 5322 message.
+func (e *Email) message(alert types.Alert) []byte {
+	var b strings.Builder
+	fmt.Fprintf(&b, "From: %v\r\n", e.from)
+	fmt.Fprintf(&b, "To: %v\r\n", strings.Join(e.to, ", "))
+	fmt.Fprintf(&b, "Subject: %v\r\n", mime.QEncoding.Encode("utf-8", "Budget alert: "+alert.Message))
+	fmt.Fprintf(&b, "Date: %v\r\n", alert.CreatedAt.Format(time.RFC1123Z))
+	b.WriteString("MIME-Version: 1.0\r\n")
+	b.WriteString("Content-Type: text/plain; charset=utf-8\r\n")
+	b.WriteString("\r\n")
+	fmt.Fprintf(&b, "%v\r\n\r\nPeriod: %v to %v\r\n", alert.Message, alert.Period.Start.Format("Jan 2, 2006"),
+		alert.Period.End.Format("Jan 2, 2006"))
+	return []byte(b.String())
+}
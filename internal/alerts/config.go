@@ -0,0 +1,158 @@
+package alerts
+
+import (
+	"errors"
+	"fmt"
+	"net/mail"
+	"net/url"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/Seymour-creates/budget-server/internal/db"
+)
+
+// Config says which thresholds raise alerts and where they are sent. Load it with LoadConfig, which validates
+// it.
+type Config struct {
+	// BudgetThresholds are the percentages of a category's forecast that raise an alert once spending reaches
+	// them, in ascending order.
+	BudgetThresholds []float64
+	// LargeTransaction and LowBalance are the amounts at or above which an expense, and below which a
+	// depository account's balance, raise an alert; zero turns that alert off.
+	LargeTransaction float64
+	LowBalance       float64
+
+	// Inbox delivers alerts to the in-app inbox.
+	Inbox bool
+	// WebhookURL receives each alert as a JSON POST, signed with WebhookSecret when it is set.
+	WebhookURL    string
+	WebhookSecret string
+	// SMTPAddr is the host:port of the mail server that emails alerts from EmailFrom to EmailTo, signing in
+	// with SMTPUsername and SMTPPassword when they are set.
+	SMTPAddr     string
+	SMTPUsername string
+	SMTPPassword string
+	EmailFrom    string
+	EmailTo      []string
+}
+
+// LoadConfig reads the alert configuration from the environment and validates it, reporting every problem
+// found rather than just the first:
+//
+//	ALERT_BUDGET_THRESHOLDS  comma separated percentages of a category's forecast, default 80,100; none disables
+//	ALERT_LARGE_TRANSACTION  alert on any expense of at least this amount, optional
+//	ALERT_LOW_BALANCE        alert when a depository account's balance falls below this amount, optional
+//	ALERT_INBOX              false stops alerts going to the in-app inbox, default true
+//	ALERT_WEBHOOK_URL        URL to POST each alert to as JSON, optional
+//	ALERT_WEBHOOK_SECRET     signs webhook bodies in X-Alert-Signature, optional
+//	ALERT_SMTP_ADDR          host:port of the mail server to email alerts through, optional
+//	ALERT_SMTP_USERNAME      optional
+//	ALERT_SMTP_PASSWORD      optional
+//	ALERT_EMAIL_FROM         required with ALERT_SMTP_ADDR
+//	ALERT_EMAIL_TO           comma separated, required with ALERT_SMTP_ADDR
+func LoadConfig() (Config, error) {
+	var problems []error
+	config := Config{
+		Inbox:         !strings.EqualFold(os.Getenv("ALERT_INBOX"), "false"),
+		WebhookURL:    strings.TrimSpace(os.Getenv("ALERT_WEBHOOK_URL")),
+		WebhookSecret: os.Getenv("ALERT_WEBHOOK_SECRET"),
+		SMTPAddr:      strings.TrimSpace(os.Getenv("ALERT_SMTP_ADDR")),
+		SMTPUsername:  os.Getenv("ALERT_SMTP_USERNAME"),
+		SMTPPassword:  os.Getenv("ALERT_SMTP_PASSWORD"),
+		EmailFrom:     strings.TrimSpace(os.Getenv("ALERT_EMAIL_FROM")),
+		EmailTo:       splitList(os.Getenv("ALERT_EMAIL_TO")),
+	}
+
+	if thresholds := envOr("ALERT_BUDGET_THRESHOLDS", "80,100"); !strings.EqualFold(thresholds, "none") {
+		for _, raw := range splitList(thresholds) {
+			percent, err := strconv.ParseFloat(strings.TrimSuffix(raw, "%"), 64)
+			if err != nil || percent <= 0 {
+				problems = append(problems, fmt.Errorf("ALERT_BUDGET_THRESHOLDS: %q is not a positive percentage", raw))
+				continue
+			}
+			config.BudgetThresholds = append(config.BudgetThresholds, percent)
+		}
+		sort.Float64s(config.BudgetThresholds)
+	}
+	var err error
+	if config.LargeTransaction, err = parseAmount("ALERT_LARGE_TRANSACTION"); err != nil {
+		problems = append(problems, err)
+	}
+	if config.LowBalance, err = parseAmount("ALERT_LOW_BALANCE"); err != nil {
+		problems = append(problems, err)
+	}
+
+	if config.WebhookURL != "" {
+		parsed, err := url.Parse(config.WebhookURL)
+		if err != nil || parsed.Host == "" || (parsed.Scheme != "https" && parsed.Scheme != "http") {
+			problems = append(problems, fmt.Errorf("ALERT_WEBHOOK_URL: %q is not an absolute http(s) URL", config.WebhookURL))
+		}
+	}
+	if config.SMTPAddr != "" {
+		if !strings.Contains(config.SMTPAddr, ":") {
+			problems = append(problems, fmt.Errorf("ALERT_SMTP_ADDR %q must be host:port", config.SMTPAddr))
+		}
+		if _, err := mail.ParseAddress(config.EmailFrom); err != nil {
+			problems = append(problems, fmt.Errorf("ALERT_EMAIL_FROM %q is not an email address", config.EmailFrom))
+		}
+		if len(config.EmailTo) == 0 {
+			problems = append(problems, errors.New("ALERT_EMAIL_TO is required with ALERT_SMTP_ADDR"))
+		}
+		for _, to := range config.EmailTo {
+			if _, err := mail.ParseAddress(to); err != nil {
+				problems = append(problems, fmt.Errorf("ALERT_EMAIL_TO: %q is not an email address", to))
+			}
+		}
+	}
+
+	return config, errors.Join(problems...)
+}
+
+// Channels returns the channels config turns on: the in-app inbox, the webhook and email, in that order.
+func (c Config) Channels(repo db.Repository) []Channel {
+	var channels []Channel
+	if c.Inbox {
+		channels = append(channels, NewInbox(repo))
+	}
+	if c.WebhookURL != "" {
+		channels = append(channels, NewWebhook(c.WebhookURL, c.WebhookSecret))
+	}
+	if c.SMTPAddr != "" {
+		channels = append(channels, NewEmail(c.SMTPAddr, c.SMTPUsername, c.SMTPPassword, c.EmailFrom, c.EmailTo))
+	}
+	return channels
+}
+
+// parseAmount reads an optional positive amount from the environment variable name, zero when it is unset.
+func parseAmount(name string) (float64, error) {
+	value := strings.TrimSpace(os.Getenv(name))
+	if value == "" {
+		return 0, nil
+	}
+	amount, err := strconv.ParseFloat(value, 64)
+	if err != nil || amount <= 0 {
+		return 0, fmt.Errorf("%v %q is not a positive amount", name, value)
+	}
+	return amount, nil
+}
+
+// envOr returns the environment variable name, or fallback when it is unset or blank.
+func envOr(name, fallback string) string {
+	if value := strings.TrimSpace(os.Getenv(name)); value != "" {
+		return value
+	}
+	return fallback
+}
+
+// splitList splits a comma separated list, dropping blank entries.
+func splitList(value string) []string {
+	var items []string
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
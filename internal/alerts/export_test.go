@@ -0,0 +1,15 @@
+package alerts
+
+import "time"
+
+// The external tests can't import testutil from inside the package, since it depends on plaidCtl, which
+// depends on this package. These give them what they need of its internals.
+const (
+	DeliveryRetryInterval = deliveryRetryInterval
+	MaxDeliveryAttempts   = maxDeliveryAttempts
+)
+
+// SetClock makes e read the time from now.
+func (e *Evaluator) SetClock(now func() time.Time) {
+	e.now = now
+}
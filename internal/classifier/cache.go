@@ -0,0 +1,69 @@
+package classifier
+
+import (
+	"sync"
+	"time"
+
+	"github.com/Seymour-creates/budget-server/internal/types"
+)
+
+// maxModelAge is how long a cached model is used before it is retrained from scratch. Learning as expenses are
+// filed misses a few changes, such as transactions Plaid removes, and retraining now and then puts those right.
+const maxModelAge = time.Hour
+
+// Cache keeps a trained model between requests so imports and suggestions don't each retrain it on every stored
+// expense. Expenses filed or corrected through the server are learned as they are stored; changes made in bulk,
+// such as merging categories, call Invalidate so the next use retrains.
+type Cache struct {
+	store ExpenseStore
+
+	mu        sync.Mutex
+	model     *Model
+	trainedAt time.Time
+}
+
+func NewCache(store ExpenseStore) *Cache {
+	return &Cache{store: store}
+}
+
+// Model returns the cached model, training it first if there is none yet or it is older than maxModelAge.
+func (c *Cache) Model() (*Model, *types.HTTPError) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.model != nil && time.Since(c.trainedAt) < maxModelAge {
+		return c.model, nil
+	}
+	model, err := Load(c.store)
+	if err != nil {
+		return nil, err
+	}
+	c.model, c.trainedAt = model, time.Now()
+	return model, nil
+}
+
+// Learn adds newly stored expenses to the cached model, if one has been trained.
+func (c *Cache) Learn(expenses ...types.Expense) {
+	if model := c.cached(); model != nil {
+		model.Learn(expenses...)
+	}
+}
+
+// Forget takes deleted or re-filed expenses back out of the cached model, if one has been trained.
+func (c *Cache) Forget(expenses ...types.Expense) {
+	if model := c.cached(); model != nil {
+		model.Forget(expenses...)
+	}
+}
+
+// Invalidate drops the cached model so the next use retrains it.
+func (c *Cache) Invalidate() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.model = nil
+}
+
+func (c *Cache) cached() *Model {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.model
+}
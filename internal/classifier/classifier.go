@@ -0,0 +1,315 @@
+package classifier
+
+import (
+	"math"
+	"sort"
+	"strings"
+	"sync"
+	"unicode"
+
+	"github.com/Seymour-creates/budget-server/internal/types"
+)
+
+// AutoApplyConfidence is the confidence at or above which a guess is filed without asking.
+const AutoApplyConfidence = 0.8
+
+// correctionWeight is how many ordinary examples a manual correction counts as, so a single correction is
+// enough to move a merchant's future imports.
+const correctionWeight = 3
+
+// smoothing is the pseudo-count added to every category so unseen combinations keep a small probability.
+const smoothing = 0.1
+
+// unknownWeight is how many examples the outcome "none of the categories seen so far" counts as. It keeps a
+// model that has only seen a category or two from being sure of them.
+const unknownWeight = 1.0
+
+// minKnownTokens is how many words of a description the model must have seen before, when it has never seen
+// the merchant, for a guess to be filed without asking.
+const minKnownTokens = 2
+
+// Model guesses an expense's category from the categories people have chosen for similar expenses. It blends
+// how often each category was used for the same merchant with a naive Bayes model over the words of the
+// description. A nil or untrained *Model makes no suggestions. A Model is safe for concurrent use.
+type Model struct {
+	mu         sync.RWMutex
+	categories []string
+
+	// merchant key -> category -> weighted count
+	merchants map[string]map[string]float64
+
+	// category -> weighted example count, and category -> token -> weighted count
+	priors      map[string]float64
+	tokens      map[string]map[string]float64
+	tokenTotals map[string]float64
+	// token -> weighted count over every category
+	vocabulary map[string]float64
+	examples   float64
+}
+
+// ExpenseStore is the part of db.Repository the model is trained from.
+type ExpenseStore interface {
+	ListExpenses(filter types.ExpenseFilter) ([]types.Expense, *types.HTTPError)
+}
+
+// Load trains a model on the stored expenses.
+func Load(store ExpenseStore) (*Model, *types.HTTPError) {
+	expenses, err := store.ListExpenses(types.ExpenseFilter{})
+	if err != nil {
+		return nil, err
+	}
+	return Train(expenses), nil
+}
+
+// Train builds a model from the expenses whose category a person chose: manual corrections, explicit
+// categories on CLI posts and user-written rules. Plaid's mapping, fallback defaults and the model's own
+// guesses are left out so they can't drown out or reinforce themselves over what people actually picked.
+// Manual corrections count extra.
+func Train(expenses []types.Expense) *Model {
+	m := &Model{
+		merchants:   map[string]map[string]float64{},
+		priors:      map[string]float64{},
+		tokens:      map[string]map[string]float64{},
+		tokenTotals: map[string]float64{},
+		vocabulary:  map[string]float64{},
+	}
+	m.Learn(expenses...)
+	return m
+}
+
+// Learn adds newly filed expenses to the model, skipping those Train would leave out.
+func (m *Model) Learn(expenses ...types.Expense) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, expense := range expenses {
+		if weight, ok := trainingWeight(expense); ok {
+			m.add(expense, weight)
+		}
+	}
+}
+
+// Forget takes expenses the model learned from back out of it, for when they are deleted or re-filed.
+func (m *Model) Forget(expenses ...types.Expense) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, expense := range expenses {
+		if weight, ok := trainingWeight(expense); ok && m.priors[expense.Category] > 0 {
+			m.add(expense, -weight)
+		}
+	}
+}
+
+// add counts expense under its category with weight, which is negative to forget it. Counts that fall to zero
+// are dropped, so a model that forgets everything it learned is the same as an untrained one.
+func (m *Model) add(expense types.Expense, weight float64) {
+	category := expense.Category
+	if key := merchantKey(expense); key != "" {
+		if m.merchants[key] == nil {
+			m.merchants[key] = map[string]float64{}
+		}
+		decrement(m.merchants[key], category, weight)
+		if len(m.merchants[key]) == 0 {
+			delete(m.merchants, key)
+		}
+	}
+
+	if m.priors[category] == 0 {
+		m.categories = append(m.categories, category)
+		sort.Strings(m.categories)
+		m.tokens[category] = map[string]float64{}
+	}
+	m.examples += weight
+	for _, token := range tokenize(expense.Description) {
+		decrement(m.tokens[category], token, weight)
+		decrement(m.tokenTotals, category, weight)
+		decrement(m.vocabulary, token, weight)
+	}
+	if decrement(m.priors, category, weight) {
+		return
+	}
+	delete(m.tokens, category)
+	delete(m.tokenTotals, category)
+	i := sort.SearchStrings(m.categories, category)
+	m.categories = append(m.categories[:i], m.categories[i+1:]...)
+}
+
+// decrement adds weight to counts[key], deleting the key when its count falls to zero or below, and reports
+// whether it is still there.
+func decrement(counts map[string]float64, key string, weight float64) bool {
+	counts[key] += weight
+	if counts[key] <= 0 {
+		delete(counts, key)
+		return false
+	}
+	return true
+}
+
+// Suggest ranks the categories expense could belong in, most likely first. Confidences sum to less than 1:
+// what is left over is the chance that the expense belongs in none of the categories the model has seen.
+func (m *Model) Suggest(expense types.Expense) []types.CategorySuggestion {
+	suggestions, _ := m.suggest(expense)
+	return suggestions
+}
+
+// Best returns the model's best guess for expense, and whether the guess is sure enough to file without asking:
+// it must be at least AutoApplyConfidence, and backed either by the merchant's history or by at least
+// minKnownTokens words of the description the model has seen before. A lopsided history alone is never enough.
+func (m *Model) Best(expense types.Expense) (types.CategorySuggestion, bool) {
+	suggestions, evidence := m.suggest(expense)
+	if len(suggestions) == 0 {
+		return types.CategorySuggestion{}, false
+	}
+	return suggestions[0], evidence && suggestions[0].Confidence >= AutoApplyConfidence
+}
+
+// Apply files expense under the model's best guess when Best says it is sure enough, reporting whether it did.
+func (m *Model) Apply(expense *types.Expense) bool {
+	best, ok := m.Best(*expense)
+	if !ok {
+		return false
+	}
+	expense.Category = best.Category
+	expense.CategorySource = types.CategorySourceModel
+	expense.CategoryConfidence = &best.Confidence
+	return true
+}
+
+// suggest ranks the categories for expense and reports whether the model has evidence about it beyond how
+// often each category is used.
+func (m *Model) suggest(expense types.Expense) ([]types.CategorySuggestion, bool) {
+	if m == nil {
+		return nil, false
+	}
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	if len(m.categories) == 0 {
+		return nil, false
+	}
+
+	probabilities, known := m.descriptionProbabilities(expense.Description)
+	counts, merchantSeen := m.merchants[merchantKey(expense)]
+	if merchantSeen {
+		// Lean on the merchant's history in proportion to how much of it there is.
+		var seen float64
+		for _, count := range counts {
+			seen += count
+		}
+		outcomes := float64(len(m.categories)) + 1
+		trust := seen / (seen + 1)
+		for category := range probabilities {
+			merchantProbability := (counts[category] + smoothing) / (seen + smoothing*outcomes)
+			probabilities[category] = trust*merchantProbability + (1-trust)*probabilities[category]
+		}
+	}
+	delete(probabilities, unknownCategory)
+
+	suggestions := make([]types.CategorySuggestion, 0, len(probabilities))
+	for category, probability := range probabilities {
+		suggestions = append(suggestions, types.CategorySuggestion{Category: category, Confidence: probability})
+	}
+	sort.Slice(suggestions, func(i, j int) bool {
+		if suggestions[i].Confidence != suggestions[j].Confidence {
+			return suggestions[i].Confidence > suggestions[j].Confidence
+		}
+		return suggestions[i].Category < suggestions[j].Category
+	})
+	return suggestions, merchantSeen || known >= minKnownTokens
+}
+
+// unknownCategory stands for "none of the categories seen so far" among the outcomes the model weighs. No
+// category has an empty slug, so it can't collide with one.
+const unknownCategory = ""
+
+// descriptionProbabilities is the naive Bayes posterior for a description over the categories seen and the
+// unknown outcome, with Laplace smoothing over the training vocabulary. The unknown outcome is weighted as
+// unknownWeight examples of no words at all. It also returns how many of the description's words the model
+// has seen before.
+func (m *Model) descriptionProbabilities(description string) (map[string]float64, int) {
+	tokens := tokenize(description)
+	vocabulary := float64(len(m.vocabulary))
+	k := float64(len(m.categories))
+	total := m.examples + unknownWeight + smoothing*k
+
+	logScores := make(map[string]float64, len(m.categories)+1)
+	logScores[unknownCategory] = math.Log(unknownWeight / total)
+	for _, category := range m.categories {
+		logScores[category] = math.Log((m.priors[category] + smoothing) / total)
+	}
+	known := 0
+	for _, token := range tokens {
+		if m.vocabulary[token] == 0 {
+			continue
+		}
+		known++
+		logScores[unknownCategory] += math.Log(1 / vocabulary)
+		for _, category := range m.categories {
+			logScores[category] += math.Log((m.tokens[category][token] + 1) / (m.tokenTotals[category] + vocabulary))
+		}
+	}
+
+	best := math.Inf(-1)
+	for _, score := range logScores {
+		best = math.Max(best, score)
+	}
+	var sum float64
+	probabilities := make(map[string]float64, len(logScores))
+	for category, score := range logScores {
+		probabilities[category] = math.Exp(score - best)
+		sum += probabilities[category]
+	}
+	for category := range probabilities {
+		probabilities[category] /= sum
+	}
+	return probabilities, known
+}
+
+// trainingWeight is how much expense counts for in training, and false when it isn't trained on at all.
+func trainingWeight(expense types.Expense) (float64, bool) {
+	if expense.Category == "" || !chosenByPerson(expense) {
+		return 0, false
+	}
+	if expense.CategorySource == types.CategorySourceUser {
+		return correctionWeight, true
+	}
+	return 1, true
+}
+
+func chosenByPerson(expense types.Expense) bool {
+	switch expense.CategorySource {
+	case types.CategorySourceUser, types.CategorySourceRule:
+		return true
+	case "":
+		// Filed before category sources were recorded, when only CLI posts carried a chosen category.
+		return expense.Source == types.ExpenseSourceCLI
+	}
+	return false
+}
+
+// merchantKey identifies the merchant behind an expense: Plaid's merchant name when there is one, otherwise the
+// first words of the description, which for card transactions is usually the merchant.
+func merchantKey(expense types.Expense) string {
+	if merchant := strings.ToLower(strings.TrimSpace(expense.Merchant)); merchant != "" {
+		return merchant
+	}
+	tokens := tokenize(expense.Description)
+	if len(tokens) > 2 {
+		tokens = tokens[:2]
+	}
+	return strings.Join(tokens, " ")
+}
+
+// tokenize lower-cases a description and splits it into words, dropping numbers, store and reference codes
+// and single letters, which say little about the category.
+func tokenize(description string) []string {
+	fields := strings.FieldsFunc(strings.ToLower(description), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+	tokens := fields[:0]
+	for _, field := range fields {
+		if len(field) < 2 || strings.IndexFunc(field, unicode.IsDigit) >= 0 {
+			continue
+		}
+		tokens = append(tokens, field)
+	}
+	return tokens
+}
@@ -0,0 +1,154 @@
+package classifier
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/Seymour-creates/budget-server/internal/types"
+)
+
+func chosen(description, merchant, category string) types.Expense {
+	return types.Expense{Description: description, Merchant: merchant, Category: category,
+		CategorySource: types.CategorySourceRule}
+}
+
+func corrected(description, merchant, category string) types.Expense {
+	expense := chosen(description, merchant, category)
+	expense.CategorySource = types.CategorySourceUser
+	return expense
+}
+
+func TestOneCategoryIsNotEvidence(t *testing.T) {
+	model := Train([]types.Expense{chosen("Blue Bottle Coffee", "Blue Bottle", "takeout")})
+
+	expense := types.Expense{Description: "Shell Oil 5724", Merchant: "Shell"}
+	best, confident := model.Best(expense)
+	if confident || best.Confidence >= AutoApplyConfidence {
+		t.Errorf("unrelated expense guessed %v at %.2f (confident %v), want an unsure guess", best.Category, best.Confidence, confident)
+	}
+	if model.Apply(&expense) {
+		t.Errorf("model with one category filed an unrelated expense under %v", expense.Category)
+	}
+
+	suggestions := model.Suggest(expense)
+	var total float64
+	for _, suggestion := range suggestions {
+		total += suggestion.Confidence
+	}
+	if total >= 0.99 {
+		t.Errorf("suggestion confidences sum to %.3f, want probability left for an unseen category", total)
+	}
+}
+
+func TestSkewedPriorIsNotEvidence(t *testing.T) {
+	var history []types.Expense
+	for i := 0; i < 50; i++ {
+		history = append(history, chosen("Safeway Store", "Safeway", "groceries"))
+	}
+	history = append(history, chosen("Chipotle Mexican Grill", "Chipotle", "takeout"))
+	model := Train(history)
+
+	unseen := types.Expense{Description: "Zqx Hardware Supply", Merchant: "Zqx Hardware"}
+	if best, confident := model.Best(unseen); confident {
+		t.Errorf("unseen merchant guessed %v at %.2f from the prior alone", best.Category, best.Confidence)
+	}
+	if model.Apply(&unseen) {
+		t.Errorf("unseen merchant filed under %v", unseen.Category)
+	}
+
+	known := types.Expense{Description: "SAFEWAY #1234", Merchant: "Safeway"}
+	if !model.Apply(&known) || known.Category != "groceries" || known.CategorySource != types.CategorySourceModel {
+		t.Errorf("known merchant filed under %q (%v), want groceries by the model", known.Category, known.CategorySource)
+	}
+}
+
+func TestDescriptionWordsAreEvidence(t *testing.T) {
+	var history []types.Expense
+	for i := 0; i < 10; i++ {
+		history = append(history, chosen("Uber Eats order", "", "takeout"), chosen("City Parking garage", "", "transport"))
+	}
+	model := Train(history)
+
+	expense := types.Expense{Description: "UBER EATS PENDING"}
+	best, confident := model.Best(expense)
+	if !confident || best.Category != "takeout" {
+		t.Errorf("guessed %v at %.2f (confident %v), want takeout auto-applied from two known words", best.Category,
+			best.Confidence, confident)
+	}
+}
+
+func TestOneCorrectionMovesMerchant(t *testing.T) {
+	model := Train([]types.Expense{
+		chosen("Target store", "Target", "shopping"),
+		chosen("Target store", "Target", "shopping"),
+	})
+	model.Learn(corrected("Target store", "Target", "groceries"))
+
+	expense := types.Expense{Description: "Target store", Merchant: "Target"}
+	if best, _ := model.Best(expense); best.Category != "groceries" {
+		t.Errorf("best guess after correction = %v, want groceries", best.Category)
+	}
+}
+
+func TestLearnAndForgetMatchTraining(t *testing.T) {
+	base := []types.Expense{
+		chosen("Blue Bottle Coffee", "Blue Bottle", "takeout"),
+		chosen("Safeway Store", "Safeway", "groceries"),
+	}
+	extra := []types.Expense{
+		corrected("Shell Oil 5724", "Shell", "transport"),
+		chosen("Safeway Fuel", "Safeway", "transport"),
+		{Description: "Plaid guess", Category: "misc", CategorySource: types.CategorySourcePlaid},
+	}
+
+	learned := Train(base)
+	learned.Learn(extra...)
+	if trained := Train(append(append([]types.Expense{}, base...), extra...)); !sameCounts(learned, trained) {
+		t.Errorf("learning incrementally gave %+v, want the same as training on everything: %+v", learned, trained)
+	}
+
+	learned.Forget(extra...)
+	if trained := Train(base); !sameCounts(learned, trained) {
+		t.Errorf("forgetting gave %+v, want the same as never learning: %+v", learned, trained)
+	}
+}
+
+func sameCounts(a, b *Model) bool {
+	return reflect.DeepEqual(a.categories, b.categories) && reflect.DeepEqual(a.merchants, b.merchants) &&
+		reflect.DeepEqual(a.priors, b.priors) && reflect.DeepEqual(a.tokens, b.tokens) &&
+		reflect.DeepEqual(a.tokenTotals, b.tokenTotals) && reflect.DeepEqual(a.vocabulary, b.vocabulary) &&
+		a.examples == b.examples
+}
+
+type fakeStore struct {
+	expenses []types.Expense
+	loads    int
+}
+
+func (s *fakeStore) ListExpenses(types.ExpenseFilter) ([]types.Expense, *types.HTTPError) {
+	s.loads++
+	return s.expenses, nil
+}
+
+func TestCacheTrainsOnce(t *testing.T) {
+	store := &fakeStore{expenses: []types.Expense{chosen("Blue Bottle Coffee", "Blue Bottle", "takeout")}}
+	cache := NewCache(store)
+
+	first, httpErr := cache.Model()
+	if httpErr != nil {
+		t.Fatal(httpErr.Message)
+	}
+	cache.Learn(chosen("Safeway Store", "Safeway", "groceries"))
+	second, _ := cache.Model()
+	if first != second || store.loads != 1 {
+		t.Fatalf("cache trained %d times, want once", store.loads)
+	}
+	if got := second.Suggest(types.Expense{Description: "Safeway Store", Merchant: "Safeway"}); got[0].Category != "groceries" {
+		t.Errorf("learned expense not used: best suggestion %v", got[0].Category)
+	}
+
+	cache.Invalidate()
+	if _, httpErr := cache.Model(); httpErr != nil || store.loads != 2 {
+		t.Errorf("cache trained %d times after invalidating, want 2", store.loads)
+	}
+}
@@ -0,0 +1,168 @@
+package db
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"github.com/Seymour-creates/budget-server/internal/types"
+	"github.com/Seymour-creates/budget-server/internal/utils"
+	"log"
+	"net/http"
+	"strings"
+)
+
+const accountColumns = `a.account_id, a.item_id, a.name, a.official_name, a.mask, a.type, a.subtype, a.currency, a.hidden,
+	a.exclude_from_budget, a.closed_at`
+
+// budgetAccountsCondition leaves out expenses on accounts kept out of budgets, and on investment accounts, whose
+// buys and sells aren't spending. It expects expenses aliased as e.
+const budgetAccountsCondition = `e.account_id NOT IN (SELECT account_id FROM accounts
+	WHERE hidden = TRUE OR exclude_from_budget = TRUE OR type = 'investment')`
+
+func scanAccount(row rowScanner) (types.Account, error) {
+	var account types.Account
+	var closedAt sql.NullString
+	err := row.Scan(&account.AccountID, &account.ItemID, &account.Name, &account.OfficialName, &account.Mask,
+		&account.Type, &account.Subtype, &account.Currency, &account.Hidden, &account.ExcludeFromBudget, &closedAt)
+	if err != nil {
+		return account, err
+	}
+	account.Manual = account.ItemID == ""
+	if closedAt.Valid {
+		closed, err := parseTimestamp(closedAt.String)
+		if err != nil {
+			return account, fmt.Errorf("parsing account closed_at: %w", err)
+		}
+		account.ClosedAt = &closed
+	}
+	return account, nil
+}
+
+// UpsertAccounts stores the accounts Plaid reports for an item. Accounts already stored have their details
+// refreshed, but keep the hidden and budget settings chosen for them; closed ones are open again.
+func (man *Manager) UpsertAccounts(accounts []types.Account) *types.HTTPError {
+	query := `INSERT INTO accounts (account_id, item_id, name, official_name, mask, type, subtype, currency, closed_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, NULL) ` +
+		man.dialect.upsert([]string{"account_id"}, "item_id", "name", "official_name", "mask", "type", "subtype", "currency",
+			"closed_at")
+
+	tx, err := man.db.Begin()
+	if err != nil {
+		return utils.NewHTTPError(http.StatusInternalServerError, fmt.Sprintf("error starting accounts transaction: %v", err))
+	}
+	defer rollback(tx)
+
+	for _, account := range accounts {
+		_, err := tx.Exec(query, account.AccountID, account.ItemID, account.Name, account.OfficialName, account.Mask,
+			account.Type, account.Subtype, account.Currency)
+		if err != nil {
+			return utils.NewHTTPError(http.StatusInternalServerError, fmt.Sprintf("error saving account %v: %v", account.AccountID, err))
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return utils.NewHTTPError(http.StatusInternalServerError, fmt.Sprintf("error committing accounts: %v", err))
+	}
+	return nil
+}
+
+// InsertManualAccount creates an account that belongs to no item, for balances entered by hand, and returns
+// it as stored.
+func (man *Manager) InsertManualAccount(account types.Account) (*types.Account, *types.HTTPError) {
+	const query = `INSERT INTO accounts (account_id, name, type, subtype, currency, hidden, exclude_from_budget)
+		VALUES (?, ?, ?, ?, ?, ?, ?)`
+	_, err := man.db.Exec(query, account.AccountID, account.Name, account.Type, account.Subtype, account.Currency,
+		account.Hidden, account.ExcludeFromBudget)
+	if err != nil {
+		if existing, _ := man.FetchAccount(account.AccountID); existing != nil {
+			return nil, utils.NewHTTPError(http.StatusConflict, fmt.Sprintf("account %v already exists", account.AccountID))
+		}
+		return nil, utils.NewHTTPError(http.StatusInternalServerError, fmt.Sprintf("error creating account: %v", err))
+	}
+	return man.FetchAccount(account.AccountID)
+}
+
+// DeleteAccount removes an account along with its balance history, or returns a 404 if there is none with
+// that id. Expenses on it are kept.
+func (man *Manager) DeleteAccount(accountID string) *types.HTTPError {
+	result, err := man.db.Exec("DELETE FROM accounts WHERE account_id = ?", accountID)
+	if err != nil {
+		return utils.NewHTTPError(http.StatusInternalServerError, fmt.Sprintf("error deleting account: %v", err))
+	}
+	if affected, err := result.RowsAffected(); err == nil && affected == 0 {
+		return utils.NewHTTPError(http.StatusNotFound, fmt.Sprintf("account %v not found", accountID))
+	}
+	return nil
+}
+
+// ListAccounts returns accounts ordered by item then name, leaving out hidden ones unless includeHidden is set.
+func (man *Manager) ListAccounts(includeHidden bool) ([]types.Account, *types.HTTPError) {
+	query := "SELECT " + accountColumns + " FROM accounts a"
+	if !includeHidden {
+		query += " WHERE a.hidden = FALSE"
+	}
+	query += " ORDER BY a.item_id, a.name, a.account_id"
+
+	rows, err := man.db.Query(query)
+	if err != nil {
+		return nil, utils.NewHTTPError(http.StatusInternalServerError, fmt.Sprintf("error fetching accounts: %v", err))
+	}
+	defer func(rows *sql.Rows) {
+		err := rows.Close()
+		if err != nil {
+			log.Printf("error closing row: %v", err)
+		}
+	}(rows)
+
+	accounts := []types.Account{}
+	for rows.Next() {
+		account, err := scanAccount(rows)
+		if err != nil {
+			return nil, utils.NewHTTPError(http.StatusInternalServerError, fmt.Sprintf("error scanning account: %v", err))
+		}
+		accounts = append(accounts, account)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, utils.NewHTTPError(http.StatusInternalServerError, fmt.Sprintf("error iterating account rows: %v", err))
+	}
+
+	return accounts, nil
+}
+
+// FetchAccount returns a single account, or a 404 if there is none with that id.
+func (man *Manager) FetchAccount(accountID string) (*types.Account, *types.HTTPError) {
+	account, err := scanAccount(man.db.QueryRow("SELECT "+accountColumns+" FROM accounts a WHERE a.account_id = ?", accountID))
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, utils.NewHTTPError(http.StatusNotFound, fmt.Sprintf("account %v not found", accountID))
+	}
+	if err != nil {
+		return nil, utils.NewHTTPError(http.StatusInternalServerError, fmt.Sprintf("error fetching account: %v", err))
+	}
+	return &account, nil
+}
+
+// UpdateAccount applies patch to an account and returns the updated row.
+func (man *Manager) UpdateAccount(accountID string, patch types.AccountPatch) (*types.Account, *types.HTTPError) {
+	if _, httpErr := man.FetchAccount(accountID); httpErr != nil {
+		return nil, httpErr
+	}
+
+	var assignments []string
+	var args []interface{}
+	if patch.Hidden != nil {
+		assignments = append(assignments, "hidden = ?")
+		args = append(args, *patch.Hidden)
+	}
+	if patch.ExcludeFromBudget != nil {
+		assignments = append(assignments, "exclude_from_budget = ?")
+		args = append(args, *patch.ExcludeFromBudget)
+	}
+	if len(assignments) > 0 {
+		query := "UPDATE accounts SET " + strings.Join(assignments, ", ") + " WHERE account_id = ?"
+		if _, err := man.db.Exec(query, append(args, accountID)...); err != nil {
+			return nil, utils.NewHTTPError(http.StatusInternalServerError, fmt.Sprintf("error updating account: %v", err))
+		}
+	}
+
+	return man.FetchAccount(accountID)
+}
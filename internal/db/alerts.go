@@ -0,0 +1,246 @@
+package db
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"github.com/Seymour-creates/budget-server/internal/types"
+	"github.com/Seymour-creates/budget-server/internal/utils"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+)
+
+const alertColumns = `a.id, a.alert_key, a.kind, a.period_start, a.period_end, a.category, a.account_id, a.expense_id,
+	a.amount, a.threshold, a.message, a.created_at`
+
+func scanAlert(row rowScanner, extra ...interface{}) (types.Alert, error) {
+	var alert types.Alert
+	var periodStart, periodEnd, createdAt string
+	dest := append([]interface{}{&alert.ID, &alert.Key, &alert.Kind, &periodStart, &periodEnd, &alert.Category,
+		&alert.AccountID, &alert.ExpenseID, &alert.Amount, &alert.Threshold, &alert.Message, &createdAt}, extra...)
+	if err := row.Scan(dest...); err != nil {
+		return alert, err
+	}
+	var err error
+	if alert.Period.Start, err = time.Parse(dateFormat, periodStart); err != nil {
+		return alert, fmt.Errorf("parsing alert period_start: %w", err)
+	}
+	if alert.Period.End, err = time.Parse(dateFormat, periodEnd); err != nil {
+		return alert, fmt.Errorf("parsing alert period_end: %w", err)
+	}
+	if alert.CreatedAt, err = parseTimestamp(createdAt); err != nil {
+		return alert, fmt.Errorf("parsing alert created_at: %w", err)
+	}
+	return alert, nil
+}
+
+// RecordAlert stores alert with a pending delivery through each of channels and returns it with its ID, unless
+// an alert with the same key has been raised before, in which case it returns nil so the alert isn't sent again.
+func (man *Manager) RecordAlert(alert types.Alert, channels ...string) (*types.Alert, *types.HTTPError) {
+	const existsQuery = `SELECT COUNT(*) FROM alerts WHERE alert_key = ?`
+	const insertQuery = `INSERT INTO alerts (alert_key, kind, period_start, period_end, category, account_id, expense_id,
+		amount, threshold, message, created_at) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`
+	const deliveryQuery = `INSERT INTO alert_deliveries (alert_id, channel, next_attempt_at) VALUES (?, ?, ?)`
+
+	tx, err := man.db.Begin()
+	if err != nil {
+		return nil, utils.NewHTTPError(http.StatusInternalServerError, fmt.Sprintf("error starting alert transaction: %v", err))
+	}
+	defer rollback(tx)
+
+	var raised int
+	if err := tx.QueryRow(existsQuery, alert.Key).Scan(&raised); err != nil {
+		return nil, utils.NewHTTPError(http.StatusInternalServerError, fmt.Sprintf("error checking alert %v: %v", alert.Key, err))
+	}
+	if raised > 0 {
+		return nil, nil
+	}
+	alert.CreatedAt = time.Now().UTC().Truncate(time.Second)
+	result, err := tx.Exec(insertQuery, alert.Key, alert.Kind, alert.Period.Start.Format(dateFormat),
+		alert.Period.End.Format(dateFormat), alert.Category, alert.AccountID, alert.ExpenseID, alert.Amount,
+		alert.Threshold, alert.Message, alert.CreatedAt.Format(timestampFormat))
+	if err != nil {
+		return nil, utils.NewHTTPError(http.StatusInternalServerError, fmt.Sprintf("error recording alert %v: %v", alert.Key, err))
+	}
+	if alert.ID, err = result.LastInsertId(); err != nil {
+		return nil, utils.NewHTTPError(http.StatusInternalServerError, fmt.Sprintf("error reading alert id: %v", err))
+	}
+	for _, channel := range channels {
+		if _, err := tx.Exec(deliveryQuery, alert.ID, channel, alert.CreatedAt.Format(timestampFormat)); err != nil {
+			return nil, utils.NewHTTPError(http.StatusInternalServerError, fmt.Sprintf("error queueing alert %v for %v: %v", alert.Key, channel, err))
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, utils.NewHTTPError(http.StatusInternalServerError, fmt.Sprintf("error committing alert: %v", err))
+	}
+	return &alert, nil
+}
+
+// ListAlerts returns every alert raised, newest first, whichever channels it was sent through.
+func (man *Manager) ListAlerts() ([]types.Alert, *types.HTTPError) {
+	rows, err := man.db.Query("SELECT " + alertColumns + " FROM alerts a ORDER BY a.created_at DESC, a.id DESC")
+	if err != nil {
+		return nil, utils.NewHTTPError(http.StatusInternalServerError, fmt.Sprintf("error fetching alerts: %v", err))
+	}
+	defer func(rows *sql.Rows) {
+		err := rows.Close()
+		if err != nil {
+			log.Printf("error closing row: %v", err)
+		}
+	}(rows)
+
+	alerts := []types.Alert{}
+	for rows.Next() {
+		alert, err := scanAlert(rows)
+		if err != nil {
+			return nil, utils.NewHTTPError(http.StatusInternalServerError, fmt.Sprintf("error scanning alert: %v", err))
+		}
+		alerts = append(alerts, alert)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, utils.NewHTTPError(http.StatusInternalServerError, fmt.Sprintf("error iterating alert rows: %v", err))
+	}
+
+	return alerts, nil
+}
+
+// PendingDeliveries returns the alert deliveries not yet sent that are due by now and have been tried fewer than
+// maxAttempts times, oldest alert first.
+func (man *Manager) PendingDeliveries(now time.Time, maxAttempts int) ([]types.AlertDelivery, *types.HTTPError) {
+	const query = "SELECT " + alertColumns + `, d.channel, d.attempts, d.last_error, d.next_attempt_at
+		FROM alert_deliveries d JOIN alerts a ON a.id = d.alert_id
+		WHERE d.delivered_at IS NULL AND d.next_attempt_at <= ? AND d.attempts < ?
+		ORDER BY a.created_at, a.id, d.channel`
+	rows, err := man.db.Query(query, now.UTC().Format(timestampFormat), maxAttempts)
+	if err != nil {
+		return nil, utils.NewHTTPError(http.StatusInternalServerError, fmt.Sprintf("error fetching pending alert deliveries: %v", err))
+	}
+	defer func(rows *sql.Rows) {
+		err := rows.Close()
+		if err != nil {
+			log.Printf("error closing row: %v", err)
+		}
+	}(rows)
+
+	deliveries := []types.AlertDelivery{}
+	for rows.Next() {
+		var delivery types.AlertDelivery
+		var nextAttemptAt string
+		if delivery.Alert, err = scanAlert(rows, &delivery.Channel, &delivery.Attempts, &delivery.LastError, &nextAttemptAt); err != nil {
+			return nil, utils.NewHTTPError(http.StatusInternalServerError, fmt.Sprintf("error scanning alert delivery: %v", err))
+		}
+		if delivery.NextAttemptAt, err = parseTimestamp(nextAttemptAt); err != nil {
+			return nil, utils.NewHTTPError(http.StatusInternalServerError, fmt.Sprintf("error parsing alert delivery next_attempt_at: %v", err))
+		}
+		deliveries = append(deliveries, delivery)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, utils.NewHTTPError(http.StatusInternalServerError, fmt.Sprintf("error iterating alert delivery rows: %v", err))
+	}
+
+	return deliveries, nil
+}
+
+// MarkDelivered records that an alert was sent through channel.
+func (man *Manager) MarkDelivered(alertID int64, channel string) *types.HTTPError {
+	const query = `UPDATE alert_deliveries SET attempts = attempts + 1, last_error = '', delivered_at = ?
+		WHERE alert_id = ? AND channel = ?`
+	if _, err := man.db.Exec(query, time.Now().UTC().Format(timestampFormat), alertID, channel); err != nil {
+		return utils.NewHTTPError(http.StatusInternalServerError, fmt.Sprintf("error marking alert %v delivered through %v: %v", alertID, channel, err))
+	}
+	return nil
+}
+
+// MarkDeliveryFailed records a failed attempt to send an alert through channel, to be retried from retryAt.
+func (man *Manager) MarkDeliveryFailed(alertID int64, channel, message string, retryAt time.Time) *types.HTTPError {
+	const query = `UPDATE alert_deliveries SET attempts = attempts + 1, last_error = ?, next_attempt_at = ?
+		WHERE alert_id = ? AND channel = ?`
+	// last_error holds 512 characters in MySQL; cut longer errors without splitting a character.
+	if len(message) > 512 {
+		message = strings.ToValidUTF8(message[:512], "")
+	}
+	if _, err := man.db.Exec(query, message, retryAt.UTC().Format(timestampFormat), alertID, channel); err != nil {
+		return utils.NewHTTPError(http.StatusInternalServerError, fmt.Sprintf("error recording failed delivery of alert %v through %v: %v", alertID, channel, err))
+	}
+	return nil
+}
+
+// AddToInbox delivers a recorded alert to the in-app inbox, unread.
+func (man *Manager) AddToInbox(alertID int64) *types.HTTPError {
+	query := "INSERT INTO alert_inbox (alert_id, read_at) VALUES (?, NULL) " + man.dialect.upsert([]string{"alert_id"}, "read_at")
+	if _, err := man.db.Exec(query, alertID); err != nil {
+		return utils.NewHTTPError(http.StatusInternalServerError, fmt.Sprintf("error adding alert %v to inbox: %v", alertID, err))
+	}
+	return nil
+}
+
+// ListInbox returns the alerts in the in-app inbox, newest first. unreadOnly leaves out those already read.
+func (man *Manager) ListInbox(unreadOnly bool) ([]types.InboxMessage, *types.HTTPError) {
+	query := "SELECT " + alertColumns + ", i.read_at FROM alert_inbox i JOIN alerts a ON a.id = i.alert_id"
+	if unreadOnly {
+		query += " WHERE i.read_at IS NULL"
+	}
+	query += " ORDER BY a.created_at DESC, a.id DESC"
+	rows, err := man.db.Query(query)
+	if err != nil {
+		return nil, utils.NewHTTPError(http.StatusInternalServerError, fmt.Sprintf("error fetching inbox: %v", err))
+	}
+	defer func(rows *sql.Rows) {
+		err := rows.Close()
+		if err != nil {
+			log.Printf("error closing row: %v", err)
+		}
+	}(rows)
+
+	messages := []types.InboxMessage{}
+	for rows.Next() {
+		message, err := scanInboxMessage(rows)
+		if err != nil {
+			return nil, utils.NewHTTPError(http.StatusInternalServerError, fmt.Sprintf("error scanning inbox message: %v", err))
+		}
+		messages = append(messages, message)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, utils.NewHTTPError(http.StatusInternalServerError, fmt.Sprintf("error iterating inbox rows: %v", err))
+	}
+
+	return messages, nil
+}
+
+// MarkInboxRead marks the inbox message for an alert as read, if it isn't already, and returns it.
+func (man *Manager) MarkInboxRead(alertID int64) (*types.InboxMessage, *types.HTTPError) {
+	const updateQuery = `UPDATE alert_inbox SET read_at = ? WHERE alert_id = ? AND read_at IS NULL`
+	const fetchQuery = "SELECT " + alertColumns + `, i.read_at FROM alert_inbox i JOIN alerts a ON a.id = i.alert_id
+		WHERE i.alert_id = ?`
+	if _, err := man.db.Exec(updateQuery, time.Now().UTC().Format(timestampFormat), alertID); err != nil {
+		return nil, utils.NewHTTPError(http.StatusInternalServerError, fmt.Sprintf("error marking alert %v read: %v", alertID, err))
+	}
+	message, err := scanInboxMessage(man.db.QueryRow(fetchQuery, alertID))
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, utils.NewHTTPError(http.StatusNotFound, fmt.Sprintf("alert %v is not in the inbox", alertID))
+	}
+	if err != nil {
+		return nil, utils.NewHTTPError(http.StatusInternalServerError, fmt.Sprintf("error fetching inbox message: %v", err))
+	}
+	return &message, nil
+}
+
+func scanInboxMessage(row rowScanner) (types.InboxMessage, error) {
+	var message types.InboxMessage
+	var readAt sql.NullString
+	var err error
+	if message.Alert, err = scanAlert(row, &readAt); err != nil {
+		return message, err
+	}
+	if readAt.Valid {
+		read, err := parseTimestamp(readAt.String)
+		if err != nil {
+			return message, fmt.Errorf("parsing inbox read_at: %w", err)
+		}
+		message.ReadAt = &read
+	}
+	return message, nil
+}
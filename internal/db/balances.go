@@ -0,0 +1,193 @@
+package db
+
+import (
+	"database/sql"
+	"fmt"
+	"github.com/Seymour-creates/budget-server/internal/types"
+	"github.com/Seymour-creates/budget-server/internal/utils"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+)
+
+const balanceColumns = `b.account_id, b.date, b.current, b.available, b.credit_limit, b.currency`
+
+func scanBalance(row rowScanner) (types.Balance, error) {
+	var balance types.Balance
+	var date string
+	var available, limit sql.NullFloat64
+	if err := row.Scan(&balance.AccountID, &date, &balance.Current, &available, &limit, &balance.Currency); err != nil {
+		return balance, err
+	}
+	if available.Valid {
+		balance.Available = &available.Float64
+	}
+	if limit.Valid {
+		balance.Limit = &limit.Float64
+	}
+	var err error
+	balance.Date, err = time.Parse(dateFormat, date)
+	if err != nil {
+		return balance, fmt.Errorf("parsing balance date: %w", err)
+	}
+	return balance, nil
+}
+
+// UpsertBalances stores balance snapshots, replacing any already recorded for the same account and day so the
+// last balance seen each day is the one kept.
+func (man *Manager) UpsertBalances(balances []types.Balance) *types.HTTPError {
+	query := `INSERT INTO account_balances (account_id, date, current, available, credit_limit, currency)
+		VALUES (?, ?, ?, ?, ?, ?) ` +
+		man.dialect.upsert([]string{"account_id", "date"}, "current", "available", "credit_limit", "currency")
+
+	tx, err := man.db.Begin()
+	if err != nil {
+		return utils.NewHTTPError(http.StatusInternalServerError, fmt.Sprintf("error starting balances transaction: %v", err))
+	}
+	defer rollback(tx)
+
+	for _, balance := range balances {
+		_, err := tx.Exec(query, balance.AccountID, balance.Date.Format(dateFormat), balance.Current, balance.Available,
+			balance.Limit, balance.Currency)
+		if err != nil {
+			return utils.NewHTTPError(http.StatusInternalServerError, fmt.Sprintf("error saving balance for account %v: %v", balance.AccountID, err))
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return utils.NewHTTPError(http.StatusInternalServerError, fmt.Sprintf("error committing balances: %v", err))
+	}
+	return nil
+}
+
+// ListBalances returns the balance history of an account between from and to inclusive, oldest first. Zero
+// bounds leave that end open.
+func (man *Manager) ListBalances(accountID string, from, to time.Time) ([]types.Balance, *types.HTTPError) {
+	conditions := []string{"b.account_id = ?"}
+	args := []interface{}{accountID}
+	if !from.IsZero() {
+		conditions = append(conditions, "b.date >= ?")
+		args = append(args, from.Format(dateFormat))
+	}
+	if !to.IsZero() {
+		conditions = append(conditions, "b.date <= ?")
+		args = append(args, to.Format(dateFormat))
+	}
+	query := "SELECT " + balanceColumns + " FROM account_balances b WHERE " + strings.Join(conditions, " AND ") +
+		" ORDER BY b.date"
+	return man.queryBalances(query, args...)
+}
+
+func (man *Manager) queryBalances(query string, args ...interface{}) ([]types.Balance, *types.HTTPError) {
+	rows, err := man.db.Query(query, args...)
+	if err != nil {
+		return nil, utils.NewHTTPError(http.StatusInternalServerError, fmt.Sprintf("error fetching balances: %v", err))
+	}
+	defer func(rows *sql.Rows) {
+		err := rows.Close()
+		if err != nil {
+			log.Printf("error closing row: %v", err)
+		}
+	}(rows)
+
+	balances := []types.Balance{}
+	for rows.Next() {
+		balance, err := scanBalance(rows)
+		if err != nil {
+			return nil, utils.NewHTTPError(http.StatusInternalServerError, fmt.Sprintf("error scanning balance: %v", err))
+		}
+		balances = append(balances, balance)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, utils.NewHTTPError(http.StatusInternalServerError, fmt.Sprintf("error iterating balance rows: %v", err))
+	}
+
+	return balances, nil
+}
+
+// ListAccountBalances returns every account that isn't hidden with its most recent balance.
+func (man *Manager) ListAccountBalances() ([]types.AccountBalance, *types.HTTPError) {
+	accounts, httpErr := man.ListAccounts(false)
+	if httpErr != nil {
+		return nil, httpErr
+	}
+	const latestQuery = "SELECT " + balanceColumns + ` FROM account_balances b
+		WHERE b.date = (SELECT MAX(latest.date) FROM account_balances latest WHERE latest.account_id = b.account_id)`
+	latest, httpErr := man.queryBalances(latestQuery)
+	if httpErr != nil {
+		return nil, httpErr
+	}
+	byAccount := make(map[string]types.Balance, len(latest))
+	for _, balance := range latest {
+		byAccount[balance.AccountID] = balance
+	}
+
+	balances := make([]types.AccountBalance, len(accounts))
+	for i, account := range accounts {
+		balances[i].Account = account
+		if balance, ok := byAccount[account.AccountID]; ok {
+			balances[i].Balance = &balance
+		}
+	}
+	return balances, nil
+}
+
+// GetNetWorth reports net worth at the end of each day of period, across every account that isn't hidden. Each
+// account counts at its latest balance on or before the day, and not at all before its first one or after the
+// day it closed.
+func (man *Manager) GetNetWorth(period types.Period) (*types.NetWorthReport, *types.HTTPError) {
+	accounts, httpErr := man.ListAccounts(false)
+	if httpErr != nil {
+		return nil, httpErr
+	}
+	liability := make(map[string]bool, len(accounts))
+	closed := map[string]time.Time{}
+	for _, account := range accounts {
+		liability[account.AccountID] = account.IsLiability()
+		if account.ClosedAt != nil {
+			closed[account.AccountID] = closingDay(*account.ClosedAt, period.Start.Location())
+		}
+	}
+	const historyQuery = "SELECT " + balanceColumns + " FROM account_balances b WHERE b.date <= ? ORDER BY b.date"
+	history, httpErr := man.queryBalances(historyQuery, period.End.Format(dateFormat))
+	if httpErr != nil {
+		return nil, httpErr
+	}
+
+	report := &types.NetWorthReport{Period: period, Points: []types.NetWorthPoint{}}
+	current := map[string]float64{}
+	next := 0
+	for day := period.Start; !day.After(period.End); day = day.AddDate(0, 0, 1) {
+		// Balance dates come back from the db as UTC midnight; compare them as calendar days in the period's zone.
+		for ; next < len(history); next++ {
+			date := history[next].Date
+			if time.Date(date.Year(), date.Month(), date.Day(), 0, 0, 0, 0, day.Location()).After(day) {
+				break
+			}
+			current[history[next].AccountID] = history[next].Current
+		}
+
+		point := types.NetWorthPoint{Date: day}
+		for accountID, balance := range current {
+			isLiability, listed := liability[accountID]
+			closedOn, isClosed := closed[accountID]
+			switch {
+			case !listed, isClosed && day.After(closedOn):
+			case isLiability:
+				point.Liabilities += balance
+			default:
+				point.Assets += balance
+			}
+		}
+		point.NetWorth = point.Assets - point.Liabilities
+		report.Points = append(report.Points, point)
+	}
+	return report, nil
+}
+
+// closingDay is the calendar day in loc on which an account closed at closedAt.
+func closingDay(closedAt time.Time, loc *time.Location) time.Time {
+	closedAt = closedAt.In(loc)
+	return time.Date(closedAt.Year(), closedAt.Month(), closedAt.Day(), 0, 0, 0, 0, loc)
+}
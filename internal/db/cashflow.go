@@ -0,0 +1,106 @@
+package db
+
+import (
+	"github.com/Seymour-creates/budget-server/internal/types"
+	"github.com/Seymour-creates/budget-server/internal/utils"
+	"math"
+	"sort"
+	"time"
+)
+
+// ProjectCashFlow simulates the cash held in open depository accounts that aren't hidden at the end of each day
+// of period, starting from their latest balances. Each day applies the bills and pay expected from active
+// recurring streams paid in or out of cash, and the minimum payments due on liability accounts. Streams on
+// credit cards and loans are left out, since they are paid off through those minimum payments.
+func (man *Manager) ProjectCashFlow(period types.Period) (*types.CashFlowProjection, *types.HTTPError) {
+	accounts, httpErr := man.ListAccountBalances()
+	if httpErr != nil {
+		return nil, httpErr
+	}
+	projection := &types.CashFlowProjection{Period: period, Events: []types.Forecast{}, Days: []types.CashFlowDay{}}
+	cash := map[string]bool{"": true}
+	for _, account := range accounts {
+		if account.Type != types.AccountTypeDepository || account.ClosedAt != nil {
+			continue
+		}
+		cash[account.AccountID] = true
+		if account.Balance != nil {
+			projection.StartingBalance += account.Balance.Current
+		}
+	}
+
+	streams, httpErr := man.ListRecurringStreams(false, period.Start)
+	if httpErr != nil {
+		return nil, httpErr
+	}
+	for _, stream := range streams {
+		if cash[stream.AccountID] {
+			projection.Events = append(projection.Events, streamEvents(stream, period)...)
+		}
+	}
+	payments, httpErr := man.minimumPayments(period, false)
+	if httpErr != nil {
+		return nil, httpErr
+	}
+	projection.Events = append(projection.Events, payments...)
+	sort.SliceStable(projection.Events, func(i, j int) bool {
+		return projection.Events[i].DueDate.Before(*projection.Events[j].DueDate)
+	})
+
+	balance := projection.StartingBalance
+	next := 0
+	for day := period.Start; !day.After(period.End); day = day.AddDate(0, 0, 1) {
+		point := types.CashFlowDay{Date: day}
+		for ; next < len(projection.Events) && !projection.Events[next].DueDate.After(day); next++ {
+			if amount := projection.Events[next].Amount; amount < 0 {
+				point.Inflow -= amount
+			} else {
+				point.Outflow += amount
+			}
+		}
+		balance += point.Inflow - point.Outflow
+		point.Balance = math.Round(balance*100) / 100
+		if len(projection.Days) == 0 || point.Balance < projection.LowestBalance {
+			projection.LowestBalance = point.Balance
+			projection.LowestDate = day
+		}
+		projection.Days = append(projection.Days, point)
+	}
+	return projection, nil
+}
+
+// streamEvents lists the dates within period on which stream's next transactions are expected, each for its
+// latest amount. Streams of unknown frequency can't be placed and have none.
+func streamEvents(stream types.RecurringStream, period types.Period) []types.Forecast {
+	amount := stream.LastAmount
+	if stream.Direction == types.StreamInflow {
+		amount = -amount
+	}
+	// Dates come back from the db as UTC midnight; compare them as calendar days in the period's zone.
+	last := time.Date(stream.LastDate.Year(), stream.LastDate.Month(), stream.LastDate.Day(), 0, 0, 0, 0, period.Start.Location())
+	description := stream.Merchant
+	if description == "" {
+		description = stream.Description
+	}
+	var events []types.Forecast
+	for n := 1; ; n++ {
+		due, ok := utils.Occurrence(stream.Frequency, last, n)
+		if !ok || due.After(period.End) {
+			break
+		}
+		if due.Before(period.Start) {
+			continue
+		}
+		events = append(events, types.Forecast{
+			Period:      utils.StartOfMonth(due),
+			Amount:      amount,
+			Category:    stream.Category,
+			Source:      types.ForecastSourceRecurring,
+			AccountID:   stream.AccountID,
+			StreamID:    stream.StreamID,
+			Description: description,
+			DueDate:     &due,
+		})
+	}
+	return events
+}
@@ -0,0 +1,347 @@
+package db
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"github.com/Seymour-creates/budget-server/internal/types"
+	"github.com/Seymour-creates/budget-server/internal/utils"
+	"log"
+	"net/http"
+	"strings"
+)
+
+const categoryColumns = `c.id, c.slug, c.name, COALESCE(p.slug, ''), c.archived, c.color`
+
+const categoryFrom = `categories c LEFT JOIN categories p ON p.id = c.parent_id`
+
+// queryer is satisfied by both *sql.DB and *sql.Tx.
+type queryer interface {
+	QueryRow(query string, args ...interface{}) *sql.Row
+}
+
+// categoryID resolves a category slug, returning a 400 for slugs that don't exist so callers can pass the
+// error straight back for bad input.
+func categoryID(q queryer, slug string) (int64, *types.HTTPError) {
+	var id int64
+	err := q.QueryRow(`SELECT id FROM categories WHERE slug = ?`, slug).Scan(&id)
+	if errors.Is(err, sql.ErrNoRows) {
+		return 0, utils.NewHTTPError(http.StatusBadRequest, fmt.Sprintf("unknown category %q", slug))
+	}
+	if err != nil {
+		return 0, utils.NewHTTPError(http.StatusInternalServerError, fmt.Sprintf("error looking up category: %v", err))
+	}
+	return id, nil
+}
+
+func scanCategory(row rowScanner) (types.Category, error) {
+	var category types.Category
+	err := row.Scan(&category.ID, &category.Slug, &category.Name, &category.Parent, &category.Archived, &category.Color)
+	return category, err
+}
+
+// ListCategories returns categories ordered by slug, leaving out archived ones unless includeArchived is set.
+func (man *Manager) ListCategories(includeArchived bool) ([]types.Category, *types.HTTPError) {
+	query := "SELECT " + categoryColumns + " FROM " + categoryFrom
+	if !includeArchived {
+		query += " WHERE c.archived = FALSE"
+	}
+	query += " ORDER BY c.slug"
+
+	rows, err := man.db.Query(query)
+	if err != nil {
+		return nil, utils.NewHTTPError(http.StatusInternalServerError, fmt.Sprintf("error fetching categories: %v", err))
+	}
+	defer func(rows *sql.Rows) {
+		err := rows.Close()
+		if err != nil {
+			log.Printf("error closing row: %v", err)
+		}
+	}(rows)
+
+	categories := []types.Category{}
+	for rows.Next() {
+		category, err := scanCategory(rows)
+		if err != nil {
+			return nil, utils.NewHTTPError(http.StatusInternalServerError, fmt.Sprintf("error scanning category: %v", err))
+		}
+		categories = append(categories, category)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, utils.NewHTTPError(http.StatusInternalServerError, fmt.Sprintf("error iterating category rows: %v", err))
+	}
+
+	return categories, nil
+}
+
+// FetchCategory returns a single category, or a 404 if there is none with that slug.
+func (man *Manager) FetchCategory(slug string) (*types.Category, *types.HTTPError) {
+	query := "SELECT " + categoryColumns + " FROM " + categoryFrom + " WHERE c.slug = ?"
+	category, err := scanCategory(man.db.QueryRow(query, slug))
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, utils.NewHTTPError(http.StatusNotFound, fmt.Sprintf("category %q not found", slug))
+	}
+	if err != nil {
+		return nil, utils.NewHTTPError(http.StatusInternalServerError, fmt.Sprintf("error fetching category: %v", err))
+	}
+	return &category, nil
+}
+
+// InsertCategory creates a category and returns it as stored.
+func (man *Manager) InsertCategory(category types.Category) (*types.Category, *types.HTTPError) {
+	if err := validateSlug(category.Slug); err != nil {
+		return nil, err
+	}
+	if category.Name == "" {
+		category.Name = category.Slug
+	}
+	var parentID sql.NullInt64
+	if category.Parent != "" {
+		id, err := categoryID(man.db, category.Parent)
+		if err != nil {
+			return nil, err
+		}
+		parentID = sql.NullInt64{Int64: id, Valid: true}
+	}
+
+	const query = `INSERT INTO categories (slug, name, parent_id, archived, color) VALUES (?, ?, ?, ?, ?)`
+	if _, err := man.db.Exec(query, category.Slug, category.Name, parentID, category.Archived, category.Color); err != nil {
+		if existing, _ := man.FetchCategory(category.Slug); existing != nil {
+			return nil, utils.NewHTTPError(http.StatusConflict, fmt.Sprintf("category %q already exists", category.Slug))
+		}
+		return nil, utils.NewHTTPError(http.StatusInternalServerError, fmt.Sprintf("error creating category: %v", err))
+	}
+	return man.FetchCategory(category.Slug)
+}
+
+// UpdateCategory applies patch to a category and returns the updated row. Expenses and forecasts reference
+// categories by id, so a new slug is picked up by existing rows without rewriting them.
+func (man *Manager) UpdateCategory(slug string, patch types.CategoryPatch) (*types.Category, *types.HTTPError) {
+	category, httpErr := man.FetchCategory(slug)
+	if httpErr != nil {
+		return nil, httpErr
+	}
+
+	var assignments []string
+	var args []interface{}
+	if patch.Slug != nil {
+		if err := validateSlug(*patch.Slug); err != nil {
+			return nil, err
+		}
+		assignments = append(assignments, "slug = ?")
+		args = append(args, *patch.Slug)
+	}
+	if patch.Name != nil {
+		assignments = append(assignments, "name = ?")
+		args = append(args, *patch.Name)
+	}
+	if patch.Parent != nil {
+		var parentID sql.NullInt64
+		if *patch.Parent != "" {
+			id, err := categoryID(man.db, *patch.Parent)
+			if err != nil {
+				return nil, err
+			}
+			if id == category.ID {
+				return nil, utils.NewHTTPError(http.StatusBadRequest, "a category cannot be its own parent")
+			}
+			descendant, lookupErr := isAncestor(man.db, category.ID, id)
+			if lookupErr != nil {
+				return nil, utils.NewHTTPError(http.StatusInternalServerError, fmt.Sprintf("error checking category parents: %v", lookupErr))
+			}
+			if descendant {
+				return nil, utils.NewHTTPError(http.StatusBadRequest, fmt.Sprintf("%q is a subcategory of %q and cannot be its parent", *patch.Parent, slug))
+			}
+			parentID = sql.NullInt64{Int64: id, Valid: true}
+		}
+		assignments = append(assignments, "parent_id = ?")
+		args = append(args, parentID)
+	}
+	if patch.Archived != nil {
+		assignments = append(assignments, "archived = ?")
+		args = append(args, *patch.Archived)
+	}
+	if patch.Color != nil {
+		assignments = append(assignments, "color = ?")
+		args = append(args, *patch.Color)
+	}
+	if len(assignments) > 0 {
+		query := "UPDATE categories SET " + strings.Join(assignments, ", ") + " WHERE id = ?"
+		if _, err := man.db.Exec(query, append(args, category.ID)...); err != nil {
+			if patch.Slug != nil && *patch.Slug != slug {
+				if existing, _ := man.FetchCategory(*patch.Slug); existing != nil {
+					return nil, utils.NewHTTPError(http.StatusConflict, fmt.Sprintf("category %q already exists", *patch.Slug))
+				}
+			}
+			return nil, utils.NewHTTPError(http.StatusInternalServerError, fmt.Sprintf("error updating category: %v", err))
+		}
+	}
+
+	if patch.Slug != nil {
+		slug = *patch.Slug
+	}
+	return man.FetchCategory(slug)
+}
+
+// DeleteCategory removes a category that nothing refers to. Categories still in use must be merged into
+// another or archived instead.
+func (man *Manager) DeleteCategory(slug string) *types.HTTPError {
+	category, httpErr := man.FetchCategory(slug)
+	if httpErr != nil {
+		return httpErr
+	}
+
+	const usageQuery = `SELECT
+		(SELECT COUNT(*) FROM expenses WHERE category_id = ?) +
+		(SELECT COUNT(*) FROM forecast WHERE category_id = ?) +
+		(SELECT COUNT(*) FROM rules WHERE category_id = ?) +
+		(SELECT COUNT(*) FROM category_mappings WHERE category_id = ?) +
+		(SELECT COUNT(*) FROM categories WHERE parent_id = ?)`
+	var uses int
+	err := man.db.QueryRow(usageQuery, category.ID, category.ID, category.ID, category.ID, category.ID).Scan(&uses)
+	if err != nil {
+		return utils.NewHTTPError(http.StatusInternalServerError, fmt.Sprintf("error checking category usage: %v", err))
+	}
+	if uses > 0 {
+		return utils.NewHTTPError(http.StatusConflict, fmt.Sprintf("category %q is in use; merge or archive it instead", slug))
+	}
+
+	if _, err := man.db.Exec(`DELETE FROM categories WHERE id = ?`, category.ID); err != nil {
+		return utils.NewHTTPError(http.StatusInternalServerError, fmt.Sprintf("error deleting category: %v", err))
+	}
+	return nil
+}
+
+// MergeCategories moves every expense, forecast, rule, Plaid mapping and child category from one category into
+// another, then deletes the emptied category. Forecasts planned for the same month in both are added together.
+// Merging a category into one of its subcategories moves the subcategory up to the merged category's parent.
+func (man *Manager) MergeCategories(from, into string) (*types.Category, *types.HTTPError) {
+	if from == into {
+		return nil, utils.NewHTTPError(http.StatusBadRequest, "cannot merge a category into itself")
+	}
+
+	tx, err := man.db.Begin()
+	if err != nil {
+		return nil, utils.NewHTTPError(http.StatusInternalServerError, fmt.Sprintf("error starting merge transaction: %v", err))
+	}
+	defer rollback(tx)
+
+	fromID, httpErr := categoryID(tx, from)
+	if httpErr != nil {
+		return nil, httpErr
+	}
+	intoID, httpErr := categoryID(tx, into)
+	if httpErr != nil {
+		return nil, httpErr
+	}
+
+	if err := mergeForecasts(tx, fromID, intoID); err != nil {
+		return nil, utils.NewHTTPError(http.StatusInternalServerError, fmt.Sprintf("error merging forecasts: %v", err))
+	}
+	statements := []string{
+		`UPDATE expenses SET category_id = ? WHERE category_id = ?`,
+		`UPDATE rules SET category_id = ? WHERE category_id = ?`,
+		`UPDATE category_mappings SET category_id = ? WHERE category_id = ?`,
+		`UPDATE category_corrections SET from_category_id = ? WHERE from_category_id = ?`,
+		`UPDATE category_corrections SET to_category_id = ? WHERE to_category_id = ?`,
+	}
+	for _, statement := range statements {
+		if _, err := tx.Exec(statement, intoID, fromID); err != nil {
+			return nil, utils.NewHTTPError(http.StatusInternalServerError, fmt.Sprintf("error re-pointing category: %v", err))
+		}
+	}
+	// A subcategory merged into its ancestor's place moves up to where the ancestor was, so that the ancestor's
+	// other children, which now hang under it, don't end up parenting it in turn.
+	descendant, err := isAncestor(tx, fromID, intoID)
+	if err != nil {
+		return nil, utils.NewHTTPError(http.StatusInternalServerError, fmt.Sprintf("error checking category parents: %v", err))
+	}
+	if descendant {
+		var parentID sql.NullInt64
+		if err := tx.QueryRow(`SELECT parent_id FROM categories WHERE id = ?`, fromID).Scan(&parentID); err != nil {
+			return nil, utils.NewHTTPError(http.StatusInternalServerError, fmt.Sprintf("error fetching category parent: %v", err))
+		}
+		if _, err := tx.Exec(`UPDATE categories SET parent_id = ? WHERE id = ?`, parentID, intoID); err != nil {
+			return nil, utils.NewHTTPError(http.StatusInternalServerError, fmt.Sprintf("error re-pointing category: %v", err))
+		}
+	}
+	if _, err := tx.Exec(`UPDATE categories SET parent_id = ? WHERE parent_id = ?`, intoID, fromID); err != nil {
+		return nil, utils.NewHTTPError(http.StatusInternalServerError, fmt.Sprintf("error re-pointing child categories: %v", err))
+	}
+	if _, err := tx.Exec(`DELETE FROM categories WHERE id = ?`, fromID); err != nil {
+		return nil, utils.NewHTTPError(http.StatusInternalServerError, fmt.Sprintf("error deleting merged category: %v", err))
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, utils.NewHTTPError(http.StatusInternalServerError, fmt.Sprintf("error committing merge: %v", err))
+	}
+	return man.FetchCategory(into)
+}
+
+// isAncestor reports whether ancestorID is among id's parents, grandparents and so on.
+func isAncestor(q queryer, ancestorID, id int64) (bool, error) {
+	seen := map[int64]bool{id: true}
+	for {
+		var parentID sql.NullInt64
+		if err := q.QueryRow(`SELECT parent_id FROM categories WHERE id = ?`, id).Scan(&parentID); err != nil {
+			return false, err
+		}
+		if !parentID.Valid || seen[parentID.Int64] {
+			return false, nil
+		}
+		if parentID.Int64 == ancestorID {
+			return true, nil
+		}
+		seen[parentID.Int64] = true
+		id = parentID.Int64
+	}
+}
+
+// mergeForecasts re-points fromID's forecasts to intoID, adding amounts where both planned the same month.
+func mergeForecasts(tx *sql.Tx, fromID, intoID int64) error {
+	type forecastRow struct {
+		id     int64
+		period string
+		amount float64
+	}
+	rows, err := tx.Query(`SELECT id, period, amount FROM forecast WHERE category_id = ?`, fromID)
+	if err != nil {
+		return err
+	}
+	var forecasts []forecastRow
+	for rows.Next() {
+		var f forecastRow
+		if err := rows.Scan(&f.id, &f.period, &f.amount); err != nil {
+			_ = rows.Close()
+			return err
+		}
+		forecasts = append(forecasts, f)
+	}
+	if err := rows.Close(); err != nil {
+		return err
+	}
+
+	for _, f := range forecasts {
+		var existingID int64
+		err := tx.QueryRow(`SELECT id FROM forecast WHERE period = ? AND category_id = ?`, f.period, intoID).Scan(&existingID)
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			_, err = tx.Exec(`UPDATE forecast SET category_id = ? WHERE id = ?`, intoID, f.id)
+		case err == nil:
+			if _, err = tx.Exec(`UPDATE forecast SET amount = amount + ? WHERE id = ?`, f.amount, existingID); err == nil {
+				_, err = tx.Exec(`DELETE FROM forecast WHERE id = ?`, f.id)
+			}
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func validateSlug(slug string) *types.HTTPError {
+	if slug == "" || strings.ContainsAny(slug, "/ ") {
+		return utils.NewHTTPError(http.StatusBadRequest, fmt.Sprintf("invalid category slug %q: must be non-empty with no spaces or slashes", slug))
+	}
+	return nil
+}
@@ -0,0 +1,68 @@
+package db_test
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/Seymour-creates/budget-server/internal/db"
+	"github.com/Seymour-creates/budget-server/internal/testutil"
+	"github.com/Seymour-creates/budget-server/internal/types"
+)
+
+// newCategoryTree stores food > dining > {coffee, bars}.
+func newCategoryTree(t *testing.T) *db.Manager {
+	t.Helper()
+	man := testutil.NewDB(t)
+	for _, category := range []types.Category{
+		{Slug: "food", Name: "Food"},
+		{Slug: "dining", Name: "Dining", Parent: "food"},
+		{Slug: "coffee", Name: "Coffee", Parent: "dining"},
+		{Slug: "bars", Name: "Bars", Parent: "dining"},
+	} {
+		_, httpErr := man.InsertCategory(category)
+		mustNot(t, httpErr)
+	}
+	return man
+}
+
+func assertParents(t *testing.T, man *db.Manager, want map[string]string) {
+	t.Helper()
+	for slug, parent := range want {
+		category, httpErr := man.FetchCategory(slug)
+		mustNot(t, httpErr)
+		if category.Parent != parent {
+			t.Errorf("%v parent = %q, want %q", slug, category.Parent, parent)
+		}
+	}
+}
+
+func TestMergeCategoryIntoItsChild(t *testing.T) {
+	man := newCategoryTree(t)
+	_, httpErr := man.MergeCategories("dining", "coffee")
+	mustNot(t, httpErr)
+	assertParents(t, man, map[string]string{"coffee": "food", "bars": "coffee"})
+}
+
+func TestMergeCategoryIntoItsGrandchild(t *testing.T) {
+	man := newCategoryTree(t)
+	_, httpErr := man.MergeCategories("food", "coffee")
+	mustNot(t, httpErr)
+	assertParents(t, man, map[string]string{"coffee": "", "dining": "coffee", "bars": "dining"})
+}
+
+func TestUpdateCategoryRejectsCycles(t *testing.T) {
+	man := newCategoryTree(t)
+	for slug, parent := range map[string]string{"food": "coffee", "dining": "bars", "coffee": "coffee"} {
+		parent := parent
+		_, httpErr := man.UpdateCategory(slug, types.CategoryPatch{Parent: &parent})
+		if httpErr == nil || httpErr.StatusCode != http.StatusBadRequest {
+			t.Errorf("moving %v under %v: error %v, want a 400", slug, parent, httpErr)
+		}
+	}
+	assertParents(t, man, map[string]string{"food": "", "dining": "food", "coffee": "dining", "bars": "dining"})
+
+	bars := "food"
+	_, httpErr := man.UpdateCategory("bars", types.CategoryPatch{Parent: &bars})
+	mustNot(t, httpErr)
+	assertParents(t, man, map[string]string{"bars": "food"})
+}
@@ -0,0 +1,99 @@
+package db
+
+import (
+	"database/sql"
+	"fmt"
+	"github.com/Seymour-creates/budget-server/internal/types"
+	"github.com/Seymour-creates/budget-server/internal/utils"
+	"log"
+	"net/http"
+	"strings"
+)
+
+const categoryMappingColumns = `m.id, m.pfc_primary, m.pfc_detailed, m.min_confidence, c.slug`
+
+const categoryMappingFrom = `category_mappings m JOIN categories c ON c.id = m.category_id`
+
+func scanCategoryMapping(row rowScanner) (types.CategoryMapping, error) {
+	var mapping types.CategoryMapping
+	err := row.Scan(&mapping.ID, &mapping.Primary, &mapping.Detailed, &mapping.MinConfidence, &mapping.Category)
+	return mapping, err
+}
+
+// ListCategoryMappings returns every Plaid personal finance category mapping, ordered by primary then detailed
+// category.
+func (man *Manager) ListCategoryMappings() ([]types.CategoryMapping, *types.HTTPError) {
+	query := "SELECT " + categoryMappingColumns + " FROM " + categoryMappingFrom + " ORDER BY m.pfc_primary, m.pfc_detailed"
+	rows, err := man.db.Query(query)
+	if err != nil {
+		return nil, utils.NewHTTPError(http.StatusInternalServerError, fmt.Sprintf("error fetching category mappings: %v", err))
+	}
+	defer func(rows *sql.Rows) {
+		err := rows.Close()
+		if err != nil {
+			log.Printf("error closing row: %v", err)
+		}
+	}(rows)
+
+	mappings := []types.CategoryMapping{}
+	for rows.Next() {
+		mapping, err := scanCategoryMapping(rows)
+		if err != nil {
+			return nil, utils.NewHTTPError(http.StatusInternalServerError, fmt.Sprintf("error scanning category mapping: %v", err))
+		}
+		mappings = append(mappings, mapping)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, utils.NewHTTPError(http.StatusInternalServerError, fmt.Sprintf("error iterating category mapping rows: %v", err))
+	}
+
+	return mappings, nil
+}
+
+// UpsertCategoryMapping stores mapping, replacing any existing mapping for the same primary and detailed
+// category, and returns it as stored.
+func (man *Manager) UpsertCategoryMapping(mapping types.CategoryMapping) (*types.CategoryMapping, *types.HTTPError) {
+	mapping.Primary = strings.ToUpper(mapping.Primary)
+	mapping.Detailed = strings.ToUpper(mapping.Detailed)
+	mapping.MinConfidence = strings.ToUpper(mapping.MinConfidence)
+	if mapping.Primary == "" {
+		return nil, utils.NewHTTPError(http.StatusBadRequest, "category mapping must have a primary category")
+	}
+	switch mapping.MinConfidence {
+	case "", "LOW", "MEDIUM", "HIGH", "VERY_HIGH":
+	default:
+		return nil, utils.NewHTTPError(http.StatusBadRequest, fmt.Sprintf("unknown confidence level %q: must be LOW, MEDIUM, HIGH or VERY_HIGH", mapping.MinConfidence))
+	}
+	if mapping.Detailed != "" && !strings.HasPrefix(mapping.Detailed, mapping.Primary+"_") {
+		return nil, utils.NewHTTPError(http.StatusBadRequest, fmt.Sprintf("detailed category %q is not under %q", mapping.Detailed, mapping.Primary))
+	}
+	catID, httpErr := categoryID(man.db, mapping.Category)
+	if httpErr != nil {
+		return nil, httpErr
+	}
+
+	query := `INSERT INTO category_mappings (pfc_primary, pfc_detailed, min_confidence, category_id) VALUES (?, ?, ?, ?) ` +
+		man.dialect.upsert([]string{"pfc_primary", "pfc_detailed"}, "min_confidence", "category_id")
+	if _, err := man.db.Exec(query, mapping.Primary, mapping.Detailed, mapping.MinConfidence, catID); err != nil {
+		return nil, utils.NewHTTPError(http.StatusInternalServerError, fmt.Sprintf("error saving category mapping: %v", err))
+	}
+
+	query = "SELECT " + categoryMappingColumns + " FROM " + categoryMappingFrom + " WHERE m.pfc_primary = ? AND m.pfc_detailed = ?"
+	stored, err := scanCategoryMapping(man.db.QueryRow(query, mapping.Primary, mapping.Detailed))
+	if err != nil {
+		return nil, utils.NewHTTPError(http.StatusInternalServerError, fmt.Sprintf("error fetching saved category mapping: %v", err))
+	}
+	return &stored, nil
+}
+
+// DeleteCategoryMapping removes a mapping, or returns a 404 if there is none with that id.
+func (man *Manager) DeleteCategoryMapping(id int64) *types.HTTPError {
+	result, err := man.db.Exec(`DELETE FROM category_mappings WHERE id = ?`, id)
+	if err != nil {
+		return utils.NewHTTPError(http.StatusInternalServerError, fmt.Sprintf("error deleting category mapping: %v", err))
+	}
+	if affected, err := result.RowsAffected(); err == nil && affected == 0 {
+		return utils.NewHTTPError(http.StatusNotFound, fmt.Sprintf("category mapping %d not found", id))
+	}
+	return nil
+}
@@ -0,0 +1,63 @@
+package db
+
+import (
+	"database/sql"
+	"fmt"
+	"github.com/Seymour-creates/budget-server/internal/types"
+	"github.com/Seymour-creates/budget-server/internal/utils"
+	"log"
+	"net/http"
+)
+
+// recordCorrection logs expense being moved from its current category into toID.
+func recordCorrection(ex execer, expense types.Expense, toID int64) *types.HTTPError {
+	fromID, httpErr := categoryID(ex, expense.Category)
+	if httpErr != nil {
+		return httpErr
+	}
+	const query = `INSERT INTO category_corrections (expense_id, merchant, description, from_category_id, to_category_id)
+		VALUES (?, ?, ?, ?, ?)`
+	if _, err := ex.Exec(query, expense.ID, expense.Merchant, expense.Description, fromID, toID); err != nil {
+		return utils.NewHTTPError(http.StatusInternalServerError, fmt.Sprintf("error recording category correction: %v", err))
+	}
+	return nil
+}
+
+// ListCategoryCorrections returns the manual category changes made to expenses, newest first.
+func (man *Manager) ListCategoryCorrections() ([]types.CategoryCorrection, *types.HTTPError) {
+	const query = `SELECT cc.id, cc.expense_id, cc.merchant, cc.description, f.slug, t.slug, cc.created_at
+		FROM category_corrections cc
+		JOIN categories f ON f.id = cc.from_category_id
+		JOIN categories t ON t.id = cc.to_category_id
+		ORDER BY cc.created_at DESC, cc.id DESC`
+	rows, err := man.db.Query(query)
+	if err != nil {
+		return nil, utils.NewHTTPError(http.StatusInternalServerError, fmt.Sprintf("error fetching category corrections: %v", err))
+	}
+	defer func(rows *sql.Rows) {
+		err := rows.Close()
+		if err != nil {
+			log.Printf("error closing row: %v", err)
+		}
+	}(rows)
+
+	corrections := []types.CategoryCorrection{}
+	for rows.Next() {
+		var correction types.CategoryCorrection
+		var createdAt string
+		if err := rows.Scan(&correction.ID, &correction.ExpenseID, &correction.Merchant, &correction.Description,
+			&correction.From, &correction.To, &createdAt); err != nil {
+			return nil, utils.NewHTTPError(http.StatusInternalServerError, fmt.Sprintf("error scanning category correction: %v", err))
+		}
+		correction.CreatedAt, err = parseTimestamp(createdAt)
+		if err != nil {
+			return nil, utils.NewHTTPError(http.StatusInternalServerError, fmt.Sprintf("error parsing correction created_at: %v", err))
+		}
+		corrections = append(corrections, correction)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, utils.NewHTTPError(http.StatusInternalServerError, fmt.Sprintf("error iterating category correction rows: %v", err))
+	}
+
+	return corrections, nil
+}
@@ -2,6 +2,7 @@ package db
 
 import (
 	"database/sql"
+	"errors"
 	"fmt"
 	"github.com/Seymour-creates/budget-server/internal/types"
 	"github.com/Seymour-creates/budget-server/internal/utils"
@@ -12,49 +13,25 @@ import (
 )
 
 type Manager struct {
-	db *sql.DB
+	db      *sql.DB
+	dialect Dialect
 }
 
-func NewDBManager(db *sql.DB) *Manager {
-	return &Manager{db: db}
+func NewDBManager(db *sql.DB, dialect Dialect) *Manager {
+	return &Manager{db: db, dialect: dialect}
 }
 
+// FetchExpenses returns every expense dated between start and end inclusive that counts towards budgets.
 func (man *Manager) FetchExpenses(start, end time.Time) ([]types.Expense, *types.HTTPError) {
-	const query = `SELECT categoryID, amount, date, description FROM expenses WHERE date >= ? AND date <= ?`
-	rows, err := man.db.Query(query, start, end)
-	if err != nil {
-		return nil, utils.NewHTTPError(http.StatusInternalServerError, fmt.Sprintf("error fetching expenses: %v", err))
-	}
-	defer func(rows *sql.Rows) {
-		err := rows.Close()
-		if err != nil {
-			log.Printf("error closing row: %v", err)
-		}
-	}(rows)
-
-	var expenses []types.Expense
-	for rows.Next() {
-		var exp types.Expense
-		var date string
-		if err := rows.Scan(&exp.Category, &exp.Amount, &date, &exp.Description); err != nil {
-			return nil, utils.NewHTTPError(http.StatusInternalServerError, fmt.Sprintf("error scanning expense: %v", err))
-		}
-		exp.Date, err = time.Parse("2006-01-02", date)
-		if err != nil {
-			return nil, utils.NewHTTPError(http.StatusInternalServerError, fmt.Sprintf("error parsing expense date: %v", err))
-		}
-		expenses = append(expenses, exp)
-	}
-	if err = rows.Err(); err != nil {
-		return nil, utils.NewHTTPError(http.StatusInternalServerError, fmt.Sprintf("error iterating expenses rows: %v", err))
-	}
-
-	return expenses, nil
+	return man.ListExpenses(types.ExpenseFilter{From: start, To: end, BudgetOnly: true})
 }
 
-func (man *Manager) FetchForecast(period time.Time) ([]types.Forecast, *types.HTTPError) {
-	const forecastQuery = `SELECT categoryID, amount FROM forecast WHERE period = ?`
-	forecastRows, err := man.db.Query(forecastQuery, period.Format("2006-01-02"))
+// FetchForecast returns the forecasts for every month that overlaps period, ordered by month, followed by the
+// minimum payments falling due on liability accounts within period.
+func (man *Manager) FetchForecast(period types.Period) ([]types.Forecast, *types.HTTPError) {
+	const forecastQuery = `SELECT f.period, c.slug, f.amount FROM forecast f JOIN categories c ON c.id = f.category_id
+		WHERE f.period >= ? AND f.period <= ? ORDER BY f.period, c.slug`
+	forecastRows, err := man.db.Query(forecastQuery, utils.StartOfMonth(period.Start).Format(dateFormat), period.End.Format(dateFormat))
 	if err != nil {
 		return nil, utils.NewHTTPError(http.StatusInternalServerError, fmt.Sprintf("error fetching forecast: %v", err))
 	}
@@ -68,57 +45,145 @@ func (man *Manager) FetchForecast(period time.Time) ([]types.Forecast, *types.HT
 	var forecast []types.Forecast
 	for forecastRows.Next() {
 		var fcast types.Forecast
-		if err := forecastRows.Scan(&fcast.Category, &fcast.Amount); err != nil {
+		var fcastPeriod string
+		if err := forecastRows.Scan(&fcastPeriod, &fcast.Category, &fcast.Amount); err != nil {
 			return nil, utils.NewHTTPError(http.StatusInternalServerError, fmt.Sprintf("error scanning forecast: %v", err))
 		}
+		fcast.Period, err = time.Parse(dateFormat, fcastPeriod)
+		if err != nil {
+			return nil, utils.NewHTTPError(http.StatusInternalServerError, fmt.Sprintf("error parsing forecast period: %v", err))
+		}
 		forecast = append(forecast, fcast)
 	}
 	if err = forecastRows.Err(); err != nil {
 		return nil, utils.NewHTTPError(http.StatusInternalServerError, fmt.Sprintf("error iterating forecast rows: %v", err))
 	}
 
-	return forecast, nil
+	payments, httpErr := man.plannedPayments(period)
+	if httpErr != nil {
+		return nil, httpErr
+	}
+	return append(forecast, payments...), nil
 }
 
-func (man *Manager) GetMonthlyBudgetInsights() (*types.MonthlyBudgetInsights, *types.HTTPError) {
-	now := time.Now()
-	firstOfMonth := time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, now.Location())
-	lastOfMonth := firstOfMonth.AddDate(0, 1, -1)
-
-	expenses, err := man.FetchExpenses(firstOfMonth, lastOfMonth)
+// GetBudgetInsights returns the expenses in period alongside the forecasts planned for it.
+func (man *Manager) GetBudgetInsights(period types.Period) (*types.BudgetInsights, *types.HTTPError) {
+	expenses, err := man.FetchExpenses(period.Start, period.End)
 	if err != nil {
 		return nil, err
 	}
 
-	forecast, err := man.FetchForecast(firstOfMonth)
+	forecast, err := man.FetchForecast(period)
 	if err != nil {
 		return nil, err
 	}
 
-	return &types.MonthlyBudgetInsights{
+	return &types.BudgetInsights{
+		Period:   period,
 		Expenses: expenses,
 		Forecast: forecast,
 	}, nil
 }
 
+// InsertExpenses writes expenses in a single transaction. Expenses carrying an ExternalID are upserted on
+// (source, external_id), so posting the same batch twice updates the existing rows instead of duplicating them.
 func (man *Manager) InsertExpenses(expenses []types.Expense) *types.HTTPError {
-	const insertQuery = "INSERT INTO expenses (date, description, amount, categoryID) VALUES (?, ?, ?, ?)"
+	tx, err := man.db.Begin()
+	if err != nil {
+		return utils.NewHTTPError(http.StatusInternalServerError, fmt.Sprintf("error starting expenses transaction: %v", err))
+	}
+	defer rollback(tx)
+
 	for _, expense := range expenses {
-		_, err := man.db.Exec(insertQuery, expense.Date, expense.Description, expense.Amount, expense.Category)
-		if err != nil {
-			return utils.NewHTTPError(http.StatusInternalServerError, fmt.Sprintf("error inserting data into expenses table: %v", err))
+		if err := man.upsertExpense(tx, "", expense); err != nil {
+			return err
 		}
 	}
+
+	if err := tx.Commit(); err != nil {
+		return utils.NewHTTPError(http.StatusInternalServerError, fmt.Sprintf("error committing expenses: %v", err))
+	}
 	return nil
 }
 
+// execer is satisfied by *sql.Tx, and by *sql.DB outside a transaction.
+type execer interface {
+	queryer
+	Exec(query string, args ...interface{}) (sql.Result, error)
+}
+
+// upsertExpense inserts or updates a single expense, imported from the Plaid item itemID if it isn't "". A
+// posted transaction that names the pending transaction it settles replaces the pending row. The category and
+// tags of an existing row are left alone on update, since they may have been corrected by hand since it was
+// first imported.
+func (man *Manager) upsertExpense(ex execer, itemID string, expense types.Expense) *types.HTTPError {
+	const deletePendingQuery = `DELETE FROM expenses WHERE source = ? AND external_id = ?`
+	upsertQuery := `INSERT INTO expenses (source, external_id, date, description, merchant, account_id, item_id, plaid_category,
+		pfc_primary, pfc_detailed, pfc_confidence, amount, category_id, category_source, category_confidence, tags)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?) ` +
+		man.dialect.upsert([]string{"source", "external_id"}, "date", "description", "merchant", "account_id", "item_id",
+			"plaid_category", "pfc_primary", "pfc_detailed", "pfc_confidence", "amount")
+
+	categoryID, httpErr := categoryID(ex, expense.Category)
+	if httpErr != nil {
+		return httpErr
+	}
+	if expense.PendingExternalID != "" {
+		if _, err := ex.Exec(deletePendingQuery, expense.Source, expense.PendingExternalID); err != nil {
+			return utils.NewHTTPError(http.StatusInternalServerError, fmt.Sprintf("error replacing pending expense: %v", err))
+		}
+	}
+	_, err := ex.Exec(upsertQuery, expense.Source, nullString(expense.ExternalID), expense.Date.Format(dateFormat), expense.Description,
+		expense.Merchant, expense.AccountID, itemID, expense.PlaidCategory, expense.PFCPrimary, expense.PFCDetailed, expense.PFCConfidence,
+		expense.Amount, categoryID, expense.CategorySource, expense.CategoryConfidence, joinTags(expense.Tags))
+	if err != nil {
+		return utils.NewHTTPError(http.StatusInternalServerError, fmt.Sprintf("error inserting data into expenses table: %v", err))
+	}
+	return nil
+}
+
+// nullString maps "" to NULL so rows without an external ID never collide on the (source, external_id) key.
+func nullString(s string) sql.NullString {
+	return sql.NullString{String: s, Valid: s != ""}
+}
+
+// rollback aborts tx unless it has already been committed.
+func rollback(tx *sql.Tx) {
+	if err := tx.Rollback(); err != nil && !errors.Is(err, sql.ErrTxDone) {
+		log.Printf("error rolling back transaction: %v", err)
+	}
+}
+
+// InsertForecast upserts forecast entries on (period, category), so re-posting a month's plan replaces the
+// amounts already stored for it.
 func (man *Manager) InsertForecast(forecast []types.Forecast) *types.HTTPError {
-	const insertQuery = "INSERT INTO forecast (categoryID, amount) VALUES (?, ?)"
+	insertQuery := "INSERT INTO forecast (period, category_id, amount) VALUES (?, ?, ?) " +
+		man.dialect.upsert([]string{"period", "category_id"}, "amount")
 	for _, f := range forecast {
-		_, err := man.db.Exec(insertQuery, f.Category, f.Amount)
+		categoryID, httpErr := categoryID(man.db, f.Category)
+		if httpErr != nil {
+			return httpErr
+		}
+		_, err := man.db.Exec(insertQuery, utils.StartOfMonth(f.Period).Format(dateFormat), categoryID, f.Amount)
 		if err != nil {
 			return utils.NewHTTPError(http.StatusInternalServerError, fmt.Sprintf("error posting forecast data to db: %v", err))
 		}
 	}
 	return nil
 }
+
+// CopyForecast seeds the month containing to with the forecast from the month containing from. Categories
+// already planned for the target month are left as they are.
+func (man *Manager) CopyForecast(from, to time.Time) *types.HTTPError {
+	const copyQuery = `INSERT INTO forecast (period, category_id, amount)
+		SELECT ?, source.category_id, source.amount FROM forecast source
+		WHERE source.period = ? AND NOT EXISTS (
+			SELECT 1 FROM forecast target WHERE target.period = ? AND target.category_id = source.category_id
+		)`
+	target := utils.StartOfMonth(to).Format(dateFormat)
+	_, err := man.db.Exec(copyQuery, target, utils.StartOfMonth(from).Format(dateFormat), target)
+	if err != nil {
+		return utils.NewHTTPError(http.StatusInternalServerError, fmt.Sprintf("error copying forecast: %v", err))
+	}
+	return nil
+}
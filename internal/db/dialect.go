@@ -0,0 +1,71 @@
+package db
+
+import (
+	"database/sql"
+	"fmt"
+	"strings"
+	"time"
+
+	_ "modernc.org/sqlite"
+)
+
+// Dialect names the SQL flavour a Manager speaks. Queries are written once in the common subset of MySQL and
+// SQLite; the few constructs that differ, such as upserts, are rendered per dialect.
+type Dialect string
+
+const (
+	MySQL  Dialect = "mysql"
+	SQLite Dialect = "sqlite"
+)
+
+// sqlitePrefix selects the embedded SQLite backend, e.g. sqlite:budget.db or sqlite::memory:.
+const sqlitePrefix = "sqlite:"
+
+// dateFormat is how dates are written to and read from DATE columns in every dialect.
+const dateFormat = "2006-01-02"
+
+// timestampFormat is how MySQL returns TIMESTAMP columns. The SQLite driver returns them as RFC 3339.
+const timestampFormat = "2006-01-02 15:04:05"
+
+// parseTimestamp reads a TIMESTAMP column scanned into a string from either driver.
+func parseTimestamp(value string) (time.Time, error) {
+	if t, err := time.Parse(timestampFormat, value); err == nil {
+		return t, nil
+	}
+	return time.Parse(time.RFC3339, value)
+}
+
+// Open connects to the database named by dsn. DSNs starting with "sqlite:" open a local SQLite file;
+// anything else is handed to the MySQL driver.
+func Open(dsn string) (*sql.DB, Dialect, error) {
+	if path, ok := strings.CutPrefix(dsn, sqlitePrefix); ok {
+		conn, err := sql.Open("sqlite", path+"?_pragma=foreign_keys(1)&_pragma=busy_timeout(5000)")
+		if err != nil {
+			return nil, "", fmt.Errorf("opening sqlite db: %w", err)
+		}
+		// SQLite allows a single writer, and each connection to :memory: is a separate database.
+		conn.SetMaxOpenConns(1)
+		return conn, SQLite, nil
+	}
+	conn, err := sql.Open("mysql", dsn)
+	if err != nil {
+		return nil, "", fmt.Errorf("opening mysql db: %w", err)
+	}
+	return conn, MySQL, nil
+}
+
+// upsert renders the clause that turns an INSERT into an update of columns when a row with the same key exists.
+func (d Dialect) upsert(key []string, columns ...string) string {
+	assignments := make([]string, len(columns))
+	for i, column := range columns {
+		if d == SQLite {
+			assignments[i] = fmt.Sprintf("%v = excluded.%v", column, column)
+		} else {
+			assignments[i] = fmt.Sprintf("%v = VALUES(%v)", column, column)
+		}
+	}
+	if d == SQLite {
+		return fmt.Sprintf("ON CONFLICT (%v) DO UPDATE SET %v", strings.Join(key, ", "), strings.Join(assignments, ", "))
+	}
+	return "ON DUPLICATE KEY UPDATE " + strings.Join(assignments, ", ")
+}
@@ -0,0 +1,18 @@
+package db
+
+import "testing"
+
+func TestDialectUpsert(t *testing.T) {
+	tests := []struct {
+		dialect Dialect
+		want    string
+	}{
+		{SQLite, "ON CONFLICT (source, external_id) DO UPDATE SET amount = excluded.amount, date = excluded.date"},
+		{MySQL, "ON DUPLICATE KEY UPDATE amount = VALUES(amount), date = VALUES(date)"},
+	}
+	for _, test := range tests {
+		if got := test.dialect.upsert([]string{"source", "external_id"}, "amount", "date"); got != test.want {
+			t.Errorf("%v upsert = %q, want %q", test.dialect, got, test.want)
+		}
+	}
+}
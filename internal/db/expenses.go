@@ -0,0 +1,232 @@
+package db
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"github.com/Seymour-creates/budget-server/internal/types"
+	"github.com/Seymour-creates/budget-server/internal/utils"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+)
+
+const expenseColumns = `e.id, COALESCE(e.source, ''), COALESCE(e.external_id, ''), c.slug, e.amount, e.date, e.description,
+	e.merchant, e.account_id, e.plaid_category, e.pfc_primary, e.pfc_detailed, e.pfc_confidence, e.category_source,
+	e.category_confidence, e.tags`
+
+const expenseFrom = `expenses e JOIN categories c ON c.id = e.category_id`
+
+// rowScanner is satisfied by both *sql.Row and *sql.Rows.
+type rowScanner interface {
+	Scan(dest ...interface{}) error
+}
+
+func scanExpense(row rowScanner) (types.Expense, error) {
+	var exp types.Expense
+	var date, tags string
+	var confidence sql.NullFloat64
+	if err := row.Scan(&exp.ID, &exp.Source, &exp.ExternalID, &exp.Category, &exp.Amount, &date, &exp.Description,
+		&exp.Merchant, &exp.AccountID, &exp.PlaidCategory, &exp.PFCPrimary, &exp.PFCDetailed, &exp.PFCConfidence,
+		&exp.CategorySource, &confidence, &tags); err != nil {
+		return exp, err
+	}
+	if confidence.Valid {
+		exp.CategoryConfidence = &confidence.Float64
+	}
+	exp.Tags = splitTags(tags)
+	var err error
+	exp.Date, err = time.Parse(dateFormat, date)
+	if err != nil {
+		return exp, fmt.Errorf("parsing expense date: %w", err)
+	}
+	return exp, nil
+}
+
+// ListExpenses returns the expenses matching filter, ordered by date then id.
+func (man *Manager) ListExpenses(filter types.ExpenseFilter) ([]types.Expense, *types.HTTPError) {
+	var conditions []string
+	var args []interface{}
+	if !filter.From.IsZero() {
+		conditions = append(conditions, "e.date >= ?")
+		args = append(args, filter.From.Format(dateFormat))
+	}
+	if !filter.To.IsZero() {
+		conditions = append(conditions, "e.date <= ?")
+		args = append(args, filter.To.Format(dateFormat))
+	}
+	if filter.Category != "" {
+		conditions = append(conditions, "c.slug = ?")
+		args = append(args, filter.Category)
+	}
+	if filter.Source != "" {
+		conditions = append(conditions, "e.source = ?")
+		args = append(args, filter.Source)
+	}
+	if filter.CategorySource != "" {
+		conditions = append(conditions, "e.category_source = ?")
+		args = append(args, filter.CategorySource)
+	}
+	if filter.AccountID != "" {
+		conditions = append(conditions, "e.account_id = ?")
+		args = append(args, filter.AccountID)
+	}
+	if filter.BudgetOnly {
+		conditions = append(conditions, budgetAccountsCondition)
+	}
+	if filter.Description != "" {
+		conditions = append(conditions, "LOWER(e.description) LIKE ?")
+		args = append(args, "%"+strings.ToLower(filter.Description)+"%")
+	}
+	if filter.MinAmount != nil {
+		conditions = append(conditions, "e.amount >= ?")
+		args = append(args, *filter.MinAmount)
+	}
+	if filter.MaxAmount != nil {
+		conditions = append(conditions, "e.amount <= ?")
+		args = append(args, *filter.MaxAmount)
+	}
+
+	query := "SELECT " + expenseColumns + " FROM " + expenseFrom
+	if len(conditions) > 0 {
+		query += " WHERE " + strings.Join(conditions, " AND ")
+	}
+	query += " ORDER BY e.date, e.id"
+	if filter.Limit > 0 {
+		query += " LIMIT ? OFFSET ?"
+		args = append(args, filter.Limit, filter.Offset)
+	}
+
+	rows, err := man.db.Query(query, args...)
+	if err != nil {
+		return nil, utils.NewHTTPError(http.StatusInternalServerError, fmt.Sprintf("error fetching expenses: %v", err))
+	}
+	defer func(rows *sql.Rows) {
+		err := rows.Close()
+		if err != nil {
+			log.Printf("error closing row: %v", err)
+		}
+	}(rows)
+
+	var expenses []types.Expense
+	for rows.Next() {
+		exp, err := scanExpense(rows)
+		if err != nil {
+			return nil, utils.NewHTTPError(http.StatusInternalServerError, fmt.Sprintf("error scanning expense: %v", err))
+		}
+		expenses = append(expenses, exp)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, utils.NewHTTPError(http.StatusInternalServerError, fmt.Sprintf("error iterating expenses rows: %v", err))
+	}
+
+	return expenses, nil
+}
+
+// FetchExpense returns a single expense, or a 404 if there is none with that id.
+func (man *Manager) FetchExpense(id int64) (*types.Expense, *types.HTTPError) {
+	query := "SELECT " + expenseColumns + " FROM " + expenseFrom + " WHERE e.id = ?"
+	exp, err := scanExpense(man.db.QueryRow(query, id))
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, utils.NewHTTPError(http.StatusNotFound, fmt.Sprintf("expense %d not found", id))
+	}
+	if err != nil {
+		return nil, utils.NewHTTPError(http.StatusInternalServerError, fmt.Sprintf("error fetching expense: %v", err))
+	}
+	return &exp, nil
+}
+
+// UpdateExpense applies patch to an expense and returns the updated row. A category change the patch doesn't
+// attribute to a rule or model is taken as a manual correction and recorded for the category model to learn
+// from.
+func (man *Manager) UpdateExpense(id int64, patch types.ExpensePatch) (*types.Expense, *types.HTTPError) {
+	current, httpErr := man.FetchExpense(id)
+	if httpErr != nil {
+		return nil, httpErr
+	}
+
+	tx, err := man.db.Begin()
+	if err != nil {
+		return nil, utils.NewHTTPError(http.StatusInternalServerError, fmt.Sprintf("error starting expense update: %v", err))
+	}
+	defer rollback(tx)
+
+	var assignments []string
+	var args []interface{}
+	if patch.Date != nil {
+		assignments = append(assignments, "date = ?")
+		args = append(args, patch.Date.Format(dateFormat))
+	}
+	if patch.Description != nil {
+		assignments = append(assignments, "description = ?")
+		args = append(args, *patch.Description)
+	}
+	if patch.Amount != nil {
+		assignments = append(assignments, "amount = ?")
+		args = append(args, *patch.Amount)
+	}
+	if patch.Category != nil {
+		toID, err := categoryID(tx, *patch.Category)
+		if err != nil {
+			return nil, err
+		}
+		source := types.CategorySourceUser
+		if patch.CategorySource != nil {
+			source = *patch.CategorySource
+		}
+		assignments = append(assignments, "category_id = ?", "category_source = ?", "category_confidence = ?")
+		args = append(args, toID, source, patch.CategoryConfidence)
+
+		if source == types.CategorySourceUser && *patch.Category != current.Category {
+			if err := recordCorrection(tx, *current, toID); err != nil {
+				return nil, err
+			}
+		}
+	}
+	if patch.Tags != nil {
+		assignments = append(assignments, "tags = ?")
+		args = append(args, joinTags(*patch.Tags))
+	}
+	if len(assignments) > 0 {
+		query := "UPDATE expenses SET " + strings.Join(assignments, ", ") + " WHERE id = ?"
+		if _, err := tx.Exec(query, append(args, id)...); err != nil {
+			return nil, utils.NewHTTPError(http.StatusInternalServerError, fmt.Sprintf("error updating expense: %v", err))
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, utils.NewHTTPError(http.StatusInternalServerError, fmt.Sprintf("error committing expense update: %v", err))
+	}
+
+	return man.FetchExpense(id)
+}
+
+// DeleteExpense removes an expense, or returns a 404 if there is none with that id.
+func (man *Manager) DeleteExpense(id int64) *types.HTTPError {
+	result, err := man.db.Exec("DELETE FROM expenses WHERE id = ?", id)
+	if err != nil {
+		return utils.NewHTTPError(http.StatusInternalServerError, fmt.Sprintf("error deleting expense: %v", err))
+	}
+	if affected, err := result.RowsAffected(); err == nil && affected == 0 {
+		return utils.NewHTTPError(http.StatusNotFound, fmt.Sprintf("expense %d not found", id))
+	}
+	return nil
+}
+
+// Tags are stored as a single comma separated column.
+func joinTags(tags []string) string {
+	cleaned := make([]string, 0, len(tags))
+	for _, tag := range tags {
+		if tag = strings.TrimSpace(strings.ReplaceAll(tag, ",", " ")); tag != "" {
+			cleaned = append(cleaned, tag)
+		}
+	}
+	return strings.Join(cleaned, ",")
+}
+
+func splitTags(tags string) []string {
+	if tags == "" {
+		return nil
+	}
+	return strings.Split(tags, ",")
+}
@@ -0,0 +1,42 @@
+package db_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/Seymour-creates/budget-server/internal/testutil"
+	"github.com/Seymour-creates/budget-server/internal/types"
+)
+
+func TestInsertForecastReplacesPlan(t *testing.T) {
+	man := testutil.NewDB(t)
+	may := time.Date(2024, 5, 1, 0, 0, 0, 0, time.UTC)
+	mustNot(t, man.InsertForecast([]types.Forecast{{Period: may, Category: "takeout", Amount: 100}}))
+	mustNot(t, man.InsertForecast([]types.Forecast{{Period: may.AddDate(0, 0, 9), Category: "takeout", Amount: 120}}))
+
+	forecast, httpErr := man.FetchForecast(types.Period{Start: may, End: may.AddDate(0, 1, -1)})
+	mustNot(t, httpErr)
+	if len(forecast) != 1 || forecast[0].Amount != 120 {
+		t.Errorf("forecast = %+v, want a single takeout plan of 120", forecast)
+	}
+}
+
+func TestCopyForecastKeepsExistingPlans(t *testing.T) {
+	man := testutil.NewDB(t)
+	may := time.Date(2024, 5, 1, 0, 0, 0, 0, time.UTC)
+	june := may.AddDate(0, 1, 0)
+	mustNot(t, man.InsertForecast([]types.Forecast{{Period: may, Category: "takeout", Amount: 100},
+		{Period: may, Category: "bill", Amount: 900}}))
+	mustNot(t, man.InsertForecast([]types.Forecast{{Period: june, Category: "takeout", Amount: 80}}))
+
+	mustNot(t, man.CopyForecast(may, june))
+	forecast, httpErr := man.FetchForecast(types.Period{Start: june, End: june.AddDate(0, 1, -1)})
+	mustNot(t, httpErr)
+	amounts := map[string]float64{}
+	for _, f := range forecast {
+		amounts[f.Category] = f.Amount
+	}
+	if len(forecast) != 2 || amounts["takeout"] != 80 || amounts["bill"] != 900 {
+		t.Errorf("june forecast = %+v, want takeout kept at 80 and bill copied at 900", forecast)
+	}
+}
@@ -0,0 +1,318 @@
+package db
+
+import (
+	"database/sql"
+	"fmt"
+	"github.com/Seymour-creates/budget-server/internal/types"
+	"github.com/Seymour-creates/budget-server/internal/utils"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+)
+
+const securityColumns = `s.security_id, s.name, s.ticker, s.type, s.is_cash_equivalent, s.close_price, s.close_price_as_of,
+	s.currency`
+
+const holdingColumns = `h.account_id, h.security_id, h.date, h.quantity, h.institution_price, h.institution_value,
+	h.cost_basis, h.currency`
+
+const investmentTransactionColumns = `t.investment_transaction_id, t.account_id, t.security_id, t.date, t.name, t.type,
+	t.subtype, t.quantity, t.price, t.amount, t.fees, t.currency`
+
+func scanHolding(row rowScanner) (types.Holding, error) {
+	var holding types.Holding
+	var security types.Security
+	var date string
+	var costBasis, closePrice sql.NullFloat64
+	var closePriceAsOf sql.NullString
+	err := row.Scan(&holding.AccountID, &holding.SecurityID, &date, &holding.Quantity, &holding.InstitutionPrice,
+		&holding.InstitutionValue, &costBasis, &holding.Currency, &security.SecurityID, &security.Name,
+		&security.Ticker, &security.Type, &security.IsCashEquivalent, &closePrice, &closePriceAsOf, &security.Currency)
+	if err != nil {
+		return holding, err
+	}
+	holding.CostBasis = nullFloat(costBasis)
+	security.ClosePrice = nullFloat(closePrice)
+	if holding.Date, err = time.Parse(dateFormat, date); err != nil {
+		return holding, fmt.Errorf("parsing holding date: %w", err)
+	}
+	if security.ClosePriceAsOf, err = nullDate(closePriceAsOf); err != nil {
+		return holding, fmt.Errorf("parsing security close_price_as_of: %w", err)
+	}
+	holding.Security = &security
+	return holding, nil
+}
+
+func scanInvestmentTransaction(row rowScanner) (types.InvestmentTransaction, error) {
+	var transaction types.InvestmentTransaction
+	var securityID sql.NullString
+	var date string
+	var fees sql.NullFloat64
+	err := row.Scan(&transaction.InvestmentTransactionID, &transaction.AccountID, &securityID, &date, &transaction.Name,
+		&transaction.Type, &transaction.Subtype, &transaction.Quantity, &transaction.Price, &transaction.Amount, &fees,
+		&transaction.Currency)
+	if err != nil {
+		return transaction, err
+	}
+	transaction.SecurityID = securityID.String
+	transaction.Fees = nullFloat(fees)
+	if transaction.Date, err = time.Parse(dateFormat, date); err != nil {
+		return transaction, fmt.Errorf("parsing investment transaction date: %w", err)
+	}
+	return transaction, nil
+}
+
+// ApplyInvestmentSync stores one import from Plaid Investments in a single transaction: securities are
+// refreshed, the day's holdings of sync.AccountIDs are replaced so positions since sold drop out, and investment
+// transactions are added or updated.
+func (man *Manager) ApplyInvestmentSync(sync types.InvestmentSync) *types.HTTPError {
+	securityQuery := `INSERT INTO securities (security_id, name, ticker, type, is_cash_equivalent, close_price,
+		close_price_as_of, currency, updated_at) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?) ` +
+		man.dialect.upsert([]string{"security_id"}, "name", "ticker", "type", "is_cash_equivalent", "close_price",
+			"close_price_as_of", "currency", "updated_at")
+	const holdingQuery = `INSERT INTO holdings (account_id, security_id, date, quantity, institution_price,
+		institution_value, cost_basis, currency) VALUES (?, ?, ?, ?, ?, ?, ?, ?)`
+	transactionQuery := `INSERT INTO investment_transactions (investment_transaction_id, account_id, security_id, date,
+		name, type, subtype, quantity, price, amount, fees, currency) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?) ` +
+		man.dialect.upsert([]string{"investment_transaction_id"}, "account_id", "security_id", "date", "name", "type",
+			"subtype", "quantity", "price", "amount", "fees", "currency")
+
+	tx, err := man.db.Begin()
+	if err != nil {
+		return utils.NewHTTPError(http.StatusInternalServerError, fmt.Sprintf("error starting investments transaction: %v", err))
+	}
+	defer rollback(tx)
+
+	now := time.Now().UTC().Format(timestampFormat)
+	for _, security := range sync.Securities {
+		_, err := tx.Exec(securityQuery, security.SecurityID, security.Name, security.Ticker, security.Type,
+			security.IsCashEquivalent, security.ClosePrice, formatNullDate(security.ClosePriceAsOf), security.Currency, now)
+		if err != nil {
+			return utils.NewHTTPError(http.StatusInternalServerError, fmt.Sprintf("error saving security %v: %v", security.SecurityID, err))
+		}
+	}
+
+	day := sync.Date.Format(dateFormat)
+	for _, accountID := range sync.AccountIDs {
+		if _, err := tx.Exec("DELETE FROM holdings WHERE account_id = ? AND date = ?", accountID, day); err != nil {
+			return utils.NewHTTPError(http.StatusInternalServerError, fmt.Sprintf("error clearing holdings for account %v: %v", accountID, err))
+		}
+	}
+	for _, holding := range sync.Holdings {
+		_, err := tx.Exec(holdingQuery, holding.AccountID, holding.SecurityID, day, holding.Quantity,
+			holding.InstitutionPrice, holding.InstitutionValue, holding.CostBasis, holding.Currency)
+		if err != nil {
+			return utils.NewHTTPError(http.StatusInternalServerError, fmt.Sprintf("error saving holding of %v in account %v: %v", holding.SecurityID, holding.AccountID, err))
+		}
+	}
+
+	for _, transaction := range sync.Transactions {
+		securityID := sql.NullString{String: transaction.SecurityID, Valid: transaction.SecurityID != ""}
+		_, err := tx.Exec(transactionQuery, transaction.InvestmentTransactionID, transaction.AccountID, securityID,
+			transaction.Date.Format(dateFormat), transaction.Name, transaction.Type, transaction.Subtype,
+			transaction.Quantity, transaction.Price, transaction.Amount, transaction.Fees, transaction.Currency)
+		if err != nil {
+			return utils.NewHTTPError(http.StatusInternalServerError, fmt.Sprintf("error saving investment transaction %v: %v", transaction.InvestmentTransactionID, err))
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return utils.NewHTTPError(http.StatusInternalServerError, fmt.Sprintf("error committing investments: %v", err))
+	}
+	return nil
+}
+
+// LatestInvestmentTransactionDate returns the date of the most recent investment transaction stored for an
+// item's accounts, or the zero time if there are none.
+func (man *Manager) LatestInvestmentTransactionDate(itemID string) (time.Time, *types.HTTPError) {
+	var latest sql.NullString
+	err := man.db.QueryRow(`SELECT MAX(t.date) FROM investment_transactions t
+		JOIN accounts a ON a.account_id = t.account_id WHERE a.item_id = ?`, itemID).Scan(&latest)
+	if err != nil {
+		return time.Time{}, utils.NewHTTPError(http.StatusInternalServerError, fmt.Sprintf("error fetching latest investment transaction: %v", err))
+	}
+	if !latest.Valid {
+		return time.Time{}, nil
+	}
+	date, err := time.Parse(dateFormat, latest.String)
+	if err != nil {
+		return time.Time{}, utils.NewHTTPError(http.StatusInternalServerError, fmt.Sprintf("error parsing latest investment transaction date: %v", err))
+	}
+	return date, nil
+}
+
+// ListHoldings returns the most recently recorded holdings of every investment account that isn't hidden, or of
+// just accountID if it is set, with their securities, largest positions first.
+func (man *Manager) ListHoldings(accountID string) ([]types.Holding, *types.HTTPError) {
+	conditions := []string{"h.date = (SELECT MAX(latest.date) FROM holdings latest WHERE latest.account_id = h.account_id)"}
+	var args []interface{}
+	if accountID != "" {
+		conditions = append(conditions, "h.account_id = ?")
+		args = append(args, accountID)
+	} else {
+		conditions = append(conditions, "a.hidden = FALSE")
+	}
+	query := "SELECT " + holdingColumns + ", " + securityColumns + ` FROM holdings h
+		JOIN accounts a ON a.account_id = h.account_id
+		JOIN securities s ON s.security_id = h.security_id
+		WHERE ` + strings.Join(conditions, " AND ") + " ORDER BY h.account_id, h.institution_value DESC"
+
+	rows, err := man.db.Query(query, args...)
+	if err != nil {
+		return nil, utils.NewHTTPError(http.StatusInternalServerError, fmt.Sprintf("error fetching holdings: %v", err))
+	}
+	defer func(rows *sql.Rows) {
+		err := rows.Close()
+		if err != nil {
+			log.Printf("error closing row: %v", err)
+		}
+	}(rows)
+
+	holdings := []types.Holding{}
+	for rows.Next() {
+		holding, err := scanHolding(rows)
+		if err != nil {
+			return nil, utils.NewHTTPError(http.StatusInternalServerError, fmt.Sprintf("error scanning holding: %v", err))
+		}
+		holdings = append(holdings, holding)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, utils.NewHTTPError(http.StatusInternalServerError, fmt.Sprintf("error iterating holding rows: %v", err))
+	}
+
+	return holdings, nil
+}
+
+// ListInvestmentTransactions returns the investment transactions matching filter, newest first. Without an
+// account in the filter, those on hidden accounts are left out.
+func (man *Manager) ListInvestmentTransactions(filter types.InvestmentTransactionFilter) ([]types.InvestmentTransaction, *types.HTTPError) {
+	var conditions []string
+	var args []interface{}
+	if filter.AccountID != "" {
+		conditions = append(conditions, "t.account_id = ?")
+		args = append(args, filter.AccountID)
+	} else {
+		conditions = append(conditions, "a.hidden = FALSE")
+	}
+	if !filter.From.IsZero() {
+		conditions = append(conditions, "t.date >= ?")
+		args = append(args, filter.From.Format(dateFormat))
+	}
+	if !filter.To.IsZero() {
+		conditions = append(conditions, "t.date <= ?")
+		args = append(args, filter.To.Format(dateFormat))
+	}
+	query := "SELECT " + investmentTransactionColumns + ` FROM investment_transactions t
+		JOIN accounts a ON a.account_id = t.account_id
+		WHERE ` + strings.Join(conditions, " AND ") + " ORDER BY t.date DESC, t.investment_transaction_id"
+
+	rows, err := man.db.Query(query, args...)
+	if err != nil {
+		return nil, utils.NewHTTPError(http.StatusInternalServerError, fmt.Sprintf("error fetching investment transactions: %v", err))
+	}
+	defer func(rows *sql.Rows) {
+		err := rows.Close()
+		if err != nil {
+			log.Printf("error closing row: %v", err)
+		}
+	}(rows)
+
+	transactions := []types.InvestmentTransaction{}
+	for rows.Next() {
+		transaction, err := scanInvestmentTransaction(rows)
+		if err != nil {
+			return nil, utils.NewHTTPError(http.StatusInternalServerError, fmt.Sprintf("error scanning investment transaction: %v", err))
+		}
+		transactions = append(transactions, transaction)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, utils.NewHTTPError(http.StatusInternalServerError, fmt.Sprintf("error iterating investment transaction rows: %v", err))
+	}
+
+	return transactions, nil
+}
+
+// GetPortfolioValue reports the value of each investment account that isn't hidden at the end of each day of
+// period, and their total. An account is valued at its latest holdings recorded on or before the day, and at
+// nothing before its first or after the day it closed.
+func (man *Manager) GetPortfolioValue(period types.Period) (*types.PortfolioReport, *types.HTTPError) {
+	accounts, httpErr := man.ListAccounts(false)
+	if httpErr != nil {
+		return nil, httpErr
+	}
+
+	const historyQuery = `SELECT h.account_id, h.date, SUM(h.institution_value) FROM holdings h WHERE h.date <= ?
+		GROUP BY h.account_id, h.date ORDER BY h.date`
+	rows, err := man.db.Query(historyQuery, period.End.Format(dateFormat))
+	if err != nil {
+		return nil, utils.NewHTTPError(http.StatusInternalServerError, fmt.Sprintf("error fetching holdings history: %v", err))
+	}
+	defer func(rows *sql.Rows) {
+		err := rows.Close()
+		if err != nil {
+			log.Printf("error closing row: %v", err)
+		}
+	}(rows)
+
+	type snapshot struct {
+		accountID string
+		date      time.Time
+		value     float64
+	}
+	var history []snapshot
+	for rows.Next() {
+		var entry snapshot
+		var date string
+		if err := rows.Scan(&entry.accountID, &date, &entry.value); err != nil {
+			return nil, utils.NewHTTPError(http.StatusInternalServerError, fmt.Sprintf("error scanning holdings history: %v", err))
+		}
+		if entry.date, err = time.Parse(dateFormat, date); err != nil {
+			return nil, utils.NewHTTPError(http.StatusInternalServerError, fmt.Sprintf("error parsing holdings date: %v", err))
+		}
+		history = append(history, entry)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, utils.NewHTTPError(http.StatusInternalServerError, fmt.Sprintf("error iterating holdings history rows: %v", err))
+	}
+
+	report := &types.PortfolioReport{Period: period, Accounts: []types.PortfolioSeries{}, Total: []types.PortfolioPoint{}}
+	closed := map[string]time.Time{}
+	for _, account := range accounts {
+		if account.Type == types.AccountTypeInvestment {
+			if account.ClosedAt != nil {
+				closed[account.AccountID] = closingDay(*account.ClosedAt, period.Start.Location())
+			}
+			report.Accounts = append(report.Accounts, types.PortfolioSeries{
+				AccountID: account.AccountID,
+				Name:      account.Name,
+				Points:    []types.PortfolioPoint{},
+			})
+		}
+	}
+
+	current := map[string]float64{}
+	next := 0
+	for day := period.Start; !day.After(period.End); day = day.AddDate(0, 0, 1) {
+		// Holding dates come back from the db as UTC midnight; compare them as calendar days in the period's zone.
+		for ; next < len(history); next++ {
+			date := history[next].date
+			if time.Date(date.Year(), date.Month(), date.Day(), 0, 0, 0, 0, day.Location()).After(day) {
+				break
+			}
+			current[history[next].accountID] = history[next].value
+		}
+
+		total := types.PortfolioPoint{Date: day}
+		for i := range report.Accounts {
+			value := current[report.Accounts[i].AccountID]
+			if closedOn, isClosed := closed[report.Accounts[i].AccountID]; isClosed && day.After(closedOn) {
+				value = 0
+			}
+			report.Accounts[i].Points = append(report.Accounts[i].Points, types.PortfolioPoint{Date: day, Value: value})
+			total.Value += value
+		}
+		report.Total = append(report.Total, total)
+	}
+	return report, nil
+}
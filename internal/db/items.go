@@ -0,0 +1,256 @@
+package db
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"github.com/Seymour-creates/budget-server/internal/types"
+	"github.com/Seymour-creates/budget-server/internal/utils"
+	"log"
+	"net/http"
+	"time"
+)
+
+// InsertPlaidItem stores a linked item. Relinking an existing item replaces its token and marks it active again.
+func (man *Manager) InsertPlaidItem(item types.PlaidItem) *types.HTTPError {
+	query := `INSERT INTO plaid_items (item_id, institution, access_token, token_key, status, error_code) VALUES (?, ?, ?, ?, ?, ?) ` +
+		man.dialect.upsert([]string{"item_id"}, "institution", "access_token", "token_key", "status", "error_code")
+	_, err := man.db.Exec(query, item.ItemID, item.Institution, item.EncryptedAccessToken, item.EncryptedKey, item.Status, item.ErrorCode)
+	if err != nil {
+		return utils.NewHTTPError(http.StatusInternalServerError, fmt.Sprintf("error saving plaid item: %v", err))
+	}
+	return nil
+}
+
+const itemColumns = `item_id, institution, access_token, token_key, status, error_code, last_synced_at, created_at`
+
+func scanPlaidItem(row rowScanner) (types.PlaidItem, error) {
+	var item types.PlaidItem
+	var createdAt string
+	var lastSyncedAt sql.NullString
+	if err := row.Scan(&item.ItemID, &item.Institution, &item.EncryptedAccessToken, &item.EncryptedKey, &item.Status,
+		&item.ErrorCode, &lastSyncedAt, &createdAt); err != nil {
+		return item, err
+	}
+	var err error
+	item.CreatedAt, err = parseTimestamp(createdAt)
+	if err != nil {
+		return item, fmt.Errorf("parsing plaid item created_at: %w", err)
+	}
+	if lastSyncedAt.Valid {
+		syncedAt, err := parseTimestamp(lastSyncedAt.String)
+		if err != nil {
+			return item, fmt.Errorf("parsing plaid item last_synced_at: %w", err)
+		}
+		item.LastSyncedAt = &syncedAt
+	}
+	return item, nil
+}
+
+// FetchPlaidItem returns a single linked item, or a 404 if there is none with that id.
+func (man *Manager) FetchPlaidItem(itemID string) (*types.PlaidItem, *types.HTTPError) {
+	item, err := scanPlaidItem(man.db.QueryRow("SELECT "+itemColumns+" FROM plaid_items WHERE item_id = ?", itemID))
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, utils.NewHTTPError(http.StatusNotFound, fmt.Sprintf("plaid item %v not found", itemID))
+	}
+	if err != nil {
+		return nil, utils.NewHTTPError(http.StatusInternalServerError, fmt.Sprintf("error fetching plaid item: %v", err))
+	}
+	return &item, nil
+}
+
+// UpdatePlaidItemStatus records a change in an item's health along with the Plaid error code behind it, if
+// any, or returns a 404 if there is no such item.
+func (man *Manager) UpdatePlaidItemStatus(itemID, status, errorCode string) *types.HTTPError {
+	result, err := man.db.Exec(`UPDATE plaid_items SET status = ?, error_code = ? WHERE item_id = ?`, status, errorCode, itemID)
+	if err != nil {
+		return utils.NewHTTPError(http.StatusInternalServerError, fmt.Sprintf("error updating plaid item status: %v", err))
+	}
+	if affected, err := result.RowsAffected(); err == nil && affected == 0 {
+		return utils.NewHTTPError(http.StatusNotFound, fmt.Sprintf("plaid item %v not found", itemID))
+	}
+	return nil
+}
+
+// RecordPlaidItemSync notes a successful sync. Working credentials clear any error, though a pending
+// consent expiry still stands until the user renews it.
+func (man *Manager) RecordPlaidItemSync(itemID string, at time.Time) *types.HTTPError {
+	const query = `UPDATE plaid_items SET last_synced_at = ?,
+		status = CASE WHEN status = ? THEN status ELSE ? END,
+		error_code = CASE WHEN status = ? THEN error_code ELSE '' END
+		WHERE item_id = ?`
+	_, err := man.db.Exec(query, at.UTC().Format(timestampFormat), types.ItemStatusPendingExpiration, types.ItemStatusActive,
+		types.ItemStatusPendingExpiration, itemID)
+	if err != nil {
+		return utils.NewHTTPError(http.StatusInternalServerError, fmt.Sprintf("error recording plaid item sync: %v", err))
+	}
+	return nil
+}
+
+// FetchPlaidItems returns every linked item, oldest first.
+func (man *Manager) FetchPlaidItems() ([]types.PlaidItem, *types.HTTPError) {
+	rows, err := man.db.Query("SELECT " + itemColumns + " FROM plaid_items ORDER BY created_at")
+	if err != nil {
+		return nil, utils.NewHTTPError(http.StatusInternalServerError, fmt.Sprintf("error fetching plaid items: %v", err))
+	}
+	defer func(rows *sql.Rows) {
+		err := rows.Close()
+		if err != nil {
+			log.Printf("error closing row: %v", err)
+		}
+	}(rows)
+
+	var items []types.PlaidItem
+	for rows.Next() {
+		item, err := scanPlaidItem(rows)
+		if err != nil {
+			return nil, utils.NewHTTPError(http.StatusInternalServerError, fmt.Sprintf("error scanning plaid item: %v", err))
+		}
+		items = append(items, item)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, utils.NewHTTPError(http.StatusInternalServerError, fmt.Sprintf("error iterating plaid item rows: %v", err))
+	}
+
+	return items, nil
+}
+
+// anonymizedDescription replaces the description of expenses anonymized when their item is removed.
+const anonymizedDescription = "Removed bank transaction"
+
+// RemovePlaidItem forgets a linked item in a single transaction: its token and sync cursor are deleted, its
+// accounts closed and their liability terms dropped, and its expenses kept, anonymized or deleted as removal's
+// ExpenseAction says. The removal is recorded and returned with what it affected, or a 404 if there is no such
+// item. Balance and holdings history on the closed accounts is kept.
+//
+// Expenses belong to the item they were synced from, or failing that to the item of their account. Plaid
+// expenses imported before either was recorded can't be traced to an item; when expenses are anonymized or
+// deleted, those are left as they are and counted in ExpensesLeftBehind. Anonymized expenses lose their Plaid
+// transaction ID too, so relinking the bank imports its transactions as new expenses instead of restoring the
+// details stripped from these.
+func (man *Manager) RemovePlaidItem(removal types.ItemRemoval) (*types.ItemRemoval, *types.HTTPError) {
+	item, httpErr := man.FetchPlaidItem(removal.ItemID)
+	if httpErr != nil {
+		return nil, httpErr
+	}
+	removal.Institution = item.Institution
+	removal.RemovedAt = time.Now().UTC().Truncate(time.Second)
+
+	tx, err := man.db.Begin()
+	if err != nil {
+		return nil, utils.NewHTTPError(http.StatusInternalServerError, fmt.Sprintf("error starting item removal transaction: %v", err))
+	}
+	defer rollback(tx)
+
+	const itemAccounts = `SELECT account_id FROM accounts WHERE item_id = ?`
+	const itemExpenses = `(item_id = ? OR account_id IN (` + itemAccounts + `))`
+	var expensesQuery string
+	var expensesArgs []interface{}
+	switch removal.ExpenseAction {
+	case types.ExpensesKeep:
+	case types.ExpensesAnonymize:
+		const correctionsQuery = `UPDATE category_corrections SET merchant = '', description = ?
+			WHERE expense_id IN (SELECT id FROM expenses WHERE ` + itemExpenses + `)`
+		if _, err := tx.Exec(correctionsQuery, anonymizedDescription, removal.ItemID, removal.ItemID); err != nil {
+			return nil, utils.NewHTTPError(http.StatusInternalServerError, fmt.Sprintf("error anonymizing category corrections: %v", err))
+		}
+		expensesQuery = `UPDATE expenses SET external_id = NULL, description = ?, merchant = '', plaid_category = '',
+			pfc_primary = '', pfc_detailed = '', pfc_confidence = '' WHERE ` + itemExpenses
+		expensesArgs = []interface{}{anonymizedDescription, removal.ItemID, removal.ItemID}
+	case types.ExpensesDelete:
+		expensesQuery = `DELETE FROM expenses WHERE ` + itemExpenses
+		expensesArgs = []interface{}{removal.ItemID, removal.ItemID}
+	default:
+		return nil, utils.NewHTTPError(http.StatusBadRequest, fmt.Sprintf("expenses must be %v, %v or %v, not %q",
+			types.ExpensesKeep, types.ExpensesAnonymize, types.ExpensesDelete, removal.ExpenseAction))
+	}
+	if expensesQuery != "" {
+		result, err := tx.Exec(expensesQuery, expensesArgs...)
+		if err != nil {
+			return nil, utils.NewHTTPError(http.StatusInternalServerError, fmt.Sprintf("error purging item expenses: %v", err))
+		}
+		affected, _ := result.RowsAffected()
+		removal.ExpensesAffected = int(affected)
+
+		const untracedQuery = `SELECT COUNT(*) FROM expenses WHERE source = ? AND item_id = ''
+			AND account_id NOT IN (SELECT account_id FROM accounts)`
+		if err := tx.QueryRow(untracedQuery, types.ExpenseSourcePlaid).Scan(&removal.ExpensesLeftBehind); err != nil {
+			return nil, utils.NewHTTPError(http.StatusInternalServerError, fmt.Sprintf("error counting untraced expenses: %v", err))
+		}
+	} else {
+		const countQuery = `SELECT COUNT(*) FROM expenses WHERE ` + itemExpenses
+		if err := tx.QueryRow(countQuery, removal.ItemID, removal.ItemID).Scan(&removal.ExpensesAffected); err != nil {
+			return nil, utils.NewHTTPError(http.StatusInternalServerError, fmt.Sprintf("error counting item expenses: %v", err))
+		}
+	}
+
+	if _, err := tx.Exec(`DELETE FROM liabilities WHERE account_id IN (`+itemAccounts+`)`, removal.ItemID); err != nil {
+		return nil, utils.NewHTTPError(http.StatusInternalServerError, fmt.Sprintf("error deleting item liabilities: %v", err))
+	}
+	result, err := tx.Exec(`UPDATE accounts SET closed_at = ? WHERE item_id = ? AND closed_at IS NULL`,
+		removal.RemovedAt.Format(timestampFormat), removal.ItemID)
+	if err != nil {
+		return nil, utils.NewHTTPError(http.StatusInternalServerError, fmt.Sprintf("error closing item accounts: %v", err))
+	}
+	closed, _ := result.RowsAffected()
+	removal.AccountsClosed = int(closed)
+
+	if _, err := tx.Exec(`DELETE FROM plaid_sync_cursors WHERE item_id = ?`, removal.ItemID); err != nil {
+		return nil, utils.NewHTTPError(http.StatusInternalServerError, fmt.Sprintf("error deleting sync cursor: %v", err))
+	}
+	if _, err := tx.Exec(`DELETE FROM plaid_items WHERE item_id = ?`, removal.ItemID); err != nil {
+		return nil, utils.NewHTTPError(http.StatusInternalServerError, fmt.Sprintf("error deleting plaid item: %v", err))
+	}
+
+	const auditQuery = `INSERT INTO item_removals (item_id, institution, expense_action, accounts_closed, expenses_affected,
+		expenses_left_behind, removed_at) VALUES (?, ?, ?, ?, ?, ?, ?)`
+	result, err = tx.Exec(auditQuery, removal.ItemID, removal.Institution, removal.ExpenseAction, removal.AccountsClosed,
+		removal.ExpensesAffected, removal.ExpensesLeftBehind, removal.RemovedAt.Format(timestampFormat))
+	if err != nil {
+		return nil, utils.NewHTTPError(http.StatusInternalServerError, fmt.Sprintf("error recording item removal: %v", err))
+	}
+	if removal.ID, err = result.LastInsertId(); err != nil {
+		return nil, utils.NewHTTPError(http.StatusInternalServerError, fmt.Sprintf("error reading item removal id: %v", err))
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, utils.NewHTTPError(http.StatusInternalServerError, fmt.Sprintf("error committing item removal: %v", err))
+	}
+	return &removal, nil
+}
+
+// ListItemRemovals returns the record of removed items, newest first.
+func (man *Manager) ListItemRemovals() ([]types.ItemRemoval, *types.HTTPError) {
+	const query = `SELECT id, item_id, institution, expense_action, accounts_closed, expenses_affected, expenses_left_behind,
+		removed_at FROM item_removals ORDER BY removed_at DESC, id DESC`
+	rows, err := man.db.Query(query)
+	if err != nil {
+		return nil, utils.NewHTTPError(http.StatusInternalServerError, fmt.Sprintf("error fetching item removals: %v", err))
+	}
+	defer func(rows *sql.Rows) {
+		err := rows.Close()
+		if err != nil {
+			log.Printf("error closing row: %v", err)
+		}
+	}(rows)
+
+	removals := []types.ItemRemoval{}
+	for rows.Next() {
+		var removal types.ItemRemoval
+		var removedAt string
+		if err := rows.Scan(&removal.ID, &removal.ItemID, &removal.Institution, &removal.ExpenseAction,
+			&removal.AccountsClosed, &removal.ExpensesAffected, &removal.ExpensesLeftBehind, &removedAt); err != nil {
+			return nil, utils.NewHTTPError(http.StatusInternalServerError, fmt.Sprintf("error scanning item removal: %v", err))
+		}
+		removal.RemovedAt, err = parseTimestamp(removedAt)
+		if err != nil {
+			return nil, utils.NewHTTPError(http.StatusInternalServerError, fmt.Sprintf("error parsing item removal removed_at: %v", err))
+		}
+		removals = append(removals, removal)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, utils.NewHTTPError(http.StatusInternalServerError, fmt.Sprintf("error iterating item removal rows: %v", err))
+	}
+
+	return removals, nil
+}
@@ -0,0 +1,86 @@
+package db_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/Seymour-creates/budget-server/internal/db"
+	"github.com/Seymour-creates/budget-server/internal/testutil"
+	"github.com/Seymour-creates/budget-server/internal/types"
+)
+
+const anonymizedDescription = "Removed bank transaction"
+
+// linkItemWithExpenses stores item-1 with one account, and returns the expenses synced for it: one on the
+// account and one synced before the account was known.
+func linkItemWithExpenses(t *testing.T, man *db.Manager, day time.Time) []types.Expense {
+	t.Helper()
+	mustNot(t, man.InsertPlaidItem(types.PlaidItem{ItemID: "item-1", Status: types.ItemStatusActive,
+		EncryptedAccessToken: []byte("token"), EncryptedKey: []byte("key")}))
+	mustNot(t, man.UpsertAccounts([]types.Account{{AccountID: "acc-1", ItemID: "item-1", Name: "Checking"}}))
+	synced := []types.Expense{
+		{Source: types.ExpenseSourcePlaid, ExternalID: "txn-1", Date: day, Description: "COFFEE", Merchant: "Blue Bottle",
+			AccountID: "acc-1", Amount: 4.5, Category: "takeout"},
+		{Source: types.ExpenseSourcePlaid, ExternalID: "txn-2", Date: day, Description: "PIZZA", Amount: 20, Category: "takeout"},
+	}
+	mustNot(t, man.ApplyTransactionSync("item-1", types.TransactionSync{Added: synced, Cursor: "cursor-1"}))
+	return synced
+}
+
+func TestRemovePlaidItemAnonymizesEveryItemExpense(t *testing.T) {
+	man := testutil.NewDB(t)
+	day := time.Date(2024, 5, 2, 0, 0, 0, 0, time.UTC)
+	linkItemWithExpenses(t, man, day)
+	// Imported before expenses recorded their item or account, so it can't be traced.
+	mustNot(t, man.InsertExpenses([]types.Expense{{Source: types.ExpenseSourcePlaid, ExternalID: "txn-old", Date: day,
+		Description: "OLD", Amount: 1, Category: "misc"}}))
+
+	removal, httpErr := man.RemovePlaidItem(types.ItemRemoval{ItemID: "item-1", ExpenseAction: types.ExpensesAnonymize})
+	mustNot(t, httpErr)
+	if removal.ExpensesAffected != 2 || removal.ExpensesLeftBehind != 1 {
+		t.Errorf("removal anonymized %d and left %d expenses, want 2 and 1", removal.ExpensesAffected, removal.ExpensesLeftBehind)
+	}
+	expenses, httpErr := man.FetchExpenses(day, day)
+	mustNot(t, httpErr)
+	for _, expense := range expenses {
+		if expense.Description == "OLD" {
+			continue
+		}
+		if expense.Description != anonymizedDescription || expense.Merchant != "" || expense.ExternalID != "" {
+			t.Errorf("expense not anonymized: %+v", expense)
+		}
+	}
+
+	removals, httpErr := man.ListItemRemovals()
+	mustNot(t, httpErr)
+	if len(removals) != 1 || removals[0].ExpensesLeftBehind != 1 {
+		t.Errorf("recorded removals = %+v, want one leaving 1 expense behind", removals)
+	}
+}
+
+func TestRelinkingLeavesAnonymizedExpensesAlone(t *testing.T) {
+	man := testutil.NewDB(t)
+	day := time.Date(2024, 5, 2, 0, 0, 0, 0, time.UTC)
+	synced := linkItemWithExpenses(t, man, day)
+	_, httpErr := man.RemovePlaidItem(types.ItemRemoval{ItemID: "item-1", ExpenseAction: types.ExpensesAnonymize})
+	mustNot(t, httpErr)
+
+	// The bank is linked again and sends the same transactions.
+	mustNot(t, man.ApplyTransactionSync("item-2", types.TransactionSync{Added: synced, Cursor: "cursor-1"}))
+	expenses, httpErr := man.FetchExpenses(day, day)
+	mustNot(t, httpErr)
+	var anonymized, imported int
+	for _, expense := range expenses {
+		switch {
+		case expense.Description == anonymizedDescription && expense.Merchant == "" && expense.ExternalID == "":
+			anonymized++
+		case expense.ExternalID == "txn-1" || expense.ExternalID == "txn-2":
+			imported++
+		default:
+			t.Errorf("unexpected expense %+v", expense)
+		}
+	}
+	if anonymized != 2 || imported != 2 {
+		t.Errorf("after relinking %d expenses are anonymized and %d imported, want 2 and 2", anonymized, imported)
+	}
+}
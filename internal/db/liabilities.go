@@ -0,0 +1,200 @@
+package db
+
+import (
+	"database/sql"
+	"fmt"
+	"github.com/Seymour-creates/budget-server/internal/types"
+	"github.com/Seymour-creates/budget-server/internal/utils"
+	"log"
+	"net/http"
+	"time"
+)
+
+// debtCategory is the budget category minimum payments are planned under.
+const debtCategory = "debt"
+
+const liabilityColumns = `l.account_id, l.kind, l.apr, l.minimum_payment, l.next_due_date, l.statement_balance,
+	l.last_payment_amount, l.last_payment_date, l.overdue`
+
+func scanLiability(row rowScanner, extra ...interface{}) (types.Liability, error) {
+	var liability types.Liability
+	var apr, minimumPayment, statementBalance, lastPaymentAmount sql.NullFloat64
+	var nextDueDate, lastPaymentDate sql.NullString
+	dest := append([]interface{}{&liability.AccountID, &liability.Kind, &apr, &minimumPayment, &nextDueDate,
+		&statementBalance, &lastPaymentAmount, &lastPaymentDate, &liability.Overdue}, extra...)
+	if err := row.Scan(dest...); err != nil {
+		return liability, err
+	}
+	liability.APR = nullFloat(apr)
+	liability.MinimumPayment = nullFloat(minimumPayment)
+	liability.StatementBalance = nullFloat(statementBalance)
+	liability.LastPaymentAmount = nullFloat(lastPaymentAmount)
+	var err error
+	if liability.NextDueDate, err = nullDate(nextDueDate); err != nil {
+		return liability, fmt.Errorf("parsing liability next_due_date: %w", err)
+	}
+	if liability.LastPaymentDate, err = nullDate(lastPaymentDate); err != nil {
+		return liability, fmt.Errorf("parsing liability last_payment_date: %w", err)
+	}
+	return liability, nil
+}
+
+func nullFloat(value sql.NullFloat64) *float64 {
+	if !value.Valid {
+		return nil
+	}
+	return &value.Float64
+}
+
+func nullDate(value sql.NullString) (*time.Time, error) {
+	if !value.Valid {
+		return nil, nil
+	}
+	date, err := time.Parse(dateFormat, value.String)
+	if err != nil {
+		return nil, err
+	}
+	return &date, nil
+}
+
+func formatNullDate(date *time.Time) sql.NullString {
+	if date == nil {
+		return sql.NullString{}
+	}
+	return sql.NullString{String: date.Format(dateFormat), Valid: true}
+}
+
+// UpsertLiabilities stores the repayment terms Plaid reports for liability accounts, replacing what was stored
+// for them before.
+func (man *Manager) UpsertLiabilities(liabilities []types.Liability) *types.HTTPError {
+	query := `INSERT INTO liabilities (account_id, kind, apr, minimum_payment, next_due_date, statement_balance,
+		last_payment_amount, last_payment_date, overdue, updated_at) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?) ` +
+		man.dialect.upsert([]string{"account_id"}, "kind", "apr", "minimum_payment", "next_due_date", "statement_balance",
+			"last_payment_amount", "last_payment_date", "overdue", "updated_at")
+
+	tx, err := man.db.Begin()
+	if err != nil {
+		return utils.NewHTTPError(http.StatusInternalServerError, fmt.Sprintf("error starting liabilities transaction: %v", err))
+	}
+	defer rollback(tx)
+
+	now := time.Now().UTC().Format(timestampFormat)
+	for _, liability := range liabilities {
+		_, err := tx.Exec(query, liability.AccountID, liability.Kind, liability.APR, liability.MinimumPayment,
+			formatNullDate(liability.NextDueDate), liability.StatementBalance, liability.LastPaymentAmount,
+			formatNullDate(liability.LastPaymentDate), liability.Overdue, now)
+		if err != nil {
+			return utils.NewHTTPError(http.StatusInternalServerError, fmt.Sprintf("error saving liability for account %v: %v", liability.AccountID, err))
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return utils.NewHTTPError(http.StatusInternalServerError, fmt.Sprintf("error committing liabilities: %v", err))
+	}
+	return nil
+}
+
+// ListUpcomingPayments returns the next payment due on each liability account that isn't hidden, if it falls
+// due on or before until, soonest first. Overdue payments are included whatever their date.
+func (man *Manager) ListUpcomingPayments(until time.Time) ([]types.UpcomingPayment, *types.HTTPError) {
+	query := "SELECT " + liabilityColumns + `, a.name, a.mask FROM liabilities l
+		JOIN accounts a ON a.account_id = l.account_id
+		WHERE a.hidden = FALSE AND l.next_due_date IS NOT NULL AND (l.next_due_date <= ? OR l.overdue = TRUE)
+		ORDER BY l.next_due_date, a.name`
+	rows, err := man.db.Query(query, until.Format(dateFormat))
+	if err != nil {
+		return nil, utils.NewHTTPError(http.StatusInternalServerError, fmt.Sprintf("error fetching upcoming payments: %v", err))
+	}
+	defer func(rows *sql.Rows) {
+		err := rows.Close()
+		if err != nil {
+			log.Printf("error closing row: %v", err)
+		}
+	}(rows)
+
+	payments := []types.UpcomingPayment{}
+	for rows.Next() {
+		var payment types.UpcomingPayment
+		payment.Liability, err = scanLiability(rows, &payment.AccountName, &payment.Mask)
+		if err != nil {
+			return nil, utils.NewHTTPError(http.StatusInternalServerError, fmt.Sprintf("error scanning upcoming payment: %v", err))
+		}
+		payments = append(payments, payment)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, utils.NewHTTPError(http.StatusInternalServerError, fmt.Sprintf("error iterating upcoming payment rows: %v", err))
+	}
+
+	return payments, nil
+}
+
+// plannedPayments plans the minimum payment of every liability account in budget under the debt category, for
+// each month it falls due within period. Payments repeat monthly from the next due date Plaid last reported;
+// nothing is planned before it, since earlier payments show up as expenses.
+func (man *Manager) plannedPayments(period types.Period) ([]types.Forecast, *types.HTTPError) {
+	if _, httpErr := man.FetchCategory(debtCategory); httpErr != nil {
+		if httpErr.StatusCode == http.StatusNotFound {
+			return nil, nil
+		}
+		return nil, httpErr
+	}
+	return man.minimumPayments(period, true)
+}
+
+// minimumPayments lists the minimum payment due on every liability account that isn't hidden, on each date it
+// falls due within period, repeating monthly from the next due date Plaid last reported. budgetOnly leaves out
+// accounts excluded from budgets.
+func (man *Manager) minimumPayments(period types.Period, budgetOnly bool) ([]types.Forecast, *types.HTTPError) {
+	query := "SELECT " + liabilityColumns + `, a.name FROM liabilities l JOIN accounts a ON a.account_id = l.account_id
+		WHERE a.hidden = FALSE AND a.closed_at IS NULL AND l.minimum_payment > 0
+		AND l.next_due_date IS NOT NULL AND l.next_due_date <= ?`
+	if budgetOnly {
+		query += " AND a.exclude_from_budget = FALSE"
+	}
+	query += " ORDER BY l.next_due_date, l.account_id"
+	rows, err := man.db.Query(query, period.End.Format(dateFormat))
+	if err != nil {
+		return nil, utils.NewHTTPError(http.StatusInternalServerError, fmt.Sprintf("error fetching liabilities: %v", err))
+	}
+	defer func(rows *sql.Rows) {
+		err := rows.Close()
+		if err != nil {
+			log.Printf("error closing row: %v", err)
+		}
+	}(rows)
+
+	var planned []types.Forecast
+	for rows.Next() {
+		var accountName string
+		liability, err := scanLiability(rows, &accountName)
+		if err != nil {
+			return nil, utils.NewHTTPError(http.StatusInternalServerError, fmt.Sprintf("error scanning liability: %v", err))
+		}
+		// Dates come back from the db as UTC midnight; compare them as calendar days in the period's zone.
+		next := *liability.NextDueDate
+		first := time.Date(next.Year(), next.Month(), next.Day(), 0, 0, 0, 0, period.Start.Location())
+		for months := 0; ; months++ {
+			due := utils.AddMonths(first, months)
+			if due.After(period.End) {
+				break
+			}
+			if due.Before(period.Start) {
+				continue
+			}
+			planned = append(planned, types.Forecast{
+				Period:      utils.StartOfMonth(due),
+				Amount:      *liability.MinimumPayment,
+				Category:    debtCategory,
+				Source:      types.ForecastSourceLiability,
+				AccountID:   liability.AccountID,
+				Description: fmt.Sprintf("Minimum payment on %v", accountName),
+				DueDate:     &due,
+			})
+		}
+	}
+	if err = rows.Err(); err != nil {
+		return nil, utils.NewHTTPError(http.StatusInternalServerError, fmt.Sprintf("error iterating liability rows: %v", err))
+	}
+
+	return planned, nil
+}
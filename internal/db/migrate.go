@@ -0,0 +1,263 @@
+package db
+
+import (
+	"database/sql"
+	"embed"
+	"fmt"
+	"io/fs"
+	"log"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Migrations live in migrations/<dialect>/ as NNNN_name.up.sql and NNNN_name.down.sql pairs. Statements
+// within a file are separated by a semicolon at the end of a line.
+//
+//go:embed migrations
+var migrationFiles embed.FS
+
+type migration struct {
+	version int
+	name    string
+	up      string
+	down    string
+}
+
+// Migrator applies the embedded schema migrations and records progress in the schema_migrations table.
+type Migrator struct {
+	db         *sql.DB
+	dialect    Dialect
+	migrations []migration
+}
+
+// NewMigrator loads the embedded migrations for dialect. Both dialects share version numbers, so a schema
+// version means the same thing whichever backend it was applied to.
+func NewMigrator(db *sql.DB, dialect Dialect) (*Migrator, error) {
+	migrations, err := loadMigrations(path.Join("migrations", string(dialect)))
+	if err != nil {
+		return nil, err
+	}
+	return &Migrator{db: db, dialect: dialect, migrations: migrations}, nil
+}
+
+func loadMigrations(dir string) ([]migration, error) {
+	entries, err := fs.ReadDir(migrationFiles, dir)
+	if err != nil {
+		return nil, fmt.Errorf("reading migrations: %w", err)
+	}
+
+	byVersion := map[int]*migration{}
+	for _, entry := range entries {
+		fileName := entry.Name()
+		base, direction, ok := cutDirection(fileName)
+		if !ok {
+			return nil, fmt.Errorf("migration %v must end in .up.sql or .down.sql", fileName)
+		}
+		prefix, name, _ := strings.Cut(base, "_")
+		version, err := strconv.Atoi(prefix)
+		if err != nil {
+			return nil, fmt.Errorf("migration %v must start with a numeric version: %w", fileName, err)
+		}
+		contents, err := migrationFiles.ReadFile(path.Join(dir, fileName))
+		if err != nil {
+			return nil, fmt.Errorf("reading migration %v: %w", fileName, err)
+		}
+
+		m, ok := byVersion[version]
+		if !ok {
+			m = &migration{version: version, name: name}
+			byVersion[version] = m
+		}
+		if direction == "up" {
+			m.up = string(contents)
+		} else {
+			m.down = string(contents)
+		}
+	}
+
+	migrations := make([]migration, 0, len(byVersion))
+	for _, m := range byVersion {
+		if m.up == "" || m.down == "" {
+			return nil, fmt.Errorf("migration %04d_%v is missing its up or down file", m.version, m.name)
+		}
+		migrations = append(migrations, *m)
+	}
+	sort.Slice(migrations, func(i, j int) bool { return migrations[i].version < migrations[j].version })
+	return migrations, nil
+}
+
+func cutDirection(fileName string) (base, direction string, ok bool) {
+	if base, ok = strings.CutSuffix(fileName, ".up.sql"); ok {
+		return base, "up", true
+	}
+	if base, ok = strings.CutSuffix(fileName, ".down.sql"); ok {
+		return base, "down", true
+	}
+	return "", "", false
+}
+
+// Version returns the highest applied migration version, or 0 for an empty database.
+func (m *Migrator) Version() (int, error) {
+	if err := m.ensureVersionTable(); err != nil {
+		return 0, err
+	}
+	var version sql.NullInt64
+	if err := m.db.QueryRow(`SELECT MAX(version) FROM schema_migrations`).Scan(&version); err != nil {
+		return 0, fmt.Errorf("reading schema version: %w", err)
+	}
+	return int(version.Int64), nil
+}
+
+// Up applies every migration newer than the current schema version, in order. A database created by hand
+// before migrations existed is adopted first rather than failing on tables that are already there.
+func (m *Migrator) Up() error {
+	current, err := m.Version()
+	if err != nil {
+		return err
+	}
+	if current == 0 {
+		legacy, err := m.tableExists("expenses")
+		if err != nil {
+			return err
+		}
+		if legacy {
+			if err := m.adoptLegacySchema(); err != nil {
+				return err
+			}
+			current = 1
+		}
+	}
+	for _, mig := range m.migrations {
+		if mig.version <= current {
+			continue
+		}
+		if err := m.exec(mig.up); err != nil {
+			return fmt.Errorf("applying migration %04d_%v: %w", mig.version, mig.name, err)
+		}
+		if _, err := m.db.Exec(`INSERT INTO schema_migrations (version) VALUES (?)`, mig.version); err != nil {
+			return fmt.Errorf("recording migration %04d_%v: %w", mig.version, mig.name, err)
+		}
+		log.Printf("applied migration %04d_%v", mig.version, mig.name)
+	}
+	return nil
+}
+
+// Down reverts the most recent steps migrations.
+func (m *Migrator) Down(steps int) error {
+	current, err := m.Version()
+	if err != nil {
+		return err
+	}
+	for i := len(m.migrations) - 1; i >= 0 && steps > 0; i-- {
+		mig := m.migrations[i]
+		if mig.version > current {
+			continue
+		}
+		if err := m.exec(mig.down); err != nil {
+			return fmt.Errorf("reverting migration %04d_%v: %w", mig.version, mig.name, err)
+		}
+		if _, err := m.db.Exec(`DELETE FROM schema_migrations WHERE version = ?`, mig.version); err != nil {
+			return fmt.Errorf("unrecording migration %04d_%v: %w", mig.version, mig.name, err)
+		}
+		log.Printf("reverted migration %04d_%v", mig.version, mig.name)
+		steps--
+	}
+	return nil
+}
+
+// legacyCopies carries the rows of a hand-made database over into the tables migration 0001 creates, using
+// only the columns the server read and wrote before migrations existed.
+const (
+	legacyExpensesCopy = `INSERT INTO expenses (date, description, amount, categoryID)
+		SELECT date, description, amount, categoryID FROM legacy_expenses`
+	legacyForecastCopy = `INSERT INTO forecast (period, categoryID, amount)
+		SELECT period, categoryID, amount FROM legacy_forecast`
+)
+
+// adoptLegacySchema brings a database whose expenses and forecast tables were created by hand up to schema
+// version 1: the old tables are renamed aside, migration 0001 creates them afresh, and their rows are copied
+// across, so the later migrations run against a known schema.
+func (m *Migrator) adoptLegacySchema() error {
+	if len(m.migrations) == 0 || m.migrations[0].version != 1 {
+		return fmt.Errorf("adopting existing database: migration 0001 not found")
+	}
+	forecast, err := m.tableExists("forecast")
+	if err != nil {
+		return err
+	}
+	statements := []string{`ALTER TABLE expenses RENAME TO legacy_expenses`}
+	if forecast {
+		statements = append(statements, `ALTER TABLE forecast RENAME TO legacy_forecast`)
+	}
+	statements = append(statements, splitStatements(m.migrations[0].up)...)
+	statements = append(statements, legacyExpensesCopy, `DROP TABLE legacy_expenses`)
+	if forecast {
+		statements = append(statements, legacyForecastCopy, `DROP TABLE legacy_forecast`)
+	}
+	for _, statement := range statements {
+		if _, err := m.db.Exec(statement); err != nil {
+			return fmt.Errorf("adopting existing database: %w", err)
+		}
+	}
+	if _, err := m.db.Exec(`INSERT INTO schema_migrations (version) VALUES (1)`); err != nil {
+		return fmt.Errorf("recording adopted database: %w", err)
+	}
+	log.Printf("adopted existing database as migration %04d_%v", m.migrations[0].version, m.migrations[0].name)
+	return nil
+}
+
+// tableExists reports whether the database already has a table called name.
+func (m *Migrator) tableExists(name string) (bool, error) {
+	query := `SELECT COUNT(*) FROM information_schema.tables WHERE table_schema = DATABASE() AND table_name = ?`
+	if m.dialect == SQLite {
+		query = `SELECT COUNT(*) FROM sqlite_master WHERE type = 'table' AND name = ?`
+	}
+	var count int
+	if err := m.db.QueryRow(query, name).Scan(&count); err != nil {
+		return false, fmt.Errorf("checking for table %v: %w", name, err)
+	}
+	return count > 0, nil
+}
+
+func (m *Migrator) ensureVersionTable() error {
+	const query = `CREATE TABLE IF NOT EXISTS schema_migrations (
+		version    INTEGER PRIMARY KEY,
+		applied_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+	)`
+	if _, err := m.db.Exec(query); err != nil {
+		return fmt.Errorf("creating schema_migrations table: %w", err)
+	}
+	return nil
+}
+
+// exec runs each statement of a migration file in turn. MySQL commits DDL implicitly, so a file that fails
+// part way must be fixed forward rather than relied on to roll back.
+func (m *Migrator) exec(script string) error {
+	for _, statement := range splitStatements(script) {
+		if _, err := m.db.Exec(statement); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func splitStatements(script string) []string {
+	var statements []string
+	var current strings.Builder
+	for _, line := range strings.Split(script, "\n") {
+		current.WriteString(line)
+		current.WriteString("\n")
+		if strings.HasSuffix(strings.TrimSpace(line), ";") {
+			if statement := strings.TrimSpace(current.String()); statement != ";" {
+				statements = append(statements, strings.TrimSuffix(statement, ";"))
+			}
+			current.Reset()
+		}
+	}
+	if statement := strings.TrimSpace(current.String()); statement != "" {
+		statements = append(statements, statement)
+	}
+	return statements
+}
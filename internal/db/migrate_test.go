@@ -0,0 +1,115 @@
+package db
+
+import (
+	"database/sql"
+	"testing"
+	"time"
+)
+
+// openTestDB opens a private in-memory SQLite database that is closed when the test ends.
+func openTestDB(t *testing.T) (*sql.DB, Dialect) {
+	t.Helper()
+	conn, dialect, err := Open("sqlite::memory:")
+	if err != nil {
+		t.Fatalf("opening in-memory db: %v", err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	return conn, dialect
+}
+
+func TestMigrateUpDownUp(t *testing.T) {
+	conn, dialect := openTestDB(t)
+	migrator, err := NewMigrator(conn, dialect)
+	if err != nil {
+		t.Fatalf("loading migrations: %v", err)
+	}
+	latest := migrator.migrations[len(migrator.migrations)-1].version
+
+	if err := migrator.Up(); err != nil {
+		t.Fatalf("first up: %v", err)
+	}
+	assertVersion(t, migrator, latest)
+
+	if err := migrator.Down(len(migrator.migrations)); err != nil {
+		t.Fatalf("down: %v", err)
+	}
+	assertVersion(t, migrator, 0)
+	var left []string
+	rows, err := conn.Query(`SELECT name FROM sqlite_master WHERE type = 'table'
+		AND name NOT IN ('schema_migrations', 'sqlite_sequence') ORDER BY name`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			t.Fatal(err)
+		}
+		left = append(left, name)
+	}
+	if len(left) != 0 {
+		t.Errorf("tables %v still exist after reverting every migration", left)
+	}
+
+	if err := migrator.Up(); err != nil {
+		t.Fatalf("second up: %v", err)
+	}
+	assertVersion(t, migrator, latest)
+}
+
+func TestMigrateAdoptsLegacySchema(t *testing.T) {
+	conn, dialect := openTestDB(t)
+	legacy := []string{
+		`CREATE TABLE expenses (id INTEGER PRIMARY KEY, date TEXT, description TEXT, amount REAL, categoryID TEXT)`,
+		`CREATE TABLE forecast (categoryID TEXT, amount REAL, period TEXT DEFAULT '2024-05-01')`,
+		`INSERT INTO expenses (date, description, amount, categoryID) VALUES ('2024-05-02', 'coffee', 4.5, 'takeout'),
+			('2024-05-03', 'gym', 30, 'fitness')`,
+		`INSERT INTO forecast (categoryID, amount) VALUES ('takeout', 100), ('fitness', 40)`,
+	}
+	for _, statement := range legacy {
+		if _, err := conn.Exec(statement); err != nil {
+			t.Fatalf("creating legacy schema: %v", err)
+		}
+	}
+	migrator, err := NewMigrator(conn, dialect)
+	if err != nil {
+		t.Fatalf("loading migrations: %v", err)
+	}
+	if err := migrator.Up(); err != nil {
+		t.Fatalf("up over legacy schema: %v", err)
+	}
+
+	man := NewDBManager(conn, dialect)
+	expenses, httpErr := man.FetchExpenses(time.Date(2024, 5, 1, 0, 0, 0, 0, time.UTC), time.Date(2024, 5, 31, 0, 0, 0, 0, time.UTC))
+	if httpErr != nil {
+		t.Fatalf("reading adopted expenses: %v", httpErr.Message)
+	}
+	var total float64
+	categories := map[string]bool{}
+	for _, expense := range expenses {
+		categories[expense.Category] = true
+		total += expense.Amount
+	}
+	if len(expenses) != 2 || !categories["takeout"] || !categories["fitness"] || total != 34.5 {
+		t.Errorf("adopted expenses = %+v, want takeout and fitness totalling 34.5", expenses)
+	}
+	var forecasts int
+	if err := conn.QueryRow(`SELECT COUNT(*) FROM forecast`).Scan(&forecasts); err != nil {
+		t.Fatal(err)
+	}
+	if forecasts != 2 {
+		t.Errorf("adopted %d forecasts, want 2", forecasts)
+	}
+}
+
+func assertVersion(t *testing.T, migrator *Migrator, want int) {
+	t.Helper()
+	version, err := migrator.Version()
+	if err != nil {
+		t.Fatalf("reading version: %v", err)
+	}
+	if version != want {
+		t.Fatalf("schema version = %d, want %d", version, want)
+	}
+}
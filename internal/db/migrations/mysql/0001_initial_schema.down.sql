@@ -0,0 +1,7 @@
+DROP TABLE plaid_sync_cursors;
+
+DROP TABLE plaid_items;
+
+DROP TABLE forecast;
+
+DROP TABLE expenses;
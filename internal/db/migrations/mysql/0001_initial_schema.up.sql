@@ -0,0 +1,35 @@
+CREATE TABLE expenses (
+    id          BIGINT AUTO_INCREMENT PRIMARY KEY,
+    source      VARCHAR(16)    NOT NULL DEFAULT 'cli',
+    external_id VARCHAR(128)   NULL,
+    date        DATE           NOT NULL,
+    description VARCHAR(255)   NOT NULL,
+    amount      DECIMAL(12, 2) NOT NULL,
+    categoryID  VARCHAR(64)    NOT NULL,
+    created_at  TIMESTAMP      NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    UNIQUE KEY uq_expenses_source_external_id (source, external_id),
+    KEY idx_expenses_date (date)
+);
+
+CREATE TABLE forecast (
+    id         BIGINT AUTO_INCREMENT PRIMARY KEY,
+    period     DATE           NOT NULL DEFAULT (DATE_FORMAT(CURRENT_DATE, '%Y-%m-01')),
+    categoryID VARCHAR(64)    NOT NULL,
+    amount     DECIMAL(12, 2) NOT NULL,
+    KEY idx_forecast_period (period)
+);
+
+CREATE TABLE plaid_items (
+    item_id      VARCHAR(64)     PRIMARY KEY,
+    institution  VARCHAR(255)    NOT NULL DEFAULT '',
+    access_token VARBINARY(1024) NOT NULL,
+    token_key    VARBINARY(128)  NOT NULL,
+    status       VARCHAR(32)     NOT NULL,
+    created_at   TIMESTAMP       NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE TABLE plaid_sync_cursors (
+    item_id      VARCHAR(64)  PRIMARY KEY,
+    cursor_value VARCHAR(512) NOT NULL,
+    updated_at   TIMESTAMP    NOT NULL DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP
+);
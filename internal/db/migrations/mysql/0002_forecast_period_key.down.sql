@@ -0,0 +1 @@
+ALTER TABLE forecast DROP INDEX uq_forecast_period_category;
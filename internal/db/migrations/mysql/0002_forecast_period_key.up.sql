@@ -0,0 +1,4 @@
+DELETE older FROM forecast older
+    JOIN forecast newer ON older.period = newer.period AND older.categoryID = newer.categoryID AND older.id < newer.id;
+
+ALTER TABLE forecast ADD UNIQUE KEY uq_forecast_period_category (period, categoryID);
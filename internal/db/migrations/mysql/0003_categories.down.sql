@@ -0,0 +1,21 @@
+ALTER TABLE forecast ADD COLUMN categoryID VARCHAR(64) NULL AFTER period;
+
+UPDATE forecast JOIN categories ON categories.id = forecast.category_id SET forecast.categoryID = categories.slug;
+
+ALTER TABLE forecast
+    DROP FOREIGN KEY fk_forecast_category,
+    DROP INDEX uq_forecast_period_category,
+    DROP COLUMN category_id,
+    MODIFY categoryID VARCHAR(64) NOT NULL,
+    ADD UNIQUE KEY uq_forecast_period_category (period, categoryID);
+
+ALTER TABLE expenses ADD COLUMN categoryID VARCHAR(64) NULL AFTER amount;
+
+UPDATE expenses JOIN categories ON categories.id = expenses.category_id SET expenses.categoryID = categories.slug;
+
+ALTER TABLE expenses
+    DROP FOREIGN KEY fk_expenses_category,
+    DROP COLUMN category_id,
+    MODIFY categoryID VARCHAR(64) NOT NULL;
+
+DROP TABLE categories;
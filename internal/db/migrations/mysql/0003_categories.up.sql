@@ -0,0 +1,44 @@
+CREATE TABLE categories (
+    id        BIGINT AUTO_INCREMENT PRIMARY KEY,
+    slug      VARCHAR(64)  NOT NULL,
+    name      VARCHAR(128) NOT NULL,
+    parent_id BIGINT       NULL,
+    archived  BOOLEAN      NOT NULL DEFAULT FALSE,
+    color     VARCHAR(16)  NOT NULL DEFAULT '',
+    UNIQUE KEY uq_categories_slug (slug),
+    CONSTRAINT fk_categories_parent FOREIGN KEY (parent_id) REFERENCES categories (id)
+);
+
+INSERT INTO categories (slug, name) VALUES
+    ('bill', 'Bills'),
+    ('debt', 'Debt'),
+    ('ent', 'Entertainment'),
+    ('misc', 'Miscellaneous'),
+    ('saving', 'Saving'),
+    ('takeout', 'Takeout');
+
+INSERT INTO categories (slug, name)
+    SELECT DISTINCT categoryID, categoryID FROM expenses WHERE categoryID NOT IN (SELECT slug FROM categories);
+
+INSERT INTO categories (slug, name)
+    SELECT DISTINCT categoryID, categoryID FROM forecast WHERE categoryID NOT IN (SELECT slug FROM categories);
+
+ALTER TABLE expenses ADD COLUMN category_id BIGINT NULL AFTER amount;
+
+UPDATE expenses JOIN categories ON categories.slug = expenses.categoryID SET expenses.category_id = categories.id;
+
+ALTER TABLE expenses
+    MODIFY category_id BIGINT NOT NULL,
+    DROP COLUMN categoryID,
+    ADD CONSTRAINT fk_expenses_category FOREIGN KEY (category_id) REFERENCES categories (id);
+
+ALTER TABLE forecast ADD COLUMN category_id BIGINT NULL AFTER period;
+
+UPDATE forecast JOIN categories ON categories.slug = forecast.categoryID SET forecast.category_id = categories.id;
+
+ALTER TABLE forecast
+    DROP INDEX uq_forecast_period_category,
+    MODIFY category_id BIGINT NOT NULL,
+    DROP COLUMN categoryID,
+    ADD UNIQUE KEY uq_forecast_period_category (period, category_id),
+    ADD CONSTRAINT fk_forecast_category FOREIGN KEY (category_id) REFERENCES categories (id);
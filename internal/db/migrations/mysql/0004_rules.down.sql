@@ -0,0 +1,7 @@
+DROP TABLE rules;
+
+ALTER TABLE expenses
+    DROP COLUMN tags,
+    DROP COLUMN plaid_category,
+    DROP COLUMN account_id,
+    DROP COLUMN merchant;
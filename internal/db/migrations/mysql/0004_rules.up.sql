@@ -0,0 +1,22 @@
+ALTER TABLE expenses
+    ADD COLUMN merchant       VARCHAR(255) NOT NULL DEFAULT '' AFTER description,
+    ADD COLUMN account_id     VARCHAR(64)  NOT NULL DEFAULT '' AFTER merchant,
+    ADD COLUMN plaid_category VARCHAR(255) NOT NULL DEFAULT '' AFTER account_id,
+    ADD COLUMN tags           VARCHAR(255) NOT NULL DEFAULT '' AFTER category_id;
+
+CREATE TABLE rules (
+    id                  BIGINT AUTO_INCREMENT PRIMARY KEY,
+    position            INT            NOT NULL,
+    name                VARCHAR(128)   NOT NULL DEFAULT '',
+    merchant            VARCHAR(255)   NOT NULL DEFAULT '',
+    description_pattern VARCHAR(255)   NOT NULL DEFAULT '',
+    min_amount          DECIMAL(12, 2) NULL,
+    max_amount          DECIMAL(12, 2) NULL,
+    account_id          VARCHAR(64)    NOT NULL DEFAULT '',
+    plaid_category      VARCHAR(255)   NOT NULL DEFAULT '',
+    category_id         BIGINT         NOT NULL,
+    tags                VARCHAR(255)   NOT NULL DEFAULT '',
+    enabled             BOOLEAN        NOT NULL DEFAULT TRUE,
+    KEY idx_rules_position (position),
+    CONSTRAINT fk_rules_category FOREIGN KEY (category_id) REFERENCES categories (id)
+);
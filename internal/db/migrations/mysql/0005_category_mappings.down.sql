@@ -0,0 +1,6 @@
+DROP TABLE category_mappings;
+
+ALTER TABLE expenses
+    DROP COLUMN pfc_confidence,
+    DROP COLUMN pfc_detailed,
+    DROP COLUMN pfc_primary;
@@ -0,0 +1,36 @@
+ALTER TABLE expenses
+    ADD COLUMN pfc_primary    VARCHAR(64)  NOT NULL DEFAULT '' AFTER plaid_category,
+    ADD COLUMN pfc_detailed   VARCHAR(128) NOT NULL DEFAULT '' AFTER pfc_primary,
+    ADD COLUMN pfc_confidence VARCHAR(16)  NOT NULL DEFAULT '' AFTER pfc_detailed;
+
+CREATE TABLE category_mappings (
+    id             BIGINT AUTO_INCREMENT PRIMARY KEY,
+    pfc_primary    VARCHAR(64)  NOT NULL,
+    pfc_detailed   VARCHAR(128) NOT NULL DEFAULT '',
+    min_confidence VARCHAR(16)  NOT NULL DEFAULT '',
+    category_id    BIGINT       NOT NULL,
+    UNIQUE KEY uq_category_mappings_pfc (pfc_primary, pfc_detailed),
+    CONSTRAINT fk_category_mappings_category FOREIGN KEY (category_id) REFERENCES categories (id)
+);
+
+INSERT INTO category_mappings (pfc_primary, category_id)
+SELECT m.pfc_primary, c.id
+FROM (
+    SELECT 'INCOME' AS pfc_primary, 'saving' AS slug
+    UNION ALL SELECT 'TRANSFER_IN', 'saving'
+    UNION ALL SELECT 'TRANSFER_OUT', 'bill'
+    UNION ALL SELECT 'LOAN_PAYMENTS', 'debt'
+    UNION ALL SELECT 'BANK_FEES', 'bill'
+    UNION ALL SELECT 'ENTERTAINMENT', 'ent'
+    UNION ALL SELECT 'FOOD_AND_DRINK', 'takeout'
+    UNION ALL SELECT 'GENERAL_MERCHANDISE', 'misc'
+    UNION ALL SELECT 'HOME_IMPROVEMENT', 'bill'
+    UNION ALL SELECT 'MEDICAL', 'bill'
+    UNION ALL SELECT 'PERSONAL_CARE', 'misc'
+    UNION ALL SELECT 'GENERAL_SERVICES', 'bill'
+    UNION ALL SELECT 'GOVERNMENT_AND_NON_PROFIT', 'bill'
+    UNION ALL SELECT 'TRANSPORTATION', 'bill'
+    UNION ALL SELECT 'TRAVEL', 'ent'
+    UNION ALL SELECT 'RENT_AND_UTILITIES', 'bill'
+) m
+JOIN categories c ON c.slug = m.slug;
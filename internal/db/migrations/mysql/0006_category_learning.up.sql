@@ -0,0 +1,17 @@
+ALTER TABLE expenses
+    ADD COLUMN category_source     VARCHAR(16)   NOT NULL DEFAULT '' AFTER category_id,
+    ADD COLUMN category_confidence DECIMAL(5, 4) NULL AFTER category_source;
+
+CREATE TABLE category_corrections (
+    id               BIGINT AUTO_INCREMENT PRIMARY KEY,
+    expense_id       BIGINT       NOT NULL,
+    merchant         VARCHAR(255) NOT NULL DEFAULT '',
+    description      VARCHAR(255) NOT NULL DEFAULT '',
+    from_category_id BIGINT       NOT NULL,
+    to_category_id   BIGINT       NOT NULL,
+    created_at       TIMESTAMP    NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    KEY idx_category_corrections_expense (expense_id),
+    CONSTRAINT fk_category_corrections_expense FOREIGN KEY (expense_id) REFERENCES expenses (id) ON DELETE CASCADE,
+    CONSTRAINT fk_category_corrections_from FOREIGN KEY (from_category_id) REFERENCES categories (id) ON DELETE CASCADE,
+    CONSTRAINT fk_category_corrections_to FOREIGN KEY (to_category_id) REFERENCES categories (id) ON DELETE CASCADE
+);
@@ -0,0 +1,3 @@
+ALTER TABLE plaid_items
+    DROP COLUMN last_synced_at,
+    DROP COLUMN error_code;
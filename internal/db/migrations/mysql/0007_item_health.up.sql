@@ -0,0 +1,3 @@
+ALTER TABLE plaid_items
+    ADD COLUMN error_code     VARCHAR(64) NOT NULL DEFAULT '' AFTER status,
+    ADD COLUMN last_synced_at TIMESTAMP   NULL AFTER error_code;
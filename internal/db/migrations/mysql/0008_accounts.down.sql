@@ -0,0 +1,3 @@
+DROP INDEX idx_expenses_account ON expenses;
+
+DROP TABLE accounts;
@@ -0,0 +1,16 @@
+CREATE TABLE accounts (
+    account_id          VARCHAR(64)  PRIMARY KEY,
+    item_id             VARCHAR(64)  NOT NULL DEFAULT '',
+    name                VARCHAR(255) NOT NULL DEFAULT '',
+    official_name       VARCHAR(255) NOT NULL DEFAULT '',
+    mask                VARCHAR(16)  NOT NULL DEFAULT '',
+    type                VARCHAR(32)  NOT NULL DEFAULT '',
+    subtype             VARCHAR(64)  NOT NULL DEFAULT '',
+    currency            VARCHAR(8)   NOT NULL DEFAULT '',
+    hidden              BOOLEAN      NOT NULL DEFAULT FALSE,
+    exclude_from_budget BOOLEAN      NOT NULL DEFAULT FALSE,
+    created_at          TIMESTAMP    NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    KEY idx_accounts_item (item_id)
+);
+
+CREATE INDEX idx_expenses_account ON expenses (account_id);
@@ -0,0 +1,11 @@
+CREATE TABLE account_balances (
+    account_id   VARCHAR(64)    NOT NULL,
+    date         DATE           NOT NULL,
+    current      DECIMAL(14, 2) NOT NULL,
+    available    DECIMAL(14, 2) NULL,
+    credit_limit DECIMAL(14, 2) NULL,
+    currency     VARCHAR(8)     NOT NULL DEFAULT '',
+    PRIMARY KEY (account_id, date),
+    KEY idx_account_balances_date (date),
+    CONSTRAINT fk_account_balances_account FOREIGN KEY (account_id) REFERENCES accounts (account_id) ON DELETE CASCADE
+);
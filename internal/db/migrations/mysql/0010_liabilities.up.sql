@@ -0,0 +1,13 @@
+CREATE TABLE liabilities (
+    account_id          VARCHAR(64)    PRIMARY KEY,
+    kind                VARCHAR(16)    NOT NULL,
+    apr                 DECIMAL(7, 4)  NULL,
+    minimum_payment     DECIMAL(14, 2) NULL,
+    next_due_date       DATE           NULL,
+    statement_balance   DECIMAL(14, 2) NULL,
+    last_payment_amount DECIMAL(14, 2) NULL,
+    last_payment_date   DATE           NULL,
+    overdue             BOOLEAN        NOT NULL DEFAULT FALSE,
+    updated_at          TIMESTAMP      NOT NULL DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP,
+    CONSTRAINT fk_liabilities_account FOREIGN KEY (account_id) REFERENCES accounts (account_id) ON DELETE CASCADE
+);
@@ -0,0 +1,44 @@
+CREATE TABLE securities (
+    security_id        VARCHAR(64)    PRIMARY KEY,
+    name               VARCHAR(255)   NOT NULL DEFAULT '',
+    ticker             VARCHAR(32)    NOT NULL DEFAULT '',
+    type               VARCHAR(32)    NOT NULL DEFAULT '',
+    is_cash_equivalent BOOLEAN        NOT NULL DEFAULT FALSE,
+    close_price        DECIMAL(18, 6) NULL,
+    close_price_as_of  DATE           NULL,
+    currency           VARCHAR(8)     NOT NULL DEFAULT '',
+    updated_at         TIMESTAMP      NOT NULL DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP
+);
+
+CREATE TABLE holdings (
+    account_id        VARCHAR(64)    NOT NULL,
+    security_id       VARCHAR(64)    NOT NULL,
+    date              DATE           NOT NULL,
+    quantity          DECIMAL(20, 8) NOT NULL,
+    institution_price DECIMAL(18, 6) NOT NULL,
+    institution_value DECIMAL(14, 2) NOT NULL,
+    cost_basis        DECIMAL(14, 2) NULL,
+    currency          VARCHAR(8)     NOT NULL DEFAULT '',
+    PRIMARY KEY (account_id, security_id, date),
+    KEY idx_holdings_date (date),
+    CONSTRAINT fk_holdings_account FOREIGN KEY (account_id) REFERENCES accounts (account_id) ON DELETE CASCADE,
+    CONSTRAINT fk_holdings_security FOREIGN KEY (security_id) REFERENCES securities (security_id)
+);
+
+CREATE TABLE investment_transactions (
+    investment_transaction_id VARCHAR(64)    PRIMARY KEY,
+    account_id                VARCHAR(64)    NOT NULL,
+    security_id               VARCHAR(64)    NULL,
+    date                      DATE           NOT NULL,
+    name                      VARCHAR(255)   NOT NULL DEFAULT '',
+    type                      VARCHAR(32)    NOT NULL DEFAULT '',
+    subtype                   VARCHAR(32)    NOT NULL DEFAULT '',
+    quantity                  DECIMAL(20, 8) NOT NULL DEFAULT 0,
+    price                     DECIMAL(18, 6) NOT NULL DEFAULT 0,
+    amount                    DECIMAL(14, 2) NOT NULL,
+    fees                      DECIMAL(14, 2) NULL,
+    currency                  VARCHAR(8)     NOT NULL DEFAULT '',
+    KEY idx_investment_transactions_account_date (account_id, date),
+    CONSTRAINT fk_investment_transactions_account FOREIGN KEY (account_id) REFERENCES accounts (account_id) ON DELETE CASCADE,
+    CONSTRAINT fk_investment_transactions_security FOREIGN KEY (security_id) REFERENCES securities (security_id)
+);
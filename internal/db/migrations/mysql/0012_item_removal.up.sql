@@ -0,0 +1,18 @@
+ALTER TABLE accounts ADD COLUMN closed_at TIMESTAMP NULL AFTER exclude_from_budget;
+
+ALTER TABLE expenses ADD COLUMN item_id VARCHAR(64) NOT NULL DEFAULT '' AFTER account_id;
+
+UPDATE expenses e JOIN accounts a ON a.account_id = e.account_id SET e.item_id = a.item_id WHERE e.source = 'plaid';
+
+CREATE INDEX idx_expenses_item ON expenses (item_id);
+
+CREATE TABLE item_removals (
+    id                   BIGINT AUTO_INCREMENT PRIMARY KEY,
+    item_id              VARCHAR(64)  NOT NULL,
+    institution          VARCHAR(255) NOT NULL DEFAULT '',
+    expense_action       VARCHAR(16)  NOT NULL,
+    accounts_closed      INT          NOT NULL DEFAULT 0,
+    expenses_affected    INT          NOT NULL DEFAULT 0,
+    expenses_left_behind INT          NOT NULL DEFAULT 0,
+    removed_at           TIMESTAMP    NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
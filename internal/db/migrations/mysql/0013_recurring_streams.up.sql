@@ -0,0 +1,19 @@
+CREATE TABLE recurring_streams (
+    stream_id         VARCHAR(64)    PRIMARY KEY,
+    source            VARCHAR(16)    NOT NULL,
+    account_id        VARCHAR(64)    NOT NULL DEFAULT '',
+    merchant          VARCHAR(255)   NOT NULL DEFAULT '',
+    description       VARCHAR(255)   NOT NULL DEFAULT '',
+    category          VARCHAR(64)    NOT NULL DEFAULT '',
+    direction         VARCHAR(8)     NOT NULL,
+    frequency         VARCHAR(16)    NOT NULL,
+    average_amount    DECIMAL(14, 2) NOT NULL,
+    last_amount       DECIMAL(14, 2) NOT NULL,
+    first_date        DATE           NOT NULL,
+    last_date         DATE           NOT NULL,
+    next_date         DATE           NULL,
+    transaction_count INT            NOT NULL DEFAULT 0,
+    is_active         BOOLEAN        NOT NULL DEFAULT TRUE,
+    updated_at        TIMESTAMP      NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    KEY idx_recurring_streams_account (account_id)
+);
@@ -0,0 +1,33 @@
+CREATE TABLE alerts (
+    id           BIGINT AUTO_INCREMENT PRIMARY KEY,
+    alert_key    VARCHAR(255)   NOT NULL,
+    kind         VARCHAR(32)    NOT NULL,
+    period_start DATE           NOT NULL,
+    period_end   DATE           NOT NULL,
+    category     VARCHAR(64)    NOT NULL DEFAULT '',
+    account_id   VARCHAR(64)    NOT NULL DEFAULT '',
+    expense_id   BIGINT         NOT NULL DEFAULT 0,
+    amount       DECIMAL(14, 2) NOT NULL,
+    threshold    DECIMAL(14, 2) NOT NULL,
+    message      VARCHAR(512)   NOT NULL,
+    created_at   TIMESTAMP      NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    UNIQUE KEY uq_alerts_key (alert_key)
+);
+
+CREATE TABLE alert_inbox (
+    alert_id BIGINT    PRIMARY KEY,
+    read_at  TIMESTAMP NULL,
+    CONSTRAINT fk_alert_inbox_alert FOREIGN KEY (alert_id) REFERENCES alerts (id) ON DELETE CASCADE
+);
+
+CREATE TABLE alert_deliveries (
+    alert_id        BIGINT        NOT NULL,
+    channel         VARCHAR(32)   NOT NULL,
+    attempts        INT           NOT NULL DEFAULT 0,
+    last_error      VARCHAR(512)  NOT NULL DEFAULT '',
+    next_attempt_at TIMESTAMP     NOT NULL,
+    delivered_at    TIMESTAMP     NULL,
+    PRIMARY KEY (alert_id, channel),
+    KEY idx_alert_deliveries_pending (delivered_at, next_attempt_at),
+    CONSTRAINT fk_alert_deliveries_alert FOREIGN KEY (alert_id) REFERENCES alerts (id) ON DELETE CASCADE
+);
@@ -0,0 +1,37 @@
+CREATE TABLE expenses (
+    id          INTEGER PRIMARY KEY AUTOINCREMENT,
+    source      TEXT    NOT NULL DEFAULT 'cli',
+    external_id TEXT    NULL,
+    date        TEXT    NOT NULL,
+    description TEXT    NOT NULL,
+    amount      REAL    NOT NULL,
+    categoryID  TEXT    NOT NULL,
+    created_at  TEXT    NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    UNIQUE (source, external_id)
+);
+
+CREATE INDEX idx_expenses_date ON expenses (date);
+
+CREATE TABLE forecast (
+    id         INTEGER PRIMARY KEY AUTOINCREMENT,
+    period     TEXT NOT NULL DEFAULT (strftime('%Y-%m-01', 'now')),
+    categoryID TEXT NOT NULL,
+    amount     REAL NOT NULL
+);
+
+CREATE INDEX idx_forecast_period ON forecast (period);
+
+CREATE TABLE plaid_items (
+    item_id      TEXT PRIMARY KEY,
+    institution  TEXT NOT NULL DEFAULT '',
+    access_token BLOB NOT NULL,
+    token_key    BLOB NOT NULL,
+    status       TEXT NOT NULL,
+    created_at   TEXT NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE TABLE plaid_sync_cursors (
+    item_id      TEXT PRIMARY KEY,
+    cursor_value TEXT NOT NULL,
+    updated_at   TEXT NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
@@ -0,0 +1 @@
+DROP INDEX uq_forecast_period_category;
@@ -0,0 +1,3 @@
+DELETE FROM forecast WHERE id NOT IN (SELECT MAX(id) FROM forecast GROUP BY period, categoryID);
+
+CREATE UNIQUE INDEX uq_forecast_period_category ON forecast (period, categoryID);
@@ -0,0 +1,41 @@
+CREATE TABLE expenses_old (
+    id          INTEGER PRIMARY KEY AUTOINCREMENT,
+    source      TEXT    NOT NULL DEFAULT 'cli',
+    external_id TEXT    NULL,
+    date        TEXT    NOT NULL,
+    description TEXT    NOT NULL,
+    amount      REAL    NOT NULL,
+    categoryID  TEXT    NOT NULL,
+    created_at  TEXT    NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    UNIQUE (source, external_id)
+);
+
+INSERT INTO expenses_old (id, source, external_id, date, description, amount, categoryID, created_at)
+    SELECT e.id, e.source, e.external_id, e.date, e.description, e.amount, c.slug, e.created_at
+    FROM expenses e JOIN categories c ON c.id = e.category_id;
+
+DROP TABLE expenses;
+
+ALTER TABLE expenses_old RENAME TO expenses;
+
+CREATE INDEX idx_expenses_date ON expenses (date);
+
+CREATE TABLE forecast_old (
+    id         INTEGER PRIMARY KEY AUTOINCREMENT,
+    period     TEXT NOT NULL DEFAULT (strftime('%Y-%m-01', 'now')),
+    categoryID TEXT NOT NULL,
+    amount     REAL NOT NULL
+);
+
+INSERT INTO forecast_old (id, period, categoryID, amount)
+    SELECT f.id, f.period, c.slug, f.amount FROM forecast f JOIN categories c ON c.id = f.category_id;
+
+DROP TABLE forecast;
+
+ALTER TABLE forecast_old RENAME TO forecast;
+
+CREATE INDEX idx_forecast_period ON forecast (period);
+
+CREATE UNIQUE INDEX uq_forecast_period_category ON forecast (period, categoryID);
+
+DROP TABLE categories;
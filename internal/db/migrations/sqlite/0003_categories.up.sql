@@ -0,0 +1,62 @@
+CREATE TABLE categories (
+    id        INTEGER PRIMARY KEY AUTOINCREMENT,
+    slug      TEXT    NOT NULL UNIQUE,
+    name      TEXT    NOT NULL,
+    parent_id INTEGER NULL REFERENCES categories (id),
+    archived  BOOLEAN NOT NULL DEFAULT FALSE,
+    color     TEXT    NOT NULL DEFAULT ''
+);
+
+INSERT INTO categories (slug, name) VALUES
+    ('bill', 'Bills'),
+    ('debt', 'Debt'),
+    ('ent', 'Entertainment'),
+    ('misc', 'Miscellaneous'),
+    ('saving', 'Saving'),
+    ('takeout', 'Takeout');
+
+INSERT INTO categories (slug, name)
+    SELECT DISTINCT categoryID, categoryID FROM expenses WHERE categoryID NOT IN (SELECT slug FROM categories);
+
+INSERT INTO categories (slug, name)
+    SELECT DISTINCT categoryID, categoryID FROM forecast WHERE categoryID NOT IN (SELECT slug FROM categories);
+
+CREATE TABLE expenses_new (
+    id          INTEGER PRIMARY KEY AUTOINCREMENT,
+    source      TEXT    NOT NULL DEFAULT 'cli',
+    external_id TEXT    NULL,
+    date        TEXT    NOT NULL,
+    description TEXT    NOT NULL,
+    amount      REAL    NOT NULL,
+    category_id INTEGER NOT NULL REFERENCES categories (id),
+    created_at  TEXT    NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    UNIQUE (source, external_id)
+);
+
+INSERT INTO expenses_new (id, source, external_id, date, description, amount, category_id, created_at)
+    SELECT e.id, e.source, e.external_id, e.date, e.description, e.amount, c.id, e.created_at
+    FROM expenses e JOIN categories c ON c.slug = e.categoryID;
+
+DROP TABLE expenses;
+
+ALTER TABLE expenses_new RENAME TO expenses;
+
+CREATE INDEX idx_expenses_date ON expenses (date);
+
+CREATE TABLE forecast_new (
+    id          INTEGER PRIMARY KEY AUTOINCREMENT,
+    period      TEXT    NOT NULL DEFAULT (strftime('%Y-%m-01', 'now')),
+    category_id INTEGER NOT NULL REFERENCES categories (id),
+    amount      REAL    NOT NULL
+);
+
+INSERT INTO forecast_new (id, period, category_id, amount)
+    SELECT f.id, f.period, c.id, f.amount FROM forecast f JOIN categories c ON c.slug = f.categoryID;
+
+DROP TABLE forecast;
+
+ALTER TABLE forecast_new RENAME TO forecast;
+
+CREATE INDEX idx_forecast_period ON forecast (period);
+
+CREATE UNIQUE INDEX uq_forecast_period_category ON forecast (period, category_id);
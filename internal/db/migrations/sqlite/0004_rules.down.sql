@@ -0,0 +1,9 @@
+DROP TABLE rules;
+
+ALTER TABLE expenses DROP COLUMN tags;
+
+ALTER TABLE expenses DROP COLUMN plaid_category;
+
+ALTER TABLE expenses DROP COLUMN account_id;
+
+ALTER TABLE expenses DROP COLUMN merchant;
@@ -0,0 +1,24 @@
+ALTER TABLE expenses ADD COLUMN merchant TEXT NOT NULL DEFAULT '';
+
+ALTER TABLE expenses ADD COLUMN account_id TEXT NOT NULL DEFAULT '';
+
+ALTER TABLE expenses ADD COLUMN plaid_category TEXT NOT NULL DEFAULT '';
+
+ALTER TABLE expenses ADD COLUMN tags TEXT NOT NULL DEFAULT '';
+
+CREATE TABLE rules (
+    id                  INTEGER PRIMARY KEY AUTOINCREMENT,
+    position            INTEGER NOT NULL,
+    name                TEXT    NOT NULL DEFAULT '',
+    merchant            TEXT    NOT NULL DEFAULT '',
+    description_pattern TEXT    NOT NULL DEFAULT '',
+    min_amount          REAL    NULL,
+    max_amount          REAL    NULL,
+    account_id          TEXT    NOT NULL DEFAULT '',
+    plaid_category      TEXT    NOT NULL DEFAULT '',
+    category_id         INTEGER NOT NULL REFERENCES categories (id),
+    tags                TEXT    NOT NULL DEFAULT '',
+    enabled             BOOLEAN NOT NULL DEFAULT TRUE
+);
+
+CREATE INDEX idx_rules_position ON rules (position);
@@ -0,0 +1,7 @@
+DROP TABLE category_mappings;
+
+ALTER TABLE expenses DROP COLUMN pfc_confidence;
+
+ALTER TABLE expenses DROP COLUMN pfc_detailed;
+
+ALTER TABLE expenses DROP COLUMN pfc_primary;
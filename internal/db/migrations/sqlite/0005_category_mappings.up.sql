@@ -0,0 +1,36 @@
+ALTER TABLE expenses ADD COLUMN pfc_primary TEXT NOT NULL DEFAULT '';
+
+ALTER TABLE expenses ADD COLUMN pfc_detailed TEXT NOT NULL DEFAULT '';
+
+ALTER TABLE expenses ADD COLUMN pfc_confidence TEXT NOT NULL DEFAULT '';
+
+CREATE TABLE category_mappings (
+    id             INTEGER PRIMARY KEY AUTOINCREMENT,
+    pfc_primary    TEXT    NOT NULL,
+    pfc_detailed   TEXT    NOT NULL DEFAULT '',
+    min_confidence TEXT    NOT NULL DEFAULT '',
+    category_id    INTEGER NOT NULL REFERENCES categories (id),
+    UNIQUE (pfc_primary, pfc_detailed)
+);
+
+INSERT INTO category_mappings (pfc_primary, category_id)
+SELECT m.pfc_primary, c.id
+FROM (
+    SELECT 'INCOME' AS pfc_primary, 'saving' AS slug
+    UNION ALL SELECT 'TRANSFER_IN', 'saving'
+    UNION ALL SELECT 'TRANSFER_OUT', 'bill'
+    UNION ALL SELECT 'LOAN_PAYMENTS', 'debt'
+    UNION ALL SELECT 'BANK_FEES', 'bill'
+    UNION ALL SELECT 'ENTERTAINMENT', 'ent'
+    UNION ALL SELECT 'FOOD_AND_DRINK', 'takeout'
+    UNION ALL SELECT 'GENERAL_MERCHANDISE', 'misc'
+    UNION ALL SELECT 'HOME_IMPROVEMENT', 'bill'
+    UNION ALL SELECT 'MEDICAL', 'bill'
+    UNION ALL SELECT 'PERSONAL_CARE', 'misc'
+    UNION ALL SELECT 'GENERAL_SERVICES', 'bill'
+    UNION ALL SELECT 'GOVERNMENT_AND_NON_PROFIT', 'bill'
+    UNION ALL SELECT 'TRANSPORTATION', 'bill'
+    UNION ALL SELECT 'TRAVEL', 'ent'
+    UNION ALL SELECT 'RENT_AND_UTILITIES', 'bill'
+) m
+JOIN categories c ON c.slug = m.slug;
@@ -0,0 +1,5 @@
+DROP TABLE category_corrections;
+
+ALTER TABLE expenses DROP COLUMN category_confidence;
+
+ALTER TABLE expenses DROP COLUMN category_source;
@@ -0,0 +1,15 @@
+ALTER TABLE expenses ADD COLUMN category_source TEXT NOT NULL DEFAULT '';
+
+ALTER TABLE expenses ADD COLUMN category_confidence REAL NULL;
+
+CREATE TABLE category_corrections (
+    id               INTEGER PRIMARY KEY AUTOINCREMENT,
+    expense_id       INTEGER   NOT NULL REFERENCES expenses (id) ON DELETE CASCADE,
+    merchant         TEXT      NOT NULL DEFAULT '',
+    description      TEXT      NOT NULL DEFAULT '',
+    from_category_id INTEGER   NOT NULL REFERENCES categories (id) ON DELETE CASCADE,
+    to_category_id   INTEGER   NOT NULL REFERENCES categories (id) ON DELETE CASCADE,
+    created_at       TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX idx_category_corrections_expense ON category_corrections (expense_id);
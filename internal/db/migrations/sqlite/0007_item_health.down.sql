@@ -0,0 +1,3 @@
+ALTER TABLE plaid_items DROP COLUMN last_synced_at;
+
+ALTER TABLE plaid_items DROP COLUMN error_code;
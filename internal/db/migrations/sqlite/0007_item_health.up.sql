@@ -0,0 +1,3 @@
+ALTER TABLE plaid_items ADD COLUMN error_code TEXT NOT NULL DEFAULT '';
+
+ALTER TABLE plaid_items ADD COLUMN last_synced_at TEXT NULL;
@@ -0,0 +1,17 @@
+CREATE TABLE accounts (
+    account_id          TEXT PRIMARY KEY,
+    item_id             TEXT      NOT NULL DEFAULT '',
+    name                TEXT      NOT NULL DEFAULT '',
+    official_name       TEXT      NOT NULL DEFAULT '',
+    mask                TEXT      NOT NULL DEFAULT '',
+    type                TEXT      NOT NULL DEFAULT '',
+    subtype             TEXT      NOT NULL DEFAULT '',
+    currency            TEXT      NOT NULL DEFAULT '',
+    hidden              BOOLEAN   NOT NULL DEFAULT FALSE,
+    exclude_from_budget BOOLEAN   NOT NULL DEFAULT FALSE,
+    created_at          TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX idx_accounts_item ON accounts (item_id);
+
+CREATE INDEX idx_expenses_account ON expenses (account_id);
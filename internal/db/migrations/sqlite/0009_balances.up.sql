@@ -0,0 +1,11 @@
+CREATE TABLE account_balances (
+    account_id   TEXT NOT NULL REFERENCES accounts (account_id) ON DELETE CASCADE,
+    date         TEXT NOT NULL,
+    current      REAL NOT NULL,
+    available    REAL NULL,
+    credit_limit REAL NULL,
+    currency     TEXT NOT NULL DEFAULT '',
+    PRIMARY KEY (account_id, date)
+);
+
+CREATE INDEX idx_account_balances_date ON account_balances (date);
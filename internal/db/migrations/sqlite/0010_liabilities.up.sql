@@ -0,0 +1,12 @@
+CREATE TABLE liabilities (
+    account_id          TEXT PRIMARY KEY REFERENCES accounts (account_id) ON DELETE CASCADE,
+    kind                TEXT      NOT NULL,
+    apr                 REAL      NULL,
+    minimum_payment     REAL      NULL,
+    next_due_date       TEXT      NULL,
+    statement_balance   REAL      NULL,
+    last_payment_amount REAL      NULL,
+    last_payment_date   TEXT      NULL,
+    overdue             BOOLEAN   NOT NULL DEFAULT FALSE,
+    updated_at          TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
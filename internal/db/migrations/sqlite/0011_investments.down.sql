@@ -0,0 +1,3 @@
+DROP TABLE investment_transactions;
+DROP TABLE holdings;
+DROP TABLE securities;
@@ -0,0 +1,40 @@
+CREATE TABLE securities (
+    security_id        TEXT PRIMARY KEY,
+    name               TEXT      NOT NULL DEFAULT '',
+    ticker             TEXT      NOT NULL DEFAULT '',
+    type               TEXT      NOT NULL DEFAULT '',
+    is_cash_equivalent BOOLEAN   NOT NULL DEFAULT FALSE,
+    close_price        REAL      NULL,
+    close_price_as_of  TEXT      NULL,
+    currency           TEXT      NOT NULL DEFAULT '',
+    updated_at         TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE TABLE holdings (
+    account_id        TEXT NOT NULL REFERENCES accounts (account_id) ON DELETE CASCADE,
+    security_id       TEXT NOT NULL REFERENCES securities (security_id),
+    date              TEXT NOT NULL,
+    quantity          REAL NOT NULL,
+    institution_price REAL NOT NULL,
+    institution_value REAL NOT NULL,
+    cost_basis        REAL NULL,
+    currency          TEXT NOT NULL DEFAULT '',
+    PRIMARY KEY (account_id, security_id, date)
+);
+CREATE INDEX idx_holdings_date ON holdings (date);
+
+CREATE TABLE investment_transactions (
+    investment_transaction_id TEXT PRIMARY KEY,
+    account_id                TEXT NOT NULL REFERENCES accounts (account_id) ON DELETE CASCADE,
+    security_id               TEXT NULL REFERENCES securities (security_id),
+    date                      TEXT NOT NULL,
+    name                      TEXT NOT NULL DEFAULT '',
+    type                      TEXT NOT NULL DEFAULT '',
+    subtype                   TEXT NOT NULL DEFAULT '',
+    quantity                  REAL NOT NULL DEFAULT 0,
+    price                     REAL NOT NULL DEFAULT 0,
+    amount                    REAL NOT NULL,
+    fees                      REAL NULL,
+    currency                  TEXT NOT NULL DEFAULT ''
+);
+CREATE INDEX idx_investment_transactions_account_date ON investment_transactions (account_id, date);
@@ -0,0 +1,7 @@
+DROP TABLE item_removals;
+
+DROP INDEX idx_expenses_item;
+
+ALTER TABLE expenses DROP COLUMN item_id;
+
+ALTER TABLE accounts DROP COLUMN closed_at;
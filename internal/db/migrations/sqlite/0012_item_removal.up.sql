@@ -0,0 +1,19 @@
+ALTER TABLE accounts ADD COLUMN closed_at TEXT NULL;
+
+ALTER TABLE expenses ADD COLUMN item_id TEXT NOT NULL DEFAULT '';
+
+UPDATE expenses SET item_id = (SELECT a.item_id FROM accounts a WHERE a.account_id = expenses.account_id)
+WHERE source = 'plaid' AND account_id IN (SELECT account_id FROM accounts);
+
+CREATE INDEX idx_expenses_item ON expenses (item_id);
+
+CREATE TABLE item_removals (
+    id                   INTEGER PRIMARY KEY AUTOINCREMENT,
+    item_id              TEXT      NOT NULL,
+    institution          TEXT      NOT NULL DEFAULT '',
+    expense_action       TEXT      NOT NULL,
+    accounts_closed      INTEGER   NOT NULL DEFAULT 0,
+    expenses_affected    INTEGER   NOT NULL DEFAULT 0,
+    expenses_left_behind INTEGER   NOT NULL DEFAULT 0,
+    removed_at           TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
@@ -0,0 +1,20 @@
+CREATE TABLE recurring_streams (
+    stream_id         TEXT PRIMARY KEY,
+    source            TEXT      NOT NULL,
+    account_id        TEXT      NOT NULL DEFAULT '',
+    merchant          TEXT      NOT NULL DEFAULT '',
+    description       TEXT      NOT NULL DEFAULT '',
+    category          TEXT      NOT NULL DEFAULT '',
+    direction         TEXT      NOT NULL,
+    frequency         TEXT      NOT NULL,
+    average_amount    REAL      NOT NULL,
+    last_amount       REAL      NOT NULL,
+    first_date        TEXT      NOT NULL,
+    last_date         TEXT      NOT NULL,
+    next_date         TEXT      NULL,
+    transaction_count INTEGER   NOT NULL DEFAULT 0,
+    is_active         BOOLEAN   NOT NULL DEFAULT TRUE,
+    updated_at        TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX idx_recurring_streams_account ON recurring_streams (account_id);
@@ -0,0 +1,5 @@
+DROP INDEX idx_alert_deliveries_pending;
+
+DROP TABLE alert_deliveries;
+DROP TABLE alert_inbox;
+DROP TABLE alerts;
@@ -0,0 +1,31 @@
+CREATE TABLE alerts (
+    id           INTEGER PRIMARY KEY AUTOINCREMENT,
+    alert_key    TEXT      NOT NULL UNIQUE,
+    kind         TEXT      NOT NULL,
+    period_start TEXT      NOT NULL,
+    period_end   TEXT      NOT NULL,
+    category     TEXT      NOT NULL DEFAULT '',
+    account_id   TEXT      NOT NULL DEFAULT '',
+    expense_id   INTEGER   NOT NULL DEFAULT 0,
+    amount       REAL      NOT NULL,
+    threshold    REAL      NOT NULL,
+    message      TEXT      NOT NULL,
+    created_at   TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE TABLE alert_inbox (
+    alert_id INTEGER PRIMARY KEY REFERENCES alerts (id) ON DELETE CASCADE,
+    read_at  TIMESTAMP NULL
+);
+
+CREATE TABLE alert_deliveries (
+    alert_id        INTEGER   NOT NULL REFERENCES alerts (id) ON DELETE CASCADE,
+    channel         TEXT      NOT NULL,
+    attempts        INTEGER   NOT NULL DEFAULT 0,
+    last_error      TEXT      NOT NULL DEFAULT '',
+    next_attempt_at TIMESTAMP NOT NULL,
+    delivered_at    TIMESTAMP NULL,
+    PRIMARY KEY (alert_id, channel)
+);
+
+CREATE INDEX idx_alert_deliveries_pending ON alert_deliveries (delivered_at, next_attempt_at);
@@ -0,0 +1,130 @@
+package db
+
+import (
+	"database/sql"
+	"fmt"
+	"github.com/Seymour-creates/budget-server/internal/types"
+	"github.com/Seymour-creates/budget-server/internal/utils"
+	"log"
+	"net/http"
+	"time"
+)
+
+// priceIncreaseMargin is how far above its average an outflow's latest amount must be to count as a price
+// increase.
+const priceIncreaseMargin = 0.05
+
+// missedPaymentGrace is how many days after its expected date a stream's next transaction may post before it
+// counts as missed, by frequency.
+var missedPaymentGrace = map[string]int{
+	types.FrequencyWeekly:      2,
+	types.FrequencyBiweekly:    3,
+	types.FrequencySemiMonthly: 3,
+	types.FrequencyMonthly:     5,
+	types.FrequencyAnnually:    14,
+}
+
+const recurringStreamColumns = `r.stream_id, r.source, r.account_id, r.merchant, r.description, r.category, r.direction,
+	r.frequency, r.average_amount, r.last_amount, r.first_date, r.last_date, r.next_date, r.transaction_count, r.is_active,
+	r.updated_at`
+
+func scanRecurringStream(row rowScanner) (types.RecurringStream, error) {
+	var stream types.RecurringStream
+	var firstDate, lastDate, updatedAt string
+	var nextDate sql.NullString
+	if err := row.Scan(&stream.StreamID, &stream.Source, &stream.AccountID, &stream.Merchant, &stream.Description,
+		&stream.Category, &stream.Direction, &stream.Frequency, &stream.AverageAmount, &stream.LastAmount, &firstDate,
+		&lastDate, &nextDate, &stream.TransactionCount, &stream.Active, &updatedAt); err != nil {
+		return stream, err
+	}
+	var err error
+	if stream.FirstDate, err = time.Parse(dateFormat, firstDate); err != nil {
+		return stream, fmt.Errorf("parsing recurring stream first_date: %w", err)
+	}
+	if stream.LastDate, err = time.Parse(dateFormat, lastDate); err != nil {
+		return stream, fmt.Errorf("parsing recurring stream last_date: %w", err)
+	}
+	if stream.NextDate, err = nullDate(nextDate); err != nil {
+		return stream, fmt.Errorf("parsing recurring stream next_date: %w", err)
+	}
+	if stream.UpdatedAt, err = parseTimestamp(updatedAt); err != nil {
+		return stream, fmt.Errorf("parsing recurring stream updated_at: %w", err)
+	}
+	return stream, nil
+}
+
+// ReplaceRecurringStreams stores a fresh detection of recurring streams in a single transaction, replacing
+// every stream stored before.
+func (man *Manager) ReplaceRecurringStreams(streams []types.RecurringStream) *types.HTTPError {
+	const query = `INSERT INTO recurring_streams (stream_id, source, account_id, merchant, description, category,
+		direction, frequency, average_amount, last_amount, first_date, last_date, next_date, transaction_count, is_active,
+		updated_at) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`
+
+	tx, err := man.db.Begin()
+	if err != nil {
+		return utils.NewHTTPError(http.StatusInternalServerError, fmt.Sprintf("error starting recurring streams transaction: %v", err))
+	}
+	defer rollback(tx)
+
+	if _, err := tx.Exec("DELETE FROM recurring_streams"); err != nil {
+		return utils.NewHTTPError(http.StatusInternalServerError, fmt.Sprintf("error clearing recurring streams: %v", err))
+	}
+	now := time.Now().UTC().Format(timestampFormat)
+	for _, stream := range streams {
+		_, err := tx.Exec(query, stream.StreamID, stream.Source, stream.AccountID, stream.Merchant, stream.Description,
+			stream.Category, stream.Direction, stream.Frequency, stream.AverageAmount, stream.LastAmount,
+			stream.FirstDate.Format(dateFormat), stream.LastDate.Format(dateFormat), formatNullDate(stream.NextDate),
+			stream.TransactionCount, stream.Active, now)
+		if err != nil {
+			return utils.NewHTTPError(http.StatusInternalServerError, fmt.Sprintf("error saving recurring stream %v: %v", stream.StreamID, err))
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return utils.NewHTTPError(http.StatusInternalServerError, fmt.Sprintf("error committing recurring streams: %v", err))
+	}
+	return nil
+}
+
+// ListRecurringStreams returns the recurring streams on accounts that aren't hidden, outflows first and then
+// by merchant, flagging price increases and payments missed as of asOf. Inactive streams are left out unless
+// includeInactive is set.
+func (man *Manager) ListRecurringStreams(includeInactive bool, asOf time.Time) ([]types.RecurringStream, *types.HTTPError) {
+	query := "SELECT " + recurringStreamColumns + ` FROM recurring_streams r
+		WHERE r.account_id NOT IN (SELECT account_id FROM accounts WHERE hidden = TRUE)`
+	if !includeInactive {
+		query += " AND r.is_active = TRUE"
+	}
+	query += " ORDER BY r.direction DESC, r.merchant, r.stream_id"
+
+	rows, err := man.db.Query(query)
+	if err != nil {
+		return nil, utils.NewHTTPError(http.StatusInternalServerError, fmt.Sprintf("error fetching recurring streams: %v", err))
+	}
+	defer func(rows *sql.Rows) {
+		err := rows.Close()
+		if err != nil {
+			log.Printf("error closing row: %v", err)
+		}
+	}(rows)
+
+	today := time.Date(asOf.Year(), asOf.Month(), asOf.Day(), 0, 0, 0, 0, time.UTC)
+	streams := []types.RecurringStream{}
+	for rows.Next() {
+		stream, err := scanRecurringStream(rows)
+		if err != nil {
+			return nil, utils.NewHTTPError(http.StatusInternalServerError, fmt.Sprintf("error scanning recurring stream: %v", err))
+		}
+		stream.PriceIncrease = stream.Direction == types.StreamOutflow &&
+			stream.LastAmount > stream.AverageAmount*(1+priceIncreaseMargin)
+		if grace, ok := missedPaymentGrace[stream.Frequency]; ok && stream.Active && stream.NextDate != nil {
+			stream.MissedPayment = today.After(stream.NextDate.AddDate(0, 0, grace))
+		}
+		streams = append(streams, stream)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, utils.NewHTTPError(http.StatusInternalServerError, fmt.Sprintf("error iterating recurring stream rows: %v", err))
+	}
+
+	return streams, nil
+}
@@ -0,0 +1,106 @@
+package db
+
+import (
+	"database/sql"
+	"fmt"
+	"github.com/Seymour-creates/budget-server/internal/types"
+	"github.com/Seymour-creates/budget-server/internal/utils"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// GetVarianceReport totals actual spending and planned forecast per category over period, leaving out accounts
+// kept out of budgets. Minimum payments due on liability accounts count as planned debt, spread evenly over the
+// days of each month from the next due date on, so a week is planned a week's share of them. Categories that
+// appear on only one side are still reported, with zero for the other.
+func (man *Manager) GetVarianceReport(period types.Period) (*types.VarianceReport, *types.HTTPError) {
+	months, monthArgs := monthShares(period)
+	query := `SELECT c.slug, SUM(v.planned), SUM(v.actual)
+		FROM (
+			SELECT category_id, amount AS planned, 0 AS actual FROM forecast WHERE period >= ? AND period <= ?
+			UNION ALL
+			SELECT category_id, 0, amount FROM expenses e WHERE date >= ? AND date <= ? AND ` + budgetAccountsCondition + `
+			UNION ALL
+			SELECT debt.id, l.minimum_payment * m.share, 0
+			FROM liabilities l
+			JOIN accounts a ON a.account_id = l.account_id
+			JOIN (` + months + `) m ON l.next_due_date <= m.month_end
+			JOIN categories debt ON debt.slug = ?
+			WHERE a.hidden = FALSE AND a.exclude_from_budget = FALSE AND l.minimum_payment > 0
+		) v
+		JOIN categories c ON c.id = v.category_id
+		GROUP BY c.slug
+		ORDER BY c.slug`
+
+	forecastStart := utils.StartOfMonth(period.Start).Format(dateFormat)
+	start, end := period.Start.Format(dateFormat), period.End.Format(dateFormat)
+	args := append([]interface{}{forecastStart, end, start, end}, monthArgs...)
+	rows, err := man.db.Query(query, append(args, debtCategory)...)
+	if err != nil {
+		return nil, utils.NewHTTPError(http.StatusInternalServerError, fmt.Sprintf("error building variance report: %v", err))
+	}
+	defer func(rows *sql.Rows) {
+		err := rows.Close()
+		if err != nil {
+			log.Printf("error closing row: %v", err)
+		}
+	}(rows)
+
+	report := &types.VarianceReport{Period: period, Categories: []types.CategoryVariance{}}
+	for rows.Next() {
+		var variance types.CategoryVariance
+		if err := rows.Scan(&variance.Category, &variance.Planned, &variance.Actual); err != nil {
+			return nil, utils.NewHTTPError(http.StatusInternalServerError, fmt.Sprintf("error scanning variance row: %v", err))
+		}
+		report.Totals.Planned += variance.Planned
+		report.Totals.Actual += variance.Actual
+		report.Categories = append(report.Categories, computeVariance(variance))
+	}
+	if err = rows.Err(); err != nil {
+		return nil, utils.NewHTTPError(http.StatusInternalServerError, fmt.Sprintf("error iterating variance rows: %v", err))
+	}
+	report.Totals = computeVariance(report.Totals)
+
+	return report, nil
+}
+
+// monthShares returns a derived table with a row for each month period overlaps, holding the month's last day
+// and the share of its days that fall within period, along with the arguments it binds.
+func monthShares(period types.Period) (string, []interface{}) {
+	var rows []string
+	var args []interface{}
+	for month := utils.StartOfMonth(period.Start); !month.After(period.End); month = utils.AddMonths(month, 1) {
+		monthEnd := utils.AddMonths(month, 1).AddDate(0, 0, -1)
+		from, to := month, monthEnd
+		if period.Start.After(from) {
+			from = period.Start
+		}
+		if period.End.Before(to) {
+			to = period.End
+		}
+		rows = append(rows, "SELECT ? AS month_end, ? AS share")
+		args = append(args, monthEnd.Format(dateFormat), float64(days(from, to))/float64(days(month, monthEnd)))
+	}
+	return strings.Join(rows, " UNION ALL "), args
+}
+
+// days counts the calendar days from first to last inclusive.
+func days(first, last time.Time) int {
+	first = time.Date(first.Year(), first.Month(), first.Day(), 0, 0, 0, 0, time.UTC)
+	last = time.Date(last.Year(), last.Month(), last.Day(), 0, 0, 0, 0, time.UTC)
+	return int(last.Sub(first).Hours()/24) + 1
+}
+
+// computeVariance fills in the fields derived from Planned and Actual.
+func computeVariance(v types.CategoryVariance) types.CategoryVariance {
+	v.Remaining = v.Planned - v.Actual
+	if v.Planned != 0 {
+		percent := v.Actual / v.Planned * 100
+		v.PercentUsed = &percent
+	}
+	v.Over = v.Actual > v.Planned
+	v.Under = v.Actual < v.Planned
+	return v
+}
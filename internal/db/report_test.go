@@ -0,0 +1,139 @@
+package db_test
+
+import (
+	"math"
+	"testing"
+	"time"
+
+	"github.com/Seymour-creates/budget-server/internal/testutil"
+	"github.com/Seymour-creates/budget-server/internal/types"
+)
+
+func TestVarianceReport(t *testing.T) {
+	man := testutil.NewDB(t)
+	may := time.Date(2024, 5, 1, 0, 0, 0, 0, time.UTC)
+	mustNot(t, man.InsertForecast([]types.Forecast{{Period: may, Category: "takeout", Amount: 100},
+		{Period: may, Category: "bill", Amount: 900}, {Period: may.AddDate(0, 1, 0), Category: "takeout", Amount: 500}}))
+	mustNot(t, man.InsertExpenses([]types.Expense{
+		{Date: may.AddDate(0, 0, 2), Description: "pizza", Amount: 30, Category: "takeout"},
+		{Date: may.AddDate(0, 0, 20), Description: "sushi", Amount: 90, Category: "takeout"},
+		{Date: may.AddDate(0, 0, 5), Description: "stamps", Amount: 15, Category: "misc"},
+		{Date: may.AddDate(0, 1, 0), Description: "june pizza", Amount: 40, Category: "takeout"},
+	}))
+
+	report, httpErr := man.GetVarianceReport(types.Period{Start: may, End: may.AddDate(0, 1, -1)})
+	mustNot(t, httpErr)
+	want := map[string]struct {
+		planned, actual float64
+		over, under     bool
+	}{
+		"bill":    {900, 0, false, true},
+		"misc":    {0, 15, true, false},
+		"takeout": {100, 120, true, false},
+	}
+	if len(report.Categories) != len(want) {
+		t.Fatalf("report categories = %+v, want %d", report.Categories, len(want))
+	}
+	for _, variance := range report.Categories {
+		expected, ok := want[variance.Category]
+		if !ok || variance.Planned != expected.planned || variance.Actual != expected.actual ||
+			variance.Over != expected.over || variance.Under != expected.under {
+			t.Errorf("%v variance = %+v, want %+v", variance.Category, variance, expected)
+		}
+		if variance.Remaining != variance.Planned-variance.Actual {
+			t.Errorf("%v remaining = %v, want %v", variance.Category, variance.Remaining, variance.Planned-variance.Actual)
+		}
+	}
+	if takeout := report.Categories[2]; takeout.PercentUsed == nil || *takeout.PercentUsed != 120 {
+		t.Errorf("takeout percent used = %v, want 120", takeout.PercentUsed)
+	}
+	if misc := report.Categories[1]; misc.PercentUsed != nil {
+		t.Errorf("unplanned misc percent used = %v, want none", *misc.PercentUsed)
+	}
+	if report.Totals.Planned != 1000 || report.Totals.Actual != 135 || report.Totals.Remaining != 865 {
+		t.Errorf("totals = %+v, want 1000 planned and 135 spent", report.Totals)
+	}
+}
+
+func TestVarianceReportLeavesOutExcludedAccounts(t *testing.T) {
+	man := testutil.NewDB(t)
+	mustNot(t, man.InsertPlaidItem(types.PlaidItem{ItemID: "item-1", Institution: "Bank", Status: types.ItemStatusActive,
+		EncryptedAccessToken: []byte("token"), EncryptedKey: []byte("key")}))
+	mustNot(t, man.UpsertAccounts([]types.Account{
+		{AccountID: "acc-everyday", ItemID: "item-1", Name: "Checking", Type: "depository"},
+		{AccountID: "acc-business", ItemID: "item-1", Name: "Business", Type: "depository"},
+	}))
+	exclude := true
+	_, httpErr := man.UpdateAccount("acc-business", types.AccountPatch{ExcludeFromBudget: &exclude})
+	mustNot(t, httpErr)
+
+	may := time.Date(2024, 5, 1, 0, 0, 0, 0, time.UTC)
+	mustNot(t, man.InsertExpenses([]types.Expense{
+		{Date: may, Description: "pizza", AccountID: "acc-everyday", Amount: 30, Category: "takeout"},
+		{Date: may, Description: "client lunch", AccountID: "acc-business", Amount: 200, Category: "takeout"},
+		{Date: may, Description: "cash tacos", Amount: 12, Category: "takeout"},
+		{Date: may, Description: "printer ink", AccountID: "acc-business", Amount: 60, Category: "misc"},
+	}))
+
+	report, httpErr := man.GetVarianceReport(types.Period{Start: may, End: may.AddDate(0, 1, -1)})
+	mustNot(t, httpErr)
+	if len(report.Categories) != 1 || report.Categories[0].Category != "takeout" || report.Categories[0].Actual != 42 {
+		t.Errorf("report categories = %+v, want only takeout with 42 spent outside the excluded account", report.Categories)
+	}
+	if report.Totals.Actual != 42 {
+		t.Errorf("total spent = %v, want 42", report.Totals.Actual)
+	}
+}
+
+func TestVarianceReportProratesMinimumPayments(t *testing.T) {
+	man := testutil.NewDB(t)
+	mustNot(t, man.InsertPlaidItem(types.PlaidItem{ItemID: "item-1", Institution: "Bank", Status: types.ItemStatusActive,
+		EncryptedAccessToken: []byte("token"), EncryptedKey: []byte("key")}))
+	mustNot(t, man.UpsertAccounts([]types.Account{
+		{AccountID: "acc-card", ItemID: "item-1", Name: "Card", Type: "credit"},
+		{AccountID: "acc-business-card", ItemID: "item-1", Name: "Business Card", Type: "credit"},
+	}))
+	exclude := true
+	_, httpErr := man.UpdateAccount("acc-business-card", types.AccountPatch{ExcludeFromBudget: &exclude})
+	mustNot(t, httpErr)
+	minimum, businessMinimum := 310.0, 1000.0
+	due := time.Date(2024, 5, 20, 0, 0, 0, 0, time.UTC)
+	mustNot(t, man.UpsertLiabilities([]types.Liability{
+		{AccountID: "acc-card", Kind: "credit", MinimumPayment: &minimum, NextDueDate: &due},
+		{AccountID: "acc-business-card", Kind: "credit", MinimumPayment: &businessMinimum, NextDueDate: &due},
+	}))
+	mustNot(t, man.InsertForecast([]types.Forecast{{Period: due, Category: "debt", Amount: 50}}))
+
+	day := func(month time.Month, day int) time.Time { return time.Date(2024, month, day, 0, 0, 0, 0, time.UTC) }
+	tests := []struct {
+		name        string
+		period      types.Period
+		wantPlanned float64
+	}{
+		{"before the first due date", types.Period{Start: day(4, 1), End: day(4, 30)}, 0},
+		{"month", types.Period{Start: day(5, 1), End: day(5, 31)}, 50 + 310},
+		{"week", types.Period{Start: day(5, 6), End: day(5, 12)}, 50 + 310.0*7/31},
+		{"week across months", types.Period{Start: day(5, 27), End: day(6, 2)}, 50 + 310.0*5/31 + 310.0*2/30},
+		{"quarter", types.Period{Start: day(5, 1), End: day(7, 31)}, 50 + 3*310},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			report, httpErr := man.GetVarianceReport(test.period)
+			mustNot(t, httpErr)
+			var planned float64
+			for _, variance := range report.Categories {
+				if variance.Category == "debt" {
+					planned = variance.Planned
+				} else {
+					t.Errorf("unexpected %v variance %+v", variance.Category, variance)
+				}
+			}
+			if math.Abs(planned-test.wantPlanned) > 0.005 {
+				t.Errorf("planned debt = %v, want %v", planned, test.wantPlanned)
+			}
+			if math.Abs(report.Totals.Planned-test.wantPlanned) > 0.005 {
+				t.Errorf("total planned = %v, want %v", report.Totals.Planned, test.wantPlanned)
+			}
+		})
+	}
+}
@@ -7,8 +7,66 @@ import (
 
 type Repository interface {
 	FetchExpenses(start, end time.Time) ([]types.Expense, *types.HTTPError)
-	FetchForecast(period time.Time) ([]types.Forecast, *types.HTTPError)
-	GetMonthlyBudgetInsights() (*types.MonthlyBudgetInsights, *types.HTTPError)
+	ListExpenses(filter types.ExpenseFilter) ([]types.Expense, *types.HTTPError)
+	FetchExpense(id int64) (*types.Expense, *types.HTTPError)
+	UpdateExpense(id int64, patch types.ExpensePatch) (*types.Expense, *types.HTTPError)
+	DeleteExpense(id int64) *types.HTTPError
+	FetchForecast(period types.Period) ([]types.Forecast, *types.HTTPError)
+	GetBudgetInsights(period types.Period) (*types.BudgetInsights, *types.HTTPError)
+	GetVarianceReport(period types.Period) (*types.VarianceReport, *types.HTTPError)
 	InsertExpenses(expenses []types.Expense) *types.HTTPError
 	InsertForecast(forecast []types.Forecast) *types.HTTPError
+	CopyForecast(from, to time.Time) *types.HTTPError
+	FetchSyncCursor(itemID string) (string, *types.HTTPError)
+	ApplyTransactionSync(itemID string, sync types.TransactionSync) *types.HTTPError
+	ListCategories(includeArchived bool) ([]types.Category, *types.HTTPError)
+	FetchCategory(slug string) (*types.Category, *types.HTTPError)
+	InsertCategory(category types.Category) (*types.Category, *types.HTTPError)
+	UpdateCategory(slug string, patch types.CategoryPatch) (*types.Category, *types.HTTPError)
+	DeleteCategory(slug string) *types.HTTPError
+	MergeCategories(from, into string) (*types.Category, *types.HTTPError)
+	ListCategoryCorrections() ([]types.CategoryCorrection, *types.HTTPError)
+	ListCategoryMappings() ([]types.CategoryMapping, *types.HTTPError)
+	UpsertCategoryMapping(mapping types.CategoryMapping) (*types.CategoryMapping, *types.HTTPError)
+	DeleteCategoryMapping(id int64) *types.HTTPError
+	ListRules() ([]types.Rule, *types.HTTPError)
+	FetchRule(id int64) (*types.Rule, *types.HTTPError)
+	InsertRule(rule types.Rule) (*types.Rule, *types.HTTPError)
+	UpdateRule(id int64, rule types.Rule) (*types.Rule, *types.HTTPError)
+	DeleteRule(id int64) *types.HTTPError
+	InsertPlaidItem(item types.PlaidItem) *types.HTTPError
+	FetchPlaidItem(itemID string) (*types.PlaidItem, *types.HTTPError)
+	FetchPlaidItems() ([]types.PlaidItem, *types.HTTPError)
+	UpdatePlaidItemStatus(itemID, status, errorCode string) *types.HTTPError
+	RecordPlaidItemSync(itemID string, at time.Time) *types.HTTPError
+	RemovePlaidItem(removal types.ItemRemoval) (*types.ItemRemoval, *types.HTTPError)
+	ListItemRemovals() ([]types.ItemRemoval, *types.HTTPError)
+	RecordAlert(alert types.Alert, channels ...string) (*types.Alert, *types.HTTPError)
+	ListAlerts() ([]types.Alert, *types.HTTPError)
+	PendingDeliveries(now time.Time, maxAttempts int) ([]types.AlertDelivery, *types.HTTPError)
+	MarkDelivered(alertID int64, channel string) *types.HTTPError
+	MarkDeliveryFailed(alertID int64, channel, message string, retryAt time.Time) *types.HTTPError
+	AddToInbox(alertID int64) *types.HTTPError
+	ListInbox(unreadOnly bool) ([]types.InboxMessage, *types.HTTPError)
+	MarkInboxRead(alertID int64) (*types.InboxMessage, *types.HTTPError)
+	ReplaceRecurringStreams(streams []types.RecurringStream) *types.HTTPError
+	ListRecurringStreams(includeInactive bool, asOf time.Time) ([]types.RecurringStream, *types.HTTPError)
+	UpsertAccounts(accounts []types.Account) *types.HTTPError
+	ListAccounts(includeHidden bool) ([]types.Account, *types.HTTPError)
+	FetchAccount(accountID string) (*types.Account, *types.HTTPError)
+	UpdateAccount(accountID string, patch types.AccountPatch) (*types.Account, *types.HTTPError)
+	InsertManualAccount(account types.Account) (*types.Account, *types.HTTPError)
+	DeleteAccount(accountID string) *types.HTTPError
+	UpsertBalances(balances []types.Balance) *types.HTTPError
+	ListBalances(accountID string, from, to time.Time) ([]types.Balance, *types.HTTPError)
+	ListAccountBalances() ([]types.AccountBalance, *types.HTTPError)
+	GetNetWorth(period types.Period) (*types.NetWorthReport, *types.HTTPError)
+	UpsertLiabilities(liabilities []types.Liability) *types.HTTPError
+	ListUpcomingPayments(until time.Time) ([]types.UpcomingPayment, *types.HTTPError)
+	ProjectCashFlow(period types.Period) (*types.CashFlowProjection, *types.HTTPError)
+	ApplyInvestmentSync(sync types.InvestmentSync) *types.HTTPError
+	LatestInvestmentTransactionDate(itemID string) (time.Time, *types.HTTPError)
+	ListHoldings(accountID string) ([]types.Holding, *types.HTTPError)
+	ListInvestmentTransactions(filter types.InvestmentTransactionFilter) ([]types.InvestmentTransaction, *types.HTTPError)
+	GetPortfolioValue(period types.Period) (*types.PortfolioReport, *types.HTTPError)
 }
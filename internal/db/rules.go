@@ -0,0 +1,130 @@
+package db
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"github.com/Seymour-creates/budget-server/internal/types"
+	"github.com/Seymour-creates/budget-server/internal/utils"
+	"log"
+	"net/http"
+)
+
+const ruleColumns = `r.id, r.position, r.name, r.merchant, r.description_pattern, r.min_amount, r.max_amount,
+	r.account_id, r.plaid_category, c.slug, r.tags, r.enabled`
+
+const ruleFrom = `rules r JOIN categories c ON c.id = r.category_id`
+
+func scanRule(row rowScanner) (types.Rule, error) {
+	var rule types.Rule
+	var minAmount, maxAmount sql.NullFloat64
+	var tags string
+	err := row.Scan(&rule.ID, &rule.Position, &rule.Name, &rule.Merchant, &rule.DescriptionPattern, &minAmount, &maxAmount,
+		&rule.AccountID, &rule.PlaidCategory, &rule.Category, &tags, &rule.Enabled)
+	if minAmount.Valid {
+		rule.MinAmount = &minAmount.Float64
+	}
+	if maxAmount.Valid {
+		rule.MaxAmount = &maxAmount.Float64
+	}
+	rule.Tags = splitTags(tags)
+	return rule, err
+}
+
+// ListRules returns every rule in the order they are applied.
+func (man *Manager) ListRules() ([]types.Rule, *types.HTTPError) {
+	rows, err := man.db.Query("SELECT " + ruleColumns + " FROM " + ruleFrom + " ORDER BY r.position, r.id")
+	if err != nil {
+		return nil, utils.NewHTTPError(http.StatusInternalServerError, fmt.Sprintf("error fetching rules: %v", err))
+	}
+	defer func(rows *sql.Rows) {
+		err := rows.Close()
+		if err != nil {
+			log.Printf("error closing row: %v", err)
+		}
+	}(rows)
+
+	rules := []types.Rule{}
+	for rows.Next() {
+		rule, err := scanRule(rows)
+		if err != nil {
+			return nil, utils.NewHTTPError(http.StatusInternalServerError, fmt.Sprintf("error scanning rule: %v", err))
+		}
+		rules = append(rules, rule)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, utils.NewHTTPError(http.StatusInternalServerError, fmt.Sprintf("error iterating rule rows: %v", err))
+	}
+
+	return rules, nil
+}
+
+// FetchRule returns a single rule, or a 404 if there is none with that id.
+func (man *Manager) FetchRule(id int64) (*types.Rule, *types.HTTPError) {
+	rule, err := scanRule(man.db.QueryRow("SELECT "+ruleColumns+" FROM "+ruleFrom+" WHERE r.id = ?", id))
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, utils.NewHTTPError(http.StatusNotFound, fmt.Sprintf("rule %d not found", id))
+	}
+	if err != nil {
+		return nil, utils.NewHTTPError(http.StatusInternalServerError, fmt.Sprintf("error fetching rule: %v", err))
+	}
+	return &rule, nil
+}
+
+// InsertRule stores a new rule. A rule without a position is appended after the existing ones.
+func (man *Manager) InsertRule(rule types.Rule) (*types.Rule, *types.HTTPError) {
+	catID, httpErr := categoryID(man.db, rule.Category)
+	if httpErr != nil {
+		return nil, httpErr
+	}
+	if rule.Position == 0 {
+		if err := man.db.QueryRow(`SELECT COALESCE(MAX(position), 0) + 1 FROM rules`).Scan(&rule.Position); err != nil {
+			return nil, utils.NewHTTPError(http.StatusInternalServerError, fmt.Sprintf("error positioning rule: %v", err))
+		}
+	}
+
+	const query = `INSERT INTO rules (position, name, merchant, description_pattern, min_amount, max_amount, account_id,
+		plaid_category, category_id, tags, enabled) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`
+	result, err := man.db.Exec(query, rule.Position, rule.Name, rule.Merchant, rule.DescriptionPattern, rule.MinAmount,
+		rule.MaxAmount, rule.AccountID, rule.PlaidCategory, catID, joinTags(rule.Tags), rule.Enabled)
+	if err != nil {
+		return nil, utils.NewHTTPError(http.StatusInternalServerError, fmt.Sprintf("error creating rule: %v", err))
+	}
+	id, err := result.LastInsertId()
+	if err != nil {
+		return nil, utils.NewHTTPError(http.StatusInternalServerError, fmt.Sprintf("error reading new rule id: %v", err))
+	}
+	return man.FetchRule(id)
+}
+
+// UpdateRule replaces every field of an existing rule.
+func (man *Manager) UpdateRule(id int64, rule types.Rule) (*types.Rule, *types.HTTPError) {
+	if _, err := man.FetchRule(id); err != nil {
+		return nil, err
+	}
+	catID, httpErr := categoryID(man.db, rule.Category)
+	if httpErr != nil {
+		return nil, httpErr
+	}
+
+	const query = `UPDATE rules SET position = ?, name = ?, merchant = ?, description_pattern = ?, min_amount = ?,
+		max_amount = ?, account_id = ?, plaid_category = ?, category_id = ?, tags = ?, enabled = ? WHERE id = ?`
+	_, err := man.db.Exec(query, rule.Position, rule.Name, rule.Merchant, rule.DescriptionPattern, rule.MinAmount,
+		rule.MaxAmount, rule.AccountID, rule.PlaidCategory, catID, joinTags(rule.Tags), rule.Enabled, id)
+	if err != nil {
+		return nil, utils.NewHTTPError(http.StatusInternalServerError, fmt.Sprintf("error updating rule: %v", err))
+	}
+	return man.FetchRule(id)
+}
+
+// DeleteRule removes a rule, or returns a 404 if there is none with that id.
+func (man *Manager) DeleteRule(id int64) *types.HTTPError {
+	result, err := man.db.Exec("DELETE FROM rules WHERE id = ?", id)
+	if err != nil {
+		return utils.NewHTTPError(http.StatusInternalServerError, fmt.Sprintf("error deleting rule: %v", err))
+	}
+	if affected, err := result.RowsAffected(); err == nil && affected == 0 {
+		return utils.NewHTTPError(http.StatusNotFound, fmt.Sprintf("rule %d not found", id))
+	}
+	return nil
+}
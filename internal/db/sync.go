@@ -0,0 +1,60 @@
+package db
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"github.com/Seymour-creates/budget-server/internal/types"
+	"github.com/Seymour-creates/budget-server/internal/utils"
+	"net/http"
+)
+
+// FetchSyncCursor returns the last /transactions/sync cursor stored for the item, or "" if the item has never synced.
+func (man *Manager) FetchSyncCursor(itemID string) (string, *types.HTTPError) {
+	const query = `SELECT cursor_value FROM plaid_sync_cursors WHERE item_id = ?`
+	var cursor string
+	err := man.db.QueryRow(query, itemID).Scan(&cursor)
+	if errors.Is(err, sql.ErrNoRows) {
+		return "", nil
+	}
+	if err != nil {
+		return "", utils.NewHTTPError(http.StatusInternalServerError, fmt.Sprintf("error fetching sync cursor: %v", err))
+	}
+	return cursor, nil
+}
+
+// ApplyTransactionSync writes a batch of Plaid deltas to the expenses table and advances the item's cursor
+// in a single transaction, so a failed sync can be retried from the previous cursor without losing or
+// duplicating rows.
+func (man *Manager) ApplyTransactionSync(itemID string, sync types.TransactionSync) *types.HTTPError {
+	const deleteQuery = `DELETE FROM expenses WHERE source = ? AND external_id = ?`
+	cursorQuery := `INSERT INTO plaid_sync_cursors (item_id, cursor_value) VALUES (?, ?) ` +
+		man.dialect.upsert([]string{"item_id"}, "cursor_value")
+
+	tx, err := man.db.Begin()
+	if err != nil {
+		return utils.NewHTTPError(http.StatusInternalServerError, fmt.Sprintf("error starting sync transaction: %v", err))
+	}
+	defer rollback(tx)
+
+	for _, expenses := range [][]types.Expense{sync.Added, sync.Modified} {
+		for _, expense := range expenses {
+			if err := man.upsertExpense(tx, itemID, expense); err != nil {
+				return err
+			}
+		}
+	}
+	for _, externalID := range sync.Removed {
+		if _, err := tx.Exec(deleteQuery, types.ExpenseSourcePlaid, externalID); err != nil {
+			return utils.NewHTTPError(http.StatusInternalServerError, fmt.Sprintf("error removing synced expense: %v", err))
+		}
+	}
+	if _, err := tx.Exec(cursorQuery, itemID, sync.Cursor); err != nil {
+		return utils.NewHTTPError(http.StatusInternalServerError, fmt.Sprintf("error saving sync cursor: %v", err))
+	}
+
+	if err := tx.Commit(); err != nil {
+		return utils.NewHTTPError(http.StatusInternalServerError, fmt.Sprintf("error committing sync transaction: %v", err))
+	}
+	return nil
+}
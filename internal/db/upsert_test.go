@@ -0,0 +1,155 @@
+package db_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/Seymour-creates/budget-server/internal/testutil"
+	"github.com/Seymour-creates/budget-server/internal/types"
+)
+
+func TestInsertExpensesUpsertsOnExternalID(t *testing.T) {
+	man := testutil.NewDB(t)
+	day := time.Date(2024, 5, 2, 0, 0, 0, 0, time.UTC)
+	expense := types.Expense{Source: types.ExpenseSourcePlaid, ExternalID: "txn-1", Date: day, Description: "coffee",
+		Amount: 4.5, Category: "takeout"}
+	manual := types.Expense{Date: day, Description: "cash", Amount: 10, Category: "misc"}
+
+	mustNot(t, man.InsertExpenses([]types.Expense{expense, manual}))
+	expense.Amount = 5.25
+	mustNot(t, man.InsertExpenses([]types.Expense{expense, manual}))
+
+	expenses, httpErr := man.FetchExpenses(day, day.AddDate(0, 0, 1))
+	mustNot(t, httpErr)
+	var imported, manuals int
+	for _, stored := range expenses {
+		switch stored.ExternalID {
+		case "txn-1":
+			imported++
+			if stored.Amount != 5.25 {
+				t.Errorf("re-imported expense amount = %v, want the updated 5.25", stored.Amount)
+			}
+		case "":
+			manuals++
+		}
+	}
+	if imported != 1 || manuals != 2 {
+		t.Errorf("stored %d imported and %d manual expenses, want 1 and 2", imported, manuals)
+	}
+}
+
+func TestPlaidItemAndCursorUpserts(t *testing.T) {
+	man := testutil.NewDB(t)
+	item := types.PlaidItem{ItemID: "item-1", Institution: "Bank", Status: "login_required",
+		EncryptedAccessToken: []byte("old"), EncryptedKey: []byte("key")}
+	mustNot(t, man.InsertPlaidItem(item))
+	item.Status, item.EncryptedAccessToken = types.ItemStatusActive, []byte("new")
+	mustNot(t, man.InsertPlaidItem(item))
+	items, httpErr := man.FetchPlaidItems()
+	mustNot(t, httpErr)
+	if len(items) != 1 || items[0].Status != types.ItemStatusActive || string(items[0].EncryptedAccessToken) != "new" {
+		t.Errorf("relinked items = %+v, want the one item active with the new token", items)
+	}
+
+	day := time.Date(2024, 5, 2, 0, 0, 0, 0, time.UTC)
+	sync := types.TransactionSync{Cursor: "cursor-1", Added: []types.Expense{{Source: types.ExpenseSourcePlaid,
+		ExternalID: "txn-1", Date: day, Description: "coffee", Amount: 4.5, Category: "takeout"}}}
+	mustNot(t, man.ApplyTransactionSync("item-1", sync))
+	sync.Cursor, sync.Added, sync.Modified = "cursor-2", nil, sync.Added
+	mustNot(t, man.ApplyTransactionSync("item-1", sync))
+	cursor, httpErr := man.FetchSyncCursor("item-1")
+	mustNot(t, httpErr)
+	if cursor != "cursor-2" {
+		t.Errorf("sync cursor = %q, want cursor-2", cursor)
+	}
+	expenses, httpErr := man.FetchExpenses(day, day.AddDate(0, 0, 1))
+	mustNot(t, httpErr)
+	if len(expenses) != 1 {
+		t.Errorf("stored %d expenses after a modified sync, want 1", len(expenses))
+	}
+}
+
+func TestUpsertAccountsUpdatesAccount(t *testing.T) {
+	man := testutil.NewDB(t)
+	mustNot(t, man.InsertPlaidItem(types.PlaidItem{ItemID: "item-1", Institution: "Bank", Status: types.ItemStatusActive,
+		EncryptedAccessToken: []byte("token"), EncryptedKey: []byte("key")}))
+	account := types.Account{AccountID: "acc-1", ItemID: "item-1", Name: "Checking", Type: "depository"}
+	mustNot(t, man.UpsertAccounts([]types.Account{account}))
+	hidden := true
+	_, httpErr := man.UpdateAccount("acc-1", types.AccountPatch{Hidden: &hidden})
+	mustNot(t, httpErr)
+
+	account.Name = "Everyday Checking"
+	mustNot(t, man.UpsertAccounts([]types.Account{account}))
+	accounts, httpErr := man.ListAccounts(true)
+	mustNot(t, httpErr)
+	if len(accounts) != 1 || accounts[0].Name != "Everyday Checking" || !accounts[0].Hidden {
+		t.Errorf("accounts = %+v, want the one account renamed and still hidden", accounts)
+	}
+}
+
+func TestUpsertBalancesKeepsOneBalancePerDay(t *testing.T) {
+	man := testutil.NewDB(t)
+	mustNot(t, man.InsertPlaidItem(types.PlaidItem{ItemID: "item-1", Institution: "Bank", Status: types.ItemStatusActive,
+		EncryptedAccessToken: []byte("token"), EncryptedKey: []byte("key")}))
+	mustNot(t, man.UpsertAccounts([]types.Account{{AccountID: "acc-1", ItemID: "item-1", Name: "Checking", Type: "depository"}}))
+
+	day := time.Date(2024, 5, 2, 0, 0, 0, 0, time.UTC)
+	mustNot(t, man.UpsertBalances([]types.Balance{{AccountID: "acc-1", Date: day, Current: 100}}))
+	mustNot(t, man.UpsertBalances([]types.Balance{{AccountID: "acc-1", Date: day, Current: 80}}))
+	balances, httpErr := man.ListAccountBalances()
+	mustNot(t, httpErr)
+	if len(balances) != 1 || balances[0].Balance == nil || balances[0].Balance.Current != 80 {
+		t.Errorf("balances = %+v, want the day's last balance of 80", balances)
+	}
+}
+
+func TestUpsertCategoryMappingReplacesMapping(t *testing.T) {
+	man := testutil.NewDB(t)
+	mapping := types.CategoryMapping{Primary: "FOOD_AND_DRINK", Detailed: "FOOD_AND_DRINK_COFFEE", Category: "takeout"}
+	_, httpErr := man.UpsertCategoryMapping(mapping)
+	mustNot(t, httpErr)
+	mapping.Category, mapping.MinConfidence = "ent", "high"
+	stored, httpErr := man.UpsertCategoryMapping(mapping)
+	mustNot(t, httpErr)
+	if stored.Category != "ent" || stored.MinConfidence != "HIGH" {
+		t.Errorf("mapping = %+v, want it moved to ent at HIGH confidence", stored)
+	}
+	mappings, httpErr := man.ListCategoryMappings()
+	mustNot(t, httpErr)
+	var coffee int
+	for _, m := range mappings {
+		if m.Detailed == "FOOD_AND_DRINK_COFFEE" {
+			coffee++
+		}
+	}
+	if coffee != 1 {
+		t.Errorf("stored %d coffee mappings, want 1", coffee)
+	}
+}
+
+func TestAddToInboxIsIdempotent(t *testing.T) {
+	man := testutil.NewDB(t)
+	day := time.Date(2024, 5, 1, 0, 0, 0, 0, time.UTC)
+	alert, httpErr := man.RecordAlert(types.Alert{Key: "budget:takeout:2024-05:80", Kind: types.AlertBudget,
+		Period: types.Period{Start: day, End: day.AddDate(0, 1, -1)}, Category: "takeout", Message: "takeout at 80%"})
+	mustNot(t, httpErr)
+	mustNot(t, man.AddToInbox(alert.ID))
+	_, httpErr = man.MarkInboxRead(alert.ID)
+	mustNot(t, httpErr)
+	mustNot(t, man.AddToInbox(alert.ID))
+
+	unread, httpErr := man.ListInbox(true)
+	mustNot(t, httpErr)
+	if len(unread) != 1 || unread[0].ID != alert.ID {
+		t.Errorf("unread inbox = %+v, want the redelivered alert unread again", unread)
+	}
+}
+
+// mustNot fails the test immediately if httpErr is set.
+func mustNot(t *testing.T, httpErr *types.HTTPError) {
+	t.Helper()
+	if httpErr != nil {
+		t.Fatalf("unexpected error: %v", httpErr.Message)
+	}
+}
@@ -0,0 +1,120 @@
+package handlers
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/Seymour-creates/budget-server/internal/types"
+	"github.com/Seymour-creates/budget-server/internal/utils"
+)
+
+// manualAccountPrefix starts the ids of manual accounts, keeping them apart from Plaid's account ids.
+const manualAccountPrefix = "manual-"
+
+// Accounts serves the /accounts collection: GET lists the accounts behind the linked items and the manual ones
+// (hidden ones too with ?hidden=true), and POST creates a manual account, such as cash or property, optionally
+// with its current balance.
+func (h *Handler) Accounts(w http.ResponseWriter, r *http.Request) error {
+	switch r.Method {
+	case http.MethodGet:
+		accounts, err := h.db.ListAccounts(r.URL.Query().Get("hidden") == "true")
+		if err != nil {
+			return err
+		}
+		return utils.WriteJSON(w, accounts)
+	case http.MethodPost:
+		var input struct {
+			types.Account
+			Balance *float64 `json:"balance"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
+			return utils.NewHTTPError(http.StatusBadRequest, fmt.Sprintf("error decoding account: %v", err))
+		}
+		account := input.Account
+		if account.Name == "" {
+			return utils.NewHTTPError(http.StatusBadRequest, "account name is required")
+		}
+		if account.Type == "" {
+			account.Type = types.AccountTypeOther
+		}
+		switch account.Type {
+		case types.AccountTypeDepository, types.AccountTypeCredit, types.AccountTypeLoan, types.AccountTypeInvestment, types.AccountTypeOther:
+		default:
+			return utils.NewHTTPError(http.StatusBadRequest, fmt.Sprintf("unknown account type %q", account.Type))
+		}
+		id := make([]byte, 8)
+		if _, err := rand.Read(id); err != nil {
+			return utils.NewHTTPError(http.StatusInternalServerError, fmt.Sprintf("error generating account id: %v", err))
+		}
+		account.AccountID = manualAccountPrefix + hex.EncodeToString(id)
+
+		stored, err := h.db.InsertManualAccount(account)
+		if err != nil {
+			return err
+		}
+		if input.Balance != nil {
+			balance := types.Balance{AccountID: stored.AccountID, Date: time.Now(), Current: *input.Balance, Currency: stored.Currency}
+			if err := h.db.UpsertBalances([]types.Balance{balance}); err != nil {
+				return err
+			}
+		}
+		return utils.WriteJSON(w, stored)
+	default:
+		return utils.NewHTTPError(http.StatusMethodNotAllowed, "Method Not Allowed")
+	}
+}
+
+// Account serves /accounts/{id}: GET returns the account, PATCH hides it or excludes it from budgets, and
+// DELETE removes a manual account. Expenses on hidden or excluded accounts are still stored and listed under
+// /expenses, but are left out of budget insights, rollups and variance reports; hidden accounts are left out
+// of net worth too. /accounts/{id}/balances serves the account's balance history.
+func (h *Handler) Account(w http.ResponseWriter, r *http.Request) error {
+	accountID, action, _ := strings.Cut(strings.TrimPrefix(r.URL.Path, "/accounts/"), "/")
+	if accountID == "" {
+		return utils.NewHTTPError(http.StatusBadRequest, "missing account id")
+	}
+	if action == "balances" {
+		return h.AccountBalances(w, r, accountID)
+	}
+	if action != "" {
+		return utils.NewHTTPError(http.StatusNotFound, fmt.Sprintf("unknown account action %q", action))
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		account, err := h.db.FetchAccount(accountID)
+		if err != nil {
+			return err
+		}
+		return utils.WriteJSON(w, account)
+	case http.MethodPatch:
+		var patch types.AccountPatch
+		if err := json.NewDecoder(r.Body).Decode(&patch); err != nil {
+			return utils.NewHTTPError(http.StatusBadRequest, fmt.Sprintf("error decoding account patch: %v", err))
+		}
+		account, err := h.db.UpdateAccount(accountID, patch)
+		if err != nil {
+			return err
+		}
+		return utils.WriteJSON(w, account)
+	case http.MethodDelete:
+		account, err := h.db.FetchAccount(accountID)
+		if err != nil {
+			return err
+		}
+		if !account.Manual {
+			return utils.NewHTTPError(http.StatusConflict, fmt.Sprintf("account %v belongs to a linked item; hide it instead", accountID))
+		}
+		if err := h.db.DeleteAccount(accountID); err != nil {
+			return err
+		}
+		return utils.WriteJSON(w, map[string]string{"status": "success"})
+	default:
+		return utils.NewHTTPError(http.StatusMethodNotAllowed, "Method Not Allowed")
+	}
+}
@@ -0,0 +1,69 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Seymour-creates/budget-server/internal/utils"
+)
+
+// Alerts serves /alerts: GET lists every alert raised, newest first, and POST checks the alert thresholds now
+// and responds with the alerts newly raised, which are then sent through every notification channel in the
+// background. Alerts are raised once per period, so checking again sends nothing new until something changes.
+func (h *Handler) Alerts(w http.ResponseWriter, r *http.Request) error {
+	switch r.Method {
+	case http.MethodGet:
+		alerts, err := h.db.ListAlerts()
+		if err != nil {
+			return err
+		}
+		return utils.WriteJSON(w, alerts)
+	case http.MethodPost:
+		if h.alerts == nil {
+			return utils.NewHTTPError(http.StatusServiceUnavailable, "alerts are not configured")
+		}
+		alerts, err := h.alerts.Evaluate(time.Now())
+		if err != nil {
+			return err
+		}
+		return utils.WriteJSON(w, alerts)
+	default:
+		return utils.NewHTTPError(http.StatusMethodNotAllowed, "Method Not Allowed")
+	}
+}
+
+// Inbox lists the alerts delivered to the in-app inbox, newest first. ?unread=true leaves out those already
+// read.
+func (h *Handler) Inbox(w http.ResponseWriter, r *http.Request) error {
+	if r.Method != http.MethodGet {
+		return utils.NewHTTPError(http.StatusMethodNotAllowed, "Method Not Allowed")
+	}
+	messages, err := h.db.ListInbox(r.URL.Query().Get("unread") == "true")
+	if err != nil {
+		return err
+	}
+	return utils.WriteJSON(w, messages)
+}
+
+// InboxMessage serves POST /inbox/{alert id}/read, which marks an inbox message read and returns it.
+func (h *Handler) InboxMessage(w http.ResponseWriter, r *http.Request) error {
+	rawID, action, _ := strings.Cut(strings.TrimPrefix(r.URL.Path, "/inbox/"), "/")
+	id, err := strconv.ParseInt(rawID, 10, 64)
+	if err != nil {
+		return utils.NewHTTPError(http.StatusBadRequest, fmt.Sprintf("invalid alert id: %v", err))
+	}
+	if action != "read" {
+		return utils.NewHTTPError(http.StatusNotFound, fmt.Sprintf("unknown inbox action %q", action))
+	}
+	if r.Method != http.MethodPost {
+		return utils.NewHTTPError(http.StatusMethodNotAllowed, "Method Not Allowed")
+	}
+	message, httpErr := h.db.MarkInboxRead(id)
+	if httpErr != nil {
+		return httpErr
+	}
+	return utils.WriteJSON(w, message)
+}
@@ -0,0 +1,136 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/Seymour-creates/budget-server/internal/types"
+	"github.com/Seymour-creates/budget-server/internal/utils"
+)
+
+// Balances returns every account that isn't hidden with its latest recorded balance.
+func (h *Handler) Balances(w http.ResponseWriter, r *http.Request) error {
+	if r.Method != http.MethodGet {
+		return utils.NewHTTPError(http.StatusMethodNotAllowed, "Method Not Allowed")
+	}
+	balances, err := h.db.ListAccountBalances()
+	if err != nil {
+		return err
+	}
+	return utils.WriteJSON(w, balances)
+}
+
+// RefreshBalances records today's balances of the accounts behind every linked item, as the daily balance job
+// does. Responds with success, or partial when some items were skipped or failed, along with the result for
+// each item.
+func (h *Handler) RefreshBalances(w http.ResponseWriter, r *http.Request) error {
+	results, err := h.plaid.RefreshBalances(r.Context())
+	if err != nil {
+		return err
+	}
+	status := "success"
+	for _, result := range results {
+		if result.Skipped || result.ErrorCode != "" || result.Status == types.ItemStatusError {
+			status = "partial"
+		}
+	}
+	return utils.WriteJSON(w, map[string]interface{}{
+		"status": status,
+		"items":  results,
+	})
+}
+
+// AccountBalances serves /accounts/{id}/balances: GET returns the account's daily balances, narrowed by
+// ?from= and ?to= (YYYY-MM-DD), and POST records a balance for a manual account. A posted balance without a
+// date is today's.
+func (h *Handler) AccountBalances(w http.ResponseWriter, r *http.Request, accountID string) error {
+	account, httpErr := h.db.FetchAccount(accountID)
+	if httpErr != nil {
+		return httpErr
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		from, err := dateParam(r, "from")
+		if err != nil {
+			return err
+		}
+		to, err := dateParam(r, "to")
+		if err != nil {
+			return err
+		}
+		balances, httpErr := h.db.ListBalances(accountID, from, to)
+		if httpErr != nil {
+			return httpErr
+		}
+		return utils.WriteJSON(w, balances)
+	case http.MethodPost:
+		if !account.Manual {
+			return utils.NewHTTPError(http.StatusConflict, fmt.Sprintf("account %v gets its balances from Plaid", accountID))
+		}
+		var balance types.Balance
+		if err := json.NewDecoder(r.Body).Decode(&balance); err != nil {
+			return utils.NewHTTPError(http.StatusBadRequest, fmt.Sprintf("error decoding balance: %v", err))
+		}
+		balance.AccountID = accountID
+		if balance.Date.IsZero() {
+			balance.Date = time.Now()
+		}
+		if balance.Currency == "" {
+			balance.Currency = account.Currency
+		}
+		if err := h.db.UpsertBalances([]types.Balance{balance}); err != nil {
+			return err
+		}
+		return utils.WriteJSON(w, balance)
+	default:
+		return utils.NewHTTPError(http.StatusMethodNotAllowed, "Method Not Allowed")
+	}
+}
+
+// GetNetWorth returns types.NetWorthReport, net worth at the end of each day of the period selected by the query
+// params, the current month by default.
+func (h *Handler) GetNetWorth(w http.ResponseWriter, r *http.Request) error {
+	if r.Method != http.MethodGet {
+		return utils.NewHTTPError(http.StatusMethodNotAllowed, "Method Not Allowed")
+	}
+
+	period, err := periodParams(r)
+	if err != nil {
+		return err
+	}
+
+	report, httpErr := h.db.GetNetWorth(period)
+	if httpErr != nil {
+		return httpErr
+	}
+
+	return utils.WriteJSON(w, report)
+}
+
+// defaultUpcomingDays is how far ahead /upcoming_payments looks unless ?days= says otherwise.
+const defaultUpcomingDays = 30
+
+// GetUpcomingPayments lists the next payment due on each credit card and loan account within ?days= days
+// (default 30), soonest first, along with any that are overdue. The terms come from Plaid Liabilities and are
+// refreshed with balances.
+func (h *Handler) GetUpcomingPayments(w http.ResponseWriter, r *http.Request) error {
+	if r.Method != http.MethodGet {
+		return utils.NewHTTPError(http.StatusMethodNotAllowed, "Method Not Allowed")
+	}
+
+	days, err := intParam(r, "days")
+	if err != nil {
+		return err
+	}
+	if days == 0 {
+		days = defaultUpcomingDays
+	}
+	payments, httpErr := h.db.ListUpcomingPayments(time.Now().AddDate(0, 0, days))
+	if httpErr != nil {
+		return httpErr
+	}
+	return utils.WriteJSON(w, payments)
+}
@@ -0,0 +1,146 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/Seymour-creates/budget-server/internal/types"
+	"github.com/Seymour-creates/budget-server/internal/utils"
+)
+
+// defaultProjectionDays is how far ahead /cashflow looks unless ?days= says otherwise, and maxProjectionDays the
+// furthest it will.
+const (
+	defaultProjectionDays = 30
+	maxProjectionDays     = 366
+)
+
+// GetCashFlow returns types.CashFlowProjection, the cash balance projected at the end of today and each of the
+// next ?days= days (default 30), from the bills and pay expected from recurring streams and the minimum payments
+// due on liability accounts, along with the lowest balance projected and the day it falls on.
+func (h *Handler) GetCashFlow(w http.ResponseWriter, r *http.Request) error {
+	if r.Method != http.MethodGet {
+		return utils.NewHTTPError(http.StatusMethodNotAllowed, "Method Not Allowed")
+	}
+	projection, err := h.projectCashFlow(r)
+	if err != nil {
+		return err
+	}
+	return utils.WriteJSON(w, projection)
+}
+
+// GetCashFlowCalendar exports the events of the projection GetCashFlow returns as an iCalendar feed, one
+// all-day event per expected bill, paycheck or minimum payment, so calendar apps can subscribe to it.
+func (h *Handler) GetCashFlowCalendar(w http.ResponseWriter, r *http.Request) error {
+	if r.Method != http.MethodGet {
+		return utils.NewHTTPError(http.StatusMethodNotAllowed, "Method Not Allowed")
+	}
+	projection, err := h.projectCashFlow(r)
+	if err != nil {
+		return err
+	}
+	w.Header().Set("Content-Type", "text/calendar; charset=utf-8")
+	w.Header().Set("Content-Disposition", `inline; filename="cashflow.ics"`)
+	_, writeErr := w.Write([]byte(calendar(projection, time.Now())))
+	return writeErr
+}
+
+func (h *Handler) projectCashFlow(r *http.Request) (*types.CashFlowProjection, error) {
+	days, err := intParam(r, "days")
+	if err != nil {
+		return nil, err
+	}
+	if days == 0 {
+		days = defaultProjectionDays
+	}
+	if days > maxProjectionDays {
+		return nil, utils.NewHTTPError(http.StatusBadRequest, fmt.Sprintf("days must be at most %v", maxProjectionDays))
+	}
+	now := time.Now()
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location())
+	projection, httpErr := h.db.ProjectCashFlow(types.Period{Start: today, End: today.AddDate(0, 0, days)})
+	if httpErr != nil {
+		return nil, httpErr
+	}
+	return projection, nil
+}
+
+// calendar renders projection's events as an RFC 5545 VCALENDAR stamped at now. Each event's UID is stable
+// across requests, so subscribed calendars update events instead of duplicating them.
+func calendar(projection *types.CashFlowProjection, now time.Time) string {
+	balances := make(map[string]float64, len(projection.Days))
+	for _, day := range projection.Days {
+		balances[day.Date.Format("20060102")] = day.Balance
+	}
+
+	var b strings.Builder
+	lines := []string{
+		"BEGIN:VCALENDAR",
+		"VERSION:2.0",
+		"PRODID:-//budget-server//cash flow//EN",
+		"CALSCALE:GREGORIAN",
+		"METHOD:PUBLISH",
+		"X-WR-CALNAME:Bills and income",
+	}
+	for _, event := range projection.Events {
+		date := event.DueDate.Format("20060102")
+		id := event.StreamID
+		if id == "" {
+			id = event.AccountID
+		}
+		summary := fmt.Sprintf("%v: %v", event.Description, dollars(event.Amount))
+		if event.Amount < 0 {
+			summary = fmt.Sprintf("%v: +%v", event.Description, dollars(-event.Amount))
+		}
+		lines = append(lines,
+			"BEGIN:VEVENT",
+			fmt.Sprintf("UID:%v-%v-%v@budget-server", event.Source, id, date),
+			"DTSTAMP:"+now.UTC().Format("20060102T150405Z"),
+			"DTSTART;VALUE=DATE:"+date,
+			"DTEND;VALUE=DATE:"+event.DueDate.AddDate(0, 0, 1).Format("20060102"),
+			"SUMMARY:"+calendarText(summary),
+			"DESCRIPTION:"+calendarText("Projected balance at the end of the day: "+dollars(balances[date])),
+			"TRANSP:TRANSPARENT",
+			"END:VEVENT",
+		)
+	}
+	lines = append(lines, "END:VCALENDAR")
+
+	for _, line := range lines {
+		b.WriteString(foldLine(line))
+		b.WriteString("\r\n")
+	}
+	return b.String()
+}
+
+func dollars(amount float64) string {
+	if amount < 0 {
+		return fmt.Sprintf("-$%.2f", -amount)
+	}
+	return fmt.Sprintf("$%.2f", amount)
+}
+
+// calendarText escapes the characters iCalendar TEXT values reserve.
+func calendarText(text string) string {
+	return strings.NewReplacer(`\`, `\\`, ";", `\;`, ",", `\,`, "\r\n", `\n`, "\n", `\n`).Replace(text)
+}
+
+// foldLine splits a content line longer than 75 octets into continuation lines, without breaking up a UTF-8
+// character.
+func foldLine(line string) string {
+	const limit = 75
+	var b strings.Builder
+	width := 0
+	for _, r := range line {
+		size := len(string(r))
+		if width+size > limit {
+			b.WriteString("\r\n ")
+			width = 1
+		}
+		b.WriteRune(r)
+		width += size
+	}
+	return b.String()
+}
@@ -0,0 +1,116 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/Seymour-creates/budget-server/internal/types"
+	"github.com/Seymour-creates/budget-server/internal/utils"
+)
+
+// Categories serves the /categories collection: GET lists categories (archived ones too with ?archived=true),
+// POST creates one.
+func (h *Handler) Categories(w http.ResponseWriter, r *http.Request) error {
+	switch r.Method {
+	case http.MethodGet:
+		categories, err := h.db.ListCategories(r.URL.Query().Get("archived") == "true")
+		if err != nil {
+			return err
+		}
+		return utils.WriteJSON(w, categories)
+	case http.MethodPost:
+		var category types.Category
+		if err := json.NewDecoder(r.Body).Decode(&category); err != nil {
+			return utils.NewHTTPError(http.StatusBadRequest, fmt.Sprintf("error decoding category: %v", err))
+		}
+		created, err := h.db.InsertCategory(category)
+		if err != nil {
+			return err
+		}
+		return utils.WriteJSON(w, created)
+	default:
+		return utils.NewHTTPError(http.StatusMethodNotAllowed, "Method Not Allowed")
+	}
+}
+
+// Category serves /categories/{slug}: GET returns the category, PATCH renames, re-parents, recolors or
+// archives it, and DELETE removes it if nothing uses it. POST /categories/{slug}/merge?into={slug} moves
+// everything filed under the category into another and deletes it.
+func (h *Handler) Category(w http.ResponseWriter, r *http.Request) error {
+	slug, action, _ := strings.Cut(strings.TrimPrefix(r.URL.Path, "/categories/"), "/")
+	if slug == "" {
+		return utils.NewHTTPError(http.StatusNotFound, "category not found")
+	}
+
+	if action == "merge" {
+		if r.Method != http.MethodPost {
+			return utils.NewHTTPError(http.StatusMethodNotAllowed, "Method Not Allowed")
+		}
+		into := r.URL.Query().Get("into")
+		if into == "" {
+			return utils.NewHTTPError(http.StatusBadRequest, "missing ?into= category to merge into")
+		}
+		merged, err := h.db.MergeCategories(slug, into)
+		if err != nil {
+			return err
+		}
+		h.model.Invalidate()
+		return utils.WriteJSON(w, merged)
+	}
+	if action != "" {
+		return utils.NewHTTPError(http.StatusNotFound, fmt.Sprintf("unknown category action %q", action))
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		category, err := h.db.FetchCategory(slug)
+		if err != nil {
+			return err
+		}
+		return utils.WriteJSON(w, category)
+	case http.MethodPatch:
+		var patch types.CategoryPatch
+		if err := json.NewDecoder(r.Body).Decode(&patch); err != nil {
+			return utils.NewHTTPError(http.StatusBadRequest, fmt.Sprintf("error decoding category patch: %v", err))
+		}
+		category, err := h.db.UpdateCategory(slug, patch)
+		if err != nil {
+			return err
+		}
+		if patch.Slug != nil {
+			h.model.Invalidate()
+		}
+		return utils.WriteJSON(w, category)
+	case http.MethodDelete:
+		if err := h.db.DeleteCategory(slug); err != nil {
+			return err
+		}
+		return utils.WriteJSON(w, map[string]string{"status": "success"})
+	default:
+		return utils.NewHTTPError(http.StatusMethodNotAllowed, "Method Not Allowed")
+	}
+}
+
+// validateCategories rejects input that files anything under an unknown or archived category.
+func (h *Handler) validateCategories(slugs []string) error {
+	categories, err := h.db.ListCategories(true)
+	if err != nil {
+		return err
+	}
+	archived := make(map[string]bool, len(categories))
+	for _, category := range categories {
+		archived[category.Slug] = category.Archived
+	}
+	for _, slug := range slugs {
+		isArchived, known := archived[slug]
+		if !known {
+			return utils.NewHTTPError(http.StatusBadRequest, fmt.Sprintf("unknown category %q", slug))
+		}
+		if isArchived {
+			return utils.NewHTTPError(http.StatusBadRequest, fmt.Sprintf("category %q is archived", slug))
+		}
+	}
+	return nil
+}
@@ -0,0 +1,73 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/Seymour-creates/budget-server/internal/plaidCtl"
+	"github.com/Seymour-creates/budget-server/internal/types"
+	"github.com/Seymour-creates/budget-server/internal/utils"
+)
+
+// CategoryMappings serves the /category_mappings collection of Plaid personal finance category to budget
+// category mappings: GET lists them, POST creates or replaces the mapping for a primary/detailed pair.
+func (h *Handler) CategoryMappings(w http.ResponseWriter, r *http.Request) error {
+	switch r.Method {
+	case http.MethodGet:
+		mappings, err := h.db.ListCategoryMappings()
+		if err != nil {
+			return err
+		}
+		return utils.WriteJSON(w, mappings)
+	case http.MethodPost:
+		var mapping types.CategoryMapping
+		if err := json.NewDecoder(r.Body).Decode(&mapping); err != nil {
+			return utils.NewHTTPError(http.StatusBadRequest, fmt.Sprintf("error decoding category mapping: %v", err))
+		}
+		stored, err := h.db.UpsertCategoryMapping(mapping)
+		if err != nil {
+			return err
+		}
+		return utils.WriteJSON(w, stored)
+	default:
+		return utils.NewHTTPError(http.StatusMethodNotAllowed, "Method Not Allowed")
+	}
+}
+
+// CategoryMapping serves /category_mappings/{id}, where DELETE removes the mapping. POST
+// /category_mappings/apply re-files Plaid expenses from the raw Plaid categories stored on them, with rules
+// and confident category model guesses still taking precedence; it takes the same period and ?dry_run=true params as /rules/apply.
+func (h *Handler) CategoryMapping(w http.ResponseWriter, r *http.Request) error {
+	rest := strings.TrimPrefix(r.URL.Path, "/category_mappings/")
+	if rest == "apply" {
+		return h.ApplyCategoryMappings(w, r)
+	}
+	id, err := strconv.ParseInt(rest, 10, 64)
+	if err != nil {
+		return utils.NewHTTPError(http.StatusBadRequest, fmt.Sprintf("invalid category mapping id: %v", err))
+	}
+	if r.Method != http.MethodDelete {
+		return utils.NewHTTPError(http.StatusMethodNotAllowed, "Method Not Allowed")
+	}
+	if err := h.db.DeleteCategoryMapping(id); err != nil {
+		return err
+	}
+	return utils.WriteJSON(w, map[string]string{"status": "success"})
+}
+
+// ApplyCategoryMappings re-categorizes Plaid expenses the way a fresh import would and returns the ones that
+// changed.
+func (h *Handler) ApplyCategoryMappings(w http.ResponseWriter, r *http.Request) error {
+	categorizer, httpErr := plaidCtl.LoadCategorizer(h.db, h.model)
+	if httpErr != nil {
+		return httpErr
+	}
+	categorize := func(expense *types.Expense) bool {
+		categorizer.Categorize(expense)
+		return true
+	}
+	return h.recategorize(w, r, types.ExpenseFilter{Source: types.ExpenseSourcePlaid}, categorize)
+}
@@ -0,0 +1,123 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/Seymour-creates/budget-server/internal/types"
+	"github.com/Seymour-creates/budget-server/internal/utils"
+)
+
+// Expenses serves the /expenses collection: GET lists expenses matching the query filters, POST creates them
+// exactly like /post_expense.
+//
+// Filters: from, to (YYYY-MM-DD), category, source, category_source (how the category was chosen, e.g. model),
+// account_id, q (description substring), min_amount, max_amount, limit and offset.
+func (h *Handler) Expenses(w http.ResponseWriter, r *http.Request) error {
+	switch r.Method {
+	case http.MethodGet:
+		filter, err := expenseFilter(r)
+		if err != nil {
+			return err
+		}
+		expenses, httpErr := h.db.ListExpenses(filter)
+		if httpErr != nil {
+			return httpErr
+		}
+		return utils.WriteJSON(w, expenses)
+	case http.MethodPost:
+		return h.PostExpense(w, r)
+	default:
+		return utils.NewHTTPError(http.StatusMethodNotAllowed, "Method Not Allowed")
+	}
+}
+
+// Expense serves /expenses/{id}: GET returns the expense, PATCH updates its category, description, amount or
+// date, and DELETE removes it. A category changed through PATCH is recorded as a correction the category model
+// learns from. GET /expenses/{id}/suggestions ranks the categories the model thinks the expense belongs in.
+func (h *Handler) Expense(w http.ResponseWriter, r *http.Request) error {
+	rawID, action, _ := strings.Cut(strings.TrimPrefix(r.URL.Path, "/expenses/"), "/")
+	id, err := strconv.ParseInt(rawID, 10, 64)
+	if err != nil {
+		return utils.NewHTTPError(http.StatusBadRequest, fmt.Sprintf("invalid expense id: %v", err))
+	}
+
+	if action == "suggestions" {
+		return h.ExpenseSuggestions(w, r, id)
+	}
+	if action != "" {
+		return utils.NewHTTPError(http.StatusNotFound, fmt.Sprintf("unknown expense action %q", action))
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		expense, err := h.db.FetchExpense(id)
+		if err != nil {
+			return err
+		}
+		return utils.WriteJSON(w, expense)
+	case http.MethodPatch:
+		var patch types.ExpensePatch
+		if err := json.NewDecoder(r.Body).Decode(&patch); err != nil {
+			return utils.NewHTTPError(http.StatusBadRequest, fmt.Sprintf("error decoding expense patch: %v", err))
+		}
+		before, err := h.db.FetchExpense(id)
+		if err != nil {
+			return err
+		}
+		expense, err := h.db.UpdateExpense(id, patch)
+		if err != nil {
+			return err
+		}
+		h.model.Forget(*before)
+		h.model.Learn(*expense)
+		return utils.WriteJSON(w, expense)
+	case http.MethodDelete:
+		expense, err := h.db.FetchExpense(id)
+		if err != nil {
+			return err
+		}
+		if err := h.db.DeleteExpense(id); err != nil {
+			return err
+		}
+		h.model.Forget(*expense)
+		return utils.WriteJSON(w, map[string]string{"status": "success"})
+	default:
+		return utils.NewHTTPError(http.StatusMethodNotAllowed, "Method Not Allowed")
+	}
+}
+
+func expenseFilter(r *http.Request) (types.ExpenseFilter, error) {
+	query := r.URL.Query()
+	filter := types.ExpenseFilter{
+		Category:       query.Get("category"),
+		Source:         query.Get("source"),
+		CategorySource: query.Get("category_source"),
+		AccountID:      query.Get("account_id"),
+		Description:    query.Get("q"),
+	}
+
+	var err error
+	if filter.From, err = dateParam(r, "from"); err != nil {
+		return filter, err
+	}
+	if filter.To, err = dateParam(r, "to"); err != nil {
+		return filter, err
+	}
+	if filter.MinAmount, err = amountParam(r, "min_amount"); err != nil {
+		return filter, err
+	}
+	if filter.MaxAmount, err = amountParam(r, "max_amount"); err != nil {
+		return filter, err
+	}
+	if filter.Limit, err = intParam(r, "limit"); err != nil {
+		return filter, err
+	}
+	if filter.Offset, err = intParam(r, "offset"); err != nil {
+		return filter, err
+	}
+	return filter, nil
+}
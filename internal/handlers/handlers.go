@@ -3,9 +3,13 @@ package handlers
 import (
 	"encoding/json"
 	"fmt"
+	"github.com/Seymour-creates/budget-server/internal/alerts"
+	"github.com/Seymour-creates/budget-server/internal/classifier"
 	"github.com/Seymour-creates/budget-server/internal/db"
 	"github.com/Seymour-creates/budget-server/internal/plaidCtl"
+	"github.com/Seymour-creates/budget-server/internal/rules"
 	"html/template"
+	"io"
 	"log"
 	"net/http"
 	"os"
@@ -16,53 +20,146 @@ import (
 )
 
 type Handler struct {
-	plaid *plaidCtl.Service
-	db    db.Repository
+	plaid  *plaidCtl.Service
+	db     db.Repository
+	alerts *alerts.Evaluator
+	model  *classifier.Cache
 }
 
-// MakeNewHttpHandler returns instance of Handler struct.
-func MakeNewHttpHandler(plaidClient *plaidCtl.Service, repo db.Repository) *Handler {
-	return &Handler{plaid: plaidClient, db: repo}
+// MakeNewHttpHandler returns instance of Handler struct. evaluator may be nil to turn alerts off. model is the
+// category model cache shared with plaidClient; nil gives the handler one of its own.
+func MakeNewHttpHandler(plaidClient *plaidCtl.Service, repo db.Repository, evaluator *alerts.Evaluator, model *classifier.Cache) *Handler {
+	if model == nil {
+		model = classifier.NewCache(repo)
+	}
+	return &Handler{plaid: plaidClient, db: repo, alerts: evaluator, model: model}
 }
 
-// GetForecastAndExpenses returns types.MonthlyBudgetInsights struct. ({ []types.Forecast, []types.Expense })
+// GetForecastAndExpenses returns types.BudgetInsights struct. ({ period, []types.Forecast, []types.Expense })
+// for the period selected by the query params, the current month by default.
 func (h *Handler) GetForecastAndExpenses(w http.ResponseWriter, r *http.Request) error {
 	if r.Method != http.MethodGet {
 		return utils.NewHTTPError(http.StatusMethodNotAllowed, "Method Not Allowed")
 	}
 
-	response, err := h.db.GetMonthlyBudgetInsights()
+	period, err := periodParams(r)
 	if err != nil {
 		return err
 	}
 
+	response, httpErr := h.db.GetBudgetInsights(period)
+	if httpErr != nil {
+		return httpErr
+	}
+
 	return utils.WriteJSON(w, response)
 }
 
-// GetExpensesSummary Returns []types.Expense for the month from db.
-func (h *Handler) GetExpensesSummary(w http.ResponseWriter, r *http.Request) error {
-	now := time.Now()
-	firstOfMonth := time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, now.Location())
-	lastOfMonth := firstOfMonth.AddDate(0, 1, -1)
+// GetVarianceReport returns types.VarianceReport of planned vs actual spending per category for the period
+// selected by the query params, the current month by default.
+func (h *Handler) GetVarianceReport(w http.ResponseWriter, r *http.Request) error {
+	if r.Method != http.MethodGet {
+		return utils.NewHTTPError(http.StatusMethodNotAllowed, "Method Not Allowed")
+	}
 
-	expenses, err := h.db.FetchExpenses(firstOfMonth, lastOfMonth)
+	period, err := periodParams(r)
 	if err != nil {
 		return err
 	}
 
+	report, httpErr := h.db.GetVarianceReport(period)
+	if httpErr != nil {
+		return httpErr
+	}
+
+	return utils.WriteJSON(w, report)
+}
+
+// GetExpensesSummary Returns []types.Expense for the period selected by the query params, the current month by default.
+func (h *Handler) GetExpensesSummary(w http.ResponseWriter, r *http.Request) error {
+	period, err := periodParams(r)
+	if err != nil {
+		return err
+	}
+
+	expenses, httpErr := h.db.FetchExpenses(period.Start, period.End)
+	if httpErr != nil {
+		return httpErr
+	}
+
 	return utils.WriteJSON(w, expenses)
 }
 
-// PostForecast Post CLI user input of types.Forecast into db.
+// GetRollup returns []types.Rollup totals for each ?interval=week|month|quarter|year (default month) of the
+// period selected by the query params.
+func (h *Handler) GetRollup(w http.ResponseWriter, r *http.Request) error {
+	if r.Method != http.MethodGet {
+		return utils.NewHTTPError(http.StatusMethodNotAllowed, "Method Not Allowed")
+	}
+
+	period, err := periodParams(r)
+	if err != nil {
+		return err
+	}
+	interval := r.URL.Query().Get("interval")
+	if interval == "" {
+		interval = types.IntervalMonth
+	}
+	buckets, err := utils.SplitPeriod(period, interval)
+	if err != nil {
+		return utils.NewHTTPError(http.StatusBadRequest, err.Error())
+	}
+
+	expenses, httpErr := h.db.FetchExpenses(period.Start, period.End)
+	if httpErr != nil {
+		return httpErr
+	}
+
+	rollups := make([]types.Rollup, len(buckets))
+	for i, bucket := range buckets {
+		rollups[i] = types.Rollup{Period: bucket, ByCategory: map[string]float64{}}
+	}
+	for _, expense := range expenses {
+		// Expense dates come back from the db as UTC midnight; compare them as calendar days in the period's zone.
+		date := time.Date(expense.Date.Year(), expense.Date.Month(), expense.Date.Day(), 0, 0, 0, 0, period.Start.Location())
+		for i := range rollups {
+			if !date.Before(rollups[i].Period.Start) && !date.After(rollups[i].Period.End) {
+				rollups[i].Total += expense.Amount
+				rollups[i].ByCategory[expense.Category] += expense.Amount
+				break
+			}
+		}
+	}
+
+	return utils.WriteJSON(w, rollups)
+}
+
+// PostForecast Post CLI user input of types.Forecast into db. Entries without a period are planned for the
+// ?month=YYYY-MM query param, or the current month if it is absent.
 func (h *Handler) PostForecast(w http.ResponseWriter, r *http.Request) error {
 	if r.Method != http.MethodPost {
 		return utils.NewHTTPError(http.StatusMethodNotAllowed, "Method Not Allowed")
 	}
 
+	month, err := monthParam(r, "month", time.Now())
+	if err != nil {
+		return err
+	}
+
 	var forecast []types.Forecast
 	if err := json.NewDecoder(r.Body).Decode(&forecast); err != nil {
 		return utils.NewHTTPError(http.StatusBadRequest, fmt.Sprintf("error converting incoming json: %v", err))
 	}
+	categories := make([]string, len(forecast))
+	for i := range forecast {
+		if forecast[i].Period.IsZero() {
+			forecast[i].Period = month
+		}
+		categories[i] = forecast[i].Category
+	}
+	if err := h.validateCategories(categories); err != nil {
+		return err
+	}
 
 	if err := h.db.InsertForecast(forecast); err != nil {
 		return err
@@ -71,11 +168,36 @@ func (h *Handler) PostForecast(w http.ResponseWriter, r *http.Request) error {
 	return utils.WriteJSON(w, map[string]string{"status": "success"})
 }
 
+// CopyForecast seeds a month's forecast from another month's. Both ?from= and ?to= are YYYY-MM and default to
+// last month and this month respectively; categories already planned for the target month are kept.
+func (h *Handler) CopyForecast(w http.ResponseWriter, r *http.Request) error {
+	if r.Method != http.MethodPost {
+		return utils.NewHTTPError(http.StatusMethodNotAllowed, "Method Not Allowed")
+	}
+
+	to, err := monthParam(r, "to", time.Now())
+	if err != nil {
+		return err
+	}
+	from, err := monthParam(r, "from", utils.StartOfMonth(to).AddDate(0, -1, 0))
+	if err != nil {
+		return err
+	}
+
+	if err := h.db.CopyForecast(from, to); err != nil {
+		return err
+	}
+
+	return utils.WriteJSON(w, map[string]string{"status": "success"})
+}
+
 func (h *Handler) GetRight(w http.ResponseWriter, r *http.Request) error {
 	return utils.WriteJSON(w, map[string]string{"status": "success"})
 }
 
-// PostExpense Post CLI user input of types.Expense in to db.
+// PostExpense Post CLI user input of types.Expense in to db. Expenses posted with an external_id are upserted.
+// Expenses without a category are filed by the first matching rule, then by the category model when it is
+// confident, and otherwise under misc. Alert thresholds are checked once the expenses are stored.
 func (h *Handler) PostExpense(w http.ResponseWriter, r *http.Request) error {
 	if r.Method != http.MethodPost {
 		return utils.NewHTTPError(http.StatusMethodNotAllowed, "Method Not Allowed")
@@ -85,27 +207,83 @@ func (h *Handler) PostExpense(w http.ResponseWriter, r *http.Request) error {
 	if err := json.NewDecoder(r.Body).Decode(&expenses); err != nil {
 		return utils.NewHTTPError(http.StatusBadRequest, fmt.Sprintf("error decoding incoming expense data: %v", err))
 	}
+	engine, httpErr := rules.Load(h.db)
+	if httpErr != nil {
+		return httpErr
+	}
+	model, httpErr := h.model.Model()
+	if httpErr != nil {
+		return httpErr
+	}
+	categories := make([]string, len(expenses))
+	for i := range expenses {
+		expense := &expenses[i]
+		expense.Source = types.ExpenseSourceCLI
+		switch {
+		case expense.Category != "":
+			expense.CategorySource = types.CategorySourceUser
+			expense.CategoryConfidence = nil
+		case engine.Apply(expense), model.Apply(expense):
+		default:
+			expense.Category = "misc"
+			expense.CategorySource = types.CategorySourceDefault
+			expense.CategoryConfidence = nil
+		}
+		categories[i] = expense.Category
+	}
+	if err := h.validateCategories(categories); err != nil {
+		return err
+	}
 
 	if err := h.db.InsertExpenses(expenses); err != nil {
 		return err
 	}
+	h.learn(expenses)
+	if h.alerts != nil {
+		if _, err := h.alerts.Evaluate(time.Now()); err != nil {
+			log.Printf("error evaluating alerts: %v", err.Message)
+		}
+	}
 
 	return utils.WriteJSON(w, map[string]string{"status": "success"})
 }
 
-// LinkBank returns HTMX page to register users bank using Plaid Link
+// learn teaches the category model the categories of newly stored expenses. Expenses with an external ID may
+// have replaced ones already learned, so the model is retrained instead.
+func (h *Handler) learn(expenses []types.Expense) {
+	for _, expense := range expenses {
+		if expense.ExternalID != "" {
+			h.model.Invalidate()
+			return
+		}
+	}
+	h.model.Learn(expenses...)
+}
+
+// LinkBank returns HTMX page to register users bank using Plaid Link. With ?item_id= it opens Link in update
+// mode instead, to sign back in to the bank behind an existing item.
 func (h *Handler) LinkBank(w http.ResponseWriter, r *http.Request) error {
 	if r.Method != http.MethodGet {
 		return utils.NewHTTPError(http.StatusMethodNotAllowed, "Method not allowed.")
 	}
 
-	linkToken, err := h.plaid.LinkBank(r)
-	if err != nil {
-		return utils.NewHTTPError(http.StatusInternalServerError, fmt.Sprintf("Error retrieving link token: %v", err))
+	itemID := r.URL.Query().Get("item_id")
+	var linkToken string
+	if itemID != "" {
+		var httpErr *types.HTTPError
+		if linkToken, httpErr = h.plaid.UpdateLinkToken(r.Context(), itemID); httpErr != nil {
+			return httpErr
+		}
+	} else {
+		var err error
+		if linkToken, err = h.plaid.LinkBank(r); err != nil {
+			return utils.NewHTTPError(http.StatusInternalServerError, fmt.Sprintf("Error retrieving link token: %v", err))
+		}
 	}
 
 	data := map[string]interface{}{
 		"LinkToken": linkToken,
+		"ItemID":    itemID,
 		"APP_URL":   os.Getenv("APP_URL"),
 	}
 	tmpl := template.Must(template.ParseFiles("internal/templates/link_bank.html"))
@@ -126,7 +304,7 @@ func (h *Handler) OauthRedirect(w http.ResponseWriter, r *http.Request) error {
 
 }
 
-// CreatePlaidBankItem links users bank to APP in plaid - returns token for plaid client
+// CreatePlaidBankItem links users bank to APP in plaid - stores the item's access token and returns its item id
 func (h *Handler) CreatePlaidBankItem(w http.ResponseWriter, r *http.Request) error {
 	if r.Method != http.MethodPost {
 		return utils.NewHTTPError(http.StatusMethodNotAllowed, "Method not allowed.")
@@ -141,32 +319,51 @@ func (h *Handler) CreatePlaidBankItem(w http.ResponseWriter, r *http.Request) er
 		return utils.NewHTTPError(http.StatusExpectationFailed, fmt.Sprintf("Error fetching public token from link: %v", errorMessage))
 	}
 
-	accessToken, err := h.plaid.CreateItem(publicToken, r)
+	item, err := h.plaid.CreateItem(publicToken, r)
 	if err != nil {
 		return utils.NewHTTPError(http.StatusInternalServerError, fmt.Sprintf("Error exchanging public token for access token: %v", err))
 	}
 
-	log.Printf("#*#*#*# accessToken: %v", accessToken)
+	log.Printf("linked plaid item: %v (%v)", item.ItemID, item.Institution)
 
-	return utils.WriteJSON(w, map[string]string{"status": "success"})
+	return utils.WriteJSON(w, map[string]string{"status": "success", "item_id": item.ItemID})
 }
 
-// UpdateExpenseData retrieves bank transaction data from plaid & posts to db - responds with success
+// UpdateExpenseData syncs bank transaction data from plaid into the db - safe to call repeatedly. Responds with
+// success, or partial when some items were skipped or failed, along with the result for each item.
 func (h *Handler) UpdateExpenseData(w http.ResponseWriter, r *http.Request) error {
-	fetchedTransactions, err := h.plaid.RetrieveTransactions(r)
+	results, err := h.plaid.SyncTransactions(r.Context())
 	if err != nil {
-		return utils.NewHTTPError(http.StatusInternalServerError, fmt.Sprintf("Error fetching transaction data: %v", err))
+		return err
 	}
-	dbReadyExpenses, err := h.plaid.FormatTransactionsToExpenseType(fetchedTransactions)
-	if err != nil {
-		return utils.NewHTTPError(http.StatusInternalServerError, err.Message)
+	status := "success"
+	for _, result := range results {
+		if result.Skipped || result.ErrorCode != "" || result.Status == types.ItemStatusError {
+			status = "partial"
+		}
+	}
+	return utils.WriteJSON(w, map[string]interface{}{
+		"status": status,
+		"items":  results,
+	})
+}
+
+// maxWebhookBody caps how much of a webhook request is read; Plaid's webhook bodies are a few hundred bytes.
+const maxWebhookBody = 1 << 20
+
+// PlaidWebhook receives Plaid webhooks. Each must carry a valid Plaid-Verification signature; syncs it asks for
+// run in the background so Plaid gets its acknowledgement straight away.
+func (h *Handler) PlaidWebhook(w http.ResponseWriter, r *http.Request) error {
+	if r.Method != http.MethodPost {
+		return utils.NewHTTPError(http.StatusMethodNotAllowed, "Method Not Allowed")
 	}
-	err = h.db.InsertExpenses(dbReadyExpenses)
+
+	body, err := io.ReadAll(io.LimitReader(r.Body, maxWebhookBody))
 	if err != nil {
-		return utils.NewHTTPError(http.StatusInternalServerError, err.Message)
+		return utils.NewHTTPError(http.StatusBadRequest, fmt.Sprintf("error reading webhook body: %v", err))
 	}
-	success := map[string]string{
-		"status": "success",
+	if err := h.plaid.HandleWebhook(r.Context(), r.Header.Get("Plaid-Verification"), body); err != nil {
+		return err
 	}
-	return utils.WriteJSON(w, success)
+	return utils.WriteJSON(w, map[string]string{"status": "success"})
 }
@@ -0,0 +1,66 @@
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/Seymour-creates/budget-server/internal/types"
+	"github.com/Seymour-creates/budget-server/internal/utils"
+)
+
+// Holdings returns the latest holdings of every investment account that isn't hidden, or of just ?account_id=,
+// with the securities held.
+func (h *Handler) Holdings(w http.ResponseWriter, r *http.Request) error {
+	if r.Method != http.MethodGet {
+		return utils.NewHTTPError(http.StatusMethodNotAllowed, "Method Not Allowed")
+	}
+	holdings, err := h.db.ListHoldings(r.URL.Query().Get("account_id"))
+	if err != nil {
+		return err
+	}
+	return utils.WriteJSON(w, holdings)
+}
+
+// InvestmentTransactions lists buys, sells, dividends and other investment transactions, newest first, narrowed
+// by ?account_id=, ?from= and ?to= (YYYY-MM-DD).
+func (h *Handler) InvestmentTransactions(w http.ResponseWriter, r *http.Request) error {
+	if r.Method != http.MethodGet {
+		return utils.NewHTTPError(http.StatusMethodNotAllowed, "Method Not Allowed")
+	}
+	from, err := dateParam(r, "from")
+	if err != nil {
+		return err
+	}
+	to, err := dateParam(r, "to")
+	if err != nil {
+		return err
+	}
+	transactions, httpErr := h.db.ListInvestmentTransactions(types.InvestmentTransactionFilter{
+		AccountID: r.URL.Query().Get("account_id"),
+		From:      from,
+		To:        to,
+	})
+	if httpErr != nil {
+		return httpErr
+	}
+	return utils.WriteJSON(w, transactions)
+}
+
+// GetPortfolio returns types.PortfolioReport, the value of each investment account's holdings at the end of each
+// day of the period selected by the query params, the current month by default.
+func (h *Handler) GetPortfolio(w http.ResponseWriter, r *http.Request) error {
+	if r.Method != http.MethodGet {
+		return utils.NewHTTPError(http.StatusMethodNotAllowed, "Method Not Allowed")
+	}
+
+	period, err := periodParams(r)
+	if err != nil {
+		return err
+	}
+
+	report, httpErr := h.db.GetPortfolioValue(period)
+	if httpErr != nil {
+		return httpErr
+	}
+
+	return utils.WriteJSON(w, report)
+}
@@ -0,0 +1,86 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/Seymour-creates/budget-server/internal/types"
+	"github.com/Seymour-creates/budget-server/internal/utils"
+)
+
+// Items lists the linked Plaid items with their health: status, the Plaid error code behind it and when each
+// last synced. Items with status login_required can be repaired through Link update mode.
+func (h *Handler) Items(w http.ResponseWriter, r *http.Request) error {
+	if r.Method != http.MethodGet {
+		return utils.NewHTTPError(http.StatusMethodNotAllowed, "Method Not Allowed")
+	}
+	items, err := h.db.FetchPlaidItems()
+	if err != nil {
+		return err
+	}
+	return utils.WriteJSON(w, items)
+}
+
+// Item serves the actions on a single item. POST /items/{id}/link_token returns an update mode Link token
+// for the item, and POST /items/{id}/repaired marks it active again once Link reports success and queues a
+// sync for it. DELETE /items/{id} unlinks the bank, closing its accounts; ?expenses= says what happens to the
+// expenses imported from it: keep (the default), anonymize or delete.
+func (h *Handler) Item(w http.ResponseWriter, r *http.Request) error {
+	itemID, action, _ := strings.Cut(strings.TrimPrefix(r.URL.Path, "/items/"), "/")
+	if itemID == "" {
+		return utils.NewHTTPError(http.StatusBadRequest, "missing item id")
+	}
+	if action == "" {
+		if r.Method != http.MethodDelete {
+			return utils.NewHTTPError(http.StatusMethodNotAllowed, "Method Not Allowed")
+		}
+		expenses := r.URL.Query().Get("expenses")
+		if expenses == "" {
+			expenses = types.ExpensesKeep
+		}
+		switch expenses {
+		case types.ExpensesKeep, types.ExpensesAnonymize, types.ExpensesDelete:
+		default:
+			return utils.NewHTTPError(http.StatusBadRequest, fmt.Sprintf("expenses must be %v, %v or %v, not %q",
+				types.ExpensesKeep, types.ExpensesAnonymize, types.ExpensesDelete, expenses))
+		}
+		removal, err := h.plaid.RemoveItem(r.Context(), itemID, expenses)
+		if err != nil {
+			return err
+		}
+		return utils.WriteJSON(w, removal)
+	}
+	if r.Method != http.MethodPost {
+		return utils.NewHTTPError(http.StatusMethodNotAllowed, "Method Not Allowed")
+	}
+
+	switch action {
+	case "link_token":
+		linkToken, err := h.plaid.UpdateLinkToken(r.Context(), itemID)
+		if err != nil {
+			return err
+		}
+		return utils.WriteJSON(w, map[string]string{"link_token": linkToken})
+	case "repaired":
+		if err := h.plaid.RepairItem(itemID); err != nil {
+			return err
+		}
+		return utils.WriteJSON(w, map[string]string{"status": "success", "item_id": itemID})
+	default:
+		return utils.NewHTTPError(http.StatusNotFound, fmt.Sprintf("unknown item action %q", action))
+	}
+}
+
+// ItemRemovals lists the items that have been removed, newest first, with what was done with their accounts
+// and expenses.
+func (h *Handler) ItemRemovals(w http.ResponseWriter, r *http.Request) error {
+	if r.Method != http.MethodGet {
+		return utils.NewHTTPError(http.StatusMethodNotAllowed, "Method Not Allowed")
+	}
+	removals, err := h.db.ListItemRemovals()
+	if err != nil {
+		return err
+	}
+	return utils.WriteJSON(w, removals)
+}
@@ -0,0 +1,127 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/Seymour-creates/budget-server/internal/types"
+	"github.com/Seymour-creates/budget-server/internal/utils"
+)
+
+// periodParams selects the reporting period from the query string. Exactly one of these forms may be used:
+//
+//	from=YYYY-MM-DD&to=YYYY-MM-DD       an explicit inclusive range
+//	month=YYYY-MM                       a calendar month
+//	period=week|month|quarter|year|ytd  the interval containing ?date=YYYY-MM-DD (default today), or
+//	                                    the year to date
+//
+// With none of them the current month is used.
+func periodParams(r *http.Request) (types.Period, error) {
+	query := r.URL.Query()
+	hasRange := query.Get("from") != "" || query.Get("to") != ""
+	forms := 0
+	for _, present := range []bool{hasRange, query.Get("month") != "", query.Get("period") != ""} {
+		if present {
+			forms++
+		}
+	}
+	if forms > 1 {
+		return types.Period{}, utils.NewHTTPError(http.StatusBadRequest, "use only one of from/to, month or period")
+	}
+
+	switch {
+	case hasRange:
+		from, err := dateParam(r, "from")
+		if err != nil {
+			return types.Period{}, err
+		}
+		to, err := dateParam(r, "to")
+		if err != nil {
+			return types.Period{}, err
+		}
+		if from.IsZero() || to.IsZero() {
+			return types.Period{}, utils.NewHTTPError(http.StatusBadRequest, "from and to must be given together")
+		}
+		if to.Before(from) {
+			return types.Period{}, utils.NewHTTPError(http.StatusBadRequest, "to must not be before from")
+		}
+		return types.Period{Start: from, End: to}, nil
+	case query.Get("month") != "":
+		month, err := monthParam(r, "month", time.Time{})
+		if err != nil {
+			return types.Period{}, err
+		}
+		return utils.PeriodContaining(month, types.IntervalMonth)
+	case query.Get("period") != "":
+		anchor, err := dateParam(r, "date")
+		if err != nil {
+			return types.Period{}, err
+		}
+		if anchor.IsZero() {
+			anchor = time.Now()
+		}
+		if query.Get("period") == "ytd" {
+			year, _ := utils.PeriodContaining(anchor, types.IntervalYear)
+			return types.Period{Start: year.Start, End: time.Date(anchor.Year(), anchor.Month(), anchor.Day(), 0, 0, 0, 0, anchor.Location())}, nil
+		}
+		period, err := utils.PeriodContaining(anchor, query.Get("period"))
+		if err != nil {
+			return types.Period{}, utils.NewHTTPError(http.StatusBadRequest, err.Error())
+		}
+		return period, nil
+	default:
+		return utils.PeriodContaining(time.Now(), types.IntervalMonth)
+	}
+}
+
+// monthParam reads a YYYY-MM query param, returning fallback if it is absent.
+func monthParam(r *http.Request, name string, fallback time.Time) (time.Time, error) {
+	value := r.URL.Query().Get(name)
+	if value == "" {
+		return fallback, nil
+	}
+	month, err := utils.ParseMonth(value)
+	if err != nil {
+		return time.Time{}, utils.NewHTTPError(http.StatusBadRequest, fmt.Sprintf("invalid %v %q, expected YYYY-MM", name, value))
+	}
+	return month, nil
+}
+
+// dateParam reads a YYYY-MM-DD query param, returning the zero time if it is absent.
+func dateParam(r *http.Request, name string) (time.Time, error) {
+	value := r.URL.Query().Get(name)
+	if value == "" {
+		return time.Time{}, nil
+	}
+	date, err := time.ParseInLocation("2006-01-02", value, time.Local)
+	if err != nil {
+		return time.Time{}, utils.NewHTTPError(http.StatusBadRequest, fmt.Sprintf("invalid %v %q, expected YYYY-MM-DD", name, value))
+	}
+	return date, nil
+}
+
+func amountParam(r *http.Request, name string) (*float64, error) {
+	value := r.URL.Query().Get(name)
+	if value == "" {
+		return nil, nil
+	}
+	amount, err := strconv.ParseFloat(value, 64)
+	if err != nil {
+		return nil, utils.NewHTTPError(http.StatusBadRequest, fmt.Sprintf("invalid %v %q", name, value))
+	}
+	return &amount, nil
+}
+
+func intParam(r *http.Request, name string) (int, error) {
+	value := r.URL.Query().Get(name)
+	if value == "" {
+		return 0, nil
+	}
+	n, err := strconv.Atoi(value)
+	if err != nil || n < 0 {
+		return 0, utils.NewHTTPError(http.StatusBadRequest, fmt.Sprintf("invalid %v %q", name, value))
+	}
+	return n, nil
+}
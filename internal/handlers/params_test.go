@@ -0,0 +1,65 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/Seymour-creates/budget-server/internal/types"
+)
+
+func TestPeriodParams(t *testing.T) {
+	today := time.Now()
+	thisMonth := time.Date(today.Year(), today.Month(), 1, 0, 0, 0, 0, time.Local)
+	tests := []struct {
+		query      string
+		start, end string
+	}{
+		{"from=2024-03-05&to=2024-04-10", "2024-03-05", "2024-04-10"},
+		{"from=2024-03-05&to=2024-03-05", "2024-03-05", "2024-03-05"},
+		{"month=2024-02", "2024-02-01", "2024-02-29"},
+		{"period=week&date=2024-05-15", "2024-05-13", "2024-05-19"},
+		{"period=week&date=2024-05-19", "2024-05-13", "2024-05-19"},
+		{"period=month&date=2024-05-15", "2024-05-01", "2024-05-31"},
+		{"period=quarter&date=2024-05-15", "2024-04-01", "2024-06-30"},
+		{"period=year&date=2024-05-15", "2024-01-01", "2024-12-31"},
+		{"period=ytd&date=2024-05-15", "2024-01-01", "2024-05-15"},
+		{"", thisMonth.Format("2006-01-02"), thisMonth.AddDate(0, 1, -1).Format("2006-01-02")},
+	}
+	for _, test := range tests {
+		period, err := periodParams(httptest.NewRequest(http.MethodGet, "/get_expenses?"+test.query, nil))
+		if err != nil {
+			t.Errorf("?%v: %v", test.query, err)
+			continue
+		}
+		if start, end := period.Start.Format("2006-01-02"), period.End.Format("2006-01-02"); start != test.start || end != test.end {
+			t.Errorf("?%v = %v to %v, want %v to %v", test.query, start, end, test.start, test.end)
+		}
+	}
+}
+
+func TestPeriodParamsRejectsBadQueries(t *testing.T) {
+	queries := []string{
+		"from=2024-03-05&month=2024-03",
+		"month=2024-03&period=week",
+		"from=2024-03-05&to=2024-03-31&period=month",
+		"from=2024-03-05",
+		"to=2024-03-05",
+		"from=2024-04-10&to=2024-03-05",
+		"from=2024-13-01&to=2024-13-31",
+		"from=03/05/2024&to=2024-03-31",
+		"month=2024-3x",
+		"month=May",
+		"period=fortnight",
+		"period=week&date=yesterday",
+	}
+	for _, query := range queries {
+		_, err := periodParams(httptest.NewRequest(http.MethodGet, "/get_expenses?"+query, nil))
+		var httpErr *types.HTTPError
+		if !errors.As(err, &httpErr) || httpErr.StatusCode != http.StatusBadRequest {
+			t.Errorf("?%v: error %v, want a 400", query, err)
+		}
+	}
+}
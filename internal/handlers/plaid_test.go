@@ -0,0 +1,197 @@
+package handlers_test
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Seymour-creates/budget-server/internal/handlers"
+	"github.com/Seymour-creates/budget-server/internal/plaidfake"
+	"github.com/Seymour-creates/budget-server/internal/testutil"
+	"github.com/Seymour-creates/budget-server/internal/types"
+	"github.com/Seymour-creates/budget-server/internal/utils"
+)
+
+// TestMain runs the tests from the repository root, where the handlers find their templates.
+func TestMain(m *testing.M) {
+	if err := os.Chdir("../.."); err != nil {
+		panic(err)
+	}
+	os.Exit(m.Run())
+}
+
+// testServer serves the Plaid routes of the real server over HTTP, backed by a fake Plaid and a fresh in-memory
+// SQLite database, with the sync worker running.
+type testServer struct {
+	*httptest.Server
+	fake *plaidfake.Server
+}
+
+func newTestServer(t *testing.T) *testServer {
+	t.Helper()
+	mux := http.NewServeMux()
+	server := httptest.NewServer(mux)
+	t.Cleanup(server.Close)
+
+	p := testutil.NewPlaid(t, server.URL+"/plaid_webhook")
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+	go p.Service.RunSyncWorker(ctx)
+
+	h := handlers.MakeNewHttpHandler(p.Service, p.DB, nil, nil)
+	mux.HandleFunc("/link_user_account", utils.ErrorHandler(h.LinkBank))
+	mux.HandleFunc("/create_plaid_item", utils.ErrorHandler(h.CreatePlaidBankItem))
+	mux.HandleFunc("/refresh_expenses_via_plaid", utils.ErrorHandler(h.UpdateExpenseData))
+	mux.HandleFunc("/plaid_webhook", utils.ErrorHandler(h.PlaidWebhook))
+	mux.HandleFunc("/expenses", utils.ErrorHandler(h.Expenses))
+	mux.HandleFunc("/items", utils.ErrorHandler(h.Items))
+	return &testServer{Server: server, fake: p.Fake}
+}
+
+// do sends a request to the server and decodes its JSON response into out, failing the test on any status but
+// 200.
+func (s *testServer) do(t *testing.T, req *http.Request, out interface{}) {
+	t.Helper()
+	resp, err := s.Client().Do(req)
+	if err != nil {
+		t.Fatalf("%v %v: %v", req.Method, req.URL.Path, err)
+	}
+	defer resp.Body.Close()
+	body, _ := io.ReadAll(resp.Body)
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("%v %v responded %v: %s", req.Method, req.URL.Path, resp.Status, body)
+	}
+	if out != nil {
+		if err := json.Unmarshal(body, out); err != nil {
+			t.Fatalf("decoding %v response %s: %v", req.URL.Path, body, err)
+		}
+	}
+}
+
+func (s *testServer) expenses(t *testing.T) []types.Expense {
+	t.Helper()
+	req, _ := http.NewRequest(http.MethodGet, s.URL+"/expenses?source="+types.ExpenseSourcePlaid, nil)
+	var expenses []types.Expense
+	s.do(t, req, &expenses)
+	return expenses
+}
+
+// link opens the Link page, links an item at the fake's default institution as Link would, and posts the
+// public token to /create_plaid_item, returning the new item's ID.
+func (s *testServer) link(t *testing.T) string {
+	t.Helper()
+	resp, err := s.Client().Get(s.URL + "/link_user_account")
+	if err != nil {
+		t.Fatalf("opening link page: %v", err)
+	}
+	page, _ := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK || !strings.Contains(string(page), `token: "link-`) {
+		t.Fatalf("link page responded %v without a link token: %s", resp.Status, page)
+	}
+
+	publicToken, err := s.fake.LinkItem("ins_fake")
+	if err != nil {
+		t.Fatalf("linking item: %v", err)
+	}
+	req, _ := http.NewRequest(http.MethodPost, s.URL+"/create_plaid_item",
+		strings.NewReader(url.Values{"public_token": {publicToken}}.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	var created map[string]string
+	s.do(t, req, &created)
+	if created["item_id"] == "" {
+		t.Fatalf("create_plaid_item returned no item id: %v", created)
+	}
+	return created["item_id"]
+}
+
+func TestLinkExchangeAndSync(t *testing.T) {
+	server := newTestServer(t)
+	itemID := server.link(t)
+
+	var items []types.PlaidItem
+	req, _ := http.NewRequest(http.MethodGet, server.URL+"/items", nil)
+	server.do(t, req, &items)
+	if len(items) != 1 || items[0].ItemID != itemID || items[0].Status != types.ItemStatusActive {
+		t.Fatalf("items = %+v, want the linked item active", items)
+	}
+
+	var synced struct {
+		Status string                 `json:"status"`
+		Items  []types.ItemSyncResult `json:"items"`
+	}
+	req, _ = http.NewRequest(http.MethodPost, server.URL+"/refresh_expenses_via_plaid", nil)
+	server.do(t, req, &synced)
+	want := len(plaidfake.DefaultFixture().Transactions)
+	if synced.Status != "success" || len(synced.Items) != 1 || synced.Items[0].Added != want {
+		t.Fatalf("sync responded %+v, want %d transactions added", synced, want)
+	}
+
+	expenses := server.expenses(t)
+	if len(expenses) != want {
+		t.Fatalf("stored %d expenses, want %d", len(expenses), want)
+	}
+	var groceries *types.Expense
+	for i := range expenses {
+		if expenses[i].ExternalID == "fake-tx-5" {
+			groceries = &expenses[i]
+		}
+	}
+	// Plaid reports amounts as float32, so they are compared with a tolerance.
+	if groceries == nil || math.Abs(groceries.Amount-64.37) > 0.005 || groceries.Merchant != "Grocery Mart" || groceries.AccountID != "fake-checking" {
+		t.Errorf("synced grocery expense = %+v", groceries)
+	}
+
+	// Syncing again picks up only what changed.
+	req, _ = http.NewRequest(http.MethodPost, server.URL+"/refresh_expenses_via_plaid", nil)
+	server.do(t, req, &synced)
+	if synced.Items[0].Added != 0 || len(server.expenses(t)) != want {
+		t.Errorf("second sync added %d and left %d expenses, want 0 and %d", synced.Items[0].Added, len(server.expenses(t)), want)
+	}
+}
+
+func TestWebhookDelivery(t *testing.T) {
+	server := newTestServer(t)
+	itemID := server.link(t)
+
+	if err := server.fake.AddTransactions(itemID, plaidfake.Transaction{TransactionID: "fake-tx-webhook",
+		AccountID: "fake-credit", Name: "BOOK NOOK", MerchantName: "Book Nook", Amount: 18.25}); err != nil {
+		t.Fatal(err)
+	}
+	if err := server.fake.FireWebhook(itemID, "TRANSACTIONS", "SYNC_UPDATES_AVAILABLE"); err != nil {
+		t.Fatalf("delivering sync webhook to /plaid_webhook: %v", err)
+	}
+
+	want := len(plaidfake.DefaultFixture().Transactions) + 1
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		expenses := server.expenses(t)
+		if len(expenses) == want {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("webhook-triggered sync stored %d expenses, want %d", len(expenses), want)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	// A webhook without Plaid's signature is refused.
+	req, _ := http.NewRequest(http.MethodPost, server.URL+"/plaid_webhook",
+		strings.NewReader(`{"webhook_type":"TRANSACTIONS","webhook_code":"SYNC_UPDATES_AVAILABLE","item_id":"`+itemID+`"}`))
+	resp, err := server.Client().Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusUnauthorized {
+		t.Errorf("unsigned webhook responded %v, want 401", resp.Status)
+	}
+}
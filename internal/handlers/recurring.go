@@ -0,0 +1,38 @@
+package handlers
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/Seymour-creates/budget-server/internal/utils"
+)
+
+// Recurring lists the active recurring streams, such as subscriptions, bills and pay, with each outflow whose
+// latest charge is well above its average flagged as a price increase, and each stream whose next transaction
+// is overdue flagged as a missed payment. ?inactive=true includes streams that have stopped.
+func (h *Handler) Recurring(w http.ResponseWriter, r *http.Request) error {
+	if r.Method != http.MethodGet {
+		return utils.NewHTTPError(http.StatusMethodNotAllowed, "Method Not Allowed")
+	}
+	streams, err := h.db.ListRecurringStreams(r.URL.Query().Get("inactive") == "true", time.Now())
+	if err != nil {
+		return err
+	}
+	return utils.WriteJSON(w, streams)
+}
+
+// RefreshRecurring finds the recurring streams again, from Plaid where it offers them and from expenses
+// otherwise, and responds with them as Recurring does. Syncs that import transactions do this on their own.
+func (h *Handler) RefreshRecurring(w http.ResponseWriter, r *http.Request) error {
+	if r.Method != http.MethodPost {
+		return utils.NewHTTPError(http.StatusMethodNotAllowed, "Method Not Allowed")
+	}
+	if err := h.plaid.RefreshRecurring(r.Context()); err != nil {
+		return err
+	}
+	streams, err := h.db.ListRecurringStreams(r.URL.Query().Get("inactive") == "true", time.Now())
+	if err != nil {
+		return err
+	}
+	return utils.WriteJSON(w, streams)
+}
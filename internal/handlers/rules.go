@@ -0,0 +1,178 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/Seymour-creates/budget-server/internal/rules"
+	"github.com/Seymour-creates/budget-server/internal/types"
+	"github.com/Seymour-creates/budget-server/internal/utils"
+)
+
+// Rules serves the /rules collection: GET lists rules in the order they are tried, POST creates one. New rules
+// are enabled and go last unless the body says otherwise.
+func (h *Handler) Rules(w http.ResponseWriter, r *http.Request) error {
+	switch r.Method {
+	case http.MethodGet:
+		stored, err := h.db.ListRules()
+		if err != nil {
+			return err
+		}
+		return utils.WriteJSON(w, stored)
+	case http.MethodPost:
+		rule, err := decodeRule(r)
+		if err != nil {
+			return err
+		}
+		created, httpErr := h.db.InsertRule(rule)
+		if httpErr != nil {
+			return httpErr
+		}
+		return utils.WriteJSON(w, created)
+	default:
+		return utils.NewHTTPError(http.StatusMethodNotAllowed, "Method Not Allowed")
+	}
+}
+
+// Rule serves /rules/{id}: GET returns the rule, PUT replaces it and DELETE removes it. POST /rules/apply
+// re-runs every enabled rule over the expenses in the selected period, or all history when no period is given;
+// add ?dry_run=true to see what would change without saving it.
+func (h *Handler) Rule(w http.ResponseWriter, r *http.Request) error {
+	rest := strings.TrimPrefix(r.URL.Path, "/rules/")
+	if rest == "apply" {
+		return h.ApplyRules(w, r)
+	}
+	id, err := strconv.ParseInt(rest, 10, 64)
+	if err != nil {
+		return utils.NewHTTPError(http.StatusBadRequest, fmt.Sprintf("invalid rule id: %v", err))
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		rule, err := h.db.FetchRule(id)
+		if err != nil {
+			return err
+		}
+		return utils.WriteJSON(w, rule)
+	case http.MethodPut:
+		rule, err := decodeRule(r)
+		if err != nil {
+			return err
+		}
+		updated, httpErr := h.db.UpdateRule(id, rule)
+		if httpErr != nil {
+			return httpErr
+		}
+		return utils.WriteJSON(w, updated)
+	case http.MethodDelete:
+		if err := h.db.DeleteRule(id); err != nil {
+			return err
+		}
+		return utils.WriteJSON(w, map[string]string{"status": "success"})
+	default:
+		return utils.NewHTTPError(http.StatusMethodNotAllowed, "Method Not Allowed")
+	}
+}
+
+// ApplyRules re-categorizes existing expenses with the current rules and returns the ones that changed.
+func (h *Handler) ApplyRules(w http.ResponseWriter, r *http.Request) error {
+	engine, httpErr := rules.Load(h.db)
+	if httpErr != nil {
+		return httpErr
+	}
+	return h.recategorize(w, r, types.ExpenseFilter{}, engine.Apply)
+}
+
+// recategorize runs categorize over the expenses matching filter, narrowed to the period in the query if one
+// is given, saves every expense whose category or tags changed, and writes those expenses out. Categories a
+// person chose by hand are left alone. With ?dry_run=true nothing is saved.
+func (h *Handler) recategorize(w http.ResponseWriter, r *http.Request, filter types.ExpenseFilter, categorize func(*types.Expense) bool) error {
+	if r.Method != http.MethodPost {
+		return utils.NewHTTPError(http.StatusMethodNotAllowed, "Method Not Allowed")
+	}
+
+	query := r.URL.Query()
+	if query.Get("from") != "" || query.Get("to") != "" || query.Get("month") != "" || query.Get("period") != "" {
+		period, err := periodParams(r)
+		if err != nil {
+			return err
+		}
+		filter.From, filter.To = period.Start, period.End
+	}
+	dryRun := query.Get("dry_run") == "true"
+
+	expenses, httpErr := h.db.ListExpenses(filter)
+	if httpErr != nil {
+		return httpErr
+	}
+
+	changed := []types.Expense{}
+	for _, expense := range expenses {
+		if expense.CategorySource == types.CategorySourceUser {
+			continue
+		}
+		updated := expense
+		if !categorize(&updated) || !categorizationChanged(expense, updated) {
+			continue
+		}
+		if !dryRun {
+			patch := types.ExpensePatch{
+				Category:           &updated.Category,
+				Tags:               &updated.Tags,
+				CategorySource:     &updated.CategorySource,
+				CategoryConfidence: updated.CategoryConfidence,
+			}
+			saved, err := h.db.UpdateExpense(expense.ID, patch)
+			if err != nil {
+				return err
+			}
+			updated = *saved
+		}
+		changed = append(changed, updated)
+	}
+	if !dryRun && len(changed) > 0 {
+		h.model.Invalidate()
+	}
+
+	return utils.WriteJSON(w, map[string]interface{}{
+		"dry_run":  dryRun,
+		"updated":  len(changed),
+		"expenses": changed,
+	})
+}
+
+// decodeRule reads a rule from the request body, defaulting it to enabled, and rejects rules that could never
+// be used.
+func decodeRule(r *http.Request) (types.Rule, error) {
+	rule := types.Rule{Enabled: true}
+	if err := json.NewDecoder(r.Body).Decode(&rule); err != nil {
+		return rule, utils.NewHTTPError(http.StatusBadRequest, fmt.Sprintf("error decoding rule: %v", err))
+	}
+	if err := rules.Validate(rule); err != nil {
+		return rule, utils.NewHTTPError(http.StatusBadRequest, err.Error())
+	}
+	return rule, nil
+}
+
+// categorizationChanged reports whether applying rules moved an expense to another category or changed its set
+// of tags.
+func categorizationChanged(before, after types.Expense) bool {
+	return before.Category != after.Category || !sameTags(before.Tags, after.Tags)
+}
+
+func sameTags(a, b []string) bool {
+	set := make(map[string]bool, len(a))
+	for _, tag := range a {
+		set[tag] = true
+	}
+	for _, tag := range b {
+		if !set[tag] {
+			return false
+		}
+		delete(set, tag)
+	}
+	return len(set) == 0
+}
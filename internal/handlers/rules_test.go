@@ -0,0 +1,95 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/Seymour-creates/budget-server/internal/testutil"
+	"github.com/Seymour-creates/budget-server/internal/types"
+)
+
+func TestApplyRulesDryRunLeavesExpensesAlone(t *testing.T) {
+	repo := testutil.NewDB(t)
+	day := time.Date(2024, 5, 3, 0, 0, 0, 0, time.UTC)
+	if err := repo.InsertExpenses([]types.Expense{
+		{Date: day, Description: "Pizza Palace", Amount: 30, Category: "misc"},
+		{Date: day, Description: "Stamps", Amount: 15, Category: "misc"},
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := repo.InsertRule(types.Rule{Merchant: "pizza", Category: "takeout", Tags: []string{"food"}, Enabled: true}); err != nil {
+		t.Fatal(err)
+	}
+	h := MakeNewHttpHandler(nil, repo, nil, nil)
+
+	apply := func(target string) (result struct {
+		DryRun   bool            `json:"dry_run"`
+		Updated  int             `json:"updated"`
+		Expenses []types.Expense `json:"expenses"`
+	}) {
+		t.Helper()
+		recorder := httptest.NewRecorder()
+		if err := h.ApplyRules(recorder, httptest.NewRequest(http.MethodPost, target, nil)); err != nil {
+			t.Fatalf("POST %v: %v", target, err)
+		}
+		if err := json.NewDecoder(recorder.Body).Decode(&result); err != nil {
+			t.Fatalf("decoding response: %v", err)
+		}
+		return result
+	}
+	categories := func() map[string]string {
+		t.Helper()
+		expenses, err := repo.ListExpenses(types.ExpenseFilter{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		byDescription := map[string]string{}
+		for _, expense := range expenses {
+			byDescription[expense.Description] = expense.Category
+		}
+		return byDescription
+	}
+
+	preview := apply("/rules/apply?dry_run=true")
+	if !preview.DryRun || preview.Updated != 1 || preview.Expenses[0].Category != "takeout" {
+		t.Fatalf("dry run = %+v, want the pizza expense moved to takeout", preview)
+	}
+	if got := categories(); got["Pizza Palace"] != "misc" || got["Stamps"] != "misc" {
+		t.Fatalf("dry run saved changes: %v", got)
+	}
+
+	applied := apply("/rules/apply")
+	if applied.DryRun || applied.Updated != 1 {
+		t.Fatalf("apply = %+v, want one update", applied)
+	}
+	if got := categories(); got["Pizza Palace"] != "takeout" || got["Stamps"] != "misc" {
+		t.Fatalf("after apply categories = %v", got)
+	}
+
+	// The tags are now in place too, so applying again changes nothing.
+	if again := apply("/rules/apply"); again.Updated != 0 {
+		t.Errorf("second apply updated %d expenses, want 0", again.Updated)
+	}
+}
+
+func TestCategorizationChangedComparesTagSets(t *testing.T) {
+	tests := []struct {
+		before, after []string
+		want          bool
+	}{
+		{nil, nil, false},
+		{[]string{"a", "b"}, []string{"b", "a"}, false},
+		{[]string{"a", "b"}, []string{"a", "c"}, true},
+		{[]string{"a"}, []string{"a", "b"}, true},
+	}
+	for _, test := range tests {
+		before := types.Expense{Category: "misc", Tags: test.before}
+		after := types.Expense{Category: "misc", Tags: test.after}
+		if got := categorizationChanged(before, after); got != test.want {
+			t.Errorf("categorizationChanged(%v, %v) = %v, want %v", test.before, test.after, got, test.want)
+		}
+	}
+}
@@ -0,0 +1,113 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	"github.com/Seymour-creates/budget-server/internal/classifier"
+	"github.com/Seymour-creates/budget-server/internal/rules"
+	"github.com/Seymour-creates/budget-server/internal/types"
+	"github.com/Seymour-creates/budget-server/internal/utils"
+)
+
+// maxSuggestions caps how many ranked categories are returned for one expense.
+const maxSuggestions = 3
+
+// categoryGuess is how an expense would be filed if it were imported now, with the model's ranked alternatives
+// so a client can ask the user to confirm guesses it isn't sure about.
+type categoryGuess struct {
+	Category       string                     `json:"category,omitempty"`
+	CategorySource string                     `json:"category_source,omitempty"`
+	Confidence     float64                    `json:"confidence"`
+	AutoApply      bool                       `json:"auto_apply"`
+	Suggestions    []types.CategorySuggestion `json:"suggestions"`
+}
+
+// SuggestCategories serves POST /categorize: given a list of expenses that haven't been posted yet, it returns
+// a categoryGuess for each, in order. A matching rule is certain; otherwise the guess is the category model's
+// best suggestion, which would only be applied automatically when auto_apply is true.
+func (h *Handler) SuggestCategories(w http.ResponseWriter, r *http.Request) error {
+	if r.Method != http.MethodPost {
+		return utils.NewHTTPError(http.StatusMethodNotAllowed, "Method Not Allowed")
+	}
+
+	var expenses []types.Expense
+	if err := json.NewDecoder(r.Body).Decode(&expenses); err != nil {
+		return utils.NewHTTPError(http.StatusBadRequest, fmt.Sprintf("error decoding expenses to categorize: %v", err))
+	}
+	engine, httpErr := rules.Load(h.db)
+	if httpErr != nil {
+		return httpErr
+	}
+	model, httpErr := h.model.Model()
+	if httpErr != nil {
+		return httpErr
+	}
+
+	guesses := make([]categoryGuess, len(expenses))
+	for i, expense := range expenses {
+		guesses[i] = guessCategory(engine, model, expense)
+	}
+	return utils.WriteJSON(w, guesses)
+}
+
+// ExpenseSuggestions serves GET /expenses/{id}/suggestions.
+func (h *Handler) ExpenseSuggestions(w http.ResponseWriter, r *http.Request, id int64) error {
+	if r.Method != http.MethodGet {
+		return utils.NewHTTPError(http.StatusMethodNotAllowed, "Method Not Allowed")
+	}
+
+	expense, httpErr := h.db.FetchExpense(id)
+	if httpErr != nil {
+		return httpErr
+	}
+	engine, httpErr := rules.Load(h.db)
+	if httpErr != nil {
+		return httpErr
+	}
+	model, httpErr := h.model.Model()
+	if httpErr != nil {
+		return httpErr
+	}
+	return utils.WriteJSON(w, guessCategory(engine, model, *expense))
+}
+
+// CategoryCorrections serves GET /category_corrections, the manual category changes the model learns from,
+// newest first.
+func (h *Handler) CategoryCorrections(w http.ResponseWriter, r *http.Request) error {
+	if r.Method != http.MethodGet {
+		return utils.NewHTTPError(http.StatusMethodNotAllowed, "Method Not Allowed")
+	}
+
+	corrections, err := h.db.ListCategoryCorrections()
+	if err != nil {
+		return err
+	}
+	return utils.WriteJSON(w, corrections)
+}
+
+func guessCategory(engine *rules.Engine, model *classifier.Model, expense types.Expense) categoryGuess {
+	best, confident := model.Best(expense)
+	suggestions := model.Suggest(expense)
+	if len(suggestions) > maxSuggestions {
+		suggestions = suggestions[:maxSuggestions]
+	}
+	guess := categoryGuess{Suggestions: suggestions}
+	if guess.Suggestions == nil {
+		guess.Suggestions = []types.CategorySuggestion{}
+	}
+
+	if engine.Apply(&expense) {
+		guess.Category = expense.Category
+		guess.CategorySource = types.CategorySourceRule
+		guess.Confidence = 1
+		guess.AutoApply = true
+	} else if len(suggestions) > 0 {
+		guess.Category = best.Category
+		guess.CategorySource = types.CategorySourceModel
+		guess.Confidence = best.Confidence
+		guess.AutoApply = confident
+	}
+	return guess
+}
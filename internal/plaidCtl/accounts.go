@@ -0,0 +1,93 @@
+package plaidCtl
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/Seymour-creates/budget-server/internal/types"
+	"github.com/plaid/plaid-go/plaid"
+)
+
+// defaultAccountFilters limits Link to the account subtypes offered before filters were configurable.
+const defaultAccountFilters = "depository:checking,savings"
+
+// linkAccountFilters parses a PLAID_ACCOUNT_FILTERS value into the account types and subtypes Link offers.
+// The format is type:subtype,subtype;type:subtype, e.g. "depository:checking,savings;credit:credit card". A type
+// without subtypes allows all of its subtypes, and "all" leaves Link unfiltered, which is reported as nil.
+func linkAccountFilters(spec string) (*plaid.LinkTokenAccountFilters, error) {
+	spec = strings.TrimSpace(spec)
+	if spec == "" {
+		spec = defaultAccountFilters
+	}
+	if strings.EqualFold(spec, "all") {
+		return nil, nil
+	}
+
+	filters := &plaid.LinkTokenAccountFilters{}
+	for _, group := range strings.Split(spec, ";") {
+		accountType, rawSubtypes, _ := strings.Cut(group, ":")
+		accountType = strings.ToLower(strings.TrimSpace(accountType))
+		if accountType == "" {
+			continue
+		}
+		subtypes := []plaid.AccountSubtype{}
+		for _, subtype := range strings.Split(rawSubtypes, ",") {
+			if subtype = strings.ToLower(strings.TrimSpace(subtype)); subtype != "" {
+				subtypes = append(subtypes, plaid.AccountSubtype(subtype))
+			}
+		}
+		if len(subtypes) == 0 {
+			subtypes = []plaid.AccountSubtype{"all"}
+		}
+		switch plaid.AccountType(accountType) {
+		case plaid.ACCOUNTTYPE_DEPOSITORY:
+			filters.Depository = &plaid.DepositoryFilter{AccountSubtypes: subtypes}
+		case plaid.ACCOUNTTYPE_CREDIT:
+			filters.Credit = &plaid.CreditFilter{AccountSubtypes: subtypes}
+		case plaid.ACCOUNTTYPE_LOAN:
+			filters.Loan = &plaid.LoanFilter{AccountSubtypes: subtypes}
+		case plaid.ACCOUNTTYPE_INVESTMENT:
+			filters.Investment = &plaid.InvestmentFilter{AccountSubtypes: subtypes}
+		default:
+			return nil, fmt.Errorf("unknown account type %q in account filters", accountType)
+		}
+	}
+	return filters, nil
+}
+
+// depositoryOnly reports whether filters limit Link to depository accounts, the only kind Auth supports.
+func depositoryOnly(filters *plaid.LinkTokenAccountFilters) bool {
+	return filters != nil && filters.Depository != nil && filters.Credit == nil && filters.Loan == nil && filters.Investment == nil
+}
+
+// RetrieveAccounts returns the accounts behind an item as /accounts/get reports them. Errors are returned as
+// the Plaid client reports them so callers can inspect the Plaid error code.
+func (s *Service) RetrieveAccounts(ctx context.Context, itemID, accessToken string) ([]types.Account, error) {
+	resp, _, err := s.Client.PlaidApi.AccountsGet(ctx).AccountsGetRequest(*plaid.NewAccountsGetRequest(accessToken)).Execute()
+	if err != nil {
+		return nil, err
+	}
+	accounts := make([]types.Account, 0, len(resp.GetAccounts()))
+	for _, account := range resp.GetAccounts() {
+		accounts = append(accounts, formatAccount(itemID, account))
+	}
+	return accounts, nil
+}
+
+func formatAccount(itemID string, account plaid.AccountBase) types.Account {
+	currency := account.Balances.GetIsoCurrencyCode()
+	if currency == "" {
+		currency = account.Balances.GetUnofficialCurrencyCode()
+	}
+	return types.Account{
+		AccountID:    account.GetAccountId(),
+		ItemID:       itemID,
+		Name:         account.GetName(),
+		OfficialName: account.GetOfficialName(),
+		Mask:         account.GetMask(),
+		Type:         string(account.GetType()),
+		Subtype:      string(account.GetSubtype()),
+		Currency:     currency,
+	}
+}
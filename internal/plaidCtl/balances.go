@@ -0,0 +1,149 @@
+package plaidCtl
+
+import (
+	"context"
+	"log"
+	"math"
+	"time"
+
+	"github.com/Seymour-creates/budget-server/internal/types"
+	"github.com/plaid/plaid-go/plaid"
+)
+
+// BalanceRefreshInterval is how often RunBalanceJob records balances. Snapshots are kept per day, so running
+// more often only refreshes the current day's.
+const BalanceRefreshInterval = 24 * time.Hour
+
+// RefreshBalances records today's balance of every account behind every linked item from
+// /accounts/balance/get, refreshing the accounts' details and the repayment terms of any credit card and loan
+// accounts on the way. Items that need the user to relink are skipped, and an item whose request fails doesn't
+// stop the others; the result for each item says which happened. Low balance alerts are checked afterwards. Only
+// database failures are returned as errors.
+func (s *Service) RefreshBalances(ctx context.Context) ([]types.ItemBalanceResult, *types.HTTPError) {
+	results, err := s.refreshBalances(ctx)
+	if err != nil {
+		return nil, err
+	}
+	s.evaluateAlerts()
+	return results, nil
+}
+
+// refreshBalances holds syncMu like a sync does, since it also writes the item's accounts: an item removed while
+// its balances were being fetched would otherwise have its closed accounts reopened.
+func (s *Service) refreshBalances(ctx context.Context) ([]types.ItemBalanceResult, *types.HTTPError) {
+	s.syncMu.Lock()
+	defer s.syncMu.Unlock()
+
+	items, err := s.db.FetchPlaidItems()
+	if err != nil {
+		return nil, err
+	}
+	results := make([]types.ItemBalanceResult, 0, len(items))
+	for _, item := range items {
+		result, err := s.refreshItemBalances(ctx, item, time.Now())
+		if err != nil {
+			return nil, err
+		}
+		results = append(results, result)
+	}
+	return results, nil
+}
+
+func (s *Service) refreshItemBalances(ctx context.Context, item types.PlaidItem, day time.Time) (types.ItemBalanceResult, *types.HTTPError) {
+	result := types.ItemBalanceResult{
+		ItemID:      item.ItemID,
+		Institution: item.Institution,
+		Status:      item.Status,
+		ErrorCode:   item.ErrorCode,
+	}
+	if item.NeedsRelink() {
+		result.Skipped = true
+		return result, nil
+	}
+
+	accessToken, err := s.accessToken(item)
+	if err != nil {
+		return result, err
+	}
+	request := plaid.NewAccountsBalanceGetRequest(accessToken)
+	resp, _, plaidErr := s.Client.PlaidApi.AccountsBalanceGet(ctx).AccountsBalanceGetRequest(*request).Execute()
+	if plaidErr != nil {
+		result.Status, result.ErrorCode = itemHealth(plaidErr)
+		log.Printf("error fetching balances for item %v: %v", item.ItemID, plaidErr)
+		return result, s.db.UpdatePlaidItemStatus(item.ItemID, result.Status, result.ErrorCode)
+	}
+
+	accounts := make([]types.Account, 0, len(resp.GetAccounts()))
+	balances := make([]types.Balance, 0, len(resp.GetAccounts()))
+	for _, account := range resp.GetAccounts() {
+		stored := formatAccount(item.ItemID, account)
+		accounts = append(accounts, stored)
+		balances = append(balances, formatBalance(stored, account.Balances, day))
+	}
+	if err := s.db.UpsertAccounts(accounts); err != nil {
+		return result, err
+	}
+	if err := s.db.UpsertBalances(balances); err != nil {
+		return result, err
+	}
+	result.Accounts = len(balances)
+
+	if s.config.Uses(plaid.PRODUCTS_LIABILITIES) && hasLiabilityAccounts(accounts) {
+		// Not every institution offers Liabilities; balances are still worth keeping when it fails.
+		liabilities, plaidErr := s.RetrieveLiabilities(ctx, accessToken)
+		if plaidErr != nil {
+			log.Printf("unable to fetch liabilities for item %v: %v", item.ItemID, plaidErr)
+			return result, nil
+		}
+		if err := s.db.UpsertLiabilities(liabilities); err != nil {
+			return result, err
+		}
+		result.Liabilities = len(liabilities)
+	}
+	return result, nil
+}
+
+func formatBalance(account types.Account, balance plaid.AccountBalance, day time.Time) types.Balance {
+	formatted := types.Balance{
+		AccountID: account.AccountID,
+		Date:      day,
+		Current:   cents(balance.GetCurrent()),
+		Currency:  account.Currency,
+	}
+	if available, ok := balance.GetAvailableOk(); ok && available != nil {
+		value := cents(*available)
+		formatted.Available = &value
+	}
+	if limit, ok := balance.GetLimitOk(); ok && limit != nil {
+		value := cents(*limit)
+		formatted.Limit = &value
+	}
+	return formatted
+}
+
+// cents converts one of Plaid's float32 amounts, rounding away the float32 noise past the cent.
+func cents(amount float32) float64 {
+	return math.Round(float64(amount)*100) / 100
+}
+
+// RunBalanceJob records balances now and then every interval until ctx is cancelled.
+func (s *Service) RunBalanceJob(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		results, err := s.RefreshBalances(ctx)
+		if err != nil {
+			log.Printf("error refreshing balances: %v", err.Message)
+		}
+		for _, result := range results {
+			if result.ErrorCode != "" {
+				log.Printf("item %v balances not refreshed: %v %v", result.ItemID, result.Status, result.ErrorCode)
+			}
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
@@ -0,0 +1,135 @@
+package plaidCtl
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/Seymour-creates/budget-server/internal/classifier"
+	"github.com/Seymour-creates/budget-server/internal/db"
+	"github.com/Seymour-creates/budget-server/internal/rules"
+	"github.com/Seymour-creates/budget-server/internal/types"
+	"github.com/plaid/plaid-go/plaid"
+)
+
+// confidenceRank orders Plaid's personal finance category confidence levels. Missing and UNKNOWN levels rank
+// below all of them.
+var confidenceRank = map[string]int{
+	"LOW":       1,
+	"MEDIUM":    2,
+	"HIGH":      3,
+	"VERY_HIGH": 4,
+}
+
+// Categorizer files Plaid expenses the way an import does: by the first matching user rule, then by the
+// category model if it is confident enough, and otherwise by the Plaid category mapping.
+type Categorizer struct {
+	rules  *rules.Engine
+	model  *classifier.Model
+	mapper *CategoryMapper
+}
+
+// LoadCategorizer builds a Categorizer from the rules and mappings currently stored and the cached category model.
+func LoadCategorizer(repo db.Repository, cache *classifier.Cache) (*Categorizer, *types.HTTPError) {
+	engine, err := rules.Load(repo)
+	if err != nil {
+		return nil, err
+	}
+	model, err := cache.Model()
+	if err != nil {
+		return nil, err
+	}
+	mapper, err := LoadCategoryMapper(repo)
+	if err != nil {
+		return nil, err
+	}
+	return &Categorizer{rules: engine, model: model, mapper: mapper}, nil
+}
+
+// Categorize sets expense's category and how it was chosen. A nil *Categorizer uses the legacy Plaid mapping.
+func (c *Categorizer) Categorize(expense *types.Expense) {
+	if c == nil {
+		c = &Categorizer{}
+	}
+	if c.rules.Apply(expense) || c.model.Apply(expense) {
+		return
+	}
+	expense.Category = c.mapper.Category(*expense)
+	expense.CategorySource = types.CategorySourcePlaid
+	expense.CategoryConfidence = nil
+}
+
+// CategoryMapper files expenses under budget categories from their Plaid personal finance category (PFC). It
+// falls back to the legacy Plaid category hierarchy when a transaction has no PFC, no mapping covers it, or
+// Plaid's confidence is below what the mapping asks for. A nil *CategoryMapper always uses the fallback.
+type CategoryMapper struct {
+	byPrimary  map[string]types.CategoryMapping
+	byDetailed map[string]types.CategoryMapping
+}
+
+// CategoryMappingStore is the part of db.Repository mappings are loaded from.
+type CategoryMappingStore interface {
+	ListCategoryMappings() ([]types.CategoryMapping, *types.HTTPError)
+}
+
+// NewCategoryMapper indexes mappings by primary and detailed PFC.
+func NewCategoryMapper(mappings []types.CategoryMapping) *CategoryMapper {
+	mapper := &CategoryMapper{
+		byPrimary:  map[string]types.CategoryMapping{},
+		byDetailed: map[string]types.CategoryMapping{},
+	}
+	for _, mapping := range mappings {
+		if mapping.Detailed != "" {
+			mapper.byDetailed[mapping.Detailed] = mapping
+		} else {
+			mapper.byPrimary[mapping.Primary] = mapping
+		}
+	}
+	return mapper
+}
+
+// LoadCategoryMapper builds a mapper from the mappings currently stored.
+func LoadCategoryMapper(store CategoryMappingStore) (*CategoryMapper, *types.HTTPError) {
+	mappings, err := store.ListCategoryMappings()
+	if err != nil {
+		return nil, err
+	}
+	return NewCategoryMapper(mappings), nil
+}
+
+// Category returns the budget category for expense from the raw Plaid categories stored on it.
+func (m *CategoryMapper) Category(expense types.Expense) string {
+	if mapping, ok := m.lookup(expense.PFCPrimary, expense.PFCDetailed); ok &&
+		confidenceRank[expense.PFCConfidence] >= confidenceRank[mapping.MinConfidence] {
+		return mapping.Category
+	}
+	var legacy []string
+	if expense.PlaidCategory != "" {
+		legacy = strings.Split(expense.PlaidCategory, " > ")
+	}
+	return cPlaidCategoryToExpense(legacy)
+}
+
+// lookup finds the most specific mapping for a PFC: its detailed category if mapped, else its primary.
+func (m *CategoryMapper) lookup(primary, detailed string) (types.CategoryMapping, bool) {
+	if m == nil || primary == "" {
+		return types.CategoryMapping{}, false
+	}
+	if mapping, ok := m.byDetailed[detailed]; ok && detailed != "" {
+		return mapping, true
+	}
+	mapping, ok := m.byPrimary[primary]
+	return mapping, ok
+}
+
+// personalFinanceCategory returns a transaction's PFC and Plaid's confidence in it. plaid-go doesn't model the
+// confidence level yet, so it is read from the category's additional properties.
+func personalFinanceCategory(transaction plaid.Transaction) (primary, detailed, confidence string) {
+	pfc, ok := transaction.GetPersonalFinanceCategoryOk()
+	if !ok || pfc == nil {
+		return "", "", ""
+	}
+	if level, ok := pfc.AdditionalProperties["confidence_level"]; ok {
+		confidence = strings.ToUpper(fmt.Sprint(level))
+	}
+	return pfc.Primary, pfc.Detailed, confidence
+}
@@ -0,0 +1,234 @@
+package plaidCtl
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"strings"
+
+	"github.com/plaid/plaid-go/plaid"
+)
+
+// environments maps the PLAID_ENV names to the Plaid API hosts they select.
+var environments = map[string]plaid.Environment{
+	"sandbox":     plaid.Sandbox,
+	"development": plaid.Development,
+	"production":  plaid.Production,
+}
+
+// linkLanguages are the languages Plaid Link can be displayed in.
+var linkLanguages = []string{"da", "de", "en", "es", "et", "fr", "it", "lt", "lv", "nl", "no", "pl", "pt", "ro", "sv"}
+
+// optionalProducts are the products this server can add to an item after Link, when its institution offers them.
+var optionalProducts = []plaid.Products{plaid.PRODUCTS_LIABILITIES, plaid.PRODUCTS_INVESTMENTS}
+
+// Config is how the server talks to Plaid and sets up Link. Load it with LoadConfig, which validates it.
+type Config struct {
+	// Environment is the name of the Plaid environment, and BaseURL the API host it selects.
+	Environment string
+	BaseURL     string
+	ClientID    string
+	Secret      string
+
+	// ClientName is the app name Link shows the user.
+	ClientName   string
+	Language     string
+	CountryCodes []plaid.CountryCode
+
+	// Products must all be supported by an institution for Link to offer it. OptionalProducts are used on items
+	// whose institution supports them, without limiting which institutions Link offers.
+	Products         []plaid.Products
+	OptionalProducts []plaid.Products
+
+	// AccountFilters limits the accounts Link offers; nil offers every account.
+	AccountFilters *plaid.LinkTokenAccountFilters
+	RedirectURI    string
+	WebhookURL     string
+}
+
+// LoadConfig reads the Plaid configuration from the environment and validates it, reporting every problem
+// found rather than just the first:
+//
+//	PLAID_ENV                sandbox (default), development or production
+//	PLAID_BASE_URL           API host to use instead of PLAID_ENV's, e.g. a fake Plaid server; optional
+//	PLAID_CLIENT_ID          required
+//	PLAID_SECRET             required
+//	PLAID_CLIENT_NAME        app name shown in Link, default XAT
+//	PLAID_LANGUAGE           Link language, default en
+//	PLAID_COUNTRY_CODES      comma separated, default US
+//	PLAID_PRODUCTS           comma separated, must include transactions; default transactions, plus auth when
+//	                         the account filters only allow depository accounts
+//	PLAID_OPTIONAL_PRODUCTS  comma separated from liabilities and investments, or none; default liabilities
+//	PLAID_ACCOUNT_FILTERS    see linkAccountFilters
+//	PLAID_REDIRECT_URI       OAuth redirect URI, default APP_URL + /oauth_after when APP_URL is set
+//	PLAID_WEBHOOK_URL        where Plaid sends webhooks, optional
+func LoadConfig() (Config, error) {
+	var problems []error
+	config := Config{
+		Environment: strings.ToLower(envOr("PLAID_ENV", "sandbox")),
+		ClientID:    os.Getenv("PLAID_CLIENT_ID"),
+		Secret:      os.Getenv("PLAID_SECRET"),
+		ClientName:  envOr("PLAID_CLIENT_NAME", "XAT"),
+		Language:    strings.ToLower(envOr("PLAID_LANGUAGE", "en")),
+		RedirectURI: os.Getenv("PLAID_REDIRECT_URI"),
+		WebhookURL:  os.Getenv("PLAID_WEBHOOK_URL"),
+	}
+
+	environment, ok := environments[config.Environment]
+	if !ok {
+		problems = append(problems, fmt.Errorf("PLAID_ENV %q must be sandbox, development or production", config.Environment))
+	}
+	config.BaseURL = string(environment)
+	if baseURL := os.Getenv("PLAID_BASE_URL"); baseURL != "" {
+		if err := checkURL(baseURL, false); err != nil {
+			problems = append(problems, fmt.Errorf("PLAID_BASE_URL: %w", err))
+		}
+		config.BaseURL = strings.TrimSuffix(baseURL, "/")
+	}
+	if config.ClientID == "" {
+		problems = append(problems, errors.New("PLAID_CLIENT_ID is required"))
+	}
+	if config.Secret == "" {
+		problems = append(problems, errors.New("PLAID_SECRET is required"))
+	}
+	if strings.TrimSpace(config.ClientName) == "" {
+		problems = append(problems, errors.New("PLAID_CLIENT_NAME must not be blank"))
+	}
+	if !contains(linkLanguages, config.Language) {
+		problems = append(problems, fmt.Errorf("PLAID_LANGUAGE %q is not a language Link supports (%v)", config.Language, strings.Join(linkLanguages, ", ")))
+	}
+
+	for _, code := range splitList(envOr("PLAID_COUNTRY_CODES", "US")) {
+		countryCode, err := plaid.NewCountryCodeFromValue(strings.ToUpper(code))
+		if err != nil {
+			problems = append(problems, fmt.Errorf("PLAID_COUNTRY_CODES: %q is not a country Plaid supports", code))
+			continue
+		}
+		config.CountryCodes = append(config.CountryCodes, *countryCode)
+	}
+
+	filters, err := linkAccountFilters(os.Getenv("PLAID_ACCOUNT_FILTERS"))
+	if err != nil {
+		problems = append(problems, fmt.Errorf("PLAID_ACCOUNT_FILTERS: %w", err))
+	}
+	config.AccountFilters = filters
+
+	var productProblems []error
+	config.Products, productProblems = parseProducts("PLAID_PRODUCTS", envOr("PLAID_PRODUCTS", "transactions"))
+	problems = append(problems, productProblems...)
+	config.OptionalProducts, productProblems = parseProducts("PLAID_OPTIONAL_PRODUCTS", envOr("PLAID_OPTIONAL_PRODUCTS", "liabilities"))
+	problems = append(problems, productProblems...)
+	if config.Uses(plaid.PRODUCTS_INVESTMENTS) && filters != nil && filters.Investment == nil {
+		// Investments is no use unless Link offers the accounts it covers.
+		filters.Investment = &plaid.InvestmentFilter{AccountSubtypes: []plaid.AccountSubtype{"all"}}
+	}
+	if os.Getenv("PLAID_PRODUCTS") == "" && depositoryOnly(filters) {
+		config.Products = append([]plaid.Products{plaid.PRODUCTS_AUTH}, config.Products...)
+	}
+
+	if !containsProduct(config.Products, plaid.PRODUCTS_TRANSACTIONS) {
+		problems = append(problems, errors.New("PLAID_PRODUCTS must include transactions"))
+	}
+	if containsProduct(config.Products, plaid.PRODUCTS_AUTH) && !depositoryOnly(filters) {
+		problems = append(problems, errors.New("PLAID_PRODUCTS: auth only covers depository accounts, so PLAID_ACCOUNT_FILTERS must allow nothing else"))
+	}
+	for _, product := range config.OptionalProducts {
+		if !containsProduct(optionalProducts, product) {
+			problems = append(problems, fmt.Errorf("PLAID_OPTIONAL_PRODUCTS: %v can't be added after Link; use liabilities or investments", product))
+		}
+		if containsProduct(config.Products, product) {
+			problems = append(problems, fmt.Errorf("%v is in both PLAID_PRODUCTS and PLAID_OPTIONAL_PRODUCTS", product))
+		}
+	}
+
+	if config.RedirectURI == "" && os.Getenv("APP_URL") != "" {
+		config.RedirectURI = strings.TrimSuffix(os.Getenv("APP_URL"), "/") + "/oauth_after"
+	}
+	if config.RedirectURI != "" {
+		if err := checkURL(config.RedirectURI, config.Environment == "production"); err != nil {
+			problems = append(problems, fmt.Errorf("redirect URI (PLAID_REDIRECT_URI or APP_URL): %w", err))
+		}
+	}
+	if config.WebhookURL != "" {
+		if err := checkURL(config.WebhookURL, false); err != nil {
+			problems = append(problems, fmt.Errorf("PLAID_WEBHOOK_URL: %w", err))
+		}
+	}
+
+	return config, errors.Join(problems...)
+}
+
+// Uses reports whether product is one of the required or optional products.
+func (c Config) Uses(product plaid.Products) bool {
+	return containsProduct(c.Products, product) || containsProduct(c.OptionalProducts, product)
+}
+
+// parseProducts reads a comma separated list of products; "none" is the empty list.
+func parseProducts(name, value string) ([]plaid.Products, []error) {
+	var products []plaid.Products
+	var problems []error
+	if strings.EqualFold(value, "none") {
+		return nil, nil
+	}
+	for _, raw := range splitList(value) {
+		product, err := plaid.NewProductsFromValue(strings.ToLower(raw))
+		if err != nil {
+			problems = append(problems, fmt.Errorf("%v: %q is not a Plaid product", name, raw))
+			continue
+		}
+		if !containsProduct(products, *product) {
+			products = append(products, *product)
+		}
+	}
+	return products, problems
+}
+
+// checkURL requires an absolute http(s) URL, and https when secure is set.
+func checkURL(raw string, secure bool) error {
+	parsed, err := url.Parse(raw)
+	if err != nil || parsed.Host == "" || (parsed.Scheme != "https" && parsed.Scheme != "http") {
+		return fmt.Errorf("%q is not an absolute http(s) URL", raw)
+	}
+	if secure && parsed.Scheme != "https" {
+		return fmt.Errorf("%q must use https in production", raw)
+	}
+	return nil
+}
+
+// envOr returns the environment variable name, or fallback when it is unset or blank.
+func envOr(name, fallback string) string {
+	if value := strings.TrimSpace(os.Getenv(name)); value != "" {
+		return value
+	}
+	return fallback
+}
+
+// splitList splits a comma separated list, dropping blank entries.
+func splitList(value string) []string {
+	var items []string
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+func contains(values []string, value string) bool {
+	for _, candidate := range values {
+		if candidate == value {
+			return true
+		}
+	}
+	return false
+}
+
+func containsProduct(products []plaid.Products, product plaid.Products) bool {
+	for _, candidate := range products {
+		if candidate == product {
+			return true
+		}
+	}
+	return false
+}
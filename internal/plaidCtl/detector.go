@@ -0,0 +1,183 @@
+package plaidCtl
+
+import (
+	"crypto/sha1"
+	"encoding/hex"
+	"math"
+	"sort"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/Seymour-creates/budget-server/internal/types"
+	"github.com/Seymour-creates/budget-server/internal/utils"
+)
+
+// cadence is a frequency the local detector recognizes: the typical days between transactions, and how far
+// each gap may stray from it.
+type cadence struct {
+	frequency string
+	days      int
+	tolerance int
+}
+
+var cadences = []cadence{
+	{types.FrequencyWeekly, 7, 1},
+	// Semi-monthly gaps overlap biweekly ones, so semi-monthly is only matched by expenses that also keep to the
+	// same two days of the month; see onTwoDaysOfMonth.
+	{types.FrequencySemiMonthly, 15, 3},
+	{types.FrequencyBiweekly, 14, 2},
+	{types.FrequencyMonthly, 30, 4},
+	{types.FrequencyAnnually, 365, 14},
+}
+
+// amountTolerance is how far a transaction's amount may stray from the median of its stream, as a fraction of
+// it, so price changes don't break a stream up.
+const amountTolerance = 0.25
+
+// DetectRecurring finds recurring streams among expenses: at least three transactions, or two for an annual
+// charge, on the same account with the same merchant, in the same direction, at similar amounts and at a
+// regular weekly, semi-monthly, biweekly, monthly or annual cadence. A stream stops being active once a whole period has gone
+// by after its next expected transaction, as of now.
+func DetectRecurring(expenses []types.Expense, now time.Time) []types.RecurringStream {
+	groups := map[string][]types.Expense{}
+	var keys []string
+	for _, expense := range expenses {
+		name := merchantKey(expense)
+		if name == "" || expense.Amount == 0 {
+			continue
+		}
+		key := expense.AccountID + "|" + direction(expense.Amount) + "|" + name
+		if _, ok := groups[key]; !ok {
+			keys = append(keys, key)
+		}
+		groups[key] = append(groups[key], expense)
+	}
+	sort.Strings(keys)
+
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.UTC)
+	var streams []types.RecurringStream
+	for _, key := range keys {
+		group := groups[key]
+		sort.SliceStable(group, func(a, b int) bool { return group[a].Date.Before(group[b].Date) })
+		match, ok := matchCadence(group)
+		if !ok || !similarAmounts(group) {
+			continue
+		}
+
+		first, last := group[0], group[len(group)-1]
+		var total float64
+		for _, expense := range group {
+			total += math.Abs(expense.Amount)
+		}
+		stream := types.RecurringStream{
+			StreamID:         localStreamID(key),
+			Source:           types.StreamSourceLocal,
+			AccountID:        last.AccountID,
+			Merchant:         last.Merchant,
+			Description:      last.Description,
+			Category:         last.Category,
+			Direction:        direction(last.Amount),
+			Frequency:        match.frequency,
+			AverageAmount:    math.Round(total/float64(len(group))*100) / 100,
+			LastAmount:       magnitude(last.Amount),
+			FirstDate:        first.Date,
+			LastDate:         last.Date,
+			NextDate:         nextOccurrence(match.frequency, last.Date),
+			TransactionCount: len(group),
+		}
+		if stream.Merchant == "" {
+			stream.Merchant = last.Description
+		}
+		stream.Active = !today.After(stream.NextDate.AddDate(0, 0, match.days+match.tolerance))
+		streams = append(streams, stream)
+	}
+	return streams
+}
+
+// matchCadence finds the cadence every gap between consecutive expenses fits, if there is one.
+func matchCadence(expenses []types.Expense) (cadence, bool) {
+	if len(expenses) < 2 {
+		return cadence{}, false
+	}
+	gaps := make([]int, len(expenses)-1)
+	for i := 1; i < len(expenses); i++ {
+		gaps[i-1] = int(math.Round(expenses[i].Date.Sub(expenses[i-1].Date).Hours() / 24))
+	}
+	for _, candidate := range cadences {
+		if len(expenses) < 3 && candidate.frequency != types.FrequencyAnnually {
+			continue
+		}
+		if candidate.frequency == types.FrequencySemiMonthly && !onTwoDaysOfMonth(expenses) {
+			continue
+		}
+		fits := true
+		for _, gap := range gaps {
+			if gap < candidate.days-candidate.tolerance || gap > candidate.days+candidate.tolerance {
+				fits = false
+				break
+			}
+		}
+		if fits {
+			return candidate, true
+		}
+	}
+	return cadence{}, false
+}
+
+// onTwoDaysOfMonth reports whether each expense falls within a couple of days of a month after the one two
+// before it, as charges on the 1st and 15th do when weekends move them. A biweekly stream drifts through the
+// month instead, though it can take a few months to show.
+func onTwoDaysOfMonth(expenses []types.Expense) bool {
+	for i := 2; i < len(expenses); i++ {
+		expected := utils.AddMonths(expenses[i-2].Date, 1)
+		if math.Abs(expenses[i].Date.Sub(expected).Hours()/24) > 2 {
+			return false
+		}
+	}
+	return true
+}
+
+// similarAmounts reports whether every expense's amount is within amountTolerance of their median.
+func similarAmounts(expenses []types.Expense) bool {
+	amounts := make([]float64, len(expenses))
+	for i, expense := range expenses {
+		amounts[i] = math.Abs(expense.Amount)
+	}
+	sort.Float64s(amounts)
+	median := amounts[len(amounts)/2]
+	if len(amounts)%2 == 0 {
+		median = (amounts[len(amounts)/2-1] + median) / 2
+	}
+	for _, amount := range amounts {
+		if math.Abs(amount-median) > median*amountTolerance {
+			return false
+		}
+	}
+	return true
+}
+
+// merchantKey identifies who an expense was with: its merchant, or failing that its description, lowercased
+// and without the digits and punctuation that vary between charges, such as dates and reference numbers.
+func merchantKey(expense types.Expense) string {
+	name := expense.Merchant
+	if name == "" {
+		name = expense.Description
+	}
+	return strings.Join(strings.FieldsFunc(strings.ToLower(name), func(r rune) bool {
+		return !unicode.IsLetter(r)
+	}), " ")
+}
+
+func direction(amount float64) string {
+	if amount < 0 {
+		return types.StreamInflow
+	}
+	return types.StreamOutflow
+}
+
+// localStreamID gives a detected stream an ID that stays the same from one detection to the next.
+func localStreamID(key string) string {
+	sum := sha1.Sum([]byte(key))
+	return "local-" + hex.EncodeToString(sum[:8])
+}
@@ -0,0 +1,123 @@
+package plaidCtl_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/Seymour-creates/budget-server/internal/plaidCtl"
+	"github.com/Seymour-creates/budget-server/internal/testutil"
+	"github.com/Seymour-creates/budget-server/internal/types"
+)
+
+// charges returns an expense at merchant for each of amounts, the first on start and each after the one
+// before by next.
+func charges(merchant string, start time.Time, next func(time.Time) time.Time, amounts ...float64) []types.Expense {
+	var expenses []types.Expense
+	day := start
+	for _, amount := range amounts {
+		expenses = append(expenses, types.Expense{Date: day, Description: merchant, Merchant: merchant, AccountID: "acc-1",
+			Amount: amount, Category: "misc"})
+		day = next(day)
+	}
+	return expenses
+}
+
+func every(days int) func(time.Time) time.Time {
+	return func(day time.Time) time.Time { return day.AddDate(0, 0, days) }
+}
+
+func TestDetectRecurringInfersCadence(t *testing.T) {
+	jan := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	// Pay on the 1st and 15th, or the Friday before when that falls on a weekend.
+	var paydays []types.Expense
+	for _, day := range []string{"2024-04-01", "2024-04-15", "2024-05-01", "2024-05-15", "2024-05-31", "2024-06-14",
+		"2024-07-01"} {
+		date, _ := time.Parse("2006-01-02", day)
+		paydays = append(paydays, types.Expense{Date: date, Description: "ACME PAYROLL", AccountID: "acc-1", Amount: -2000})
+	}
+
+	tests := []struct {
+		name     string
+		expenses []types.Expense
+		want     string
+	}{
+		{"weekly", charges("Farm Box", jan, every(7), 30, 30, 32, 30), types.FrequencyWeekly},
+		{"biweekly", charges("Cleaner", jan, every(14), 80, 80, 80, 80, 80, 80, 80, 80), types.FrequencyBiweekly},
+		{"semi-monthly", paydays, types.FrequencySemiMonthly},
+		{"monthly", charges("Netflix", jan.AddDate(0, 0, 30), func(day time.Time) time.Time {
+			return day.AddDate(0, 1, 0)
+		}, 15.49, 15.49, 15.49), types.FrequencyMonthly},
+		{"annually", charges("Costco Membership", jan, func(day time.Time) time.Time { return day.AddDate(1, 0, 0) }, 60, 65),
+			types.FrequencyAnnually},
+		{"irregular", charges("Hardware Store", jan, every(9), 20, 20, 20), ""},
+		{"two monthly charges are too few", charges("Gym", jan, every(30), 40, 40), ""},
+		{"amounts too far apart", charges("Utility", jan, every(30), 40, 90, 40), ""},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			streams := plaidCtl.DetectRecurring(test.expenses, jan.AddDate(1, 4, 0))
+			if test.want == "" {
+				if len(streams) != 0 {
+					t.Errorf("detected %+v, want nothing", streams)
+				}
+				return
+			}
+			if len(streams) != 1 || streams[0].Frequency != test.want {
+				t.Fatalf("detected %+v, want one %v stream", streams, test.want)
+			}
+			if streams[0].TransactionCount != len(test.expenses) {
+				t.Errorf("stream counts %d transactions, want %d", streams[0].TransactionCount, len(test.expenses))
+			}
+		})
+	}
+}
+
+func TestDetectRecurringKeepsStreamsApart(t *testing.T) {
+	jan := time.Date(2024, 1, 5, 0, 0, 0, 0, time.UTC)
+	expenses := append(charges("Spotify", jan, every(30), 10.99, 10.99, 10.99),
+		charges("Hulu", jan, every(30), 7.99, 7.99, 7.99)...)
+	// A refund from the same merchant isn't part of its outflow stream.
+	expenses = append(expenses, types.Expense{Date: jan.AddDate(0, 0, 3), Description: "Spotify", Merchant: "Spotify",
+		AccountID: "acc-1", Amount: -10.99})
+
+	streams := plaidCtl.DetectRecurring(expenses, jan.AddDate(0, 2, 0))
+	if len(streams) != 2 {
+		t.Fatalf("detected %d streams, want 2: %+v", len(streams), streams)
+	}
+	for _, stream := range streams {
+		if stream.Direction != types.StreamOutflow || stream.TransactionCount != 3 {
+			t.Errorf("stream = %+v, want an outflow of 3 charges", stream)
+		}
+	}
+}
+
+func TestRecurringStreamFlags(t *testing.T) {
+	jan := time.Date(2024, 1, 10, 0, 0, 0, 0, time.UTC)
+	monthly := func(day time.Time) time.Time { return day.AddDate(0, 1, 0) }
+	expenses := append(charges("Streamflix", jan, monthly, 10, 10, 10, 10, 12),
+		charges("Gym", jan, monthly, 40, 40, 40, 40)...)
+	expenses = append(expenses, charges("Paper", jan, every(7), 5, 5, 5, 5)...)
+	// The Gym's next charge was due on May 10th; the paper's stopped coming long ago.
+	asOf := time.Date(2024, 5, 20, 0, 0, 0, 0, time.UTC)
+
+	man := testutil.NewDB(t)
+	if err := man.ReplaceRecurringStreams(plaidCtl.DetectRecurring(expenses, asOf)); err != nil {
+		t.Fatal(err.Message)
+	}
+	streams, err := man.ListRecurringStreams(false, asOf)
+	if err != nil {
+		t.Fatal(err.Message)
+	}
+	flags := map[string][2]bool{}
+	for _, stream := range streams {
+		flags[stream.Merchant] = [2]bool{stream.PriceIncrease, stream.MissedPayment}
+	}
+	want := map[string][2]bool{
+		// Raised from 10 to 12, and charged on May 10th as expected.
+		"Streamflix": {true, false},
+		"Gym":        {false, true},
+	}
+	if len(flags) != len(want) || flags["Streamflix"] != want["Streamflix"] || flags["Gym"] != want["Gym"] {
+		t.Errorf("price increase and missed payment flags = %v, want %v", flags, want)
+	}
+}
@@ -0,0 +1,180 @@
+package plaidCtl
+
+import (
+	"context"
+	"log"
+	"math"
+	"time"
+
+	"github.com/Seymour-creates/budget-server/internal/types"
+	"github.com/plaid/plaid-go/plaid"
+)
+
+// investmentHistoryMonths is how far back the first import of an item's investment transactions reaches, the
+// most Plaid keeps.
+const investmentHistoryMonths = 24
+
+// investmentOverlapDays is how far before the latest stored investment transaction later imports start, so ones
+// the institution reports late aren't missed.
+const investmentOverlapDays = 30
+
+// investmentPageSize is the largest page /investments/transactions/get will return.
+const investmentPageSize = 500
+
+// RetrieveInvestments returns the holdings of an item's investment accounts from /investments/holdings/get,
+// recorded as of day, and its investment transactions since since from /investments/transactions/get, along
+// with the securities they refer to. Errors are returned as the Plaid client reports them so callers can inspect
+// the Plaid error code.
+func (s *Service) RetrieveInvestments(ctx context.Context, accessToken string, since, day time.Time) (*types.InvestmentSync, error) {
+	sync := &types.InvestmentSync{Date: day}
+	securities := map[string]bool{}
+	addSecurities := func(list []plaid.Security) {
+		for _, security := range list {
+			if !securities[security.SecurityId] {
+				securities[security.SecurityId] = true
+				sync.Securities = append(sync.Securities, formatSecurity(security))
+			}
+		}
+	}
+
+	holdingsRequest := plaid.NewInvestmentsHoldingsGetRequest(accessToken)
+	holdings, _, err := s.Client.PlaidApi.InvestmentsHoldingsGet(ctx).InvestmentsHoldingsGetRequest(*holdingsRequest).Execute()
+	if err != nil {
+		return nil, err
+	}
+	addSecurities(holdings.GetSecurities())
+	for _, account := range holdings.GetAccounts() {
+		if account.GetType() == plaid.ACCOUNTTYPE_INVESTMENT {
+			sync.AccountIDs = append(sync.AccountIDs, account.GetAccountId())
+		}
+	}
+	for _, holding := range holdings.GetHoldings() {
+		sync.Holdings = append(sync.Holdings, types.Holding{
+			AccountID:        holding.AccountId,
+			SecurityID:       holding.SecurityId,
+			Date:             day,
+			Quantity:         decimal(holding.Quantity, 8),
+			InstitutionPrice: decimal(holding.InstitutionPrice, 6),
+			InstitutionValue: cents(holding.InstitutionValue),
+			CostBasis:        nullableAmount(holding.CostBasis),
+			Currency:         currencyCode(holding.IsoCurrencyCode, holding.UnofficialCurrencyCode),
+		})
+	}
+
+	for offset := int32(0); ; {
+		request := plaid.NewInvestmentsTransactionsGetRequest(accessToken, since.Format("2006-01-02"), day.Format("2006-01-02"))
+		request.SetOptions(plaid.InvestmentsTransactionsGetRequestOptions{
+			Count:  plaid.PtrInt32(investmentPageSize),
+			Offset: plaid.PtrInt32(offset),
+		})
+		page, _, err := s.Client.PlaidApi.InvestmentsTransactionsGet(ctx).InvestmentsTransactionsGetRequest(*request).Execute()
+		if err != nil {
+			return nil, err
+		}
+		addSecurities(page.GetSecurities())
+		for _, transaction := range page.GetInvestmentTransactions() {
+			formatted, ok := formatInvestmentTransaction(transaction)
+			if !ok {
+				log.Printf("skipping investment transaction %v with invalid date %q", transaction.InvestmentTransactionId, transaction.Date)
+				continue
+			}
+			sync.Transactions = append(sync.Transactions, formatted)
+		}
+		offset += int32(len(page.GetInvestmentTransactions()))
+		if len(page.GetInvestmentTransactions()) == 0 || offset >= page.GetTotalInvestmentTransactions() {
+			break
+		}
+	}
+	return sync, nil
+}
+
+// syncInvestments imports an item's holdings and recent investment transactions into result's item. Plaid
+// failures are only logged, since not every institution offers Investments and the rest of the sync is still
+// worth keeping; only database failures are returned.
+func (s *Service) syncInvestments(ctx context.Context, item types.PlaidItem, accessToken string, result *types.ItemSyncResult) *types.HTTPError {
+	latest, err := s.db.LatestInvestmentTransactionDate(item.ItemID)
+	if err != nil {
+		return err
+	}
+	now := time.Now()
+	since := now.AddDate(0, -investmentHistoryMonths, 0)
+	if !latest.IsZero() {
+		since = latest.AddDate(0, 0, -investmentOverlapDays)
+	}
+
+	sync, plaidErr := s.RetrieveInvestments(ctx, accessToken, since, now)
+	if plaidErr != nil {
+		log.Printf("unable to fetch investments for item %v: %v", item.ItemID, plaidErr)
+		return nil
+	}
+	if err := s.db.ApplyInvestmentSync(*sync); err != nil {
+		return err
+	}
+	result.Holdings, result.InvestmentTransactions = len(sync.Holdings), len(sync.Transactions)
+	return nil
+}
+
+// hasInvestmentAccounts reports whether any of accounts is an investment account.
+func hasInvestmentAccounts(accounts []types.Account) bool {
+	for _, account := range accounts {
+		if account.Type == types.AccountTypeInvestment {
+			return true
+		}
+	}
+	return false
+}
+
+func formatSecurity(security plaid.Security) types.Security {
+	formatted := types.Security{
+		SecurityID:       security.SecurityId,
+		Name:             security.GetName(),
+		Ticker:           security.GetTickerSymbol(),
+		Type:             security.GetType(),
+		IsCashEquivalent: security.GetIsCashEquivalent(),
+		ClosePriceAsOf:   date(security.ClosePriceAsOf.Get()),
+		Currency:         currencyCode(security.IsoCurrencyCode, security.UnofficialCurrencyCode),
+	}
+	if price := security.ClosePrice.Get(); price != nil {
+		value := decimal(*price, 6)
+		formatted.ClosePrice = &value
+	}
+	return formatted
+}
+
+func formatInvestmentTransaction(transaction plaid.InvestmentTransaction) (types.InvestmentTransaction, bool) {
+	day := date(&transaction.Date)
+	if day == nil {
+		return types.InvestmentTransaction{}, false
+	}
+	return types.InvestmentTransaction{
+		InvestmentTransactionID: transaction.InvestmentTransactionId,
+		AccountID:               transaction.AccountId,
+		SecurityID:              transaction.GetSecurityId(),
+		Date:                    *day,
+		Name:                    transaction.Name,
+		Type:                    transaction.Type,
+		Subtype:                 transaction.Subtype,
+		Quantity:                decimal(transaction.Quantity, 8),
+		Price:                   decimal(transaction.Price, 6),
+		Amount:                  cents(transaction.Amount),
+		Fees:                    nullableAmount(transaction.Fees),
+		Currency:                currencyCode(transaction.IsoCurrencyCode, transaction.UnofficialCurrencyCode),
+	}, true
+}
+
+// currencyCode prefers the ISO currency code Plaid reports, falling back to its unofficial one.
+func currencyCode(iso, unofficial plaid.NullableString) string {
+	if code := iso.Get(); code != nil && *code != "" {
+		return *code
+	}
+	if code := unofficial.Get(); code != nil {
+		return *code
+	}
+	return ""
+}
+
+// decimal converts one of Plaid's float32 quantities or prices, keeping places decimal places.
+func decimal(value float32, places int) float64 {
+	scale := math.Pow(10, float64(places))
+	return math.Round(float64(value)*scale) / scale
+}
@@ -0,0 +1,121 @@
+package plaidCtl
+
+import (
+	"context"
+	"math"
+	"time"
+
+	"github.com/Seymour-creates/budget-server/internal/types"
+	"github.com/plaid/plaid-go/plaid"
+)
+
+// purchaseAPR is the APR type Plaid uses for the rate charged on card purchases.
+const purchaseAPR = "purchase_apr"
+
+// RetrieveLiabilities returns the repayment terms of an item's credit card, student loan and mortgage accounts
+// from /liabilities/get. Plaid adds the Liabilities product to items linked without it on the first call.
+// Errors are returned as the Plaid client reports them so callers can inspect the Plaid error code.
+func (s *Service) RetrieveLiabilities(ctx context.Context, accessToken string) ([]types.Liability, error) {
+	request := plaid.NewLiabilitiesGetRequest(accessToken)
+	resp, _, err := s.Client.PlaidApi.LiabilitiesGet(ctx).LiabilitiesGetRequest(*request).Execute()
+	if err != nil {
+		return nil, err
+	}
+
+	var liabilities []types.Liability
+	for _, card := range resp.Liabilities.Credit {
+		if card.GetAccountId() == "" {
+			continue
+		}
+		liability := types.Liability{
+			AccountID:         card.GetAccountId(),
+			Kind:              types.LiabilityKindCredit,
+			MinimumPayment:    amount(card.MinimumPaymentAmount),
+			NextDueDate:       date(card.NextPaymentDueDate.Get()),
+			StatementBalance:  amount(card.LastStatementBalance),
+			LastPaymentAmount: amount(card.LastPaymentAmount),
+			LastPaymentDate:   date(card.LastPaymentDate.Get()),
+			Overdue:           card.GetIsOverdue(),
+		}
+		for i, apr := range card.Aprs {
+			if i == 0 || apr.AprType == purchaseAPR {
+				liability.APR = rate(&apr.AprPercentage)
+			}
+			if apr.AprType == purchaseAPR {
+				break
+			}
+		}
+		liabilities = append(liabilities, liability)
+	}
+	for _, loan := range resp.Liabilities.Student {
+		if loan.GetAccountId() == "" {
+			continue
+		}
+		liabilities = append(liabilities, types.Liability{
+			AccountID:         loan.GetAccountId(),
+			Kind:              types.LiabilityKindStudent,
+			APR:               rate(&loan.InterestRatePercentage),
+			MinimumPayment:    nullableAmount(loan.MinimumPaymentAmount),
+			NextDueDate:       date(loan.NextPaymentDueDate.Get()),
+			LastPaymentAmount: nullableAmount(loan.LastPaymentAmount),
+			LastPaymentDate:   date(loan.LastPaymentDate.Get()),
+			Overdue:           loan.GetIsOverdue(),
+		})
+	}
+	for _, mortgage := range resp.Liabilities.Mortgage {
+		liabilities = append(liabilities, types.Liability{
+			AccountID:         mortgage.AccountId,
+			Kind:              types.LiabilityKindMortgage,
+			APR:               rate(mortgage.InterestRate.Percentage.Get()),
+			MinimumPayment:    nullableAmount(mortgage.NextMonthlyPayment),
+			NextDueDate:       date(mortgage.NextPaymentDueDate.Get()),
+			LastPaymentAmount: nullableAmount(mortgage.LastPaymentAmount),
+			LastPaymentDate:   date(mortgage.LastPaymentDate.Get()),
+			Overdue:           mortgage.GetPastDueAmount() > 0,
+		})
+	}
+	return liabilities, nil
+}
+
+// hasLiabilityAccounts reports whether any of accounts is a credit card or loan.
+func hasLiabilityAccounts(accounts []types.Account) bool {
+	for _, account := range accounts {
+		if account.IsLiability() {
+			return true
+		}
+	}
+	return false
+}
+
+func amount(value float32) *float64 {
+	rounded := cents(value)
+	return &rounded
+}
+
+func nullableAmount(value plaid.NullableFloat32) *float64 {
+	if value.Get() == nil {
+		return nil
+	}
+	return amount(*value.Get())
+}
+
+// rate converts one of Plaid's float32 percentages, keeping the four decimal places it is stored with.
+func rate(value *float32) *float64 {
+	if value == nil {
+		return nil
+	}
+	rounded := math.Round(float64(*value)*10000) / 10000
+	return &rounded
+}
+
+// date parses one of Plaid's YYYY-MM-DD dates, treating missing or malformed ones as unknown.
+func date(value *string) *time.Time {
+	if value == nil {
+		return nil
+	}
+	parsed, err := time.Parse("2006-01-02", *value)
+	if err != nil {
+		return nil
+	}
+	return &parsed
+}
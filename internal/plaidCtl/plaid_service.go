@@ -1,74 +1,147 @@
 package plaidCtl
 
 import (
+	"context"
 	"fmt"
+	"github.com/Seymour-creates/budget-server/internal/alerts"
+	"github.com/Seymour-creates/budget-server/internal/classifier"
+	"github.com/Seymour-creates/budget-server/internal/db"
+	"github.com/Seymour-creates/budget-server/internal/secrets"
 	"github.com/Seymour-creates/budget-server/internal/types"
 	"github.com/Seymour-creates/budget-server/internal/utils"
 	"github.com/plaid/plaid-go/plaid"
 	"log"
 	"net/http"
-	"os"
 	"strings"
+	"sync"
 	"time"
 )
 
+// syncPageSize is the largest page /transactions/sync will return.
+const syncPageSize = 500
+
+// maxSyncRestarts is how many times RetrieveTransactions restarts pagination because the item changed under it
+// before giving up until the next sync, and syncRestartBackoff how long it waits before the first restart,
+// doubling each time.
+const maxSyncRestarts = 3
+
+var syncRestartBackoff = time.Second
+
+// syncQueueSize bounds how many items can be waiting for a webhook-triggered sync.
+const syncQueueSize = 64
+
 type Service struct {
-	Client *plaid.APIClient
+	Client   *plaid.APIClient
+	db       db.Repository
+	sealer   *secrets.Sealer
+	verifier *webhookVerifier
+	config   Config
+	// alerts checks alert thresholds after each import; nil turns alerts off.
+	alerts *alerts.Evaluator
+	// model is the category model imports are filed with, shared with the HTTP handlers.
+	model *classifier.Cache
+
+	// syncMu serializes syncs, balance refreshes and item removals, so a webhook and a manual refresh never page
+	// the same cursor at once, and nothing writes an item's accounts while it is being removed.
+	syncMu    sync.Mutex
+	syncQueue chan string
+	queueMu   sync.Mutex
+	queued    map[string]bool
 }
 
-func NewService(client *plaid.APIClient) *Service {
+// NewService returns a Service for the Plaid API behind client. model may be nil to give the service a category
+// model cache of its own.
+func NewService(client *plaid.APIClient, repo db.Repository, sealer *secrets.Sealer, config Config, evaluator *alerts.Evaluator,
+	model *classifier.Cache) *Service {
+	if model == nil {
+		model = classifier.NewCache(repo)
+	}
 	return &Service{
-		Client: client,
+		Client:    client,
+		db:        repo,
+		sealer:    sealer,
+		verifier:  newWebhookVerifier(client),
+		config:    config,
+		alerts:    evaluator,
+		model:     model,
+		syncQueue: make(chan string, syncQueueSize),
+		queued:    map[string]bool{},
 	}
 }
 
-func (s *Service) RetrieveTransactions(r *http.Request) ([]plaid.Transaction, *types.HTTPError) {
-	log.Printf("access token used for req: %v", os.Getenv("PLAID_ACCESS_TOKEN"))
-	const dateFormat = "2006-01-02"
-	currentMo := time.Now()
-	startDate := time.Date(currentMo.Year(), currentMo.Month(), 1, 0, 0, 0, 0, currentMo.Location()).Format(dateFormat)
-	endDate := time.Now().Format(dateFormat)
-	isTrue := true
-	request := plaid.NewTransactionsGetRequest(os.Getenv("PLAID_ACCESS_TOKEN"), startDate, endDate)
-	options := plaid.TransactionsGetRequestOptions{
-		IncludePersonalFinanceCategoryBeta: &isTrue,
-		Offset:                             plaid.PtrInt32(0),
-		Count:                              plaid.PtrInt32(100),
-	}
-	request.SetOptions(options)
-	getTransactionData, _, err := s.Client.PlaidApi.TransactionsGet(r.Context()).TransactionsGetRequest(*request).Execute()
-	if err != nil {
-		return nil, utils.NewHTTPError(http.StatusInternalServerError, fmt.Sprintf("Error requesting transctions from plaidCtl: %v", err))
-	}
-	transactions := getTransactionData.Transactions
-	for _, action := range transactions {
-		log.Printf("category: %v, name: %v, date: %v, amount: %v", action.Category, action.Name, action.Date, action.Amount)
+// RetrieveTransactions pages through /transactions/sync from cursor until has_more is false and returns
+// every added, modified and removed transaction along with the cursor to resume from next time. If the item
+// changes mid-pagination the whole loop restarts from cursor, at most maxSyncRestarts times with a growing
+// backoff, after which Plaid's error is returned. Errors are returned as the Plaid client reports them so
+// callers can inspect the Plaid error code.
+func (s *Service) RetrieveTransactions(ctx context.Context, accessToken, cursor string) (*plaid.TransactionsSyncResponse, error) {
+	result := &plaid.TransactionsSyncResponse{}
+	nextCursor := cursor
+	restarts := 0
+	for {
+		request := plaid.NewTransactionsSyncRequest(accessToken)
+		request.SetCount(syncPageSize)
+		if nextCursor != "" {
+			request.SetCursor(nextCursor)
+		}
+		page, _, err := s.Client.PlaidApi.TransactionsSync(ctx).TransactionsSyncRequest(*request).Execute()
+		if err != nil {
+			plaidErr, convErr := plaid.ToPlaidError(err)
+			if convErr != nil || plaidErr.ErrorCode != "TRANSACTIONS_SYNC_MUTATION_DURING_PAGINATION" || restarts == maxSyncRestarts {
+				return nil, err
+			}
+			// Plaid requires restarting the whole pagination loop from the original cursor.
+			backoff := syncRestartBackoff << restarts
+			restarts++
+			log.Printf("transactions changed during sync pagination, restarting in %v (%d of %d)", backoff, restarts, maxSyncRestarts)
+			select {
+			case <-ctx.Done():
+				return nil, ctx.Err()
+			case <-time.After(backoff):
+			}
+			result = &plaid.TransactionsSyncResponse{}
+			nextCursor = cursor
+			continue
+		}
+		result.Added = append(result.Added, page.Added...)
+		result.Modified = append(result.Modified, page.Modified...)
+		result.Removed = append(result.Removed, page.Removed...)
+		nextCursor = page.GetNextCursor()
+		if !page.GetHasMore() {
+			break
+		}
 	}
-	return transactions, nil
+	result.NextCursor = nextCursor
+	log.Printf("sync retrieved %d added, %d modified, %d removed transactions", len(result.Added), len(result.Modified), len(result.Removed))
+	return result, nil
 }
 
-func (s *Service) LinkBank(r *http.Request) (string, error) {
-	client := s.Client
+// newLinkTokenRequest starts a Link token request with the settings shared by every Link flow.
+func (s *Service) newLinkTokenRequest() *plaid.LinkTokenCreateRequest {
 	// Specify the user
 	user := plaid.LinkTokenCreateRequestUser{
-		ClientUserId: os.Getenv("PLAID_CLIENT_ID"),
+		ClientUserId: s.config.ClientID,
 	}
 
+	request := plaid.NewLinkTokenCreateRequest(s.config.ClientName, s.config.Language, s.config.CountryCodes, user)
+	if s.config.WebhookURL != "" {
+		request.SetWebhook(s.config.WebhookURL)
+	}
+	if s.config.RedirectURI != "" {
+		request.SetRedirectUri(s.config.RedirectURI)
+	}
+	return request
+}
+
+func (s *Service) LinkBank(r *http.Request) (string, error) {
+	client := s.Client
+
 	// Specify the configuration for the Link token
-	request := plaid.NewLinkTokenCreateRequest("XAT", "en", []plaid.CountryCode{plaid.COUNTRYCODE_US}, user)
-	request.SetProducts([]plaid.Products{plaid.PRODUCTS_AUTH, plaid.PRODUCTS_TRANSACTIONS})
-	//request.SetWebhook("https://webhook-uri.com")
-	request.SetAccountFilters(plaid.LinkTokenAccountFilters{
-		Depository: &plaid.DepositoryFilter{
-			AccountSubtypes: []plaid.AccountSubtype{
-				plaid.ACCOUNTSUBTYPE_CHECKING,
-				plaid.ACCOUNTSUBTYPE_SAVINGS,
-			},
-		},
-	})
-	//request.SetRedirectUri(os.Getenv("LOCAL_URL") + "/assets/oauth-after.html")
-
-	request.SetRedirectUri(os.Getenv("APP_URL") + `/oauth_after`)
+	request := s.newLinkTokenRequest()
+	request.SetProducts(s.config.Products)
+	if s.config.AccountFilters != nil {
+		request.SetAccountFilters(*s.config.AccountFilters)
+	}
 
 	// Create the Link token
 	resp, _, err := client.PlaidApi.LinkTokenCreate(r.Context()).LinkTokenCreateRequest(*request).Execute()
@@ -77,38 +150,173 @@ func (s *Service) LinkBank(r *http.Request) (string, error) {
 		return "", utils.NewHTTPError(http.StatusBadRequest, fmt.Sprintf("error generating plaidCtl client: %v & %v \n ######END LOG", plaidErr.ErrorMessage, err))
 	}
 
-	linkToken := resp.GetLinkToken()
-	log.Printf("link token: %v", linkToken)
-	return linkToken, nil
+	return resp.GetLinkToken(), nil
+}
+
+// UpdateLinkToken creates a Link token in update mode for an existing item, so the user can sign in to their
+// bank again or renew consent without the item, its accounts or its sync cursor changing. Items whose access
+// was revoked can't be repaired this way and have to be linked afresh.
+func (s *Service) UpdateLinkToken(ctx context.Context, itemID string) (string, *types.HTTPError) {
+	item, httpErr := s.db.FetchPlaidItem(itemID)
+	if httpErr != nil {
+		return "", httpErr
+	}
+	if item.Status == types.ItemStatusRevoked {
+		return "", utils.NewHTTPError(http.StatusConflict, fmt.Sprintf("access to item %v was revoked; link the bank again instead", itemID))
+	}
+	accessToken, httpErr := s.accessToken(*item)
+	if httpErr != nil {
+		return "", httpErr
+	}
+
+	request := s.newLinkTokenRequest()
+	request.SetAccessToken(accessToken)
+	resp, _, err := s.Client.PlaidApi.LinkTokenCreate(ctx).LinkTokenCreateRequest(*request).Execute()
+	if err != nil {
+		return "", utils.NewHTTPError(http.StatusBadGateway, fmt.Sprintf("error creating update mode link token for item %v: %v", itemID, err))
+	}
+	return resp.GetLinkToken(), nil
+}
+
+// RepairItem marks an item healthy again after the user has been through Link update mode, and queues a sync
+// to catch up on what was missed while it was broken.
+func (s *Service) RepairItem(itemID string) *types.HTTPError {
+	if err := s.db.UpdatePlaidItemStatus(itemID, types.ItemStatusActive, ""); err != nil {
+		return err
+	}
+	s.EnqueueSync(itemID)
+	return nil
+}
+
+// RemoveItem unlinks an item. Plaid is asked to forget it with /item/remove, which invalidates its access token
+// and stops billing for it, and only then is it removed here: its accounts closed and its expenses kept,
+// anonymized or deleted as expenseAction says. Items Plaid has already lost access to are removed all the same.
+func (s *Service) RemoveItem(ctx context.Context, itemID, expenseAction string) (*types.ItemRemoval, *types.HTTPError) {
+	// Hold off syncs and balance refreshes so none reopens the item's accounts mid-removal.
+	s.syncMu.Lock()
+	defer s.syncMu.Unlock()
+
+	item, httpErr := s.db.FetchPlaidItem(itemID)
+	if httpErr != nil {
+		return nil, httpErr
+	}
+	accessToken, httpErr := s.accessToken(*item)
+	if httpErr != nil {
+		return nil, httpErr
+	}
+	request := plaid.NewItemRemoveRequest(accessToken)
+	if _, _, err := s.Client.PlaidApi.ItemRemove(ctx).ItemRemoveRequest(*request).Execute(); err != nil {
+		if status, _ := itemHealth(err); status != types.ItemStatusRevoked {
+			return nil, utils.NewHTTPError(http.StatusBadGateway, fmt.Sprintf("error removing item %v from plaid: %v", itemID, err))
+		}
+		log.Printf("plaid no longer has access to item %v, removing it anyway: %v", itemID, err)
+	}
+
+	removal, httpErr := s.db.RemovePlaidItem(types.ItemRemoval{ItemID: itemID, ExpenseAction: expenseAction})
+	if httpErr != nil {
+		return nil, httpErr
+	}
+	if removal.ExpensesAffected > 0 {
+		s.model.Invalidate()
+	}
+	log.Printf("removed plaid item %v (%v): closed %d accounts, %v %d expenses", removal.ItemID, removal.Institution,
+		removal.AccountsClosed, removal.ExpenseAction, removal.ExpensesAffected)
+	if removal.ExpensesLeftBehind > 0 {
+		log.Printf("left %d plaid expenses that can't be traced to an item as they are", removal.ExpensesLeftBehind)
+	}
+	return removal, nil
 }
 
-func (s *Service) CreateItem(publicToken string, r *http.Request) (string, error) {
+// CreateItem exchanges the public token from Link for an access token and stores it, encrypted, as a new item
+// along with its accounts. The access token itself never leaves this package.
+func (s *Service) CreateItem(publicToken string, r *http.Request) (*types.PlaidItem, error) {
 
 	exchangePublicTokenReq := plaid.NewItemPublicTokenExchangeRequest(publicToken)
 	exchangedToken, _, err := s.Client.PlaidApi.ItemPublicTokenExchange(r.Context()).ItemPublicTokenExchangeRequest(*exchangePublicTokenReq).Execute()
 	if err != nil {
-		return "", utils.NewHTTPError(http.StatusInternalServerError, fmt.Sprintf("Error exchanging public token for access token: %v", err))
+		return nil, utils.NewHTTPError(http.StatusInternalServerError, fmt.Sprintf("Error exchanging public token for access token: %v", err))
 	}
 
 	accessToken := exchangedToken.GetAccessToken()
-	err = os.Setenv("PLAID_ACCESS_TOKEN", accessToken)
+	encryptedToken, encryptedKey, err := s.sealer.Seal(accessToken)
 	if err != nil {
-		log.Printf("Unable to update access token in env file: %v", err)
+		return nil, utils.NewHTTPError(http.StatusInternalServerError, fmt.Sprintf("Error encrypting access token: %v", err))
 	}
-	return accessToken, nil
+	item := types.PlaidItem{
+		ItemID:               exchangedToken.GetItemId(),
+		Institution:          s.institutionName(r.Context(), accessToken),
+		Status:               types.ItemStatusActive,
+		CreatedAt:            time.Now(),
+		EncryptedAccessToken: encryptedToken,
+		EncryptedKey:         encryptedKey,
+	}
+	if err := s.db.InsertPlaidItem(item); err != nil {
+		return nil, err
+	}
+	// The accounts are refreshed on every sync, so a failure here only delays them until then.
+	if accounts, err := s.RetrieveAccounts(r.Context(), item.ItemID, accessToken); err != nil {
+		log.Printf("unable to fetch accounts for item %v: %v", item.ItemID, err)
+	} else if err := s.db.UpsertAccounts(accounts); err != nil {
+		return nil, err
+	}
+	return &item, nil
 }
 
-func (s *Service) FormatTransactionsToExpenseType(transactions []plaid.Transaction) ([]types.Expense, *types.HTTPError) {
+// institutionName looks up the display name of the institution behind an item, falling back to its Plaid
+// institution ID (or "" if even that is unavailable) so a lookup failure never blocks linking.
+func (s *Service) institutionName(ctx context.Context, accessToken string) string {
+	itemResp, _, err := s.Client.PlaidApi.ItemGet(ctx).ItemGetRequest(*plaid.NewItemGetRequest(accessToken)).Execute()
+	if err != nil {
+		log.Printf("unable to look up item institution: %v", err)
+		return ""
+	}
+	institutionID := itemResp.Item.GetInstitutionId()
+	if institutionID == "" {
+		return ""
+	}
+	request := plaid.NewInstitutionsGetByIdRequest(institutionID, s.config.CountryCodes)
+	instResp, _, err := s.Client.PlaidApi.InstitutionsGetById(ctx).InstitutionsGetByIdRequest(*request).Execute()
+	if err != nil {
+		log.Printf("unable to look up institution %v: %v", institutionID, err)
+		return institutionID
+	}
+	return instResp.Institution.GetName()
+}
+
+// accessToken decrypts the stored access token for item.
+func (s *Service) accessToken(item types.PlaidItem) (string, *types.HTTPError) {
+	token, err := s.sealer.Open(item.EncryptedAccessToken, item.EncryptedKey)
+	if err != nil {
+		return "", utils.NewHTTPError(http.StatusInternalServerError, fmt.Sprintf("Error decrypting access token for item %v: %v", item.ItemID, err))
+	}
+	return token, nil
+}
+
+// FormatTransactionsToExpenseType converts Plaid transactions to expenses, filed by categorizer.
+func (s *Service) FormatTransactionsToExpenseType(transactions []plaid.Transaction, categorizer *Categorizer) ([]types.Expense, *types.HTTPError) {
 	var expenses []types.Expense
 	for _, action := range transactions {
-		log.Printf("category: %v, name: %v, date: %v, big amount: %v", action.Category, action.Name, action.Date, action.Amount)
 		date, err := time.Parse("2006-01-02", action.Date)
-		// need to find way to intelligently sort expenses into appropriate categories
 		if err != nil {
 			return nil, utils.NewHTTPError(http.StatusInternalServerError, fmt.Sprintf("Error parsing date: %v", err))
 		}
-		category := cPlaidCategoryToExpense(action.Category)
-		expenses = append(expenses, types.Expense{Description: action.Name, Date: date, Category: category, Amount: float64(action.Amount)})
+		primary, detailed, confidence := personalFinanceCategory(action)
+		expense := types.Expense{
+			Source:            types.ExpenseSourcePlaid,
+			ExternalID:        action.TransactionId,
+			PendingExternalID: action.GetPendingTransactionId(),
+			Description:       action.Name,
+			Merchant:          action.GetMerchantName(),
+			AccountID:         action.AccountId,
+			PlaidCategory:     strings.Join(action.Category, " > "),
+			PFCPrimary:        primary,
+			PFCDetailed:       detailed,
+			PFCConfidence:     confidence,
+			Date:              date,
+			Amount:            float64(action.Amount),
+		}
+		categorizer.Categorize(&expense)
+		expenses = append(expenses, expense)
 	}
 	return expenses, nil
 }
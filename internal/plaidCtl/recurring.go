@@ -0,0 +1,146 @@
+package plaidCtl
+
+import (
+	"context"
+	"log"
+	"math"
+	"strings"
+	"time"
+
+	"github.com/Seymour-creates/budget-server/internal/types"
+	"github.com/Seymour-creates/budget-server/internal/utils"
+	"github.com/plaid/plaid-go/plaid"
+)
+
+// recurringHistoryMonths is how far back expenses are read to describe and detect recurring streams; enough
+// for an annual charge to have come round twice.
+const recurringHistoryMonths = 25
+
+// RefreshRecurring finds the recurring streams across every account and stores them in place of the last
+// ones found. Each item's streams come from Plaid's /transactions/recurring/get where it is available; accounts
+// of items it fails for, and accounts that belong to no item, have their streams detected locally from their
+// expenses. Only database failures are returned.
+func (s *Service) RefreshRecurring(ctx context.Context) *types.HTTPError {
+	items, err := s.db.FetchPlaidItems()
+	if err != nil {
+		return err
+	}
+	accounts, err := s.db.ListAccounts(true)
+	if err != nil {
+		return err
+	}
+	now := time.Now()
+	expenses, err := s.db.ListExpenses(types.ExpenseFilter{From: now.AddDate(0, -recurringHistoryMonths, 0)})
+	if err != nil {
+		return err
+	}
+
+	byItem := map[string][]string{}
+	for _, account := range accounts {
+		if account.ItemID != "" && account.ClosedAt == nil &&
+			(account.Type == types.AccountTypeDepository || account.Type == types.AccountTypeCredit) {
+			byItem[account.ItemID] = append(byItem[account.ItemID], account.AccountID)
+		}
+	}
+	byExternalID := make(map[string]types.Expense, len(expenses))
+	for _, expense := range expenses {
+		if expense.Source == types.ExpenseSourcePlaid && expense.ExternalID != "" {
+			byExternalID[expense.ExternalID] = expense
+		}
+	}
+
+	streams := []types.RecurringStream{}
+	coveredByPlaid := map[string]bool{}
+	for _, item := range items {
+		accountIDs := byItem[item.ItemID]
+		if item.NeedsRelink() || len(accountIDs) == 0 {
+			continue
+		}
+		accessToken, err := s.accessToken(item)
+		if err != nil {
+			return err
+		}
+		request := plaid.NewTransactionsRecurringGetRequest(accessToken, accountIDs)
+		resp, _, plaidErr := s.Client.PlaidApi.TransactionsRecurringGet(ctx).TransactionsRecurringGetRequest(*request).Execute()
+		if plaidErr != nil {
+			log.Printf("recurring transactions unavailable for item %v, detecting them locally: %v", item.ItemID, plaidErr)
+			continue
+		}
+		for _, accountID := range accountIDs {
+			coveredByPlaid[accountID] = true
+		}
+		for _, stream := range resp.GetOutflowStreams() {
+			streams = append(streams, formatStream(stream, types.StreamOutflow, byExternalID))
+		}
+		for _, stream := range resp.GetInflowStreams() {
+			streams = append(streams, formatStream(stream, types.StreamInflow, byExternalID))
+		}
+	}
+
+	var uncovered []types.Expense
+	for _, expense := range expenses {
+		if !coveredByPlaid[expense.AccountID] {
+			uncovered = append(uncovered, expense)
+		}
+	}
+	streams = append(streams, DetectRecurring(uncovered, now)...)
+	return s.db.ReplaceRecurringStreams(streams)
+}
+
+// formatStream converts a Plaid stream, filling in what Plaid doesn't report from the expense imported for its
+// latest transaction.
+func formatStream(stream plaid.TransactionStream, direction string, byExternalID map[string]types.Expense) types.RecurringStream {
+	formatted := types.RecurringStream{
+		StreamID:         stream.StreamId,
+		Source:           types.StreamSourcePlaid,
+		AccountID:        stream.AccountId,
+		Merchant:         stream.Description,
+		Description:      stream.Description,
+		Direction:        direction,
+		Frequency:        strings.ToLower(string(stream.Frequency)),
+		TransactionCount: len(stream.TransactionIds),
+		Active:           stream.IsActive,
+	}
+	if stream.AverageAmount.Amount != nil {
+		formatted.AverageAmount = math.Abs(cents(*stream.AverageAmount.Amount))
+	}
+	formatted.LastAmount = formatted.AverageAmount
+	if first := date(&stream.FirstDate); first != nil {
+		formatted.FirstDate = *first
+	}
+	if last := date(&stream.LastDate); last != nil {
+		formatted.LastDate = *last
+	}
+	// Plaid doesn't promise any order for a stream's transactions, so the latest is found by date.
+	var latest *types.Expense
+	for _, transactionID := range stream.TransactionIds {
+		if expense, ok := byExternalID[transactionID]; ok && (latest == nil || !expense.Date.Before(latest.Date)) {
+			latest = &expense
+		}
+	}
+	if latest != nil {
+		formatted.LastAmount = magnitude(latest.Amount)
+		formatted.Description = latest.Description
+		formatted.Category = latest.Category
+		if latest.Merchant != "" {
+			formatted.Merchant = latest.Merchant
+		}
+	}
+	formatted.NextDate = nextOccurrence(formatted.Frequency, formatted.LastDate)
+	return formatted
+}
+
+// nextOccurrence is when a stream's next transaction is expected after one on last, or nil if its frequency
+// is unknown.
+func nextOccurrence(frequency string, last time.Time) *time.Time {
+	next, ok := utils.Occurrence(frequency, last, 1)
+	if !ok {
+		return nil
+	}
+	return &next
+}
+
+// magnitude is the size of an expense amount in either direction, to the cent.
+func magnitude(amount float64) float64 {
+	return math.Round(math.Abs(amount)*100) / 100
+}
@@ -0,0 +1,42 @@
+package plaidCtl
+
+import (
+	"testing"
+	"time"
+
+	"github.com/Seymour-creates/budget-server/internal/types"
+	"github.com/plaid/plaid-go/plaid"
+)
+
+func TestFormatStreamUsesLatestTransaction(t *testing.T) {
+	amount := float32(11.5)
+	stream := plaid.TransactionStream{
+		StreamId:    "stream-1",
+		AccountId:   "acc-1",
+		Description: "NETFLIX",
+		FirstDate:   "2024-03-05",
+		LastDate:    "2024-05-05",
+		Frequency:   plaid.RECURRINGTRANSACTIONFREQUENCY_MONTHLY,
+		// Plaid doesn't list a stream's transactions in date order.
+		TransactionIds: []string{"txn-may", "txn-march", "txn-april"},
+		AverageAmount:  plaid.TransactionStreamAmount{Amount: &amount},
+		IsActive:       true,
+	}
+	byExternalID := map[string]types.Expense{
+		"txn-march": {ExternalID: "txn-march", Date: time.Date(2024, 3, 5, 0, 0, 0, 0, time.UTC), Description: "NETFLIX.COM",
+			Merchant: "Netflix", Amount: 10, Category: "subscriptions"},
+		"txn-april": {ExternalID: "txn-april", Date: time.Date(2024, 4, 5, 0, 0, 0, 0, time.UTC), Description: "NETFLIX.COM",
+			Merchant: "Netflix", Amount: 10, Category: "subscriptions"},
+		"txn-may": {ExternalID: "txn-may", Date: time.Date(2024, 5, 5, 0, 0, 0, 0, time.UTC), Description: "NETFLIX.COM 0505",
+			Merchant: "Netflix", Amount: 14.5, Category: "entertainment"},
+	}
+
+	formatted := formatStream(stream, types.StreamOutflow, byExternalID)
+	if formatted.LastAmount != 14.5 || formatted.Description != "NETFLIX.COM 0505" || formatted.Category != "entertainment" {
+		t.Errorf("formatted stream = %+v, want the details of the May charge", formatted)
+	}
+	if formatted.Frequency != types.FrequencyMonthly || formatted.AverageAmount != 11.5 ||
+		formatted.NextDate == nil || !formatted.NextDate.Equal(time.Date(2024, 6, 5, 0, 0, 0, 0, time.UTC)) {
+		t.Errorf("formatted stream = %+v, want a monthly stream averaging 11.50 due next on June 5th", formatted)
+	}
+}
@@ -0,0 +1,78 @@
+package plaidCtl_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/Seymour-creates/budget-server/internal/plaidfake"
+	"github.com/Seymour-creates/budget-server/internal/testutil"
+	"github.com/Seymour-creates/budget-server/internal/types"
+)
+
+func TestLinkAndResyncTransactions(t *testing.T) {
+	p := testutil.NewPlaid(t, "")
+	item := p.LinkItem(t)
+	if item.Institution != "Fake Bank" {
+		t.Errorf("linked item institution = %q, want Fake Bank", item.Institution)
+	}
+	accounts, httpErr := p.DB.ListAccounts(true)
+	if httpErr != nil {
+		t.Fatal(httpErr.Message)
+	}
+	if len(accounts) != 3 {
+		t.Errorf("stored %d accounts on linking, want 3", len(accounts))
+	}
+
+	results, httpErr := p.Service.SyncTransactions(context.Background())
+	if httpErr != nil {
+		t.Fatalf("syncing: %v", httpErr.Message)
+	}
+	if len(results) != 1 || results[0].Added != len(plaidfake.DefaultFixture().Transactions) {
+		t.Fatalf("sync results = %+v, want every fixture transaction added", results)
+	}
+	pizza, ok := p.Expenses(t)["fake-tx-3"]
+	if !ok || pizza.Amount != 23.5 || pizza.Merchant != "Corner Pizza" || pizza.AccountID != "fake-credit" ||
+		pizza.PFCDetailed != "FOOD_AND_DRINK_RESTAURANT" || pizza.CategorySource != types.CategorySourcePlaid {
+		t.Errorf("synced pizza expense = %+v", pizza)
+	}
+
+	if err := p.Fake.AddTransactions(item.ItemID, plaidfake.Transaction{TransactionID: "fake-tx-new", AccountID: "fake-checking",
+		Name: "HARDWARE BARN", Amount: 42}); err != nil {
+		t.Fatal(err)
+	}
+	if err := p.Fake.RemoveTransactions(item.ItemID, "fake-tx-4"); err != nil {
+		t.Fatal(err)
+	}
+	if _, httpErr := p.Service.SyncItem(context.Background(), item.ItemID); httpErr != nil {
+		t.Fatalf("resyncing: %v", httpErr.Message)
+	}
+	expenses := p.Expenses(t)
+	if _, ok := expenses["fake-tx-new"]; !ok {
+		t.Error("transaction added since the last sync was not stored")
+	}
+	if _, ok := expenses["fake-tx-4"]; ok {
+		t.Error("transaction removed since the last sync is still stored")
+	}
+	if len(expenses) != len(plaidfake.DefaultFixture().Transactions) {
+		t.Errorf("stored %d expenses after resync, want %d", len(expenses), len(plaidfake.DefaultFixture().Transactions))
+	}
+}
+
+func TestItemErrorWebhook(t *testing.T) {
+	p := testutil.NewPlaid(t, "")
+	item := p.LinkItem(t)
+
+	if err := p.Fake.SetItemError(item.ItemID, "ITEM_LOGIN_REQUIRED"); err != nil {
+		t.Fatal(err)
+	}
+	if err := p.Fake.FireWebhook(item.ItemID, "ITEM", "ERROR"); err != nil {
+		t.Fatalf("delivering item error webhook: %v", err)
+	}
+	stored, httpErr := p.DB.FetchPlaidItem(item.ItemID)
+	if httpErr != nil {
+		t.Fatal(httpErr.Message)
+	}
+	if stored.Status != types.ItemStatusLoginRequired {
+		t.Errorf("item status after error webhook = %v, want %v", stored.Status, types.ItemStatusLoginRequired)
+	}
+}
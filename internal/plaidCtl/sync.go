@@ -0,0 +1,185 @@
+package plaidCtl
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"github.com/Seymour-creates/budget-server/internal/types"
+	"github.com/plaid/plaid-go/plaid"
+)
+
+// SyncTransactions brings the expenses table up to date with Plaid for every linked item. Each item resumes
+// from its stored cursor, so running it repeatedly only applies what changed since the last successful sync.
+// Items that need the user to relink are skipped, and an item whose sync fails doesn't stop the others; the
+// result for each item says which happened. Recurring streams are refreshed and alert thresholds checked if
+// anything changed. Only database failures are returned as errors.
+func (s *Service) SyncTransactions(ctx context.Context) ([]types.ItemSyncResult, *types.HTTPError) {
+	s.syncMu.Lock()
+	defer s.syncMu.Unlock()
+
+	items, err := s.db.FetchPlaidItems()
+	if err != nil {
+		return nil, err
+	}
+	categorizer, err := LoadCategorizer(s.db, s.model)
+	if err != nil {
+		return nil, err
+	}
+	results := make([]types.ItemSyncResult, 0, len(items))
+	for _, item := range items {
+		result, err := s.syncItem(ctx, item, categorizer)
+		if err != nil {
+			return nil, err
+		}
+		results = append(results, result)
+	}
+	s.afterSync(ctx, results...)
+	return results, nil
+}
+
+// SyncItem brings the expenses table up to date with Plaid for a single item.
+func (s *Service) SyncItem(ctx context.Context, itemID string) (*types.ItemSyncResult, *types.HTTPError) {
+	s.syncMu.Lock()
+	defer s.syncMu.Unlock()
+
+	item, err := s.db.FetchPlaidItem(itemID)
+	if err != nil {
+		return nil, err
+	}
+	categorizer, err := LoadCategorizer(s.db, s.model)
+	if err != nil {
+		return nil, err
+	}
+	result, err := s.syncItem(ctx, *item, categorizer)
+	if err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
+
+func (s *Service) syncItem(ctx context.Context, item types.PlaidItem, categorizer *Categorizer) (types.ItemSyncResult, *types.HTTPError) {
+	result := types.ItemSyncResult{
+		ItemID:      item.ItemID,
+		Institution: item.Institution,
+		Status:      item.Status,
+		ErrorCode:   item.ErrorCode,
+	}
+	if item.NeedsRelink() {
+		result.Skipped = true
+		return result, nil
+	}
+
+	accessToken, err := s.accessToken(item)
+	if err != nil {
+		return result, err
+	}
+
+	cursor, err := s.db.FetchSyncCursor(item.ItemID)
+	if err != nil {
+		return result, err
+	}
+
+	accounts, plaidErr := s.RetrieveAccounts(ctx, item.ItemID, accessToken)
+	if plaidErr != nil {
+		return s.syncFailed(result, plaidErr)
+	}
+	if err := s.db.UpsertAccounts(accounts); err != nil {
+		return result, err
+	}
+
+	changes, plaidErr := s.RetrieveTransactions(ctx, accessToken, cursor)
+	if plaidErr != nil {
+		return s.syncFailed(result, plaidErr)
+	}
+
+	added, err := s.FormatTransactionsToExpenseType(changes.Added, categorizer)
+	if err != nil {
+		return result, err
+	}
+	modified, err := s.FormatTransactionsToExpenseType(changes.Modified, categorizer)
+	if err != nil {
+		return result, err
+	}
+	removed := make([]string, 0, len(changes.Removed))
+	for _, transaction := range changes.Removed {
+		removed = append(removed, transaction.GetTransactionId())
+	}
+
+	err = s.db.ApplyTransactionSync(item.ItemID, types.TransactionSync{
+		Added:    added,
+		Modified: modified,
+		Removed:  removed,
+		Cursor:   changes.NextCursor,
+	})
+	if err != nil {
+		return result, err
+	}
+	// Posted transactions replacing pending ones were learned when they were pending. Modified ones keep the
+	// category they were filed with, and removed ones are left for the model's periodic retrain.
+	for _, expense := range added {
+		if expense.PendingExternalID == "" {
+			s.model.Learn(expense)
+		}
+	}
+	if s.config.Uses(plaid.PRODUCTS_INVESTMENTS) && hasInvestmentAccounts(accounts) {
+		if err := s.syncInvestments(ctx, item, accessToken, &result); err != nil {
+			return result, err
+		}
+	}
+	if err := s.db.RecordPlaidItemSync(item.ItemID, time.Now()); err != nil {
+		return result, err
+	}
+	if item.Status != types.ItemStatusPendingExpiration {
+		result.Status, result.ErrorCode = types.ItemStatusActive, ""
+	}
+	result.Added, result.Modified, result.Removed = len(added), len(modified), len(removed)
+	return result, nil
+}
+
+// syncFailed records the item health a failed Plaid call implies and reports it in result.
+func (s *Service) syncFailed(result types.ItemSyncResult, plaidErr error) (types.ItemSyncResult, *types.HTTPError) {
+	result.Status, result.ErrorCode = itemHealth(plaidErr)
+	log.Printf("error syncing item %v: %v", result.ItemID, plaidErr)
+	return result, s.db.UpdatePlaidItemStatus(result.ItemID, result.Status, result.ErrorCode)
+}
+
+// itemHealth classifies a failed Plaid call into the item status it implies and Plaid's error code.
+func itemHealth(err error) (status, errorCode string) {
+	plaidErr, convErr := plaid.ToPlaidError(err)
+	if convErr != nil {
+		return types.ItemStatusError, ""
+	}
+	switch plaidErr.ErrorCode {
+	case "ITEM_LOGIN_REQUIRED", "PENDING_EXPIRATION", "INVALID_CREDENTIALS", "INVALID_MFA", "ITEM_LOCKED":
+		return types.ItemStatusLoginRequired, plaidErr.ErrorCode
+	case "ACCESS_NOT_GRANTED", "ITEM_NOT_FOUND", "USER_PERMISSION_REVOKED":
+		return types.ItemStatusRevoked, plaidErr.ErrorCode
+	}
+	return types.ItemStatusError, plaidErr.ErrorCode
+}
+
+// afterSync refreshes recurring streams and checks alert thresholds once a sync has imported something, logging
+// failures rather than failing the sync.
+func (s *Service) afterSync(ctx context.Context, results ...types.ItemSyncResult) {
+	for _, result := range results {
+		if result.Added+result.Modified+result.Removed > 0 {
+			if err := s.RefreshRecurring(ctx); err != nil {
+				log.Printf("error refreshing recurring streams: %v", err.Message)
+			}
+			s.evaluateAlerts()
+			return
+		}
+	}
+}
+
+// evaluateAlerts checks alert thresholds against what was just imported, logging failures. Alerts raised are
+// sent by the evaluator's delivery worker.
+func (s *Service) evaluateAlerts() {
+	if s.alerts == nil {
+		return
+	}
+	if _, err := s.alerts.Evaluate(time.Now()); err != nil {
+		log.Printf("error evaluating alerts: %v", err.Message)
+	}
+}
@@ -0,0 +1,307 @@
+package plaidCtl
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"math/big"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Seymour-creates/budget-server/internal/types"
+	"github.com/Seymour-creates/budget-server/internal/utils"
+	"github.com/plaid/plaid-go/plaid"
+)
+
+// maxWebhookAge is how old a webhook's signature may be before it is rejected as a possible replay.
+const maxWebhookAge = 5 * time.Minute
+
+// verificationKeyTTL is how long a fetched verification key is trusted before asking Plaid again whether it
+// has expired.
+const verificationKeyTTL = 24 * time.Hour
+
+// maxVerificationKeys bounds how many verification keys, and how many key IDs Plaid didn't know, are cached.
+const maxVerificationKeys = 16
+
+// unknownKeyTTL is how long a key ID Plaid couldn't return is rejected without asking again, and
+// unknownKeyInterval the least time between asking Plaid about key IDs that aren't cached, so that tokens with
+// made-up key IDs can't be used to hammer the Plaid API.
+const (
+	unknownKeyTTL      = time.Minute
+	unknownKeyInterval = time.Second
+)
+
+// Webhook is the part of a Plaid webhook body this server acts on.
+type Webhook struct {
+	WebhookType string `json:"webhook_type"`
+	WebhookCode string `json:"webhook_code"`
+	ItemID      string `json:"item_id"`
+	Error       *struct {
+		ErrorCode string `json:"error_code"`
+	} `json:"error"`
+}
+
+// HandleWebhook verifies a webhook delivered by Plaid and acts on it: new transaction or investment data queues
+// a sync of the item, and item problems are recorded as a change to its status.
+func (s *Service) HandleWebhook(ctx context.Context, signature string, body []byte) *types.HTTPError {
+	if err := s.verifier.verify(ctx, signature, body); err != nil {
+		return utils.NewHTTPError(http.StatusUnauthorized, fmt.Sprintf("invalid webhook signature: %v", err))
+	}
+
+	var webhook Webhook
+	if err := json.Unmarshal(body, &webhook); err != nil {
+		return utils.NewHTTPError(http.StatusBadRequest, fmt.Sprintf("error decoding webhook: %v", err))
+	}
+	log.Printf("plaid webhook %v %v for item %v", webhook.WebhookType, webhook.WebhookCode, webhook.ItemID)
+
+	switch {
+	case webhook.WebhookType == "TRANSACTIONS" && webhook.WebhookCode == "SYNC_UPDATES_AVAILABLE",
+		webhook.WebhookType == "HOLDINGS" && webhook.WebhookCode == "DEFAULT_UPDATE",
+		webhook.WebhookType == "INVESTMENTS_TRANSACTIONS" && webhook.WebhookCode == "DEFAULT_UPDATE":
+		s.EnqueueSync(webhook.ItemID)
+	case webhook.WebhookType == "ITEM" && webhook.WebhookCode == "ERROR" &&
+		webhook.Error != nil && webhook.Error.ErrorCode == "ITEM_LOGIN_REQUIRED":
+		return s.db.UpdatePlaidItemStatus(webhook.ItemID, types.ItemStatusLoginRequired, webhook.Error.ErrorCode)
+	case webhook.WebhookType == "ITEM" && webhook.WebhookCode == "PENDING_EXPIRATION":
+		return s.db.UpdatePlaidItemStatus(webhook.ItemID, types.ItemStatusPendingExpiration, "")
+	case webhook.WebhookType == "ITEM" && webhook.WebhookCode == "USER_PERMISSION_REVOKED":
+		return s.db.UpdatePlaidItemStatus(webhook.ItemID, types.ItemStatusRevoked, "")
+	case webhook.WebhookType == "ITEM" && webhook.WebhookCode == "LOGIN_REPAIRED":
+		if err := s.db.UpdatePlaidItemStatus(webhook.ItemID, types.ItemStatusActive, ""); err != nil {
+			return err
+		}
+		s.EnqueueSync(webhook.ItemID)
+	}
+	return nil
+}
+
+// EnqueueSync asks the sync worker to bring one item up to date. Requests for an item that is already waiting
+// are merged, since a single sync picks up everything available.
+func (s *Service) EnqueueSync(itemID string) {
+	s.queueMu.Lock()
+	defer s.queueMu.Unlock()
+	if s.queued[itemID] {
+		return
+	}
+	select {
+	case s.syncQueue <- itemID:
+		s.queued[itemID] = true
+	default:
+		log.Printf("sync queue full, dropping sync for item %v until its next webhook", itemID)
+	}
+}
+
+// RunSyncWorker syncs queued items one at a time until ctx is cancelled, refreshing recurring streams after
+// each sync that changed something.
+func (s *Service) RunSyncWorker(ctx context.Context) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case itemID := <-s.syncQueue:
+			s.queueMu.Lock()
+			delete(s.queued, itemID)
+			s.queueMu.Unlock()
+			result, err := s.SyncItem(ctx, itemID)
+			if err != nil {
+				log.Printf("error syncing item %v: %v", itemID, err.Message)
+			} else if result.ErrorCode != "" {
+				log.Printf("item %v did not sync: %v %v", itemID, result.Status, result.ErrorCode)
+			} else {
+				s.afterSync(ctx, *result)
+			}
+		}
+	}
+}
+
+// webhookVerifier checks the Plaid-Verification JWT on incoming webhooks. Plaid signs with ES256 keys that
+// it rotates; each key is fetched by ID and cached for verificationKeyTTL. Key IDs Plaid doesn't know are
+// remembered for unknownKeyTTL.
+type webhookVerifier struct {
+	fetchKey func(ctx context.Context, keyID string) (*plaid.JWKPublicKey, error)
+	now      func() time.Time
+
+	mu   sync.Mutex
+	keys map[string]cachedKey
+	// unknown holds when each key ID Plaid couldn't return was asked about, and lastUnknown when any key ID
+	// that wasn't cached last was.
+	unknown     map[string]time.Time
+	lastUnknown time.Time
+}
+
+type cachedKey struct {
+	jwk       *plaid.JWKPublicKey
+	fetchedAt time.Time
+}
+
+func newWebhookVerifier(client *plaid.APIClient) *webhookVerifier {
+	return &webhookVerifier{
+		fetchKey: func(ctx context.Context, keyID string) (*plaid.JWKPublicKey, error) {
+			request := plaid.NewWebhookVerificationKeyGetRequest(keyID)
+			resp, _, err := client.PlaidApi.WebhookVerificationKeyGet(ctx).WebhookVerificationKeyGetRequest(*request).Execute()
+			if err != nil {
+				return nil, err
+			}
+			return &resp.Key, nil
+		},
+		now:     time.Now,
+		keys:    map[string]cachedKey{},
+		unknown: map[string]time.Time{},
+	}
+}
+
+type jwtHeader struct {
+	Alg string `json:"alg"`
+	Kid string `json:"kid"`
+}
+
+type jwtClaims struct {
+	IssuedAt          int64  `json:"iat"`
+	RequestBodySHA256 string `json:"request_body_sha256"`
+}
+
+func (v *webhookVerifier) verify(ctx context.Context, token string, body []byte) error {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return errors.New("malformed token")
+	}
+
+	var header jwtHeader
+	if err := decodeSegment(parts[0], &header); err != nil {
+		return fmt.Errorf("decoding header: %w", err)
+	}
+	if header.Alg != "ES256" {
+		return fmt.Errorf("unexpected algorithm %q", header.Alg)
+	}
+	key, err := v.key(ctx, header.Kid)
+	if err != nil {
+		return err
+	}
+
+	signature, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil || len(signature) != 64 {
+		return errors.New("malformed signature")
+	}
+	digest := sha256.Sum256([]byte(parts[0] + "." + parts[1]))
+	r, sig := new(big.Int).SetBytes(signature[:32]), new(big.Int).SetBytes(signature[32:])
+	if !ecdsa.Verify(key, digest[:], r, sig) {
+		return errors.New("signature does not match")
+	}
+
+	var claims jwtClaims
+	if err := decodeSegment(parts[1], &claims); err != nil {
+		return fmt.Errorf("decoding claims: %w", err)
+	}
+	if age := v.now().Sub(time.Unix(claims.IssuedAt, 0)); age > maxWebhookAge || age < -maxWebhookAge {
+		return errors.New("token is too old")
+	}
+	bodyHash := sha256.Sum256(body)
+	if subtle.ConstantTimeCompare([]byte(hex.EncodeToString(bodyHash[:])), []byte(claims.RequestBodySHA256)) != 1 {
+		return errors.New("body does not match token")
+	}
+	return nil
+}
+
+// key returns the public key with keyID, fetching it from Plaid unless a live copy is cached. Key IDs that
+// aren't cached are only looked up once every unknownKeyInterval, and not again for unknownKeyTTL if Plaid
+// couldn't return them.
+func (v *webhookVerifier) key(ctx context.Context, keyID string) (*ecdsa.PublicKey, error) {
+	v.mu.Lock()
+	now := v.now()
+	cached, ok := v.keys[keyID]
+	if !ok {
+		if askedAt, unknown := v.unknown[keyID]; unknown && now.Sub(askedAt) < unknownKeyTTL {
+			v.mu.Unlock()
+			return nil, fmt.Errorf("unknown verification key %v", keyID)
+		}
+		if now.Sub(v.lastUnknown) < unknownKeyInterval {
+			v.mu.Unlock()
+			return nil, fmt.Errorf("too many new verification keys, not fetching %v", keyID)
+		}
+		v.lastUnknown = now
+	}
+	v.mu.Unlock()
+
+	if !ok || now.Sub(cached.fetchedAt) > verificationKeyTTL {
+		fetched, err := v.fetchKey(ctx, keyID)
+		v.mu.Lock()
+		if err != nil {
+			if !ok {
+				v.makeRoomForUnknown()
+				v.unknown[keyID] = now
+			}
+			v.mu.Unlock()
+			return nil, fmt.Errorf("fetching verification key %v: %w", keyID, err)
+		}
+		cached = cachedKey{jwk: fetched, fetchedAt: now}
+		if !ok {
+			v.makeRoomForKey()
+		}
+		v.keys[keyID] = cached
+		delete(v.unknown, keyID)
+		v.mu.Unlock()
+	}
+	jwk := cached.jwk
+	if expiredAt := jwk.ExpiredAt.Get(); expiredAt != nil && now.After(time.Unix(int64(*expiredAt), 0)) {
+		return nil, fmt.Errorf("verification key %v has expired", keyID)
+	}
+	if jwk.Kty != "EC" || jwk.Crv != "P-256" {
+		return nil, fmt.Errorf("unsupported verification key type %v/%v", jwk.Kty, jwk.Crv)
+	}
+
+	x, err := base64.RawURLEncoding.DecodeString(jwk.X)
+	if err != nil {
+		return nil, fmt.Errorf("decoding verification key: %w", err)
+	}
+	y, err := base64.RawURLEncoding.DecodeString(jwk.Y)
+	if err != nil {
+		return nil, fmt.Errorf("decoding verification key: %w", err)
+	}
+	return &ecdsa.PublicKey{Curve: elliptic.P256(), X: new(big.Int).SetBytes(x), Y: new(big.Int).SetBytes(y)}, nil
+}
+
+// makeRoomForKey drops the longest-cached key if the cache is full. v.mu must be held.
+func (v *webhookVerifier) makeRoomForKey() {
+	if len(v.keys) < maxVerificationKeys {
+		return
+	}
+	var oldest string
+	for keyID, key := range v.keys {
+		if oldest == "" || key.fetchedAt.Before(v.keys[oldest].fetchedAt) {
+			oldest = keyID
+		}
+	}
+	delete(v.keys, oldest)
+}
+
+// makeRoomForUnknown drops the unknown key ID asked about longest ago if the cache is full. v.mu must be held.
+func (v *webhookVerifier) makeRoomForUnknown() {
+	if len(v.unknown) < maxVerificationKeys {
+		return
+	}
+	var oldest string
+	for keyID, askedAt := range v.unknown {
+		if oldest == "" || askedAt.Before(v.unknown[oldest]) {
+			oldest = keyID
+		}
+	}
+	delete(v.unknown, oldest)
+}
+
+func decodeSegment(segment string, into interface{}) error {
+	raw, err := base64.RawURLEncoding.DecodeString(segment)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(raw, into)
+}
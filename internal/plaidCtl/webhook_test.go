@@ -0,0 +1,226 @@
+package plaidCtl
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/plaid/plaid-go/plaid"
+)
+
+var webhookBody = []byte(`{"webhook_type":"TRANSACTIONS","webhook_code":"SYNC_UPDATES_AVAILABLE","item_id":"item-1"}`)
+
+// testSigner signs webhook tokens the way Plaid does, and serves its public key to a webhookVerifier.
+type testSigner struct {
+	private   *ecdsa.PrivateKey
+	keyID     string
+	expiredAt *int32
+	// fetches counts calls to fetchKey for each key ID.
+	fetches map[string]int
+}
+
+func newTestSigner(t *testing.T) *testSigner {
+	t.Helper()
+	private, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &testSigner{private: private, keyID: "key-1", fetches: map[string]int{}}
+}
+
+func (s *testSigner) fetchKey(_ context.Context, keyID string) (*plaid.JWKPublicKey, error) {
+	s.fetches[keyID]++
+	if keyID != s.keyID {
+		return nil, errors.New("key not found")
+	}
+	jwk := plaid.NewJWKPublicKey("ES256", "P-256", keyID, "EC", "sig",
+		base64.RawURLEncoding.EncodeToString(s.private.X.FillBytes(make([]byte, 32))),
+		base64.RawURLEncoding.EncodeToString(s.private.Y.FillBytes(make([]byte, 32))),
+		0, *plaid.NewNullableInt32(s.expiredAt))
+	return jwk, nil
+}
+
+// sign returns a Plaid-Verification token for body issued at iat.
+func (s *testSigner) sign(t *testing.T, alg string, iat time.Time, body []byte) string {
+	t.Helper()
+	segment := func(v interface{}) string {
+		raw, err := json.Marshal(v)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return base64.RawURLEncoding.EncodeToString(raw)
+	}
+	bodyHash := sha256.Sum256(body)
+	signed := segment(jwtHeader{Alg: alg, Kid: s.keyID}) + "." +
+		segment(jwtClaims{IssuedAt: iat.Unix(), RequestBodySHA256: hex.EncodeToString(bodyHash[:])})
+	digest := sha256.Sum256([]byte(signed))
+	r, sig, err := ecdsa.Sign(rand.Reader, s.private, digest[:])
+	if err != nil {
+		t.Fatal(err)
+	}
+	signature := append(r.FillBytes(make([]byte, 32)), sig.FillBytes(make([]byte, 32))...)
+	return signed + "." + base64.RawURLEncoding.EncodeToString(signature)
+}
+
+// testVerifier returns a verifier fetching keys from signer, whose clock reads *now.
+func testVerifier(signer *testSigner, now *time.Time) *webhookVerifier {
+	return &webhookVerifier{
+		fetchKey: signer.fetchKey,
+		now:      func() time.Time { return *now },
+		keys:     map[string]cachedKey{},
+		unknown:  map[string]time.Time{},
+	}
+}
+
+func TestVerifyWebhook(t *testing.T) {
+	signer := newTestSigner(t)
+	now := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	other := newTestSigner(t)
+
+	tests := []struct {
+		name    string
+		token   func() string
+		body    []byte
+		wantErr string
+	}{
+		{"valid", func() string { return signer.sign(t, "ES256", now, webhookBody) }, webhookBody, ""},
+		{"slightly early clock", func() string { return signer.sign(t, "ES256", now.Add(time.Minute), webhookBody) }, webhookBody, ""},
+		{"malformed", func() string { return "not-a-jwt" }, webhookBody, "malformed token"},
+		{"wrong algorithm", func() string { return signer.sign(t, "HS256", now, webhookBody) }, webhookBody, "unexpected algorithm"},
+		{"no algorithm", func() string { return signer.sign(t, "none", now, webhookBody) }, webhookBody, "unexpected algorithm"},
+		{"signed by another key", func() string { return other.sign(t, "ES256", now, webhookBody) }, webhookBody,
+			"signature does not match"},
+		{"tampered claims", func() string {
+			parts := strings.Split(signer.sign(t, "ES256", now, webhookBody), ".")
+			bodyHash := sha256.Sum256([]byte(`{}`))
+			claims, _ := json.Marshal(jwtClaims{IssuedAt: now.Unix(), RequestBodySHA256: hex.EncodeToString(bodyHash[:])})
+			return parts[0] + "." + base64.RawURLEncoding.EncodeToString(claims) + "." + parts[2]
+		}, []byte(`{}`), "signature does not match"},
+		{"stale", func() string { return signer.sign(t, "ES256", now.Add(-maxWebhookAge-time.Second), webhookBody) },
+			webhookBody, "too old"},
+		{"issued in the future", func() string { return signer.sign(t, "ES256", now.Add(maxWebhookAge+time.Second), webhookBody) },
+			webhookBody, "too old"},
+		{"body hash mismatch", func() string { return signer.sign(t, "ES256", now, webhookBody) },
+			[]byte(`{"webhook_type":"ITEM","webhook_code":"ERROR","item_id":"item-1"}`), "body does not match"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			err := testVerifier(signer, &now).verify(context.Background(), test.token(), test.body)
+			if test.wantErr == "" {
+				if err != nil {
+					t.Errorf("verify = %v, want nil", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), test.wantErr) {
+				t.Errorf("verify = %v, want error containing %q", err, test.wantErr)
+			}
+		})
+	}
+}
+
+func TestVerifyRejectsExpiredKey(t *testing.T) {
+	signer := newTestSigner(t)
+	now := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	expiredAt := int32(now.Add(-time.Hour).Unix())
+	signer.expiredAt = &expiredAt
+
+	err := testVerifier(signer, &now).verify(context.Background(), signer.sign(t, "ES256", now, webhookBody), webhookBody)
+	if err == nil || !strings.Contains(err.Error(), "expired") {
+		t.Errorf("verify = %v, want an expired key error", err)
+	}
+}
+
+func TestVerifierCachesKeys(t *testing.T) {
+	signer := newTestSigner(t)
+	now := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	verifier := testVerifier(signer, &now)
+
+	for i := 0; i < 3; i++ {
+		if err := verifier.verify(context.Background(), signer.sign(t, "ES256", now, webhookBody), webhookBody); err != nil {
+			t.Fatalf("verify: %v", err)
+		}
+	}
+	now = now.Add(verificationKeyTTL + time.Minute)
+	if err := verifier.verify(context.Background(), signer.sign(t, "ES256", now, webhookBody), webhookBody); err != nil {
+		t.Fatalf("verify after TTL: %v", err)
+	}
+	if got := signer.fetches["key-1"]; got != 2 {
+		t.Errorf("fetched key-1 %d times, want once and again after the TTL", got)
+	}
+}
+
+func TestVerifierLimitsUnknownKeyLookups(t *testing.T) {
+	signer := newTestSigner(t)
+	now := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	verifier := testVerifier(signer, &now)
+	verify := func(keyID string) error {
+		forged := *signer
+		forged.keyID = keyID
+		return verifier.verify(context.Background(), forged.sign(t, "ES256", now, webhookBody), webhookBody)
+	}
+
+	if err := verify("made-up"); err == nil {
+		t.Fatal("verified a token with an unknown key")
+	}
+	// Unknown key IDs aren't looked up again until unknownKeyTTL has passed, and new ones are only looked up
+	// once every unknownKeyInterval.
+	now = now.Add(unknownKeyInterval)
+	verify("made-up")
+	verify("made-up-2")
+	verify("made-up-3")
+	if signer.fetches["made-up"] != 1 || signer.fetches["made-up-2"] != 1 || signer.fetches["made-up-3"] != 0 {
+		t.Errorf("fetches = %v, want made-up and made-up-2 fetched once each", signer.fetches)
+	}
+	now = now.Add(unknownKeyTTL)
+	verify("made-up")
+	if signer.fetches["made-up"] != 2 {
+		t.Errorf("fetched made-up %d times, want it retried after unknownKeyTTL", signer.fetches["made-up"])
+	}
+
+	// Neither cache grows without bound.
+	for i := 0; i < 3*maxVerificationKeys; i++ {
+		now = now.Add(unknownKeyInterval)
+		verify(fmt.Sprintf("spray-%d", i))
+	}
+	if len(verifier.unknown) > maxVerificationKeys {
+		t.Errorf("cached %d unknown key IDs, want at most %d", len(verifier.unknown), maxVerificationKeys)
+	}
+	now = now.Add(unknownKeyInterval)
+	if err := verify("key-1"); err != nil {
+		t.Errorf("verify with the real key after a spray: %v", err)
+	}
+	if len(verifier.keys) != 1 {
+		t.Errorf("cached %d keys, want 1", len(verifier.keys))
+	}
+}
+
+func TestVerifierBoundsKeyCache(t *testing.T) {
+	signer := newTestSigner(t)
+	now := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	verifier := testVerifier(signer, &now)
+
+	for i := 0; i < maxVerificationKeys+4; i++ {
+		now = now.Add(unknownKeyInterval)
+		signer.keyID = fmt.Sprintf("rotated-%d", i)
+		if err := verifier.verify(context.Background(), signer.sign(t, "ES256", now, webhookBody), webhookBody); err != nil {
+			t.Fatalf("verify with %v: %v", signer.keyID, err)
+		}
+	}
+	if len(verifier.keys) != maxVerificationKeys {
+		t.Errorf("cached %d keys, want %d", len(verifier.keys), maxVerificationKeys)
+	}
+	if _, ok := verifier.keys["rotated-0"]; ok {
+		t.Error("the oldest key is still cached")
+	}
+}
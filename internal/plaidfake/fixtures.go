@@ -0,0 +1,137 @@
+package plaidfake
+
+import (
+	"time"
+
+	"github.com/plaid/plaid-go/plaid"
+)
+
+// Fixture scripts an institution the fake can link, along with the accounts and transactions each item linked
+// to it starts with.
+type Fixture struct {
+	InstitutionID   string        `json:"institution_id"`
+	InstitutionName string        `json:"institution_name"`
+	Accounts        []Account     `json:"accounts"`
+	Transactions    []Transaction `json:"transactions"`
+}
+
+// Account is an account at a fixture institution. Balances are in Currency, USD if unset.
+type Account struct {
+	AccountID    string   `json:"account_id"`
+	Name         string   `json:"name"`
+	OfficialName string   `json:"official_name,omitempty"`
+	Mask         string   `json:"mask,omitempty"`
+	Type         string   `json:"type"`
+	Subtype      string   `json:"subtype,omitempty"`
+	Currency     string   `json:"currency,omitempty"`
+	Current      float64  `json:"current"`
+	Available    *float64 `json:"available,omitempty"`
+	Limit        *float64 `json:"limit,omitempty"`
+}
+
+// Transaction is a transaction on a fixture account. Amount is positive for money leaving the account, as Plaid
+// reports it, and Date is YYYY-MM-DD, today if unset.
+type Transaction struct {
+	TransactionID        string   `json:"transaction_id"`
+	PendingTransactionID string   `json:"pending_transaction_id,omitempty"`
+	AccountID            string   `json:"account_id"`
+	Name                 string   `json:"name"`
+	MerchantName         string   `json:"merchant_name,omitempty"`
+	Amount               float64  `json:"amount"`
+	Date                 string   `json:"date,omitempty"`
+	Pending              bool     `json:"pending,omitempty"`
+	Category             []string `json:"category,omitempty"`
+	PFCPrimary           string   `json:"pfc_primary,omitempty"`
+	PFCDetailed          string   `json:"pfc_detailed,omitempty"`
+}
+
+// DefaultFixture is a small bank with a checking account, a savings account and a credit card, and a handful of
+// recent transactions across them.
+func DefaultFixture() Fixture {
+	day := func(daysAgo int) string {
+		return time.Now().AddDate(0, 0, -daysAgo).Format("2006-01-02")
+	}
+	available, limit := 1180.25, 5000.0
+	return Fixture{
+		InstitutionID:   "ins_fake",
+		InstitutionName: "Fake Bank",
+		Accounts: []Account{
+			{AccountID: "fake-checking", Name: "Fake Checking", Mask: "0000", Type: "depository", Subtype: "checking", Current: 1230.25, Available: &available},
+			{AccountID: "fake-savings", Name: "Fake Savings", Mask: "1111", Type: "depository", Subtype: "savings", Current: 8200},
+			{AccountID: "fake-credit", Name: "Fake Credit Card", Mask: "3333", Type: "credit", Subtype: "credit card", Current: 410.1, Limit: &limit},
+		},
+		Transactions: []Transaction{
+			{TransactionID: "fake-tx-1", AccountID: "fake-checking", Name: "PAYROLL DEPOSIT", Amount: -2500, Date: day(14), Category: []string{"Transfer", "Payroll"}, PFCPrimary: "INCOME", PFCDetailed: "INCOME_WAGES"},
+			{TransactionID: "fake-tx-2", AccountID: "fake-checking", Name: "CITY POWER & LIGHT", MerchantName: "City Power", Amount: 84.12, Date: day(10), Category: []string{"Service", "Utilities"}, PFCPrimary: "RENT_AND_UTILITIES", PFCDetailed: "RENT_AND_UTILITIES_GAS_AND_ELECTRICITY"},
+			{TransactionID: "fake-tx-3", AccountID: "fake-credit", Name: "CORNER PIZZA", MerchantName: "Corner Pizza", Amount: 23.5, Date: day(6), Category: []string{"Food and Drink", "Restaurants"}, PFCPrimary: "FOOD_AND_DRINK", PFCDetailed: "FOOD_AND_DRINK_RESTAURANT"},
+			{TransactionID: "fake-tx-4", AccountID: "fake-credit", Name: "STREAMFLIX", MerchantName: "Streamflix", Amount: 15.99, Date: day(3), Category: []string{"Service", "Subscription"}, PFCPrimary: "ENTERTAINMENT", PFCDetailed: "ENTERTAINMENT_TV_AND_MOVIES"},
+			{TransactionID: "fake-tx-5", AccountID: "fake-checking", Name: "GROCERY MART", MerchantName: "Grocery Mart", Amount: 64.37, Date: day(1), Category: []string{"Shops", "Supermarkets and Groceries"}, PFCPrimary: "FOOD_AND_DRINK", PFCDetailed: "FOOD_AND_DRINK_GROCERIES"},
+		},
+	}
+}
+
+func (a Account) plaid() plaid.AccountBase {
+	currency := a.Currency
+	if currency == "" {
+		currency = "USD"
+	}
+	account := plaid.AccountBase{
+		AccountId: a.AccountID,
+		Name:      a.Name,
+		Type:      plaid.AccountType(a.Type),
+		Balances: plaid.AccountBalance{
+			Current:         *plaid.NewNullableFloat32(plaid.PtrFloat32(float32(a.Current))),
+			IsoCurrencyCode: *plaid.NewNullableString(&currency),
+		},
+	}
+	if a.OfficialName != "" {
+		account.OfficialName = *plaid.NewNullableString(&a.OfficialName)
+	}
+	if a.Mask != "" {
+		account.Mask = *plaid.NewNullableString(&a.Mask)
+	}
+	if a.Subtype != "" {
+		subtype := plaid.AccountSubtype(a.Subtype)
+		account.Subtype = *plaid.NewNullableAccountSubtype(&subtype)
+	}
+	if a.Available != nil {
+		account.Balances.Available = *plaid.NewNullableFloat32(plaid.PtrFloat32(float32(*a.Available)))
+	}
+	if a.Limit != nil {
+		account.Balances.Limit = *plaid.NewNullableFloat32(plaid.PtrFloat32(float32(*a.Limit)))
+	}
+	return account
+}
+
+func (t Transaction) plaid(currency string) plaid.Transaction {
+	if currency == "" {
+		currency = "USD"
+	}
+	transaction := plaid.Transaction{
+		TransactionId:   t.TransactionID,
+		AccountId:       t.AccountID,
+		Name:            t.Name,
+		Amount:          float32(t.Amount),
+		Date:            t.Date,
+		Pending:         t.Pending,
+		Category:        t.Category,
+		PaymentChannel:  "other",
+		IsoCurrencyCode: *plaid.NewNullableString(&currency),
+	}
+	if transaction.Category == nil {
+		transaction.Category = []string{}
+	}
+	if t.MerchantName != "" {
+		transaction.MerchantName = *plaid.NewNullableString(&t.MerchantName)
+	}
+	if t.PendingTransactionID != "" {
+		transaction.PendingTransactionId = *plaid.NewNullableString(&t.PendingTransactionID)
+	}
+	if t.PFCPrimary != "" {
+		transaction.PersonalFinanceCategory = *plaid.NewNullablePersonalFinanceCategory(&plaid.PersonalFinanceCategory{
+			Primary:  t.PFCPrimary,
+			Detailed: t.PFCDetailed,
+		})
+	}
+	return transaction
+}
@@ -0,0 +1,574 @@
+// Package plaidfake is an in-memory stand-in for the Plaid API, for exercising plaidCtl without the network.
+// It covers the endpoints this server calls to link, sync and remove items, plus the sandbox endpoints
+// used to link without the Link UI, break items and fire webhooks, all scripted by Fixtures:
+//
+//	fake := plaidfake.New(plaidfake.DefaultFixture())
+//	srv := httptest.NewServer(fake)
+//	// point the Plaid client at srv.URL, e.g. with PLAID_BASE_URL
+//	publicToken, _ := fake.LinkItem("ins_fake")
+//
+// Requests are served from memory only; nothing is persisted.
+package plaidfake
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/plaid/plaid-go/plaid"
+)
+
+// defaultSyncCount and maxSyncCount are the /transactions/sync page sizes Plaid uses by default and at most.
+const (
+	defaultSyncCount = 100
+	maxSyncCount     = 500
+)
+
+// Server is a fake Plaid API. Create it with New and serve it with net/http or httptest.
+type Server struct {
+	// ClientID and Secret, when set, must be sent with every request, as the Plaid client does in its
+	// PLAID-CLIENT-ID and PLAID-SECRET headers.
+	ClientID string
+	Secret   string
+
+	mux    *http.ServeMux
+	client *http.Client
+	key    *ecdsa.PrivateKey
+	keyID  string
+
+	mu           sync.Mutex
+	fixtures     map[string]Fixture
+	items        map[string]*item
+	accessTokens map[string]string
+	publicTokens map[string]string
+	webhook      string
+	// linked holds the institutions items have been linked at, removed ones included.
+	linked map[string]bool
+
+	idMu   sync.Mutex
+	nextID int
+}
+
+// item is an item linked on the fake, with its own copy of its fixture's accounts and transactions.
+type item struct {
+	id            string
+	institutionID string
+	webhook       string
+	errorCode     string
+	accounts      []Account
+	// idSuffix makes the fixture's account and transaction IDs the item's own.
+	idSuffix     string
+	transactions map[string]Transaction
+	// changes is the log /transactions/sync pages through; a cursor is an index into it.
+	changes []change
+}
+
+type change struct {
+	kind        string
+	transaction Transaction
+}
+
+const (
+	changeAdded    = "added"
+	changeModified = "modified"
+	changeRemoved  = "removed"
+)
+
+// New returns a fake that can link items at the institutions in fixtures.
+func New(fixtures ...Fixture) *Server {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		panic(fmt.Sprintf("plaidfake: generating webhook key: %v", err))
+	}
+	s := &Server{
+		mux:          http.NewServeMux(),
+		client:       &http.Client{Timeout: 10 * time.Second},
+		key:          key,
+		keyID:        "plaidfake-key",
+		fixtures:     map[string]Fixture{},
+		items:        map[string]*item{},
+		accessTokens: map[string]string{},
+		publicTokens: map[string]string{},
+		linked:       map[string]bool{},
+	}
+	for _, fixture := range fixtures {
+		s.fixtures[fixture.InstitutionID] = fixture
+	}
+
+	s.handle("/link/token/create", s.linkTokenCreate)
+	s.handle("/sandbox/public_token/create", s.sandboxPublicTokenCreate)
+	s.handle("/item/public_token/exchange", s.itemPublicTokenExchange)
+	s.handle("/item/get", s.itemGet)
+	s.handle("/item/remove", s.itemRemove)
+	s.handle("/institutions/get_by_id", s.institutionsGetByID)
+	s.handle("/accounts/get", s.accountsGet)
+	s.handle("/accounts/balance/get", s.accountsGet)
+	s.handle("/transactions/sync", s.transactionsSync)
+	s.handle("/transactions/get", s.transactionsGet)
+	s.handle("/webhook_verification_key/get", s.webhookVerificationKeyGet)
+	s.handle("/sandbox/item/fire_webhook", s.sandboxItemFireWebhook)
+	s.handle("/sandbox/item/reset_login", s.sandboxItemResetLogin)
+	return s
+}
+
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mux.ServeHTTP(w, r)
+}
+
+// request holds the fields of every request body the fake reads.
+type request struct {
+	AccessToken   string `json:"access_token"`
+	PublicToken   string `json:"public_token"`
+	Cursor        string `json:"cursor"`
+	Count         int32  `json:"count"`
+	StartDate     string `json:"start_date"`
+	EndDate       string `json:"end_date"`
+	InstitutionID string `json:"institution_id"`
+	Webhook       string `json:"webhook"`
+	WebhookCode   string `json:"webhook_code"`
+	KeyID         string `json:"key_id"`
+	Options       struct {
+		Count   int32  `json:"count"`
+		Offset  int32  `json:"offset"`
+		Webhook string `json:"webhook"`
+	} `json:"options"`
+}
+
+// apiError is a Plaid error response.
+type apiError struct {
+	status int
+	body   plaid.Error
+}
+
+func newError(status int, errorType, code, message string) *apiError {
+	return &apiError{status: status, body: plaid.Error{ErrorType: errorType, ErrorCode: code, ErrorMessage: message}}
+}
+
+func (s *Server) handle(path string, endpoint func(req request) (interface{}, *apiError)) {
+	s.mux.HandleFunc(path, func(w http.ResponseWriter, r *http.Request) {
+		var req request
+		var response interface{}
+		apiErr := s.authenticate(r)
+		if apiErr == nil {
+			if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+				apiErr = newError(http.StatusBadRequest, "INVALID_REQUEST", "INVALID_BODY", fmt.Sprintf("invalid request body: %v", err))
+			}
+		}
+		if apiErr == nil {
+			response, apiErr = endpoint(req)
+		}
+
+		requestID := s.newID("request")
+		w.Header().Set("Content-Type", "application/json")
+		if apiErr != nil {
+			log.Printf("plaidfake %v -> %v", path, apiErr.body.ErrorCode)
+			apiErr.body.RequestId = &requestID
+			w.WriteHeader(apiErr.status)
+			_ = json.NewEncoder(w).Encode(apiErr.body)
+			return
+		}
+		// Every Plaid response carries a request_id; add it without a field on each response type.
+		encoded, err := json.Marshal(response)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		var fields map[string]interface{}
+		_ = json.Unmarshal(encoded, &fields)
+		fields["request_id"] = requestID
+		_ = json.NewEncoder(w).Encode(fields)
+	})
+}
+
+func (s *Server) authenticate(r *http.Request) *apiError {
+	if s.ClientID == "" && s.Secret == "" {
+		return nil
+	}
+	if r.Header.Get("PLAID-CLIENT-ID") != s.ClientID || r.Header.Get("PLAID-SECRET") != s.Secret {
+		return newError(http.StatusBadRequest, "INVALID_INPUT", "INVALID_API_KEYS", "invalid client_id or secret provided")
+	}
+	return nil
+}
+
+// newID returns a unique ID starting with prefix. Callers may hold s.mu or not.
+func (s *Server) newID(prefix string) string {
+	s.idMu.Lock()
+	defer s.idMu.Unlock()
+	s.nextID++
+	return prefix + "-" + strconv.Itoa(s.nextID)
+}
+
+// LinkItem links a new item at the fixture institution, as a user finishing Link would, and returns the public
+// token to exchange for it. The item sends webhooks to the URL of the last Link token created, if any.
+func (s *Server) LinkItem(institutionID string) (string, error) {
+	token, apiErr := s.createPublicToken(institutionID, "")
+	if apiErr != nil {
+		return "", fmt.Errorf("%v: %v", apiErr.body.ErrorCode, apiErr.body.ErrorMessage)
+	}
+	return token, nil
+}
+
+func (s *Server) createPublicToken(institutionID, webhook string) (string, *apiError) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	fixture, ok := s.fixtures[institutionID]
+	if !ok {
+		return "", newError(http.StatusBadRequest, "INVALID_INPUT", "INVALID_INSTITUTION", fmt.Sprintf("unknown institution %q", institutionID))
+	}
+	if webhook == "" {
+		webhook = s.webhook
+	}
+
+	linked := &item{
+		id:            s.newID("item"),
+		institutionID: institutionID,
+		webhook:       webhook,
+		transactions:  map[string]Transaction{},
+	}
+	// Plaid gives every item its own account and transaction IDs. The first item at an institution keeps the
+	// fixture's, so they can be used as they are; later ones get them suffixed with their item ID.
+	if s.linked[institutionID] {
+		linked.idSuffix = "-" + linked.id
+	}
+	s.linked[institutionID] = true
+	for _, account := range fixture.Accounts {
+		account.AccountID = linked.ownID(account.AccountID)
+		linked.accounts = append(linked.accounts, account)
+	}
+	for _, transaction := range fixture.Transactions {
+		linked.add(changeAdded, transaction)
+	}
+	s.items[linked.id] = linked
+	publicToken := s.newID("public-sandbox")
+	s.publicTokens[publicToken] = linked.id
+	return publicToken, nil
+}
+
+// ownID turns a fixture's account or transaction ID into the item's own; IDs that already are are unchanged.
+func (i *item) ownID(id string) string {
+	if id == "" || strings.HasSuffix(id, i.idSuffix) {
+		return id
+	}
+	return id + i.idSuffix
+}
+
+// add records a change to the item's transactions. Transactions and their accounts may be given by their
+// fixture IDs.
+func (i *item) add(kind string, transaction Transaction) {
+	transaction.TransactionID = i.ownID(transaction.TransactionID)
+	transaction.PendingTransactionID = i.ownID(transaction.PendingTransactionID)
+	transaction.AccountID = i.ownID(transaction.AccountID)
+	if transaction.Date == "" {
+		transaction.Date = time.Now().Format("2006-01-02")
+	}
+	if kind == changeRemoved {
+		delete(i.transactions, transaction.TransactionID)
+	} else {
+		i.transactions[transaction.TransactionID] = transaction
+	}
+	i.changes = append(i.changes, change{kind: kind, transaction: transaction})
+}
+
+func (i *item) currency(accountID string) string {
+	for _, account := range i.accounts {
+		if account.AccountID == accountID {
+			return account.Currency
+		}
+	}
+	return ""
+}
+
+func (i *item) plaidAccounts() []plaid.AccountBase {
+	accounts := make([]plaid.AccountBase, 0, len(i.accounts))
+	for _, account := range i.accounts {
+		accounts = append(accounts, account.plaid())
+	}
+	return accounts
+}
+
+func (i *item) plaidItem() plaid.Item {
+	linked := plaid.Item{
+		ItemId:            i.id,
+		InstitutionId:     *plaid.NewNullableString(&i.institutionID),
+		AvailableProducts: []plaid.Products{},
+		BilledProducts:    []plaid.Products{plaid.PRODUCTS_TRANSACTIONS},
+		UpdateType:        "background",
+	}
+	if i.webhook != "" {
+		linked.Webhook = *plaid.NewNullableString(&i.webhook)
+	}
+	if i.errorCode != "" {
+		linked.Error = *plaid.NewNullableError(&itemError(i.errorCode).body)
+	}
+	return linked
+}
+
+// itemError is the error Plaid returns for calls on an item in the state code describes.
+func itemError(code string) *apiError {
+	return newError(http.StatusBadRequest, "ITEM_ERROR", code, "the item is in an error state: "+code)
+}
+
+// withItem runs fn on the item the request's access token belongs to, holding s.mu. Items in an error state
+// fail every call that reads their data unless allowBroken is set.
+func (s *Server) withItem(req request, allowBroken bool, fn func(*item) (interface{}, *apiError)) (interface{}, *apiError) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	linked, ok := s.items[s.accessTokens[req.AccessToken]]
+	if !ok {
+		return nil, newError(http.StatusBadRequest, "INVALID_INPUT", "INVALID_ACCESS_TOKEN", "provided access token is in an invalid format or does not exist")
+	}
+	if linked.errorCode != "" && !allowBroken {
+		return nil, itemError(linked.errorCode)
+	}
+	return fn(linked)
+}
+
+func (s *Server) linkTokenCreate(req request) (interface{}, *apiError) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if req.AccessToken != "" {
+		// Update mode: the item stays broken until the user would finish Link; SetItemError("") stands in for that.
+		if _, ok := s.accessTokens[req.AccessToken]; !ok {
+			return nil, newError(http.StatusBadRequest, "INVALID_INPUT", "INVALID_ACCESS_TOKEN", "provided access token is in an invalid format or does not exist")
+		}
+	} else if req.Webhook != "" {
+		s.webhook = req.Webhook
+	}
+	return plaid.LinkTokenCreateResponse{
+		LinkToken:  s.newID("link-sandbox"),
+		Expiration: time.Now().Add(4 * time.Hour).UTC(),
+	}, nil
+}
+
+func (s *Server) sandboxPublicTokenCreate(req request) (interface{}, *apiError) {
+	publicToken, apiErr := s.createPublicToken(req.InstitutionID, req.Options.Webhook)
+	if apiErr != nil {
+		return nil, apiErr
+	}
+	return plaid.SandboxPublicTokenCreateResponse{PublicToken: publicToken}, nil
+}
+
+func (s *Server) itemPublicTokenExchange(req request) (interface{}, *apiError) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	itemID, ok := s.publicTokens[req.PublicToken]
+	if !ok {
+		return nil, newError(http.StatusBadRequest, "INVALID_INPUT", "INVALID_PUBLIC_TOKEN", "provided public token is expired or does not exist")
+	}
+	// Public tokens can only be exchanged once.
+	delete(s.publicTokens, req.PublicToken)
+	accessToken := s.newID("access-sandbox")
+	s.accessTokens[accessToken] = itemID
+	return plaid.ItemPublicTokenExchangeResponse{AccessToken: accessToken, ItemId: itemID}, nil
+}
+
+func (s *Server) itemGet(req request) (interface{}, *apiError) {
+	return s.withItem(req, true, func(linked *item) (interface{}, *apiError) {
+		return plaid.ItemGetResponse{Item: linked.plaidItem()}, nil
+	})
+}
+
+func (s *Server) itemRemove(req request) (interface{}, *apiError) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	itemID, ok := s.accessTokens[req.AccessToken]
+	if !ok {
+		return nil, newError(http.StatusBadRequest, "INVALID_INPUT", "INVALID_ACCESS_TOKEN", "provided access token is in an invalid format or does not exist")
+	}
+	// Removing an item invalidates every access token for it.
+	for token, id := range s.accessTokens {
+		if id == itemID {
+			delete(s.accessTokens, token)
+		}
+	}
+	delete(s.items, itemID)
+	return plaid.ItemRemoveResponse{}, nil
+}
+
+func (s *Server) institutionsGetByID(req request) (interface{}, *apiError) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	fixture, ok := s.fixtures[req.InstitutionID]
+	if !ok {
+		return nil, newError(http.StatusBadRequest, "INVALID_INPUT", "INVALID_INSTITUTION", fmt.Sprintf("unknown institution %q", req.InstitutionID))
+	}
+	return plaid.InstitutionsGetByIdResponse{Institution: plaid.Institution{
+		InstitutionId:  fixture.InstitutionID,
+		Name:           fixture.InstitutionName,
+		Products:       []plaid.Products{plaid.PRODUCTS_AUTH, plaid.PRODUCTS_TRANSACTIONS},
+		CountryCodes:   []plaid.CountryCode{plaid.COUNTRYCODE_US},
+		RoutingNumbers: []string{},
+	}}, nil
+}
+
+func (s *Server) accountsGet(req request) (interface{}, *apiError) {
+	return s.withItem(req, false, func(linked *item) (interface{}, *apiError) {
+		return plaid.AccountsGetResponse{Accounts: linked.plaidAccounts(), Item: linked.plaidItem()}, nil
+	})
+}
+
+func (s *Server) transactionsSync(req request) (interface{}, *apiError) {
+	return s.withItem(req, false, func(linked *item) (interface{}, *apiError) {
+		start := 0
+		if req.Cursor != "" {
+			var err error
+			if start, err = strconv.Atoi(req.Cursor); err != nil || start < 0 || start > len(linked.changes) {
+				return nil, newError(http.StatusBadRequest, "INVALID_INPUT", "INVALID_FIELD", "cursor is invalid")
+			}
+		}
+		count := int(req.Count)
+		if count <= 0 {
+			count = defaultSyncCount
+		}
+		if count > maxSyncCount {
+			count = maxSyncCount
+		}
+		end := start + count
+		if end > len(linked.changes) {
+			end = len(linked.changes)
+		}
+
+		response := plaid.TransactionsSyncResponse{
+			Added:      []plaid.Transaction{},
+			Modified:   []plaid.Transaction{},
+			Removed:    []plaid.RemovedTransaction{},
+			NextCursor: strconv.Itoa(end),
+			HasMore:    end < len(linked.changes),
+		}
+		for _, change := range linked.changes[start:end] {
+			transaction := change.transaction.plaid(linked.currency(change.transaction.AccountID))
+			switch change.kind {
+			case changeAdded:
+				response.Added = append(response.Added, transaction)
+			case changeModified:
+				response.Modified = append(response.Modified, transaction)
+			case changeRemoved:
+				id := change.transaction.TransactionID
+				response.Removed = append(response.Removed, plaid.RemovedTransaction{TransactionId: &id})
+			}
+		}
+		return response, nil
+	})
+}
+
+func (s *Server) transactionsGet(req request) (interface{}, *apiError) {
+	return s.withItem(req, false, func(linked *item) (interface{}, *apiError) {
+		var matching []Transaction
+		for _, transaction := range linked.transactions {
+			if transaction.Date >= req.StartDate && transaction.Date <= req.EndDate {
+				matching = append(matching, transaction)
+			}
+		}
+		// Newest first, as Plaid returns them.
+		sort.Slice(matching, func(a, b int) bool {
+			if matching[a].Date != matching[b].Date {
+				return matching[a].Date > matching[b].Date
+			}
+			return matching[a].TransactionID < matching[b].TransactionID
+		})
+
+		offset, count := int(req.Options.Offset), int(req.Options.Count)
+		if count <= 0 {
+			count = defaultSyncCount
+		}
+		response := plaid.TransactionsGetResponse{
+			Accounts:          linked.plaidAccounts(),
+			Transactions:      []plaid.Transaction{},
+			TotalTransactions: int32(len(matching)),
+			Item:              linked.plaidItem(),
+		}
+		for i := offset; i < len(matching) && i < offset+count; i++ {
+			response.Transactions = append(response.Transactions, matching[i].plaid(linked.currency(matching[i].AccountID)))
+		}
+		return response, nil
+	})
+}
+
+func (s *Server) sandboxItemResetLogin(req request) (interface{}, *apiError) {
+	return s.withItem(req, true, func(linked *item) (interface{}, *apiError) {
+		linked.errorCode = "ITEM_LOGIN_REQUIRED"
+		return plaid.SandboxItemResetLoginResponse{ResetLogin: true}, nil
+	})
+}
+
+// AddTransactions adds transactions to an item, to be picked up by its next sync. Here and in the other
+// scripting methods, transactions and accounts can be given by their fixture IDs.
+func (s *Server) AddTransactions(itemID string, transactions ...Transaction) error {
+	return s.changeTransactions(itemID, changeAdded, transactions)
+}
+
+// ModifyTransactions replaces transactions of an item that have the same IDs.
+func (s *Server) ModifyTransactions(itemID string, transactions ...Transaction) error {
+	return s.changeTransactions(itemID, changeModified, transactions)
+}
+
+// RemoveTransactions removes transactions from an item by ID.
+func (s *Server) RemoveTransactions(itemID string, transactionIDs ...string) error {
+	transactions := make([]Transaction, 0, len(transactionIDs))
+	for _, id := range transactionIDs {
+		transactions = append(transactions, Transaction{TransactionID: id})
+	}
+	return s.changeTransactions(itemID, changeRemoved, transactions)
+}
+
+func (s *Server) changeTransactions(itemID, kind string, transactions []Transaction) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	linked, ok := s.items[itemID]
+	if !ok {
+		return fmt.Errorf("unknown item %q", itemID)
+	}
+	for _, transaction := range transactions {
+		if _, exists := linked.transactions[linked.ownID(transaction.TransactionID)]; exists == (kind == changeAdded) {
+			return fmt.Errorf("item %v: can't mark transaction %q %v", itemID, transaction.TransactionID, kind)
+		}
+		linked.add(kind, transaction)
+	}
+	return nil
+}
+
+// SetItemError puts an item in the error state code, e.g. ITEM_LOGIN_REQUIRED, so calls for its data fail with
+// it. An empty code repairs the item.
+func (s *Server) SetItemError(itemID, code string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	linked, ok := s.items[itemID]
+	if !ok {
+		return fmt.Errorf("unknown item %q", itemID)
+	}
+	linked.errorCode = code
+	return nil
+}
+
+// ItemIDs returns the IDs of every item linked on the fake, in the order they were linked.
+func (s *Server) ItemIDs() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	ids := make([]string, 0, len(s.items))
+	for id := range s.items {
+		ids = append(ids, id)
+	}
+	sort.Slice(ids, func(a, b int) bool {
+		return idNumber(ids[a]) < idNumber(ids[b])
+	})
+	return ids
+}
+
+func idNumber(id string) int {
+	for i := len(id) - 1; i >= 0; i-- {
+		if id[i] == '-' {
+			n, _ := strconv.Atoi(id[i+1:])
+			return n
+		}
+	}
+	return 0
+}
@@ -0,0 +1,151 @@
+package plaidfake
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/plaid/plaid-go/plaid"
+)
+
+// webhookTypes gives the webhook type /sandbox/item/fire_webhook sends for each code it accepts.
+var webhookTypes = map[string]string{
+	"SYNC_UPDATES_AVAILABLE":  "TRANSACTIONS",
+	"DEFAULT_UPDATE":          "TRANSACTIONS",
+	"NEW_ACCOUNTS_AVAILABLE":  "ITEM",
+	"ERROR":                   "ITEM",
+	"PENDING_EXPIRATION":      "ITEM",
+	"USER_PERMISSION_REVOKED": "ITEM",
+	"LOGIN_REPAIRED":          "ITEM",
+}
+
+// FireWebhook sends an item's webhook URL a webhook of the given type and code, signed the way Plaid signs them
+// so it passes verification against /webhook_verification_key/get. ITEM ERROR webhooks carry the item's error,
+// ITEM_LOGIN_REQUIRED if it has none. The webhook is delivered before FireWebhook returns.
+func (s *Server) FireWebhook(itemID, webhookType, webhookCode string) error {
+	s.mu.Lock()
+	linked, ok := s.items[itemID]
+	var url, errorCode string
+	if ok {
+		url, errorCode = linked.webhook, linked.errorCode
+	}
+	s.mu.Unlock()
+	if !ok {
+		return fmt.Errorf("unknown item %q", itemID)
+	}
+	if url == "" {
+		return fmt.Errorf("item %v has no webhook URL", itemID)
+	}
+
+	payload := map[string]interface{}{
+		"webhook_type": webhookType,
+		"webhook_code": webhookCode,
+		"item_id":      itemID,
+		"environment":  "sandbox",
+	}
+	switch {
+	case webhookType == "TRANSACTIONS" && webhookCode == "SYNC_UPDATES_AVAILABLE":
+		payload["initial_update_complete"] = true
+		payload["historical_update_complete"] = true
+	case webhookType == "ITEM" && webhookCode == "ERROR":
+		if errorCode == "" {
+			errorCode = "ITEM_LOGIN_REQUIRED"
+		}
+		payload["error"] = itemError(errorCode).body
+	}
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+	token, err := s.sign(body)
+	if err != nil {
+		return err
+	}
+
+	request, err := http.NewRequest(http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	request.Header.Set("Content-Type", "application/json")
+	request.Header.Set("Plaid-Verification", token)
+	resp, err := s.client.Do(request)
+	if err != nil {
+		return fmt.Errorf("delivering webhook: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("webhook receiver responded %v", resp.Status)
+	}
+	return nil
+}
+
+// sign returns the Plaid-Verification JWT for a webhook body: an ES256 signature over the time and the body's
+// SHA-256.
+func (s *Server) sign(body []byte) (string, error) {
+	header, err := json.Marshal(map[string]string{"alg": "ES256", "kid": s.keyID, "typ": "JWT"})
+	if err != nil {
+		return "", err
+	}
+	bodyHash := sha256.Sum256(body)
+	claims, err := json.Marshal(map[string]interface{}{
+		"iat":                 time.Now().Unix(),
+		"request_body_sha256": hex.EncodeToString(bodyHash[:]),
+	})
+	if err != nil {
+		return "", err
+	}
+
+	signingInput := base64.RawURLEncoding.EncodeToString(header) + "." + base64.RawURLEncoding.EncodeToString(claims)
+	digest := sha256.Sum256([]byte(signingInput))
+	r, sig, err := ecdsa.Sign(rand.Reader, s.key, digest[:])
+	if err != nil {
+		return "", err
+	}
+	signature := make([]byte, 64)
+	r.FillBytes(signature[:32])
+	sig.FillBytes(signature[32:])
+	return signingInput + "." + base64.RawURLEncoding.EncodeToString(signature), nil
+}
+
+func (s *Server) webhookVerificationKeyGet(req request) (interface{}, *apiError) {
+	if req.KeyID != s.keyID {
+		return nil, newError(http.StatusBadRequest, "INVALID_INPUT", "INVALID_WEBHOOK_VERIFICATION_KEY_ID", fmt.Sprintf("unknown key id %q", req.KeyID))
+	}
+	x, y := make([]byte, 32), make([]byte, 32)
+	s.key.PublicKey.X.FillBytes(x)
+	s.key.PublicKey.Y.FillBytes(y)
+	return plaid.WebhookVerificationKeyGetResponse{Key: plaid.JWKPublicKey{
+		Alg:       "ES256",
+		Crv:       "P-256",
+		Kid:       s.keyID,
+		Kty:       "EC",
+		Use:       "sig",
+		X:         base64.RawURLEncoding.EncodeToString(x),
+		Y:         base64.RawURLEncoding.EncodeToString(y),
+		CreatedAt: int32(time.Now().Unix()),
+	}}, nil
+}
+
+func (s *Server) sandboxItemFireWebhook(req request) (interface{}, *apiError) {
+	webhookType, ok := webhookTypes[req.WebhookCode]
+	if !ok {
+		return nil, newError(http.StatusBadRequest, "INVALID_INPUT", "INVALID_FIELD", fmt.Sprintf("unsupported webhook_code %q", req.WebhookCode))
+	}
+	response, apiErr := s.withItem(req, true, func(linked *item) (interface{}, *apiError) {
+		return linked.id, nil
+	})
+	if apiErr != nil {
+		return nil, apiErr
+	}
+	if err := s.FireWebhook(response.(string), webhookType, req.WebhookCode); err != nil {
+		return nil, newError(http.StatusBadRequest, "INVALID_INPUT", "SANDBOX_WEBHOOK_INVALID", err.Error())
+	}
+	return plaid.SandboxItemFireWebhookResponse{WebhookFired: true}, nil
+}
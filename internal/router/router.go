@@ -3,8 +3,11 @@ package router
 import (
 	"context"
 	"database/sql"
+	"github.com/Seymour-creates/budget-server/internal/alerts"
+	"github.com/Seymour-creates/budget-server/internal/classifier"
 	"github.com/Seymour-creates/budget-server/internal/db"
 	"github.com/Seymour-creates/budget-server/internal/plaidCtl"
+	"github.com/Seymour-creates/budget-server/internal/secrets"
 	"github.com/plaid/plaid-go/plaid"
 	"golang.ngrok.com/ngrok"
 	"golang.ngrok.com/ngrok/config"
@@ -21,24 +24,53 @@ type Server struct {
 	handler *handlers.Handler
 }
 
-func createNewPlaidClient() *plaid.APIClient {
+func createNewPlaidClient(plaidConfig plaidCtl.Config) *plaid.APIClient {
 	clientOptions := plaid.NewConfiguration()
-	clientOptions.AddDefaultHeader("PLAID-CLIENT-ID", os.Getenv("PLAID_CLIENT_ID"))
-	clientOptions.AddDefaultHeader("PLAID-SECRET", os.Getenv("PLAID_SECRET"))
-
-	// Use plaidCtl.Development or plaidCtl.Production depending on your environment
-	clientOptions.UseEnvironment(plaid.Sandbox)
+	clientOptions.AddDefaultHeader("PLAID-CLIENT-ID", plaidConfig.ClientID)
+	clientOptions.AddDefaultHeader("PLAID-SECRET", plaidConfig.Secret)
+	clientOptions.UseEnvironment(plaid.Environment(plaidConfig.BaseURL))
 	return plaid.NewAPIClient(clientOptions)
 }
 
+// migrateSchema brings the database schema up to date before any handler touches it.
+func migrateSchema(conn *sql.DB, dialect db.Dialect) {
+	migrator, err := db.NewMigrator(conn, dialect)
+	if err != nil {
+		log.Fatalf("error loading migrations: %v", err)
+	}
+	if err := migrator.Up(); err != nil {
+		log.Fatalf("error migrating db: %v", err)
+	}
+}
+
 func ConfigServer() *Server {
-	mysqlConn, err := sql.Open("mysql", os.Getenv("DSN"))
+	plaidConfig, err := plaidCtl.LoadConfig()
+	if err != nil {
+		log.Fatalf("invalid Plaid configuration:\n%v", err)
+	}
+	conn, dialect, err := db.Open(os.Getenv("DSN"))
+	if err != nil {
+		log.Fatalf("error connecting to db: %v", err)
+	}
+	if os.Getenv("DB_AUTO_MIGRATE") != "false" {
+		migrateSchema(conn, dialect)
+	}
+	DBManager := db.NewDBManager(conn, dialect)
+	sealer, err := secrets.NewSealer(os.Getenv("PLAID_TOKEN_KEY"))
+	if err != nil {
+		log.Fatalf("error loading PLAID_TOKEN_KEY: %v", err)
+	}
+	alertConfig, err := alerts.LoadConfig()
 	if err != nil {
-		log.Printf("error connecting to db: %v", err)
+		log.Fatalf("invalid alert configuration:\n%v", err)
 	}
-	DBManager := db.NewDBManager(mysqlConn)
-	plaidClient := plaidCtl.NewService(createNewPlaidClient())
-	handler := handlers.MakeNewHttpHandler(plaidClient, DBManager)
+	evaluator := alerts.NewEvaluator(DBManager, alertConfig, alertConfig.Channels(DBManager)...)
+	go evaluator.RunDeliveryWorker(context.Background())
+	model := classifier.NewCache(DBManager)
+	plaidClient := plaidCtl.NewService(createNewPlaidClient(plaidConfig), DBManager, sealer, plaidConfig, evaluator, model)
+	go plaidClient.RunSyncWorker(context.Background())
+	go plaidClient.RunBalanceJob(context.Background(), plaidCtl.BalanceRefreshInterval)
+	handler := handlers.MakeNewHttpHandler(plaidClient, DBManager, evaluator, model)
 	server := &Server{
 		mux:     http.NewServeMux(),
 		handler: handler,
@@ -54,13 +86,46 @@ func (s *Server) registerRoutes() {
 
 	s.mux.HandleFunc("/get_expenses", utils.ErrorHandler(s.handler.GetExpensesSummary))
 	s.mux.HandleFunc("/get_compare", utils.ErrorHandler(s.handler.GetForecastAndExpenses))
+	s.mux.HandleFunc("/get_rollup", utils.ErrorHandler(s.handler.GetRollup))
+	s.mux.HandleFunc("/get_report", utils.ErrorHandler(s.handler.GetVarianceReport))
 	s.mux.HandleFunc("/post_expense", utils.ErrorHandler(s.handler.PostExpense))
+	s.mux.HandleFunc("/expenses", utils.ErrorHandler(s.handler.Expenses))
+	s.mux.HandleFunc("/expenses/", utils.ErrorHandler(s.handler.Expense))
 	s.mux.HandleFunc("/post_forecast", utils.ErrorHandler(s.handler.PostForecast))
+	s.mux.HandleFunc("/copy_forecast", utils.ErrorHandler(s.handler.CopyForecast))
+	s.mux.HandleFunc("/categories", utils.ErrorHandler(s.handler.Categories))
+	s.mux.HandleFunc("/categories/", utils.ErrorHandler(s.handler.Category))
+	s.mux.HandleFunc("/rules", utils.ErrorHandler(s.handler.Rules))
+	s.mux.HandleFunc("/rules/", utils.ErrorHandler(s.handler.Rule))
+	s.mux.HandleFunc("/category_mappings", utils.ErrorHandler(s.handler.CategoryMappings))
+	s.mux.HandleFunc("/category_mappings/", utils.ErrorHandler(s.handler.CategoryMapping))
+	s.mux.HandleFunc("/category_corrections", utils.ErrorHandler(s.handler.CategoryCorrections))
+	s.mux.HandleFunc("/categorize", utils.ErrorHandler(s.handler.SuggestCategories))
 	s.mux.HandleFunc("/link_user_account", utils.ErrorHandler(s.handler.LinkBank))
 	s.mux.HandleFunc("/main", utils.ErrorHandler(s.handler.GetRight))
 	s.mux.HandleFunc("/create_plaid_item", utils.ErrorHandler(s.handler.CreatePlaidBankItem))
 	s.mux.HandleFunc("/oauth_after", utils.ErrorHandler(s.handler.OauthRedirect))
+	s.mux.HandleFunc("/accounts", utils.ErrorHandler(s.handler.Accounts))
+	s.mux.HandleFunc("/accounts/", utils.ErrorHandler(s.handler.Account))
+	s.mux.HandleFunc("/balances", utils.ErrorHandler(s.handler.Balances))
+	s.mux.HandleFunc("/refresh_balances", utils.ErrorHandler(s.handler.RefreshBalances))
+	s.mux.HandleFunc("/net_worth", utils.ErrorHandler(s.handler.GetNetWorth))
+	s.mux.HandleFunc("/upcoming_payments", utils.ErrorHandler(s.handler.GetUpcomingPayments))
+	s.mux.HandleFunc("/cashflow", utils.ErrorHandler(s.handler.GetCashFlow))
+	s.mux.HandleFunc("/cashflow.ics", utils.ErrorHandler(s.handler.GetCashFlowCalendar))
+	s.mux.HandleFunc("/holdings", utils.ErrorHandler(s.handler.Holdings))
+	s.mux.HandleFunc("/investment_transactions", utils.ErrorHandler(s.handler.InvestmentTransactions))
+	s.mux.HandleFunc("/portfolio", utils.ErrorHandler(s.handler.GetPortfolio))
+	s.mux.HandleFunc("/recurring", utils.ErrorHandler(s.handler.Recurring))
+	s.mux.HandleFunc("/recurring/refresh", utils.ErrorHandler(s.handler.RefreshRecurring))
+	s.mux.HandleFunc("/alerts", utils.ErrorHandler(s.handler.Alerts))
+	s.mux.HandleFunc("/inbox", utils.ErrorHandler(s.handler.Inbox))
+	s.mux.HandleFunc("/inbox/", utils.ErrorHandler(s.handler.InboxMessage))
+	s.mux.HandleFunc("/items", utils.ErrorHandler(s.handler.Items))
+	s.mux.HandleFunc("/items/", utils.ErrorHandler(s.handler.Item))
+	s.mux.HandleFunc("/item_removals", utils.ErrorHandler(s.handler.ItemRemovals))
 	s.mux.HandleFunc("/refresh_expenses_via_plaid", utils.ErrorHandler(s.handler.UpdateExpenseData))
+	s.mux.HandleFunc("/plaid_webhook", utils.ErrorHandler(s.handler.PlaidWebhook))
 	// ... other routes
 }
 
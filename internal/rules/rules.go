@@ -0,0 +1,169 @@
+package rules
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/Seymour-creates/budget-server/internal/types"
+	"github.com/Seymour-creates/budget-server/internal/utils"
+)
+
+type compiledRule struct {
+	rule        types.Rule
+	description *regexp.Regexp
+}
+
+// Engine categorizes expenses with an ordered set of user-defined rules. A nil *Engine matches nothing.
+type Engine struct {
+	rules []compiledRule
+}
+
+// NewEngine compiles the enabled rules, ordered by position.
+func NewEngine(rules []types.Rule) (*Engine, error) {
+	engine := &Engine{}
+	for _, rule := range rules {
+		if !rule.Enabled {
+			continue
+		}
+		compiled, err := compile(rule)
+		if err != nil {
+			return nil, err
+		}
+		engine.rules = append(engine.rules, compiled)
+	}
+	sort.SliceStable(engine.rules, func(i, j int) bool {
+		return engine.rules[i].rule.Position < engine.rules[j].rule.Position
+	})
+	return engine, nil
+}
+
+// Store is the part of db.Repository rules are loaded from.
+type Store interface {
+	ListRules() ([]types.Rule, *types.HTTPError)
+}
+
+// Load builds an engine from the rules currently stored.
+func Load(store Store) (*Engine, *types.HTTPError) {
+	stored, err := store.ListRules()
+	if err != nil {
+		return nil, err
+	}
+	engine, compileErr := NewEngine(stored)
+	if compileErr != nil {
+		return nil, utils.NewHTTPError(http.StatusInternalServerError, fmt.Sprintf("error compiling rules: %v", compileErr))
+	}
+	return engine, nil
+}
+
+// Validate reports why a rule can never be used, or nil if it is well formed.
+func Validate(rule types.Rule) error {
+	_, err := compile(rule)
+	return err
+}
+
+func compile(rule types.Rule) (compiledRule, error) {
+	compiled := compiledRule{rule: rule}
+	if rule.Category == "" {
+		return compiled, fmt.Errorf("rule %q must assign a category", rule.Name)
+	}
+	if rule.Merchant == "" && rule.DescriptionPattern == "" && rule.MinAmount == nil && rule.MaxAmount == nil &&
+		rule.AccountID == "" && rule.PlaidCategory == "" {
+		return compiled, fmt.Errorf("rule %q must have at least one criterion", rule.Name)
+	}
+	if rule.MinAmount != nil && rule.MaxAmount != nil && *rule.MinAmount > *rule.MaxAmount {
+		return compiled, errors.New("min_amount must not be greater than max_amount")
+	}
+	if rule.DescriptionPattern != "" {
+		pattern, err := regexp.Compile(rule.DescriptionPattern)
+		if err != nil {
+			return compiled, fmt.Errorf("invalid description_pattern: %w", err)
+		}
+		compiled.description = pattern
+	}
+	return compiled, nil
+}
+
+// Match returns the first rule that matches expense, or nil.
+func (e *Engine) Match(expense types.Expense) *types.Rule {
+	if e == nil {
+		return nil
+	}
+	for i := range e.rules {
+		if e.rules[i].matches(expense) {
+			return &e.rules[i].rule
+		}
+	}
+	return nil
+}
+
+// Apply files expense under the first matching rule's category and adds its tags, reporting whether a rule
+// matched.
+func (e *Engine) Apply(expense *types.Expense) bool {
+	rule := e.Match(*expense)
+	if rule == nil {
+		return false
+	}
+	expense.Category = rule.Category
+	expense.CategorySource = types.CategorySourceRule
+	expense.CategoryConfidence = nil
+	expense.Tags = MergeTags(expense.Tags, rule.Tags)
+	return true
+}
+
+func (c compiledRule) matches(expense types.Expense) bool {
+	rule := c.rule
+	if rule.Merchant != "" && !containsFold(merchantName(expense), rule.Merchant) {
+		return false
+	}
+	if c.description != nil && !c.description.MatchString(expense.Description) {
+		return false
+	}
+	if rule.MinAmount != nil && expense.Amount < *rule.MinAmount {
+		return false
+	}
+	if rule.MaxAmount != nil && expense.Amount > *rule.MaxAmount {
+		return false
+	}
+	if rule.AccountID != "" && expense.AccountID != rule.AccountID {
+		return false
+	}
+	if rule.PlaidCategory != "" && !containsFold(expense.PlaidCategory, rule.PlaidCategory) {
+		return false
+	}
+	return true
+}
+
+// merchantName is the name merchant criteria match: Plaid's merchant name, falling back to the description for
+// expenses that don't have one.
+func merchantName(expense types.Expense) string {
+	if expense.Merchant != "" {
+		return expense.Merchant
+	}
+	return expense.Description
+}
+
+func containsFold(s, substr string) bool {
+	return strings.Contains(strings.ToLower(s), strings.ToLower(substr))
+}
+
+// MergeTags returns existing with any of added it doesn't already contain appended.
+func MergeTags(existing, added []string) []string {
+	merged := append([]string(nil), existing...)
+	for _, tag := range added {
+		found := false
+		for _, have := range merged {
+			if have == tag {
+				found = true
+				break
+			}
+		}
+		if !found {
+			merged = append(merged, tag)
+		}
+	}
+	return merged
+}
@@ -0,0 +1,142 @@
+package rules
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/Seymour-creates/budget-server/internal/types"
+)
+
+func amount(a float64) *float64 { return &a }
+
+func TestMatch(t *testing.T) {
+	coffee := types.Expense{
+		Description:   "SQ *BLUE BOTTLE 0042",
+		Merchant:      "Blue Bottle Coffee",
+		AccountID:     "acc-checking",
+		PlaidCategory: "Food and Drink > Restaurants > Coffee Shop",
+		Amount:        6.5,
+	}
+	handEntered := types.Expense{Description: "Blue Bottle latte", Amount: 5}
+
+	tests := []struct {
+		name    string
+		rule    types.Rule
+		expense types.Expense
+		want    bool
+	}{
+		{"description pattern", types.Rule{DescriptionPattern: `^SQ \*BLUE`}, coffee, true},
+		{"description pattern miss", types.Rule{DescriptionPattern: `^TST\*`}, coffee, false},
+		{"description pattern is case sensitive", types.Rule{DescriptionPattern: `blue bottle`}, coffee, false},
+		{"min amount", types.Rule{MinAmount: amount(6.5)}, coffee, true},
+		{"below min amount", types.Rule{MinAmount: amount(10)}, coffee, false},
+		{"max amount", types.Rule{MaxAmount: amount(6.5)}, coffee, true},
+		{"above max amount", types.Rule{MaxAmount: amount(5)}, coffee, false},
+		{"amount range", types.Rule{MinAmount: amount(5), MaxAmount: amount(10)}, coffee, true},
+		{"merchant substring ignores case", types.Rule{Merchant: "blue bottle"}, coffee, true},
+		{"merchant miss", types.Rule{Merchant: "starbucks"}, coffee, false},
+		{"merchant falls back to description", types.Rule{Merchant: "blue bottle"}, handEntered, true},
+		{"merchant fallback miss", types.Rule{Merchant: "starbucks"}, handEntered, false},
+		{"account", types.Rule{AccountID: "acc-checking"}, coffee, true},
+		{"other account", types.Rule{AccountID: "acc-savings"}, coffee, false},
+		{"plaid category", types.Rule{PlaidCategory: "coffee shop"}, coffee, true},
+		{"plaid category miss", types.Rule{PlaidCategory: "groceries"}, coffee, false},
+		{"no plaid category", types.Rule{PlaidCategory: "coffee shop"}, handEntered, false},
+		{"every criterion", types.Rule{Merchant: "blue", DescriptionPattern: "BOTTLE", MinAmount: amount(1),
+			MaxAmount: amount(10), AccountID: "acc-checking", PlaidCategory: "coffee"}, coffee, true},
+		{"one criterion misses", types.Rule{Merchant: "blue", AccountID: "acc-savings"}, coffee, false},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			rule := test.rule
+			rule.Category, rule.Enabled = "coffee", true
+			engine, err := NewEngine([]types.Rule{rule})
+			if err != nil {
+				t.Fatalf("compiling rule: %v", err)
+			}
+			if got := engine.Match(test.expense) != nil; got != test.want {
+				t.Errorf("matched = %v, want %v", got, test.want)
+			}
+		})
+	}
+}
+
+func TestFirstMatchWins(t *testing.T) {
+	engine, err := NewEngine([]types.Rule{
+		{ID: 1, Position: 2, Merchant: "bottle", Category: "dining", Enabled: true},
+		{ID: 2, Position: 1, Merchant: "blue", Category: "coffee", Tags: []string{"caffeine"}, Enabled: true},
+		{ID: 3, Position: 0, Merchant: "blue", Category: "misc", Enabled: false},
+	})
+	if err != nil {
+		t.Fatalf("compiling rules: %v", err)
+	}
+
+	expense := types.Expense{Merchant: "Blue Bottle", Category: "takeout", Tags: []string{"work"}}
+	if !engine.Apply(&expense) {
+		t.Fatal("no rule matched")
+	}
+	if expense.Category != "coffee" || strings.Join(expense.Tags, ",") != "work,caffeine" {
+		t.Errorf("applied %v %v, want coffee [work caffeine]", expense.Category, expense.Tags)
+	}
+
+	if rule := engine.Match(types.Expense{Merchant: "Green Bottle"}); rule == nil || rule.ID != 1 {
+		t.Errorf("matched %+v, want rule 1", rule)
+	}
+}
+
+func TestDisabledRulesNeverMatch(t *testing.T) {
+	engine, err := NewEngine([]types.Rule{
+		// Disabled rules aren't compiled, so a broken one doesn't stop the rest loading.
+		{Merchant: "blue", DescriptionPattern: "(", Category: "coffee", Enabled: false},
+	})
+	if err != nil {
+		t.Fatalf("compiling rules: %v", err)
+	}
+	expense := types.Expense{Merchant: "Blue Bottle", Category: "takeout"}
+	if engine.Apply(&expense) || expense.Category != "takeout" {
+		t.Errorf("disabled rule applied: %+v", expense)
+	}
+}
+
+func TestNilEngineMatchesNothing(t *testing.T) {
+	var engine *Engine
+	if engine.Match(types.Expense{Merchant: "Blue Bottle"}) != nil {
+		t.Error("nil engine matched")
+	}
+}
+
+func TestValidate(t *testing.T) {
+	tests := []struct {
+		name    string
+		rule    types.Rule
+		wantErr string
+	}{
+		{"valid", types.Rule{Merchant: "blue", Category: "coffee"}, ""},
+		{"no category", types.Rule{Merchant: "blue"}, "must assign a category"},
+		{"no criteria", types.Rule{Name: "everything", Category: "coffee", Tags: []string{"x"}}, "at least one criterion"},
+		{"bad regex", types.Rule{DescriptionPattern: "([a-z", Category: "coffee"}, "invalid description_pattern"},
+		{"inverted amount range", types.Rule{MinAmount: amount(10), MaxAmount: amount(5), Category: "coffee"},
+			"min_amount must not be greater"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			err := Validate(test.rule)
+			if test.wantErr == "" {
+				if err != nil {
+					t.Errorf("Validate = %v, want nil", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), test.wantErr) {
+				t.Errorf("Validate = %v, want error containing %q", err, test.wantErr)
+			}
+		})
+	}
+}
+
+func TestMergeTags(t *testing.T) {
+	got := MergeTags([]string{"work", "coffee"}, []string{"coffee", "caffeine"})
+	if strings.Join(got, ",") != "work,coffee,caffeine" {
+		t.Errorf("MergeTags = %v", got)
+	}
+}
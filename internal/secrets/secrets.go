@@ -0,0 +1,100 @@
+package secrets
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"fmt"
+)
+
+// KeySize is the length in bytes of both the master key and the per-secret data keys (AES-256).
+const KeySize = 32
+
+// Sealer envelope-encrypts secrets: each value is encrypted under a fresh random data key, and that data key is
+// in turn encrypted under the master key. Only the wrapped data key is stored, so rotating the master key means
+// re-wrapping keys rather than re-encrypting every secret.
+type Sealer struct {
+	master cipher.AEAD
+}
+
+// NewSealer returns a Sealer using the base64 encoded master key from configuration.
+func NewSealer(encodedKey string) (*Sealer, error) {
+	if encodedKey == "" {
+		return nil, errors.New("master key is not set")
+	}
+	key, err := base64.StdEncoding.DecodeString(encodedKey)
+	if err != nil {
+		return nil, fmt.Errorf("decoding master key: %w", err)
+	}
+	if len(key) != KeySize {
+		return nil, fmt.Errorf("master key must be %d bytes, got %d", KeySize, len(key))
+	}
+	master, err := newAEAD(key)
+	if err != nil {
+		return nil, err
+	}
+	return &Sealer{master: master}, nil
+}
+
+// Seal encrypts plaintext and returns the ciphertext along with the wrapped data key needed to open it.
+func (s *Sealer) Seal(plaintext string) (ciphertext, wrappedKey []byte, err error) {
+	dataKey := make([]byte, KeySize)
+	if _, err := rand.Read(dataKey); err != nil {
+		return nil, nil, fmt.Errorf("generating data key: %w", err)
+	}
+	data, err := newAEAD(dataKey)
+	if err != nil {
+		return nil, nil, err
+	}
+	if ciphertext, err = seal(data, []byte(plaintext)); err != nil {
+		return nil, nil, err
+	}
+	if wrappedKey, err = seal(s.master, dataKey); err != nil {
+		return nil, nil, err
+	}
+	return ciphertext, wrappedKey, nil
+}
+
+// Open reverses Seal.
+func (s *Sealer) Open(ciphertext, wrappedKey []byte) (string, error) {
+	dataKey, err := open(s.master, wrappedKey)
+	if err != nil {
+		return "", fmt.Errorf("unwrapping data key: %w", err)
+	}
+	data, err := newAEAD(dataKey)
+	if err != nil {
+		return "", err
+	}
+	plaintext, err := open(data, ciphertext)
+	if err != nil {
+		return "", fmt.Errorf("decrypting secret: %w", err)
+	}
+	return string(plaintext), nil
+}
+
+func newAEAD(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, fmt.Errorf("creating cipher: %w", err)
+	}
+	return cipher.NewGCM(block)
+}
+
+// seal prepends a random nonce to the GCM ciphertext.
+func seal(aead cipher.AEAD, plaintext []byte) ([]byte, error) {
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, fmt.Errorf("generating nonce: %w", err)
+	}
+	return aead.Seal(nonce, nonce, plaintext, nil), nil
+}
+
+func open(aead cipher.AEAD, sealed []byte) ([]byte, error) {
+	if len(sealed) < aead.NonceSize() {
+		return nil, errors.New("ciphertext too short")
+	}
+	nonce, ciphertext := sealed[:aead.NonceSize()], sealed[aead.NonceSize():]
+	return aead.Open(nil, nonce, ciphertext, nil)
+}
@@ -0,0 +1,92 @@
+package secrets
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"strings"
+	"testing"
+)
+
+func newTestSealer(t *testing.T) *Sealer {
+	t.Helper()
+	key := make([]byte, KeySize)
+	if _, err := rand.Read(key); err != nil {
+		t.Fatal(err)
+	}
+	sealer, err := NewSealer(base64.StdEncoding.EncodeToString(key))
+	if err != nil {
+		t.Fatalf("creating sealer: %v", err)
+	}
+	return sealer
+}
+
+func TestSealOpenRoundTrip(t *testing.T) {
+	sealer := newTestSealer(t)
+	const token = "access-sandbox-8ab976e6-64bc-4b38-98f7-731e7a349970"
+
+	ciphertext, wrappedKey, err := sealer.Seal(token)
+	if err != nil {
+		t.Fatalf("sealing: %v", err)
+	}
+	if strings.Contains(string(ciphertext), token) || strings.Contains(string(wrappedKey), token) {
+		t.Fatal("sealed token contains the plaintext")
+	}
+	opened, err := sealer.Open(ciphertext, wrappedKey)
+	if err != nil || opened != token {
+		t.Fatalf("opened %q, %v, want %q", opened, err, token)
+	}
+
+	// Each seal uses a fresh data key and nonce.
+	again, _, err := sealer.Seal(token)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(again) == string(ciphertext) {
+		t.Error("sealing the same token twice gave the same ciphertext")
+	}
+}
+
+func TestOpenRejectsTampering(t *testing.T) {
+	sealer := newTestSealer(t)
+	ciphertext, wrappedKey, err := sealer.Seal("access-sandbox-token")
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name                   string
+		ciphertext, wrappedKey []byte
+	}{
+		{"ciphertext", flipLastByte(ciphertext), wrappedKey},
+		{"wrapped key", ciphertext, flipLastByte(wrappedKey)},
+		{"truncated", ciphertext[:4], wrappedKey},
+	}
+	for _, test := range tests {
+		if opened, err := sealer.Open(test.ciphertext, test.wrappedKey); err == nil {
+			t.Errorf("%v: opened tampered secret as %q", test.name, opened)
+		}
+	}
+}
+
+func TestOpenWithWrongKey(t *testing.T) {
+	ciphertext, wrappedKey, err := newTestSealer(t).Seal("access-sandbox-token")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if opened, err := newTestSealer(t).Open(ciphertext, wrappedKey); err == nil {
+		t.Errorf("opened a secret under another master key as %q", opened)
+	}
+}
+
+func TestNewSealerRejectsBadKeys(t *testing.T) {
+	for _, key := range []string{"", "not base64!", base64.StdEncoding.EncodeToString(make([]byte, 16))} {
+		if _, err := NewSealer(key); err == nil {
+			t.Errorf("NewSealer(%q) succeeded", key)
+		}
+	}
+}
+
+func flipLastByte(b []byte) []byte {
+	flipped := append([]byte{}, b...)
+	flipped[len(flipped)-1] ^= 0xff
+	return flipped
+}
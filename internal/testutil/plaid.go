@@ -0,0 +1,105 @@
+package testutil
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/Seymour-creates/budget-server/internal/db"
+	"github.com/Seymour-creates/budget-server/internal/plaidCtl"
+	"github.com/Seymour-creates/budget-server/internal/plaidfake"
+	"github.com/Seymour-creates/budget-server/internal/secrets"
+	"github.com/Seymour-creates/budget-server/internal/types"
+	"github.com/plaid/plaid-go/plaid"
+)
+
+// Plaid is a plaidCtl.Service talking over HTTP to a fake Plaid API and storing into a fresh in-memory
+// database.
+type Plaid struct {
+	Service *plaidCtl.Service
+	Fake    *plaidfake.Server
+	DB      *db.Manager
+}
+
+// NewPlaid starts a fake Plaid API loaded with plaidfake.DefaultFixture and returns a Service pointed at it.
+// Webhooks the fake sends are delivered to webhookURL, or straight to the Service's HandleWebhook when it is
+// empty. Everything started here is shut down when the test ends.
+func NewPlaid(t testing.TB, webhookURL string) *Plaid {
+	t.Helper()
+	p := &Plaid{DB: NewDB(t), Fake: plaidfake.New(plaidfake.DefaultFixture())}
+	fakeServer := httptest.NewServer(p.Fake)
+	t.Cleanup(fakeServer.Close)
+
+	if webhookURL == "" {
+		receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			body, _ := io.ReadAll(r.Body)
+			if httpErr := p.Service.HandleWebhook(r.Context(), r.Header.Get("Plaid-Verification"), body); httpErr != nil {
+				http.Error(w, httpErr.Message, httpErr.StatusCode)
+			}
+		}))
+		t.Cleanup(receiver.Close)
+		webhookURL = receiver.URL
+	}
+
+	clientOptions := plaid.NewConfiguration()
+	clientOptions.UseEnvironment(plaid.Environment(fakeServer.URL))
+	config := plaidCtl.Config{
+		ClientName:   "Test",
+		Language:     "en",
+		CountryCodes: []plaid.CountryCode{plaid.COUNTRYCODE_US},
+		Products:     []plaid.Products{plaid.PRODUCTS_TRANSACTIONS},
+		WebhookURL:   webhookURL,
+	}
+	p.Service = plaidCtl.NewService(plaid.NewAPIClient(clientOptions), p.DB, NewSealer(t), config, nil, nil)
+	return p
+}
+
+// NewSealer returns a Sealer with a random key.
+func NewSealer(t testing.TB) *secrets.Sealer {
+	t.Helper()
+	key := make([]byte, secrets.KeySize)
+	if _, err := rand.Read(key); err != nil {
+		t.Fatal(err)
+	}
+	sealer, err := secrets.NewSealer(base64.StdEncoding.EncodeToString(key))
+	if err != nil {
+		t.Fatalf("creating sealer: %v", err)
+	}
+	return sealer
+}
+
+// LinkItem creates a Link token, links an item at the fake's default institution as a user finishing Link
+// would, and exchanges the public token for it.
+func (p *Plaid) LinkItem(t testing.TB) *types.PlaidItem {
+	t.Helper()
+	linkToken, err := p.Service.LinkBank(httptest.NewRequest(http.MethodGet, "/link_user_account", nil))
+	if err != nil || linkToken == "" {
+		t.Fatalf("creating link token: %q, %v", linkToken, err)
+	}
+	publicToken, err := p.Fake.LinkItem("ins_fake")
+	if err != nil {
+		t.Fatalf("linking item: %v", err)
+	}
+	item, err := p.Service.CreateItem(publicToken, httptest.NewRequest(http.MethodPost, "/create_plaid_item", nil))
+	if err != nil {
+		t.Fatalf("exchanging public token: %v", err)
+	}
+	return item
+}
+
+// Expenses returns the stored Plaid expenses by transaction ID.
+func (p *Plaid) Expenses(t testing.TB) map[string]types.Expense {
+	t.Helper()
+	expenses, httpErr := p.DB.ListExpenses(types.ExpenseFilter{Source: types.ExpenseSourcePlaid})
+	if httpErr != nil {
+		t.Fatalf("listing expenses: %v", httpErr.Message)
+	}
+	byID := map[string]types.Expense{}
+	for _, expense := range expenses {
+		byID[expense.ExternalID] = expense
+	}
+	return byID
+}
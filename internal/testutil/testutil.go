@@ -0,0 +1,27 @@
+// Package testutil builds the fixtures that tests in several packages share.
+package testutil
+
+import (
+	"testing"
+
+	"github.com/Seymour-creates/budget-server/internal/db"
+)
+
+// NewDB returns a Manager over a fresh in-memory SQLite database with every migration applied. The database is
+// closed when the test ends.
+func NewDB(t testing.TB) *db.Manager {
+	t.Helper()
+	conn, dialect, err := db.Open("sqlite::memory:")
+	if err != nil {
+		t.Fatalf("opening in-memory db: %v", err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	migrator, err := db.NewMigrator(conn, dialect)
+	if err != nil {
+		t.Fatalf("loading migrations: %v", err)
+	}
+	if err := migrator.Up(); err != nil {
+		t.Fatalf("migrating: %v", err)
+	}
+	return db.NewDBManager(conn, dialect)
+}
@@ -5,23 +5,610 @@ import (
 	"time"
 )
 
+// Expense sources recorded alongside ExternalID.
+const (
+	ExpenseSourcePlaid = "plaid"
+	ExpenseSourceCLI   = "cli"
+)
+
+// How an expense's category was chosen. Only categories a person chose, directly or through a rule, are used
+// to train the category model.
+const (
+	CategorySourceUser    = "user"
+	CategorySourceRule    = "rule"
+	CategorySourcePlaid   = "plaid"
+	CategorySourceModel   = "model"
+	CategorySourceDefault = "default"
+)
+
 type Expense struct {
-	Date        time.Time `json:"date"`
+	ID int64 `json:"id"`
+	// Source and ExternalID identify the expense in the system it was imported from: the Plaid
+	// transaction_id for synced transactions, or an optional client-supplied ID for CLI posts.
+	Source     string `json:"source,omitempty"`
+	ExternalID string `json:"external_id,omitempty"`
+	// PendingExternalID is the ID of the pending transaction this posted one replaces, if any.
+	PendingExternalID string    `json:"pending_external_id,omitempty"`
+	Date              time.Time `json:"date"`
+	Description       string    `json:"description"`
+	Merchant          string    `json:"merchant,omitempty"`
+	AccountID         string    `json:"account_id,omitempty"`
+	// PlaidCategory is Plaid's legacy category hierarchy for the transaction, joined with " > ".
+	PlaidCategory string `json:"plaid_category,omitempty"`
+	// PFCPrimary, PFCDetailed and PFCConfidence are Plaid's personal finance category for the transaction and
+	// how confident Plaid is in it.
+	PFCPrimary    string  `json:"pfc_primary,omitempty"`
+	PFCDetailed   string  `json:"pfc_detailed,omitempty"`
+	PFCConfidence string  `json:"pfc_confidence,omitempty"`
+	Amount        float64 `json:"amount"`
+	Category      string  `json:"category"`
+	// CategorySource records how Category was chosen, and CategoryConfidence how sure the category model was
+	// when it chose it.
+	CategorySource     string   `json:"category_source,omitempty"`
+	CategoryConfidence *float64 `json:"category_confidence,omitempty"`
+	Tags               []string `json:"tags,omitempty"`
+}
+
+// ExpensePatch holds the fields of an expense to change; nil fields are left as they are.
+type ExpensePatch struct {
+	Date        *time.Time `json:"date"`
+	Description *string    `json:"description"`
+	Amount      *float64   `json:"amount"`
+	Category    *string    `json:"category"`
+	Tags        *[]string  `json:"tags"`
+	// CategorySource and CategoryConfidence say how a new Category was chosen. They are set by the server,
+	// never by clients; a category change without a source is a manual correction.
+	CategorySource     *string  `json:"-"`
+	CategoryConfidence *float64 `json:"-"`
+}
+
+// ExpenseFilter narrows an expense listing. Zero values leave that dimension unfiltered.
+type ExpenseFilter struct {
+	From     time.Time
+	To       time.Time
+	Category string
+	Source   string
+	// CategorySource matches how the category was chosen, e.g. types.CategorySourceModel.
+	CategorySource string
+	Description    string
+	AccountID      string
+	MinAmount      *float64
+	MaxAmount      *float64
+	// BudgetOnly leaves out expenses on accounts that are hidden or excluded from budgets.
+	BudgetOnly bool
+	Limit      int
+	Offset     int
+}
+
+// Category is a budget category. Expenses and forecasts refer to categories by slug.
+type Category struct {
+	ID       int64  `json:"id"`
+	Slug     string `json:"slug"`
+	Name     string `json:"name"`
+	Parent   string `json:"parent,omitempty"`
+	Archived bool   `json:"archived"`
+	Color    string `json:"color,omitempty"`
+}
+
+// CategoryPatch holds the fields of a category to change; nil fields are left as they are. Renaming the slug
+// keeps every expense and forecast attached to the category. An empty Parent clears the parent.
+type CategoryPatch struct {
+	Slug     *string `json:"slug"`
+	Name     *string `json:"name"`
+	Parent   *string `json:"parent"`
+	Archived *bool   `json:"archived"`
+	Color    *string `json:"color"`
+}
+
+// CategoryCorrection records a person moving an expense from one category to another.
+type CategoryCorrection struct {
+	ID          int64     `json:"id"`
+	ExpenseID   int64     `json:"expense_id"`
+	Merchant    string    `json:"merchant,omitempty"`
 	Description string    `json:"description"`
-	Amount      float64   `json:"amount"`
-	Category    string    `json:"category"`
+	From        string    `json:"from"`
+	To          string    `json:"to"`
+	CreatedAt   time.Time `json:"created_at"`
+}
+
+// CategorySuggestion is a category the category model thinks an expense belongs in, with its confidence
+// between 0 and 1.
+type CategorySuggestion struct {
+	Category   string  `json:"category"`
+	Confidence float64 `json:"confidence"`
+}
+
+// CategoryMapping files transactions with a Plaid personal finance category under a budget category. A mapping
+// with an empty Detailed covers every detailed category under Primary that has no mapping of its own.
+// MinConfidence, if set, is the lowest Plaid confidence level (LOW, MEDIUM, HIGH, VERY_HIGH) the mapping is
+// trusted at; below it the legacy Plaid category is used instead.
+type CategoryMapping struct {
+	ID            int64  `json:"id"`
+	Primary       string `json:"primary"`
+	Detailed      string `json:"detailed,omitempty"`
+	MinConfidence string `json:"min_confidence,omitempty"`
+	Category      string `json:"category"`
+}
+
+// Rule assigns a category, and optionally tags, to expenses matching all of its non-empty criteria. Rules are
+// tried in Position order and the first match wins.
+type Rule struct {
+	ID       int64  `json:"id"`
+	Position int    `json:"position"`
+	Name     string `json:"name,omitempty"`
+	// Merchant matches a case-insensitive substring of the expense's merchant name, or of its description when
+	// the expense has no merchant, as with expenses entered by hand.
+	Merchant string `json:"merchant,omitempty"`
+	// DescriptionPattern is a regular expression matched against the description.
+	DescriptionPattern string   `json:"description_pattern,omitempty"`
+	MinAmount          *float64 `json:"min_amount,omitempty"`
+	MaxAmount          *float64 `json:"max_amount,omitempty"`
+	AccountID          string   `json:"account_id,omitempty"`
+	// PlaidCategory matches a case-insensitive substring of the expense's Plaid category.
+	PlaidCategory string   `json:"plaid_category,omitempty"`
+	Category      string   `json:"category"`
+	Tags          []string `json:"tags,omitempty"`
+	Enabled       bool     `json:"enabled"`
 }
 
 type Forecast struct {
-	Amount   float64 `json:"amount"`
-	Category string  `json:"category"`
+	// Period is the first day of the month the forecast plans for.
+	Period   time.Time `json:"period"`
+	Amount   float64   `json:"amount"`
+	Category string    `json:"category"`
+	// Source is ForecastSourceLiability for the minimum payment due on a liability account, which is planned
+	// for the month it falls due rather than stored, or ForecastSourceRecurring for a dated bill or income
+	// expected from a recurring stream in a cash-flow projection. AccountID, StreamID and DueDate say which
+	// payment it is; Amount is negative for money coming in.
+	Source      string     `json:"source,omitempty"`
+	AccountID   string     `json:"account_id,omitempty"`
+	StreamID    string     `json:"stream_id,omitempty"`
+	Description string     `json:"description,omitempty"`
+	DueDate     *time.Time `json:"due_date,omitempty"`
 }
 
-type MonthlyBudgetInsights struct {
+// Sources of forecast entries that aren't stored.
+const (
+	// ForecastSourceLiability marks a forecast entry planned from a liability's minimum payment.
+	ForecastSourceLiability = "liability"
+	// ForecastSourceRecurring marks a forecast entry expected from a recurring stream.
+	ForecastSourceRecurring = "recurring"
+)
+
+// Period is an inclusive range of days.
+type Period struct {
+	Start time.Time `json:"start"`
+	End   time.Time `json:"end"`
+}
+
+// Rollup intervals accepted by period queries.
+const (
+	IntervalWeek    = "week"
+	IntervalMonth   = "month"
+	IntervalQuarter = "quarter"
+	IntervalYear    = "year"
+)
+
+// BudgetInsights holds a period's expenses and the forecasts for every month the period touches.
+type BudgetInsights struct {
+	Period   Period     `json:"period"`
 	Expenses []Expense  `json:"expenses"`
 	Forecast []Forecast `json:"forecast"`
 }
 
+// Rollup totals spending for one interval of a period.
+type Rollup struct {
+	Period     Period             `json:"period"`
+	Total      float64            `json:"total"`
+	ByCategory map[string]float64 `json:"by_category"`
+}
+
+// TransactionSync is the set of changes pulled from Plaid's /transactions/sync for one item,
+// along with the cursor to resume from once they are applied.
+type TransactionSync struct {
+	Added    []Expense
+	Modified []Expense
+	Removed  []string
+	Cursor   string
+}
+
+// Plaid item statuses.
+const (
+	ItemStatusActive = "active"
+	// ItemStatusLoginRequired means the bank needs the user to sign in again through Link update mode.
+	ItemStatusLoginRequired = "login_required"
+	// ItemStatusPendingExpiration means the item's consent expires soon and should be renewed through Link.
+	ItemStatusPendingExpiration = "pending_expiration"
+	// ItemStatusRevoked means the user withdrew access at their bank; the item can only be relinked.
+	ItemStatusRevoked = "revoked"
+	// ItemStatusError means the last sync failed for some other reason, such as the bank being down. Syncs
+	// keep retrying it.
+	ItemStatusError = "error"
+)
+
+// PlaidItem is a linked bank login. The access token is stored envelope-encrypted and is never serialized.
+type PlaidItem struct {
+	ItemID      string `json:"item_id"`
+	Institution string `json:"institution"`
+	Status      string `json:"status"`
+	// ErrorCode is the Plaid error code behind a status other than active, if there was one.
+	ErrorCode            string     `json:"error_code,omitempty"`
+	LastSyncedAt         *time.Time `json:"last_synced_at,omitempty"`
+	CreatedAt            time.Time  `json:"created_at"`
+	EncryptedAccessToken []byte     `json:"-"`
+	EncryptedKey         []byte     `json:"-"`
+}
+
+// NeedsRelink reports whether the item can't be synced until the user goes through Link again.
+func (item PlaidItem) NeedsRelink() bool {
+	return item.Status == ItemStatusLoginRequired || item.Status == ItemStatusRevoked
+}
+
+// What to do with the expenses imported from an item when it is unlinked.
+const (
+	// ExpensesKeep leaves them as they are.
+	ExpensesKeep = "keep"
+	// ExpensesAnonymize keeps their dates, amounts and categories for past budgets, but strips their
+	// descriptions, merchants, Plaid's categories and Plaid's transaction IDs, so relinking the bank can't
+	// restore them.
+	ExpensesAnonymize = "anonymize"
+	// ExpensesDelete deletes them.
+	ExpensesDelete = "delete"
+)
+
+// ItemRemoval records a linked item being removed, and what was done with its accounts and expenses.
+type ItemRemoval struct {
+	ID               int64  `json:"id"`
+	ItemID           string `json:"item_id"`
+	Institution      string `json:"institution"`
+	ExpenseAction    string `json:"expense_action"`
+	AccountsClosed   int    `json:"accounts_closed"`
+	ExpensesAffected int    `json:"expenses_affected"`
+	// ExpensesLeftBehind counts Plaid expenses that couldn't be traced to any item, and so were neither
+	// anonymized nor deleted.
+	ExpensesLeftBehind int       `json:"expenses_left_behind,omitempty"`
+	RemovedAt          time.Time `json:"removed_at"`
+}
+
+// ItemSyncResult reports what a sync did for one item, or why it didn't.
+type ItemSyncResult struct {
+	ItemID      string `json:"item_id"`
+	Institution string `json:"institution"`
+	Status      string `json:"status"`
+	ErrorCode   string `json:"error_code,omitempty"`
+	Skipped     bool   `json:"skipped,omitempty"`
+	Added       int    `json:"added"`
+	Modified    int    `json:"modified"`
+	Removed     int    `json:"removed"`
+	// Holdings and InvestmentTransactions count what was imported from Plaid Investments, when it is enabled.
+	Holdings               int `json:"holdings,omitempty"`
+	InvestmentTransactions int `json:"investment_transactions,omitempty"`
+}
+
+// Account is a bank account behind a linked item, as reported by Plaid's /accounts/get. Expenses refer to
+// accounts by AccountID.
+type Account struct {
+	AccountID    string `json:"account_id"`
+	ItemID       string `json:"item_id"`
+	Name         string `json:"name"`
+	OfficialName string `json:"official_name,omitempty"`
+	Mask         string `json:"mask,omitempty"`
+	Type         string `json:"type"`
+	Subtype      string `json:"subtype,omitempty"`
+	Currency     string `json:"currency,omitempty"`
+	// Hidden accounts are left out of account listings and budgets; ExcludeFromBudget keeps an account listed
+	// but leaves its expenses out of budget reports.
+	Hidden            bool `json:"hidden"`
+	ExcludeFromBudget bool `json:"exclude_from_budget"`
+	// Manual accounts, such as cash or property, belong to no item and have their balances entered by hand.
+	Manual bool `json:"manual"`
+	// ClosedAt is when the account's item was unlinked. Closed accounts are still listed, with their history,
+	// but no longer sync and stop counting towards net worth.
+	ClosedAt *time.Time `json:"closed_at,omitempty"`
+}
+
+// Account types, as Plaid names them. Manual accounts use the same types; cash and property are "other".
+const (
+	AccountTypeDepository = "depository"
+	AccountTypeCredit     = "credit"
+	AccountTypeLoan       = "loan"
+	AccountTypeInvestment = "investment"
+	AccountTypeOther      = "other"
+)
+
+// IsLiability reports whether the account's balance is money owed rather than money held.
+func (account Account) IsLiability() bool {
+	return account.Type == AccountTypeCredit || account.Type == AccountTypeLoan
+}
+
+// Balance is an account's balance as of the end of a day. Liability balances are the positive amount owed.
+type Balance struct {
+	AccountID string    `json:"account_id"`
+	Date      time.Time `json:"date"`
+	Current   float64   `json:"current"`
+	Available *float64  `json:"available,omitempty"`
+	Limit     *float64  `json:"limit,omitempty"`
+	Currency  string    `json:"currency,omitempty"`
+}
+
+// AccountBalance pairs an account with its latest balance, if one has been recorded.
+type AccountBalance struct {
+	Account
+	Balance *Balance `json:"balance"`
+}
+
+// NetWorthPoint is net worth at the end of one day: asset balances minus liability balances.
+type NetWorthPoint struct {
+	Date        time.Time `json:"date"`
+	Assets      float64   `json:"assets"`
+	Liabilities float64   `json:"liabilities"`
+	NetWorth    float64   `json:"net_worth"`
+}
+
+// NetWorthReport is a daily net worth series over a period.
+type NetWorthReport struct {
+	Period Period          `json:"period"`
+	Points []NetWorthPoint `json:"points"`
+}
+
+// CashFlowDay is the projected balance at the end of one day, after the money expected in and out that day.
+type CashFlowDay struct {
+	Date    time.Time `json:"date"`
+	Inflow  float64   `json:"inflow"`
+	Outflow float64   `json:"outflow"`
+	Balance float64   `json:"balance"`
+}
+
+// CashFlowProjection simulates the cash in depository accounts day by day over a period, starting from their
+// latest balances and applying the dated bills, pay and minimum payments expected in Events.
+type CashFlowProjection struct {
+	Period          Period        `json:"period"`
+	StartingBalance float64       `json:"starting_balance"`
+	Events          []Forecast    `json:"events"`
+	Days            []CashFlowDay `json:"days"`
+	// LowestBalance is the lowest end-of-day balance projected, on LowestDate, the first day it is reached.
+	LowestBalance float64   `json:"lowest_balance"`
+	LowestDate    time.Time `json:"lowest_date"`
+}
+
+// Liability kinds, after the sections of Plaid's /liabilities/get response.
+const (
+	LiabilityKindCredit   = "credit"
+	LiabilityKindStudent  = "student"
+	LiabilityKindMortgage = "mortgage"
+)
+
+// Liability holds the repayment terms of a credit card or loan account, as reported by Plaid Liabilities.
+type Liability struct {
+	AccountID string `json:"account_id"`
+	Kind      string `json:"kind"`
+	// APR is the purchase APR of a card, or the interest rate of a loan, as a percentage.
+	APR               *float64   `json:"apr,omitempty"`
+	MinimumPayment    *float64   `json:"minimum_payment,omitempty"`
+	NextDueDate       *time.Time `json:"next_due_date,omitempty"`
+	StatementBalance  *float64   `json:"statement_balance,omitempty"`
+	LastPaymentAmount *float64   `json:"last_payment_amount,omitempty"`
+	LastPaymentDate   *time.Time `json:"last_payment_date,omitempty"`
+	Overdue           bool       `json:"overdue"`
+}
+
+// UpcomingPayment is the next payment due on a liability account.
+type UpcomingPayment struct {
+	Liability
+	AccountName string `json:"account_name"`
+	Mask        string `json:"mask,omitempty"`
+}
+
+// Security is a stock, fund, bond, cash position or other instrument held in an investment account, as
+// reported by Plaid Investments.
+type Security struct {
+	SecurityID       string     `json:"security_id"`
+	Name             string     `json:"name"`
+	Ticker           string     `json:"ticker,omitempty"`
+	Type             string     `json:"type,omitempty"`
+	IsCashEquivalent bool       `json:"is_cash_equivalent"`
+	ClosePrice       *float64   `json:"close_price,omitempty"`
+	ClosePriceAsOf   *time.Time `json:"close_price_as_of,omitempty"`
+	Currency         string     `json:"currency,omitempty"`
+}
+
+// Holding is how much of one security an investment account held at the end of one day.
+type Holding struct {
+	AccountID        string    `json:"account_id"`
+	SecurityID       string    `json:"security_id"`
+	Date             time.Time `json:"date"`
+	Quantity         float64   `json:"quantity"`
+	InstitutionPrice float64   `json:"institution_price"`
+	InstitutionValue float64   `json:"institution_value"`
+	CostBasis        *float64  `json:"cost_basis,omitempty"`
+	Currency         string    `json:"currency,omitempty"`
+	Security         *Security `json:"security,omitempty"`
+}
+
+// InvestmentTransaction is a buy, sell, dividend, fee or transfer in an investment account. They are kept apart
+// from expenses, so they never count towards the budget. Amount is positive for money leaving the account, as
+// with expenses.
+type InvestmentTransaction struct {
+	InvestmentTransactionID string    `json:"investment_transaction_id"`
+	AccountID               string    `json:"account_id"`
+	SecurityID              string    `json:"security_id,omitempty"`
+	Date                    time.Time `json:"date"`
+	Name                    string    `json:"name"`
+	Type                    string    `json:"type"`
+	Subtype                 string    `json:"subtype,omitempty"`
+	Quantity                float64   `json:"quantity"`
+	Price                   float64   `json:"price"`
+	Amount                  float64   `json:"amount"`
+	Fees                    *float64  `json:"fees,omitempty"`
+	Currency                string    `json:"currency,omitempty"`
+}
+
+// InvestmentTransactionFilter narrows a listing of investment transactions. Zero fields don't filter.
+type InvestmentTransactionFilter struct {
+	AccountID string
+	From      time.Time
+	To        time.Time
+}
+
+// InvestmentSync is one import from Plaid Investments for an item: the holdings of its investment accounts on
+// Date, replacing any recorded for them that day, and its recent investment transactions, along with the
+// securities both refer to.
+type InvestmentSync struct {
+	Date         time.Time
+	AccountIDs   []string
+	Securities   []Security
+	Holdings     []Holding
+	Transactions []InvestmentTransaction
+}
+
+// PortfolioPoint is the value of an account's holdings, or of all of them, at the end of one day.
+type PortfolioPoint struct {
+	Date  time.Time `json:"date"`
+	Value float64   `json:"value"`
+}
+
+// PortfolioSeries is the daily value of one investment account's holdings.
+type PortfolioSeries struct {
+	AccountID string           `json:"account_id"`
+	Name      string           `json:"name"`
+	Points    []PortfolioPoint `json:"points"`
+}
+
+// PortfolioReport is the daily value of each investment account over a period, and their total.
+type PortfolioReport struct {
+	Period   Period            `json:"period"`
+	Accounts []PortfolioSeries `json:"accounts"`
+	Total    []PortfolioPoint  `json:"total"`
+}
+
+// RecurringStream is a series of transactions that repeat on a regular schedule, such as a subscription, a
+// bill or a paycheck. Amounts are positive in both directions.
+type RecurringStream struct {
+	StreamID string `json:"stream_id"`
+	// Source says whether Plaid found the stream or it was detected locally from expenses.
+	Source    string `json:"source"`
+	AccountID string `json:"account_id,omitempty"`
+	Merchant  string `json:"merchant"`
+	// Description and Category are those of the stream's latest transaction.
+	Description      string     `json:"description"`
+	Category         string     `json:"category,omitempty"`
+	Direction        string     `json:"direction"`
+	Frequency        string     `json:"frequency"`
+	AverageAmount    float64    `json:"average_amount"`
+	LastAmount       float64    `json:"last_amount"`
+	FirstDate        time.Time  `json:"first_date"`
+	LastDate         time.Time  `json:"last_date"`
+	NextDate         *time.Time `json:"next_date,omitempty"`
+	TransactionCount int        `json:"transaction_count"`
+	Active           bool       `json:"is_active"`
+	UpdatedAt        time.Time  `json:"updated_at"`
+	// PriceIncrease and MissedPayment are worked out when streams are listed: the latest amount of an outflow
+	// is well above its average, or the next transaction is overdue.
+	PriceIncrease bool `json:"price_increase"`
+	MissedPayment bool `json:"missed_payment"`
+}
+
+// Where a recurring stream was found.
+const (
+	StreamSourcePlaid = "plaid"
+	StreamSourceLocal = "local"
+)
+
+// Recurring stream directions: money leaving, as for bills and subscriptions, or coming in, as for pay.
+const (
+	StreamOutflow = "outflow"
+	StreamInflow  = "inflow"
+)
+
+// Recurring stream frequencies. Plaid reports all but annually, which is only detected locally.
+const (
+	FrequencyWeekly      = "weekly"
+	FrequencyBiweekly    = "biweekly"
+	FrequencySemiMonthly = "semi_monthly"
+	FrequencyMonthly     = "monthly"
+	FrequencyAnnually    = "annually"
+	FrequencyUnknown     = "unknown"
+)
+
+// Alert kinds.
+const (
+	// AlertBudget is raised when spending in a category reaches a percentage of its forecast.
+	AlertBudget = "budget"
+	// AlertLargeTransaction is raised for a single expense of at least the configured amount.
+	AlertLargeTransaction = "large_transaction"
+	// AlertLowBalance is raised when a depository account's balance falls below the configured amount.
+	AlertLowBalance = "low_balance"
+)
+
+// Alert is a notice that spending or a balance crossed a threshold. Key names what crossed which threshold in
+// which period, so an alert is raised only once per period however often thresholds are checked.
+type Alert struct {
+	ID        int64  `json:"id"`
+	Key       string `json:"key"`
+	Kind      string `json:"kind"`
+	Period    Period `json:"period"`
+	Category  string `json:"category,omitempty"`
+	AccountID string `json:"account_id,omitempty"`
+	ExpenseID int64  `json:"expense_id,omitempty"`
+	// Amount is what crossed Threshold: the category's spending, the expense's amount or the balance.
+	Amount    float64   `json:"amount"`
+	Threshold float64   `json:"threshold"`
+	Message   string    `json:"message"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// AlertDelivery is a recorded alert still to be sent through one notification channel. A failed send is retried
+// from NextAttemptAt, with Attempts and LastError saying how it has gone so far.
+type AlertDelivery struct {
+	Alert
+	Channel       string    `json:"channel"`
+	Attempts      int       `json:"attempts"`
+	LastError     string    `json:"last_error,omitempty"`
+	NextAttemptAt time.Time `json:"next_attempt_at"`
+}
+
+// InboxMessage is an alert delivered to the in-app inbox.
+type InboxMessage struct {
+	Alert
+	ReadAt *time.Time `json:"read_at"`
+}
+
+// ItemBalanceResult reports how many account balances a refresh recorded for one item, or why it didn't.
+type ItemBalanceResult struct {
+	ItemID      string `json:"item_id"`
+	Institution string `json:"institution"`
+	Status      string `json:"status"`
+	ErrorCode   string `json:"error_code,omitempty"`
+	Skipped     bool   `json:"skipped,omitempty"`
+	Accounts    int    `json:"accounts"`
+	Liabilities int    `json:"liabilities"`
+}
+
+// AccountPatch holds the account settings to change; nil fields are left as they are.
+type AccountPatch struct {
+	Hidden            *bool `json:"hidden"`
+	ExcludeFromBudget *bool `json:"exclude_from_budget"`
+}
+
+// CategoryVariance compares planned and actual spending for one category, or for all categories in a total.
+type CategoryVariance struct {
+	Category  string  `json:"category,omitempty"`
+	Planned   float64 `json:"planned"`
+	Actual    float64 `json:"actual"`
+	Remaining float64 `json:"remaining"`
+	// PercentUsed is actual as a percentage of planned, omitted when nothing was planned.
+	PercentUsed *float64 `json:"percent_used,omitempty"`
+	Over        bool     `json:"over"`
+	Under       bool     `json:"under"`
+}
+
+// VarianceReport is a period's budget-vs-actual breakdown by category.
+type VarianceReport struct {
+	Period     Period             `json:"period"`
+	Categories []CategoryVariance `json:"categories"`
+	Totals     CategoryVariance   `json:"totals"`
+}
+
 type APIFunc func(w http.ResponseWriter, r *http.Request) error
 
 type HTTPError struct {
@@ -3,13 +3,16 @@ package utils
 import (
 	"encoding/json"
 	"errors"
+	"fmt"
 	"github.com/Seymour-creates/budget-server/internal/types"
 	"log"
 	"net/http"
+	"time"
 )
 
 func WriteError(w http.ResponseWriter, httpErr *types.HTTPError) {
 	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(httpErr.StatusCode)
 	if err := json.NewEncoder(w).Encode(httpErr); err != nil {
 		log.Printf("error writing error response: %v", err)
 	}
@@ -24,11 +27,12 @@ func WriteJSON(w http.ResponseWriter, data interface{}) error {
 func ErrorHandler(f types.APIFunc) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		if err := f(w, r); err != nil {
+			log.Printf("########ERROR: %v", err)
 			var httpErr *types.HTTPError
 			if errors.As(err, &httpErr) {
 				WriteError(w, httpErr)
+				return
 			}
-			log.Printf("########ERROR: %v", err)
 			WriteError(w, NewHTTPError(http.StatusInternalServerError, err.Error()))
 		}
 	}
@@ -40,3 +44,125 @@ func NewHTTPError(statusCode int, message string) *types.HTTPError {
 		Message:    message,
 	}
 }
+
+// StartOfMonth returns midnight on the first day of t's month.
+func StartOfMonth(t time.Time) time.Time {
+	return time.Date(t.Year(), t.Month(), 1, 0, 0, 0, 0, t.Location())
+}
+
+// AddMonths returns the same day n months after t, or the last day of that month if it is shorter, so monthly
+// dates on the 31st land on the 30th or 28th instead of spilling into the month after.
+func AddMonths(t time.Time, n int) time.Time {
+	first := time.Date(t.Year(), t.Month()+time.Month(n), 1, 0, 0, 0, 0, t.Location())
+	day := t.Day()
+	if last := first.AddDate(0, 1, -1).Day(); day > last {
+		day = last
+	}
+	return time.Date(first.Year(), first.Month(), day, t.Hour(), t.Minute(), t.Second(), t.Nanosecond(), t.Location())
+}
+
+// Occurrence returns the nth date after from on a recurring stream's schedule, counting whole months from from
+// itself so monthly dates on the 31st don't drift to the 28th after February. Semi-monthly streams fall on two
+// fixed days each month; see semiMonthlyDays. ok is false if the frequency is unknown.
+func Occurrence(frequency string, from time.Time, n int) (date time.Time, ok bool) {
+	switch frequency {
+	case types.FrequencyWeekly:
+		return from.AddDate(0, 0, 7*n), true
+	case types.FrequencyBiweekly:
+		return from.AddDate(0, 0, 14*n), true
+	case types.FrequencySemiMonthly:
+		return semiMonthly(from, n), true
+	case types.FrequencyMonthly:
+		return AddMonths(from, n), true
+	case types.FrequencyAnnually:
+		return AddMonths(from, 12*n), true
+	default:
+		return time.Time{}, false
+	}
+}
+
+// semiMonthlyDays returns the two days of the month a semi-monthly stream that was last seen on day falls on:
+// the 15th and the last day of the month for a day on the 15th or at the month's end, otherwise day and the day
+// two weeks after it (so the 1st pairs with the 15th). A second day of 0 means the last day of the month.
+func semiMonthlyDays(day int) (first, second int) {
+	switch {
+	case day == 15 || day >= 28:
+		return 15, 0
+	case day > 15:
+		return day - 14, day
+	default:
+		return day, day + 14
+	}
+}
+
+// semiMonthly returns the nth semi-monthly date after from, counting half months from from's month so the
+// dates stay on the same two days every month instead of drifting by the length of the month.
+func semiMonthly(from time.Time, n int) time.Time {
+	first, second := semiMonthlyDays(from.Day())
+	half := n
+	if from.Day() != first {
+		half++
+	}
+	months := half / 2
+	if half%2 < 0 {
+		months--
+	}
+	month := time.Date(from.Year(), from.Month()+time.Month(months), 1, 0, 0, 0, 0, from.Location())
+	last := month.AddDate(0, 1, -1).Day()
+	day := first
+	if half-2*months == 1 {
+		day = second
+	}
+	if day == 0 || day > last {
+		day = last
+	}
+	return time.Date(month.Year(), month.Month(), day, from.Hour(), from.Minute(), from.Second(), from.Nanosecond(), from.Location())
+}
+
+// ParseMonth parses a YYYY-MM month, returning the first day of that month.
+func ParseMonth(month string) (time.Time, error) {
+	return time.ParseInLocation("2006-01", month, time.Local)
+}
+
+// PeriodContaining returns the week (Monday to Sunday), month, quarter or year containing t.
+func PeriodContaining(t time.Time, interval string) (types.Period, error) {
+	day := time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, t.Location())
+	var start, end time.Time
+	switch interval {
+	case types.IntervalWeek:
+		start = day.AddDate(0, 0, -(int(day.Weekday())+6)%7)
+		end = start.AddDate(0, 0, 6)
+	case types.IntervalMonth:
+		start = StartOfMonth(day)
+		end = start.AddDate(0, 1, -1)
+	case types.IntervalQuarter:
+		start = time.Date(day.Year(), day.Month()-(day.Month()-1)%3, 1, 0, 0, 0, 0, day.Location())
+		end = start.AddDate(0, 3, -1)
+	case types.IntervalYear:
+		start = time.Date(day.Year(), time.January, 1, 0, 0, 0, 0, day.Location())
+		end = start.AddDate(1, 0, -1)
+	default:
+		return types.Period{}, fmt.Errorf("unknown interval %q, expected week, month, quarter or year", interval)
+	}
+	return types.Period{Start: start, End: end}, nil
+}
+
+// SplitPeriod divides period into consecutive intervals, clipping the first and last to the period's bounds.
+func SplitPeriod(period types.Period, interval string) ([]types.Period, error) {
+	var periods []types.Period
+	for day := period.Start; !day.After(period.End); {
+		bucket, err := PeriodContaining(day, interval)
+		if err != nil {
+			return nil, err
+		}
+		if bucket.Start.Before(period.Start) {
+			bucket.Start = period.Start
+		}
+		if bucket.End.After(period.End) {
+			bucket.End = period.End
+		}
+		periods = append(periods, bucket)
+		day = bucket.End.AddDate(0, 0, 1)
+	}
+	return periods, nil
+}
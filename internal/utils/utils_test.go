@@ -0,0 +1,76 @@
+package utils
+
+import (
+	"testing"
+	"time"
+
+	"github.com/Seymour-creates/budget-server/internal/types"
+)
+
+func TestSplitPeriod(t *testing.T) {
+	tests := []struct {
+		start, end string
+		interval   string
+		want       [][2]string
+	}{
+		{"2024-05-01", "2024-05-31", types.IntervalWeek, [][2]string{
+			{"2024-05-01", "2024-05-05"}, {"2024-05-06", "2024-05-12"}, {"2024-05-13", "2024-05-19"},
+			{"2024-05-20", "2024-05-26"}, {"2024-05-27", "2024-05-31"}}},
+		{"2024-01-15", "2024-03-10", types.IntervalMonth, [][2]string{
+			{"2024-01-15", "2024-01-31"}, {"2024-02-01", "2024-02-29"}, {"2024-03-01", "2024-03-10"}}},
+		{"2024-02-01", "2024-12-31", types.IntervalQuarter, [][2]string{
+			{"2024-02-01", "2024-03-31"}, {"2024-04-01", "2024-06-30"}, {"2024-07-01", "2024-09-30"},
+			{"2024-10-01", "2024-12-31"}}},
+		{"2023-12-31", "2024-01-01", types.IntervalYear, [][2]string{{"2023-12-31", "2023-12-31"}, {"2024-01-01", "2024-01-01"}}},
+		{"2024-05-08", "2024-05-08", types.IntervalWeek, [][2]string{{"2024-05-08", "2024-05-08"}}},
+	}
+	for _, test := range tests {
+		start, _ := time.Parse("2006-01-02", test.start)
+		end, _ := time.Parse("2006-01-02", test.end)
+		periods, err := SplitPeriod(types.Period{Start: start, End: end}, test.interval)
+		if err != nil {
+			t.Errorf("splitting %v to %v by %v: %v", test.start, test.end, test.interval, err)
+			continue
+		}
+		var got [][2]string
+		for _, period := range periods {
+			got = append(got, [2]string{period.Start.Format("2006-01-02"), period.End.Format("2006-01-02")})
+		}
+		if len(got) != len(test.want) {
+			t.Errorf("splitting %v to %v by %v = %v, want %v", test.start, test.end, test.interval, got, test.want)
+			continue
+		}
+		for i := range got {
+			if got[i] != test.want[i] {
+				t.Errorf("splitting %v to %v by %v = %v, want %v", test.start, test.end, test.interval, got, test.want)
+				break
+			}
+		}
+	}
+
+	if _, err := SplitPeriod(types.Period{Start: time.Now(), End: time.Now()}, "fortnight"); err == nil {
+		t.Error("splitting by an unknown interval succeeded")
+	}
+}
+
+func TestSemiMonthlyOccurrence(t *testing.T) {
+	tests := []struct {
+		from string
+		want []string
+	}{
+		{"2024-01-01", []string{"2024-01-15", "2024-02-01", "2024-02-15", "2024-03-01"}},
+		{"2024-01-15", []string{"2024-01-31", "2024-02-15", "2024-02-29", "2024-03-15", "2024-03-31"}},
+		{"2024-01-31", []string{"2024-02-15", "2024-02-29", "2024-03-15", "2024-03-31"}},
+		{"2024-02-29", []string{"2024-03-15", "2024-03-31"}},
+		{"2024-01-20", []string{"2024-02-06", "2024-02-20", "2024-03-06"}},
+	}
+	for _, test := range tests {
+		from, _ := time.Parse("2006-01-02", test.from)
+		for i, want := range test.want {
+			got, ok := Occurrence(types.FrequencySemiMonthly, from, i+1)
+			if !ok || got.Format("2006-01-02") != want {
+				t.Errorf("occurrence %d after %v = %v, want %v", i+1, test.from, got.Format("2006-01-02"), want)
+			}
+		}
+	}
+}